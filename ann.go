@@ -0,0 +1,213 @@
+package fasttext
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ANNIndexSuffix is appended to the database file name to name the
+// file of its approximate nearest neighbor index. For a model stored
+// with WithTableName, the table name is inserted before it.
+const ANNIndexSuffix = ".hnsw"
+
+// ErrNoANNIndex is returned by approximate searches when no index has
+// been built for the database.
+var ErrNoANNIndex = errors.New("No ANN index found, call BuildANNIndex first")
+
+type annConfig struct {
+	m              int
+	efConstruction int
+	seed           int64
+}
+
+// ANNOption configures BuildANNIndex.
+type ANNOption func(*annConfig)
+
+// WithANNM sets the number of links per node of the HNSW graph,
+// 16 by default, and at least 2. More links improve recall at the cost
+// of memory and build time.
+func WithANNM(m int) ANNOption {
+	return func(c *annConfig) {
+		c.m = m
+	}
+}
+
+// WithANNEfConstruction sets the size of the candidate list used while
+// building the HNSW graph, 200 by default. Larger values build a better
+// graph, more slowly.
+func WithANNEfConstruction(ef int) ANNOption {
+	return func(c *annConfig) {
+		c.efConstruction = ef
+	}
+}
+
+// WithANNEfSearch sets the size of the candidate list of approximate
+// searches, 64 by default (or k if larger). Larger values improve
+// recall at the cost of latency. BuildANNIndex and the approximate
+// searches return an error if ef is not positive.
+func WithANNEfSearch(ef int) Option {
+	return func(ft *FastText) {
+		if ef <= 0 {
+			// Zero is the default.
+			ef = -1
+		}
+		ft.efSearch = ef
+	}
+}
+
+// errANNEfSearch is returned for an invalid WithANNEfSearch.
+var errANNEfSearch = errors.New("fasttext: the ef of WithANNEfSearch must be positive")
+
+// BuildANNIndex builds an approximate nearest neighbor index (an HNSW
+// graph) over the whole vocabulary and persists it next to the database
+// file, with the ANNIndexSuffix. Sessions on in-memory databases keep
+// the index in memory only. The index holds a copy of all the vectors,
+// so it takes about as much memory as NewFastTextInMem.
+// The index must be rebuilt when the vocabulary changes: the
+// approximate searches return an error until then.
+func (ft *FastText) BuildANNIndex(opts ...ANNOption) error {
+	cfg := &annConfig{m: 16, efConstruction: 200, seed: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.m < 2 {
+		return fmt.Errorf("fasttext: invalid HNSW m %d, must be at least 2", cfg.m)
+	}
+	if cfg.efConstruction <= 0 {
+		return fmt.Errorf("fasttext: invalid HNSW efConstruction %d, must be positive", cfg.efConstruction)
+	}
+	if ft.efSearch < 0 {
+		return errANNEfSearch
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	gen, err := ft.generation()
+	if err != nil {
+		return err
+	}
+	h := newHNSW(f.dim, cfg.m, cfg.efConstruction, cfg.seed)
+	h.generation = gen
+	err = ft.ForEach(func(word string, emb []float32) error {
+		if h.dim == 0 {
+			h.dim = len(emb)
+		}
+		h.add(word, emb)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if ft.dbPath() != "" {
+		file, err := os.Create(ft.sidecar(ANNIndexSuffix))
+		if err != nil {
+			return err
+		}
+		if err := h.writeTo(file); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	ft.annMu.Lock()
+	ft.ann = h
+	ft.annMu.Unlock()
+	return nil
+}
+
+// annIndex returns the index of the session, loading it from disk on
+// first use. It returns an error if the vocabulary changed since the
+// index was built.
+func (ft *FastText) annIndex() (*hnsw, error) {
+	ft.annMu.Lock()
+	defer ft.annMu.Unlock()
+	if ft.ann == nil {
+		h, err := ft.loadANNIndex()
+		if err != nil {
+			return nil, err
+		}
+		ft.ann = h
+	}
+	gen, err := ft.generation()
+	if err != nil {
+		return nil, err
+	}
+	if gen != ft.ann.generation {
+		return nil, fmt.Errorf("fasttext: stale ANN index of generation %d for generation %d, rebuild it",
+			ft.ann.generation, gen)
+	}
+	return ft.ann, nil
+}
+
+// loadANNIndex reads the index of the database file.
+func (ft *FastText) loadANNIndex() (*hnsw, error) {
+	if ft.dbPath() == "" {
+		return nil, ErrNoANNIndex
+	}
+	file, err := os.Open(ft.sidecar(ANNIndexSuffix))
+	if os.IsNotExist(err) {
+		return nil, ErrNoANNIndex
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return readHNSW(file, info.Size())
+}
+
+// NearestNeighborsANN is the approximate version of NearestNeighbors,
+// using the index built by BuildANNIndex. It returns ErrNoANNIndex if
+// there is no index. Filters only apply to the candidates of the graph
+// search, so a selective filter can yield fewer than k words.
+func (ft *FastText) NearestNeighborsANN(word string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	return ft.nearestANN(vec, k, keepWith(ft.excludeWords(word), opts))
+}
+
+// NearestByVectorANN is the approximate version of NearestByVector.
+func (ft *FastText) NearestByVectorANN(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	if err := ft.checkDim(vec); err != nil {
+		return nil, err
+	}
+	return ft.nearestANN(vec, k, keepWith(ft.excludeWords(), opts))
+}
+
+func (ft *FastText) nearestANN(vec []float32, k int, keep func(word string, score float64) bool) ([]ScoredWord, error) {
+	defer ft.observeSearch(MethodANN, time.Now())
+	if ft.efSearch < 0 {
+		return nil, errANNEfSearch
+	}
+	h, err := ft.annIndex()
+	if err != nil {
+		return nil, err
+	}
+	ef := ft.efSearch
+	if ef <= 0 {
+		ef = 64
+	}
+	// Leave room for the candidates rejected by keep.
+	if ef < k+1 {
+		ef = k + 1
+	}
+	top := NewTopK(k)
+	for _, c := range h.search(vec, ef) {
+		word := h.words[c.node]
+		score := float64(1 - c.dist)
+		if keep(word, score) {
+			top.Push(ScoredWord{Word: word, Score: score})
+		}
+	}
+	return top.Sorted(), nil
+}
@@ -0,0 +1,216 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+)
+
+// FAISSMetric is the metric of an index written by ExportFAISS.
+type FAISSMetric int32
+
+// The values are those of faiss::MetricType.
+const (
+	// FAISSInnerProduct ranks by inner product, the cosine similarity
+	// for normalized vectors (see WithNormalizedVectors).
+	FAISSInnerProduct FAISSMetric = 0
+	// FAISSL2 ranks by Euclidean distance.
+	FAISSL2 FAISSMetric = 1
+)
+
+// ExportFAISS writes the vectors as a FAISS IndexFlat file, read by
+// faiss.read_index, and the words of its rows to vocab, one per line.
+// The vectors are loaded in memory, see LoadMatrix.
+func (ft *FastText) ExportFAISS(index, vocab io.Writer, metric FAISSMetric) error {
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		return err
+	}
+	if err := writeVocab(vocab, m.Words); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(index)
+	fourcc := "IxF2"
+	if metric == FAISSInnerProduct {
+		fourcc = "IxFI"
+	}
+	w.WriteString(fourcc)
+	// The header of faiss::write_index_header.
+	header := []interface{}{
+		int32(m.Dim()), int64(m.Len()),
+		int64(1 << 20), int64(1 << 20), // unused
+		uint8(1), // is_trained
+		int32(metric),
+		// The vectors, preceded by their number of values.
+		uint64(len(m.Data)), m.Data,
+	}
+	for _, v := range header {
+		if err := binary.Write(w, binary.LittleEndian, v); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+// ExportAnnoy writes the vectors as an Annoy index file of the given
+// number of trees with the angular metric, read by
+// AnnoyIndex(dim, "angular").load, and the words of its items to vocab,
+// one per line. More trees give more accurate searches and a larger
+// index. The vectors are loaded in memory, see LoadMatrix.
+func (ft *FastText) ExportAnnoy(index, vocab io.Writer, trees int) error {
+	if trees <= 0 {
+		return errors.New("fasttext: an Annoy index needs at least one tree")
+	}
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		return err
+	}
+	if err := writeVocab(vocab, m.Words); err != nil {
+		return err
+	}
+	b := &annoyBuilder{m: m, rng: rand.New(rand.NewSource(1)), k: m.Dim() + 2}
+	// The items come first, their node IDs being their indices.
+	for i := 0; i < m.Len(); i++ {
+		b.nodes = append(b.nodes, annoyNode{descendants: 1, v: m.Row(i)})
+	}
+	items := make([]int32, m.Len())
+	for i := range items {
+		items[i] = int32(i)
+	}
+	var roots []int32
+	for t := 0; t < trees && m.Len() > 0; t++ {
+		roots = append(roots, b.makeTree(items, true))
+	}
+	// The roots are copied at the end, where Annoy finds them.
+	for _, root := range roots {
+		b.nodes = append(b.nodes, b.nodes[root])
+	}
+	return b.write(index)
+}
+
+func writeVocab(w io.Writer, words []string) error {
+	bw := bufio.NewWriter(w)
+	for _, word := range words {
+		bw.WriteString(word)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// annoyNode is a node of an Annoy index with the angular metric: an
+// item, a split or a leaf listing items.
+type annoyNode struct {
+	descendants int32
+	children    [2]int32
+	// v is the vector of an item or the normal of a split.
+	v []float32
+	// items are the items of a leaf.
+	items []int32
+}
+
+type annoyBuilder struct {
+	m     *Matrix
+	rng   *rand.Rand
+	nodes []annoyNode
+	// k is the maximum number of items of a leaf, which are stored in
+	// place of the children and the vector.
+	k int
+}
+
+// makeTree builds the tree of the items, following AnnoyIndex::_make_tree,
+// and returns the ID of its root.
+func (b *annoyBuilder) makeTree(items []int32, root bool) int32 {
+	if len(items) == 1 && !root {
+		return items[0]
+	}
+	if len(items) <= b.k && (!root || b.m.Len() <= b.k || len(items) == 1) {
+		b.nodes = append(b.nodes, annoyNode{descendants: int32(len(items)), items: items})
+		return int32(len(b.nodes) - 1)
+	}
+	normal := b.split(items)
+	var sides [2][]int32
+	for _, item := range items {
+		side := 0
+		if dot(normal, b.m.Row(int(item))) > 0 {
+			side = 1
+		}
+		sides[side] = append(sides[side], item)
+	}
+	for len(sides[0]) == 0 || len(sides[1]) == 0 {
+		// No separating hyperplane: split at random, as Annoy does.
+		sides[0], sides[1] = nil, nil
+		for i := range normal {
+			normal[i] = 0
+		}
+		for _, item := range items {
+			side := b.rng.Intn(2)
+			sides[side] = append(sides[side], item)
+		}
+	}
+	node := annoyNode{descendants: int32(len(items)), v: normal}
+	for side := range sides {
+		node.children[side] = b.makeTree(sides[side], false)
+	}
+	b.nodes = append(b.nodes, node)
+	return int32(len(b.nodes) - 1)
+}
+
+// annoyIterations is the number of iterations of the two-means
+// clustering of a split.
+const annoyIterations = 200
+
+// split returns the normal of the hyperplane between two centroids of
+// the items found by two-means clustering of their unit vectors.
+func (b *annoyBuilder) split(items []int32) []float32 {
+	row := func() []float32 {
+		return unitVec(b.m.Row(int(items[b.rng.Intn(len(items))])))
+	}
+	p, q := row(), row()
+	np, nq := 1, 1
+	for i := 0; i < annoyIterations; i++ {
+		x := row()
+		dp, dq := float64(np)*(2-2*dot(p, x)), float64(nq)*(2-2*dot(q, x))
+		if dp < dq {
+			scale(float32(np)/float32(np+1), p)
+			axpy(1/float32(np+1), x, p)
+			np++
+		} else if dq < dp {
+			scale(float32(nq)/float32(nq+1), q)
+			axpy(1/float32(nq+1), x, q)
+			nq++
+		}
+	}
+	axpy(-1, q, p)
+	return unitVec(p)
+}
+
+// write writes the nodes in the memory layout of Annoy: the number of
+// descendants, the two children and the vector, as 32-bit values.
+func (b *annoyBuilder) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, 4*(3+b.m.Dim()))
+	for _, node := range b.nodes {
+		for i := range buf {
+			buf[i] = 0
+		}
+		binary.LittleEndian.PutUint32(buf, uint32(node.descendants))
+		if node.items != nil {
+			for i, item := range node.items {
+				binary.LittleEndian.PutUint32(buf[4+4*i:], uint32(item))
+			}
+		} else {
+			binary.LittleEndian.PutUint32(buf[4:], uint32(node.children[0]))
+			binary.LittleEndian.PutUint32(buf[8:], uint32(node.children[1]))
+			for i, v := range node.v {
+				binary.LittleEndian.PutUint32(buf[12+4*i:], math.Float32bits(v))
+			}
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,70 @@
+package fasttext
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// backupStepPages is the number of pages copied by a step of Backup,
+// between which the writers of the database get the lock.
+const backupStepPages = 1024
+
+// Backup copies the database of the session to the file dstPath with
+// the online backup of SQLite3, e.g. to snapshot or replicate a
+// database in use: the readers are not stopped, and a write during the
+// copy restarts it so the copy is consistent. progress, if not nil, is
+// called after each step with the number of pages copied and the total.
+// The copy is opened with NewFastText, or switched to with Reload.
+// Backup needs the built-in SQLite3 driver, see WithDriver.
+func (ft *FastText) Backup(ctx context.Context, dstPath string, progress func(done, total int)) error {
+	conn, err := ft.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return conn.Raw(func(driverConn interface{}) error {
+		if c, ok := driverConn.(*sourceConn); ok {
+			driverConn = c.Conn
+		}
+		src, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return errors.New("fasttext: Backup needs the built-in SQLite3 driver")
+		}
+		d, err := (&sqlite3.SQLiteDriver{}).Open(dstPath)
+		if err != nil {
+			return err
+		}
+		dst := d.(*sqlite3.SQLiteConn)
+		defer dst.Close()
+		bk, err := dst.Backup("main", src, "main")
+		if err != nil {
+			return err
+		}
+		for {
+			remaining := bk.Remaining()
+			done, err := bk.Step(backupStepPages)
+			if err != nil {
+				bk.Close()
+				return err
+			}
+			if progress != nil {
+				total := bk.PageCount()
+				progress(total-bk.Remaining(), total)
+			}
+			if done {
+				return bk.Close()
+			}
+			if err := ctx.Err(); err != nil {
+				bk.Close()
+				return err
+			}
+			if bk.Remaining() == remaining {
+				// The database is locked by a writer.
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	})
+}
@@ -0,0 +1,162 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+// maxBatchVars is the number of words looked up per query, below
+// SQLite's default limit on the number of host parameters.
+const maxBatchVars = 500
+
+// GetEmbs returns the word embeddings of the given words, looked up in
+// batches. The embedding of a word missing from the vocabulary is nil,
+// unless resolved by the OOV policy.
+func (ft *FastText) GetEmbs(words []string) ([][]float32, error) {
+	return ft.GetEmbsContext(context.Background(), words)
+}
+
+// GetEmbsContext is GetEmbs with a context, which cancels the look-ups
+// and is the parent of their span, see WithTracer.
+func (ft *FastText) GetEmbsContext(ctx context.Context, words []string) (embs [][]float32, err error) {
+	ctx, end := ft.startSpan(ctx, "fasttext.GetEmbs", "words", len(words))
+	defer func() { end(err) }()
+	start := time.Now()
+	embs, hits, err := ft.batchLookup(ctx, words)
+	if ft.observing() && err == nil {
+		st := LookupStats{Words: len(words), MemoryHits: hits}
+		for _, emb := range embs {
+			if emb == nil {
+				st.Misses++
+			}
+		}
+		defer func() {
+			st.Duration = time.Since(start)
+			ft.observe(st, "words", len(words))
+		}()
+	}
+	if err != nil || ft.oovPolicy == nil {
+		return embs, err
+	}
+	resolved := make(map[string][]float32)
+	for i, emb := range embs {
+		if emb != nil {
+			continue
+		}
+		vec, ok := resolved[words[i]]
+		if !ok {
+			vec, err = ft.oovPolicy(ft, words[i])
+			if err != nil && !errors.Is(err, ErrNoEmbFound) {
+				return nil, err
+			}
+			resolved[words[i]] = vec
+		}
+		embs[i] = vec
+	}
+	return embs, nil
+}
+
+// lookupEmbs looks up the embeddings of the words in the vocabulary in
+// batches, without the OOV policy.
+func (ft *FastText) lookupEmbs(words []string) ([][]float32, error) {
+	embs, _, err := ft.batchLookup(context.Background(), words)
+	return embs, err
+}
+
+// batchLookup is lookupEmbs, also returning the number of words found
+// in memory.
+func (ft *FastText) batchLookup(ctx context.Context, words []string) ([][]float32, int, error) {
+	embs := make([][]float32, len(words))
+	hits := 0
+	// Positions of each word still to be looked up in the database.
+	missing := make(map[string][]int)
+	var queue []string
+	for i, word := range ft.normalizeAll(words) {
+		vec, ok, err := ft.preloaded(word)
+		if err != nil {
+			return nil, 0, err
+		}
+		if ok {
+			embs[i] = vec
+			hits++
+			continue
+		}
+		if ft.cache != nil {
+			if vec, ok := ft.cache.get(word); ok {
+				embs[i] = vec
+				hits++
+				continue
+			}
+		}
+		if _, ok := missing[word]; !ok {
+			queue = append(queue, word)
+		}
+		missing[word] = append(missing[word], i)
+	}
+	for start := 0; start < len(queue); start += maxBatchVars {
+		end := start + maxBatchVars
+		if end > len(queue) {
+			end = len(queue)
+		}
+		err := ft.timedLookupBatch(ctx, queue[start:end], func(word string, vec []float32) {
+			for _, i := range missing[word] {
+				embs[i] = vec
+			}
+			if ft.cache != nil {
+				ft.cache.add(word, vec)
+			}
+		})
+		if err != nil {
+			if !ft.absorb(err) {
+				return nil, 0, err
+			}
+			for _, word := range queue[start:end] {
+				for _, i := range missing[word] {
+					embs[i] = ft.hashedFallback(word)
+				}
+			}
+		}
+	}
+	return embs, hits, nil
+}
+
+// lookupBatch calls fn on the embedding of each word found in the
+// database.
+func (ft *FastText) lookupBatch(ctx context.Context, words []string, fn func(word string, vec []float32)) error {
+	args := make([]interface{}, len(words))
+	for i, w := range words {
+		args[i] = w
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(words)), ",")
+	rows, err := ft.db.QueryContext(ctx, ft.sql(`SELECT word, emb FROM fasttext WHERE word IN (`)+placeholders+`);`, args...)
+	if err != nil {
+		return err
+	}
+	return ft.scanEmbs(rows, fn)
+}
+
+// scanEmbs decodes the (word, emb) rows, calling fn on each of them,
+// and closes the rows.
+func (ft *FastText) scanEmbs(rows *sql.Rows, fn func(word string, vec []float32)) error {
+	defer rows.Close()
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var word string
+		var binVec []byte
+		if err := rows.Scan(&word, &binVec); err != nil {
+			return err
+		}
+		vec, err := f.decode(binVec)
+		if err != nil {
+			return err
+		}
+		fn(word, vec)
+	}
+	return rows.Err()
+}
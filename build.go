@@ -0,0 +1,492 @@
+package fasttext
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// buildBatchSize is the number of words inserted per transaction by a
+// build, so an interrupted build keeps most of its work.
+var buildBatchSize int64 = 100000
+
+// Build states recorded in the metadata.
+const (
+	metaBuildState = "build_state"
+	buildRunning   = "running"
+	buildComplete  = "complete"
+	// metaBuildWords is the number of words of the input committed by
+	// a running build.
+	metaBuildWords = "build_words"
+)
+
+type buildConfig struct {
+	casing      *casingCounter
+	codec       Codec
+	conflict    ConflictPolicy
+	compression Compression
+	freqs       io.Reader
+	normalized  bool
+	pcaDim      int
+	filter      vocabFilter
+	parse       parseConfig
+	payloads    func(word string) ([]byte, error)
+	progress    *progress
+	vacuum      bool
+	// done is closed when the build stops, to stop the sender of the
+	// word embeddings.
+	done chan struct{}
+}
+
+func (ft *FastText) newBuildConfig(opts []BuildOption) *buildConfig {
+	cfg := &buildConfig{
+		codec: Codec{Precision: DefaultCodec.Precision, Order: ByteOrder},
+		done:  make(chan struct{}),
+		parse: parseConfig{workers: runtime.NumCPU(), logger: ft.logger},
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// BuildOption configures BuildDB.
+type BuildOption func(*buildConfig)
+
+// WithParseWorkers sets the number of goroutines parsing the text
+// embedding files in parallel, the number of CPUs by default.
+func WithParseWorkers(n int) BuildOption {
+	return func(cfg *buildConfig) {
+		if n > 0 {
+			cfg.parse.workers = n
+		}
+	}
+}
+
+// stop stops the sender of the word embeddings.
+func (cfg *buildConfig) stop() {
+	select {
+	case <-cfg.done:
+	default:
+		close(cfg.done)
+	}
+}
+
+// send sends the word embedding to out, unless the build stopped, and
+// returns whether it did.
+func send(out chan<- *wordEmb, emb *wordEmb, done <-chan struct{}) bool {
+	select {
+	case out <- emb:
+		return true
+	case <-done:
+		return false
+	}
+}
+
+// stopped returns whether the build stopped.
+func stopped(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// build creates the table and inserts the word embeddings received
+// from embs, which must be closed by the sender. Words are committed
+// in batches and the word index is only created at the end. If a
+// previous build of the database was interrupted, the words it already
+// inserted are skipped, which requires the same input.
+func (ft *FastText) build(embs <-chan *wordEmb, cfg *buildConfig) error {
+	defer cfg.stop()
+	skip, err := ft.startBuild(cfg)
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if skip > 0 {
+		ft.logInfo("fasttext: resuming build", "table", ft.table, "words", skip)
+	} else {
+		ft.logInfo("fasttext: starting build", "table", ft.table)
+	}
+	format := vecFormat{codec: cfg.codec, normalized: cfg.normalized}
+	if skip > 0 {
+		if format, err = ft.vecFormat(); err != nil {
+			return err
+		}
+	} else {
+		if cfg.pcaDim > 0 {
+			var head []*wordEmb
+			head, embs = peekEmbs(embs, pcaSampleRows, cfg.done)
+			vecs := make([][]float32, 0, len(head))
+			for _, emb := range head {
+				if emb.Err == nil && emb.flushed == nil {
+					vecs = append(vecs, emb.Vec)
+				}
+			}
+			if format.pca, err = fitPCA(vecs, cfg.pcaDim); err != nil {
+				return err
+			}
+		}
+		if cfg.compression == Zstd {
+			var head []*wordEmb
+			head, embs = peekEmbs(embs, zstdSampleRows, cfg.done)
+			samples := make([][]byte, 0, len(head))
+			for _, emb := range head {
+				if emb.Err == nil && emb.flushed == nil {
+					vec := emb.Vec
+					if format.pca != nil {
+						vec = format.pca.project(vec)
+					}
+					samples = append(samples, cfg.codec.Encode(vec))
+				}
+			}
+			if format.zstd, err = trainZstd(samples); err != nil {
+				return err
+			}
+		}
+	}
+	var freqs map[string]int64
+	if cfg.freqs != nil {
+		if freqs, err = ft.readFrequencies(cfg.freqs); err != nil {
+			return err
+		}
+	}
+	if cfg.payloads != nil {
+		if err := ft.createPayloadTable(ft.db); err != nil {
+			return err
+		}
+	}
+	// n counts the words inserted and pos their position in the input.
+	var n, pos int64
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { tx.Rollback() }()
+	const insert = `INSERT INTO fasttext(id, word, emb, rank, freq) VALUES(?, ?, ?, ?, ?);`
+	stmt, err := tx.Prepare(ft.sql(insert))
+	if err != nil {
+		return err
+	}
+	// commit commits the batch along with the format, which a resumed
+	// build reuses.
+	commit := func() error {
+		if err := ft.recordBatch(tx, format, n); err != nil {
+			return err
+		}
+		stmt.Close()
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		if tx, err = ft.db.Begin(); err != nil {
+			return err
+		}
+		stmt, err = tx.Prepare(ft.sql(insert))
+		return err
+	}
+	words := newBuildWords(cfg.conflict)
+	for emb := range embs {
+		if emb.Err != nil {
+			return emb.Err
+		}
+		if emb.flushed != nil {
+			err := commit()
+			emb.flushed <- err
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		if cfg.filter.full(n) {
+			break
+		}
+		word := ft.normalize(emb.Word)
+		if _, ok := words.ids[word]; ok {
+			emb.Word = word
+			if err := words.resolve(ft, tx, format, emb); err != nil {
+				return err
+			}
+			if err := ft.resolvePayload(tx, cfg, words, word); err != nil {
+				return err
+			}
+			continue
+		}
+		words.ids[word] = 0
+		pos++
+		if !cfg.filter.keep(ft, emb.Word, word) {
+			continue
+		}
+		if cfg.casing != nil {
+			cfg.casing.add(emb.Word)
+		}
+		emb.Word = word
+		n++
+		words.ids[word] = n
+		if n <= skip {
+			continue
+		}
+		vec, err := format.input(emb)
+		if err != nil {
+			return err
+		}
+		var freq interface{}
+		if f, ok := freqs[emb.Word]; ok {
+			freq = f
+		}
+		if _, err := stmt.Exec(n, emb.Word, format.encode(vec), pos, freq); err != nil {
+			return err
+		}
+		cfg.progress.inserted()
+		if cfg.payloads != nil {
+			if err := ft.buildPayload(tx, cfg.payloads, emb.Word, false); err != nil {
+				return err
+			}
+		}
+		if n%buildBatchSize == 0 {
+			if err := commit(); err != nil {
+				return err
+			}
+			ft.logInfo("fasttext: build progress", "table", ft.table, "words", n)
+		}
+	}
+	stmt.Close()
+	if n < skip {
+		return fmt.Errorf("fasttext: cannot resume build of %d words from input of %d words", skip, n)
+	}
+	if dupErr := words.err(); dupErr != nil {
+		// Keep the words read, for a build resumed with another policy.
+		if err := ft.recordBatch(tx, format, n); err != nil {
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		return dupErr
+	}
+	if err := ft.setVecFormat(tx, format); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ft.sql(`CREATE UNIQUE INDEX IF NOT EXISTS fasttext_word ON fasttext(word);`)); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ft.sql(`CREATE INDEX IF NOT EXISTS fasttext_rank ON fasttext(rank);`)); err != nil {
+		return err
+	}
+	// Gather the statistics of the query planner on the new indexes.
+	if _, err := tx.Exec(ft.sql(`ANALYZE fasttext;`)); err != nil {
+		return err
+	}
+	if cfg.casing != nil {
+		if err := cfg.casing.finish(ft, tx); err != nil {
+			return err
+		}
+	}
+	if err := ft.setMeta(tx, metaBadLines, strconv.FormatInt(atomic.LoadInt64(&cfg.parse.badLines), 10)); err != nil {
+		return err
+	}
+	if err := ft.setMeta(tx, metaBuildState, buildComplete); err != nil {
+		return err
+	}
+	if err := ft.setMeta(tx, metaBuiltAt, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		return err
+	}
+	if err := ft.bumpGeneration(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if cfg.vacuum {
+		if err := ft.Optimize(); err != nil {
+			return err
+		}
+	}
+	cfg.progress.done()
+	ft.logInfo("fasttext: build complete", "table", ft.table, "words", n,
+		"bad_lines", atomic.LoadInt64(&cfg.parse.badLines), "duration", time.Since(start))
+	return nil
+}
+
+// recordBatch records the format of the vectors and the number of words
+// of the input along with a batch of the build.
+func (ft *FastText) recordBatch(tx execer, format vecFormat, words int64) error {
+	if err := ft.setVecFormat(tx, format); err != nil {
+		return err
+	}
+	return ft.setMeta(tx, metaBuildWords, strconv.FormatInt(words, 10))
+}
+
+// startBuild creates the table of a new build, or returns the number
+// of words inserted by an interrupted one.
+func (ft *FastText) startBuild(cfg *buildConfig) (int64, error) {
+	state, ok, err := ft.getMeta(metaBuildState)
+	if err != nil {
+		return 0, err
+	}
+	if ok && state == buildRunning {
+		f, err := ft.vecFormat()
+		if err != nil {
+			return 0, err
+		}
+		if f.dim != 0 && f.codec != cfg.codec {
+			return 0, fmt.Errorf("fasttext: cannot resume build with codec %v, started with %v",
+				cfg.codec, f.codec)
+		}
+		if f.dim != 0 && f.compression() != cfg.compression {
+			return 0, fmt.Errorf("fasttext: cannot resume build with compression %v, started with %v",
+				cfg.compression, f.compression())
+		}
+		if f.dim != 0 && f.normalized != cfg.normalized {
+			return 0, errors.New("fasttext: cannot resume build with a different vector normalization")
+		}
+		if f.dim != 0 && f.pcaDim() != cfg.pcaDim {
+			return 0, errors.New("fasttext: cannot resume build with a different PCA")
+		}
+		if words, ok, err := ft.getMeta(metaBuildWords); err != nil || ok {
+			if err != nil {
+				return 0, err
+			}
+			return strconv.ParseInt(words, 10, 64)
+		}
+		// Builds without metadata of the words committed did not leave
+		// words out.
+		var count int64
+		err = ft.db.QueryRow(ft.sql(`SELECT COUNT(*) FROM fasttext;`)).Scan(&count)
+		return count, err
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	_, err = tx.Exec(ft.sql(`
+	CREATE TABLE fasttext(
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		word TEXT,
+		emb BLOB,
+		rank INTEGER,
+		freq INTEGER
+	);`))
+	if err != nil {
+		return 0, err
+	}
+	if err := ft.createMetaTable(tx); err != nil {
+		return 0, err
+	}
+	if err := ft.setMeta(tx, metaBuildState, buildRunning); err != nil {
+		return 0, err
+	}
+	if err := ft.setMeta(tx, metaSchemaVersion, strconv.Itoa(len(migrations))); err != nil {
+		return 0, err
+	}
+	// The lists of a previous model of the table are stale.
+	if err := ft.clearNNCache(tx); err != nil {
+		return 0, err
+	}
+	return 0, tx.Commit()
+}
+
+// ErrBuildIncomplete is returned when appending to a database whose
+// build was interrupted.
+var ErrBuildIncomplete = errors.New("Database build is incomplete, resume it with BuildDB")
+
+// AppendDB adds the word embeddings of another file, in any format
+// supported by BuildDB, to the database. The embeddings are stored with
+// the codec of the database and must have its dimension. Words already
+// in the database keep their embeddings.
+func (ft *FastText) AppendDB(wordEmbFile io.Reader, opts ...BuildOption) error {
+	cfg := ft.newBuildConfig(opts)
+	wordEmbFile, err := decompress(cfg.progress.reader(wordEmbFile))
+	if err != nil {
+		return err
+	}
+	return ft.append(readwordEmbdFile(wordEmbFile, &cfg.parse, cfg.done), cfg)
+}
+
+// append inserts the word embeddings received from embs in the
+// existing table, skipping words already in it.
+func (ft *FastText) append(embs <-chan *wordEmb, cfg *buildConfig) error {
+	state, ok, err := ft.getMeta(metaBuildState)
+	if err != nil {
+		return err
+	}
+	if ok && state != buildComplete {
+		return ErrBuildIncomplete
+	}
+	defer cfg.stop()
+	format, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(ft.sql(`INSERT OR IGNORE INTO fasttext(word, emb) VALUES(?, ?);`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	var n int64
+	for emb := range embs {
+		if emb.Err != nil {
+			return emb.Err
+		}
+		if cfg.filter.full(n) {
+			break
+		}
+		word := ft.normalize(emb.Word)
+		if !cfg.filter.keep(ft, emb.Word, word) {
+			continue
+		}
+		n++
+		vec, err := format.input(emb)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(word, format.encode(vec)); err != nil {
+			return err
+		}
+		cfg.progress.inserted()
+	}
+	if err := ft.createMetaTable(tx); err != nil {
+		return err
+	}
+	if err := ft.setVecFormat(tx, format); err != nil {
+		return err
+	}
+	if err := ft.bumpGeneration(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cfg.progress.done()
+	ft.logInfo("fasttext: append complete", "table", ft.table, "words", n)
+	return nil
+}
+
+// WithNormalizedVectors scales the vectors to unit length before
+// storing them, so that the cosine similarity of two vectors is their
+// dot product, which speeds up the neighbor searches. The normalization
+// is recorded in the metadata table, and the vectors inserted later by
+// AppendDB or PutEmb are normalized too.
+func WithNormalizedVectors() BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.normalized = true
+	}
+}
+
+// Normalized returns whether the stored vectors have unit length, see
+// WithNormalizedVectors.
+func (ft *FastText) Normalized() (bool, error) {
+	f, err := ft.vecFormat()
+	return f.normalized, err
+}
@@ -0,0 +1,82 @@
+package fasttext
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruCache is a fixed-size least-recently-used cache of word embeddings
+// sitting in front of the SQLite3 look-ups.
+type lruCache struct {
+	mu    sync.Mutex
+	size  int
+	ll    *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	word string
+	vec  []float32
+}
+
+func newLRUCache(size int) *lruCache {
+	return &lruCache{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[string]*list.Element, size),
+	}
+}
+
+// get returns a copy of the cached embedding of the word, if any.
+func (c *lruCache) get(word string) ([]float32, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[word]
+	if !ok {
+		return nil, false
+	}
+	c.ll.MoveToFront(e)
+	return copyVec(e.Value.(*cacheEntry).vec), true
+}
+
+// add inserts the embedding of the word, evicting the least recently
+// used entry if the cache is full.
+func (c *lruCache) add(word string, vec []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[word]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*cacheEntry).vec = copyVec(vec)
+		return
+	}
+	c.items[word] = c.ll.PushFront(&cacheEntry{word: word, vec: copyVec(vec)})
+	if c.ll.Len() > c.size {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).word)
+	}
+}
+
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ll.Len()
+}
+
+// purge removes all the cached embeddings.
+func (c *lruCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ll.Init()
+	c.items = make(map[string]*list.Element, c.size)
+}
+
+// remove drops the cached embedding of the word, if any.
+func (c *lruCache) remove(word string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[word]; ok {
+		c.ll.Remove(e)
+		delete(c.items, word)
+	}
+}
@@ -0,0 +1,149 @@
+package fasttext
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// CasingStats describes how many words of the vocabulary differ only
+// by case or diacritics, to decide whether case-insensitive or
+// normalized look-ups are worth enabling for a model.
+type CasingStats struct {
+	// Words is the number of words in the vocabulary.
+	Words int
+	// CaseGroups is the number of distinct lowercased forms shared by
+	// at least two words, e.g. "Paris" and "paris".
+	CaseGroups int
+	// CaseVariants is the number of words in the case groups.
+	CaseVariants int
+	// DiacriticGroups is the number of distinct lowercased forms with
+	// diacritics stripped shared by at least two words that are not
+	// already case variants, e.g. "café" and "cafe".
+	DiacriticGroups int
+	// DiacriticVariants is the number of words in the diacritic groups.
+	DiacriticVariants int
+}
+
+var casingStatsKeys = []string{
+	"casing_words", "casing_case_groups", "casing_case_variants",
+	"casing_diacritic_groups", "casing_diacritic_variants",
+}
+
+func (s *CasingStats) fields() []*int {
+	return []*int{&s.Words, &s.CaseGroups, &s.CaseVariants,
+		&s.DiacriticGroups, &s.DiacriticVariants}
+}
+
+// WithCasingStats computes the casing statistics of the vocabulary
+// while building the database and persists them in the metadata table,
+// where CasingStats finds them. If report is not nil, a human-readable
+// report is also written to it at the end of the build.
+func WithCasingStats(report io.Writer) BuildOption {
+	return func(c *buildConfig) {
+		c.casing = &casingCounter{
+			report: report,
+			lower:  make(map[string]int),
+			folded: make(map[string]int),
+		}
+	}
+}
+
+// CasingStats returns the casing statistics computed at build time,
+// or nil if the database was built without WithCasingStats.
+func (ft *FastText) CasingStats() (*CasingStats, error) {
+	stats := &CasingStats{}
+	for i, field := range stats.fields() {
+		value, ok, err := ft.getMeta(casingStatsKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		if *field, err = strconv.Atoi(value); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
+
+// Report writes a human-readable summary of the statistics.
+func (s *CasingStats) Report(w io.Writer) error {
+	pct := func(n int) float64 {
+		if s.Words == 0 {
+			return 0
+		}
+		return 100 * float64(n) / float64(s.Words)
+	}
+	_, err := fmt.Fprintf(w, `Vocabulary: %d words
+Case variants: %d words (%.2f%%) in %d groups
+Diacritic variants: %d words (%.2f%%) in %d groups
+`, s.Words, s.CaseVariants, pct(s.CaseVariants), s.CaseGroups,
+		s.DiacriticVariants, pct(s.DiacriticVariants), s.DiacriticGroups)
+	return err
+}
+
+type casingCounter struct {
+	report io.Writer
+	words  int
+	// Number of words per lowercased form.
+	lower map[string]int
+	// Number of distinct lowercased forms per diacritic-stripped form.
+	folded map[string]int
+}
+
+func (c *casingCounter) add(word string) {
+	c.words++
+	lower := strings.ToLower(word)
+	c.lower[lower]++
+	if c.lower[lower] == 1 {
+		c.folded[stripDiacritics(lower)]++
+	}
+}
+
+func (c *casingCounter) stats() *CasingStats {
+	s := &CasingStats{Words: c.words}
+	for _, n := range c.lower {
+		if n > 1 {
+			s.CaseGroups++
+			s.CaseVariants += n
+		}
+	}
+	for _, n := range c.folded {
+		if n > 1 {
+			s.DiacriticGroups++
+			s.DiacriticVariants += n
+		}
+	}
+	return s
+}
+
+// finish persists the statistics and writes the report.
+func (c *casingCounter) finish(ft *FastText, db execer) error {
+	s := c.stats()
+	for i, field := range s.fields() {
+		if err := ft.setMeta(db, casingStatsKeys[i], strconv.Itoa(*field)); err != nil {
+			return err
+		}
+	}
+	if c.report != nil {
+		return s.Report(c.report)
+	}
+	return nil
+}
+
+// stripDiacritics removes the combining marks of the word.
+func stripDiacritics(word string) string {
+	decomposed := norm.NFD.String(word)
+	return strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, decomposed)
+}
@@ -0,0 +1,185 @@
+package fasttext
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// Cluster is a cluster of the vocabulary found by ClusterVocab.
+type Cluster struct {
+	// ID is the index of the cluster in the result of ClusterVocab.
+	ID int
+	// Centroid is the estimated mean of the vectors of the cluster.
+	Centroid []float32
+	// Size is the number of words of the cluster.
+	Size int
+}
+
+type clusterConfig struct {
+	batchSize  int
+	iterations int
+	seed       int64
+	persist    bool
+}
+
+// ClusterOption configures ClusterVocab.
+type ClusterOption func(*clusterConfig)
+
+// WithClusterBatchSize sets the number of vectors sampled at each
+// iteration of ClusterVocab, 1024 by default.
+func WithClusterBatchSize(n int) ClusterOption {
+	return func(cfg *clusterConfig) {
+		if n > 0 {
+			cfg.batchSize = n
+		}
+	}
+}
+
+// WithClusterIterations sets the number of iterations of ClusterVocab,
+// 100 by default.
+func WithClusterIterations(n int) ClusterOption {
+	return func(cfg *clusterConfig) {
+		if n > 0 {
+			cfg.iterations = n
+		}
+	}
+}
+
+// WithClusterSeed sets the seed of the random sampling of ClusterVocab,
+// for reproducible clusters.
+func WithClusterSeed(seed int64) ClusterOption {
+	return func(cfg *clusterConfig) {
+		cfg.seed = seed
+	}
+}
+
+// WithPersistClusters stores the cluster of each word in the clusters
+// table of the database, replacing previous clusters, for GetCluster.
+func WithPersistClusters() ClusterOption {
+	return func(cfg *clusterConfig) {
+		cfg.persist = true
+	}
+}
+
+// ClusterVocab groups the words of the vocabulary in k clusters of
+// nearby vectors by mini-batch k-means (Sculley, "Web-Scale K-Means
+// Clustering", 2010), e.g. as coarse semantic buckets. The vectors are
+// loaded in memory, see LoadMatrix. Normalize the vectors at build time
+// with WithNormalizedVectors to cluster by cosine similarity.
+func (ft *FastText) ClusterVocab(k int, opts ...ClusterOption) ([]Cluster, error) {
+	cfg := &clusterConfig{batchSize: 1024, iterations: 100, seed: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		return nil, err
+	}
+	if k <= 0 || k > m.Len() {
+		return nil, fmt.Errorf("fasttext: cannot make %d clusters of %d words", k, m.Len())
+	}
+	rng := rand.New(rand.NewSource(cfg.seed))
+	// Start from k distinct random words.
+	centroids := make([][]float32, k)
+	for i, row := range rng.Perm(m.Len())[:k] {
+		centroids[i] = copyVec(m.Row(row))
+	}
+	counts := make([]int, k)
+	batch := make([]int, cfg.batchSize)
+	nearest := make([]int, cfg.batchSize)
+	for iter := 0; iter < cfg.iterations; iter++ {
+		for i := range batch {
+			batch[i] = rng.Intn(m.Len())
+			nearest[i] = nearestCentroid(centroids, m.Row(batch[i]))
+		}
+		for i, row := range batch {
+			c := nearest[i]
+			counts[c]++
+			// Move the centroid towards the vector with a learning rate
+			// decreasing with the number of vectors it has seen.
+			eta := 1 / float32(counts[c])
+			scale(1-eta, centroids[c])
+			axpy(eta, m.Row(row), centroids[c])
+		}
+	}
+	clusters := make([]Cluster, k)
+	for i := range clusters {
+		clusters[i] = Cluster{ID: i, Centroid: centroids[i]}
+	}
+	assignments := make([]int, m.Len())
+	for row := range assignments {
+		assignments[row] = nearestCentroid(centroids, m.Row(row))
+		clusters[assignments[row]].Size++
+	}
+	if cfg.persist {
+		if err := ft.persistClusters(m.Words, assignments); err != nil {
+			return nil, err
+		}
+	}
+	return clusters, nil
+}
+
+// nearestCentroid returns the index of the centroid closest to vec.
+func nearestCentroid(centroids [][]float32, vec []float32) int {
+	best, bestDist := 0, math.Inf(1)
+	for i, c := range centroids {
+		// |c - v|² = |c|² - 2 c·v, up to |v|².
+		d := dot(c, c) - 2*dot(c, vec)
+		if d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// persistClusters replaces the clusters table.
+func (ft *FastText) persistClusters(words []string, assignments []int) error {
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(ft.sql(`DROP TABLE IF EXISTS fasttext_clusters;`)); err != nil {
+		return err
+	}
+	_, err = tx.Exec(ft.sql(`
+	CREATE TABLE fasttext_clusters(
+		word TEXT PRIMARY KEY,
+		cluster INTEGER
+	);`))
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(ft.sql(`INSERT INTO fasttext_clusters(word, cluster) VALUES(?, ?);`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i, word := range words {
+		if _, err := stmt.Exec(word, assignments[i]); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetCluster returns the ID of the cluster of the word stored by
+// ClusterVocab with WithPersistClusters.
+func (ft *FastText) GetCluster(word string) (int, error) {
+	var cluster int
+	err := ft.db.QueryRow(ft.sql(`SELECT cluster FROM fasttext_clusters WHERE word=?;`),
+		ft.normalize(word)).Scan(&cluster)
+	if err == sql.ErrNoRows {
+		return 0, &WordNotFoundError{Word: word}
+	}
+	if err != nil {
+		if isNoSuchTable(err) {
+			return 0, errors.New("fasttext: no clusters stored, see WithPersistClusters")
+		}
+		return 0, err
+	}
+	return cluster, nil
+}
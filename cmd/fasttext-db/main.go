@@ -0,0 +1,128 @@
+// Command fasttext-db maintains fastText SQLite3 databases.
+//
+// Usage:
+//
+//	fasttext-db verify [-sample n] [-full] [-source file.vec] [-pair a,b,min]... model.sqlite
+//	fasttext-db migrate model.sqlite
+//
+// verify runs the self-test of the database and exits with a non-zero
+// status if any check fails, so it can gate CI/CD pipelines shipping
+// embedding artifacts. With -full, it checks the integrity of the whole
+// database instead, and with -source also compares a sample of the
+// vectors of the file it was built from.
+//
+// migrate upgrades a database built by an older version of the package
+// in place.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: fasttext-db verify [-sample n] [-full] [-source file.vec] [-pair a,b,min]... model.sqlite")
+	fmt.Fprintln(os.Stderr, "       fasttext-db migrate model.sqlite")
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "verify":
+		os.Exit(verify(os.Args[2:]))
+	case "migrate":
+		os.Exit(migrate(os.Args[2:]))
+	default:
+		usage()
+	}
+}
+
+// pairFlag collects the -pair flags.
+type pairFlag []fasttext.SelfTestOption
+
+func (p *pairFlag) String() string {
+	return ""
+}
+
+func (p *pairFlag) Set(value string) error {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return fmt.Errorf("expected a,b,min, got %q", value)
+	}
+	min, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return err
+	}
+	*p = append(*p, fasttext.WithSimilarityCheck(parts[0], parts[1], min))
+	return nil
+}
+
+func verify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	sample := fs.Int("sample", fasttext.DefaultSelfTestSample, "number of random rows to decode, or of words compared with -source")
+	full := fs.Bool("full", false, "check every row and the integrity of the file")
+	source := fs.String("source", "", "compare with the word embedding file the database was built from (implies -full)")
+	var pairs pairFlag
+	fs.Var(&pairs, "pair", "check that words a and b have a similarity of at least min (a,b,min)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		usage()
+	}
+	if _, err := os.Stat(fs.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ft := fasttext.NewFastText(fs.Arg(0))
+	defer ft.Close()
+	var report *fasttext.SelfTestReport
+	var err error
+	if *full || *source != "" {
+		opts := []fasttext.SelfTestOption(pairs)
+		if *source != "" {
+			f, err := os.Open(*source)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			defer f.Close()
+			opts = append(opts, fasttext.WithSourceCheck(f, *sample))
+		}
+		report, err = ft.Verify(opts...)
+	} else {
+		report, err = ft.SelfTest(append(pairs, fasttext.WithSample(*sample))...)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	report.WriteTo(os.Stdout)
+	if !report.Passed() {
+		return 1
+	}
+	return 0
+}
+
+func migrate(args []string) int {
+	if len(args) != 1 {
+		usage()
+	}
+	if _, err := os.Stat(args[0]); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	ft := fasttext.NewFastText(args[0])
+	defer ft.Close()
+	if err := ft.Migrate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,391 @@
+// Command fasttext builds and queries fastText SQLite3 databases.
+//
+// Usage:
+//
+//	fasttext build [-precision p] -db model.sqlite wiki.en.vec
+//	fasttext build -db model.sqlite https://example.com/wiki.en.vec.gz
+//	fasttext get -db model.sqlite word...
+//	fasttext nn [-k 10] -db model.sqlite word
+//	fasttext export [-o out.vec] [-format vec|npy|npz] -db model.sqlite
+//	fasttext stats -db model.sqlite
+//	fasttext serve [-addr :8080] -db model.sqlite
+//
+// Instead of -db, every subcommand accepts -config with a configuration
+// file as read by config.Load.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekzhu/go-fasttext"
+	"github.com/ekzhu/go-fasttext/config"
+	"github.com/ekzhu/go-fasttext/server"
+)
+
+const usageText = `usage: fasttext <command> [flags] [args]
+
+commands:
+  build   build a database from a .vec file
+  get     print the embeddings of words
+  nn      print the nearest neighbors of a word
+  export  write the vocabulary in .vec format
+  stats   print statistics about a database
+  serve   serve a database over HTTP
+
+Run fasttext <command> -h for the flags of a command.`
+
+// stdout and stderr are the outputs of the commands, replaced by the
+// tests.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// errUsage is returned for a missing or unknown command.
+var errUsage = errors.New(usageText)
+
+// flagError is an invalid flag, already reported with the usage of the
+// command.
+type flagError struct {
+	error
+}
+
+func (e flagError) Unwrap() error {
+	return e.error
+}
+
+func main() {
+	err := run(os.Args[1:])
+	var fe flagError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+		os.Exit(0)
+	case errors.As(err, &fe):
+		os.Exit(2)
+	case err == errUsage:
+		fmt.Fprintln(stderr, usageText)
+		os.Exit(2)
+	default:
+		fmt.Fprintln(stderr, "fasttext:", err)
+		os.Exit(1)
+	}
+}
+
+// run runs the command of the command line arguments.
+func run(args []string) error {
+	commands := map[string]func([]string) error{
+		"build":  build,
+		"get":    get,
+		"nn":     nn,
+		"export": export,
+		"stats":  stats,
+		"serve":  serve,
+	}
+	if len(args) == 0 {
+		return errUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return errUsage
+	}
+	return cmd(args[1:])
+}
+
+// parseFlags parses the flags of a command.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return flagError{err}
+	}
+	return nil
+}
+
+// dbFlags are the flags locating the database, shared by all commands.
+type dbFlags struct {
+	db     string
+	config string
+	table  string
+}
+
+func newFlagSet(name string, dbf *dbFlags) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&dbf.db, "db", "", "SQLite3 database file")
+	fs.StringVar(&dbf.config, "config", "", "configuration file (YAML, TOML or JSON)")
+	fs.StringVar(&dbf.table, "table", "", "table of the model in the database file")
+	return fs
+}
+
+// open starts a session on an existing database.
+func (dbf *dbFlags) open() (*fasttext.FastText, error) {
+	cfg, err := dbf.load()
+	if err != nil {
+		return nil, err
+	}
+	return cfg.Open(), nil
+}
+
+// load returns the configuration given by the flags, checking that
+// the database exists.
+func (dbf *dbFlags) load() (*config.Config, error) {
+	cfg, err := dbf.resolve()
+	if err != nil {
+		return nil, err
+	}
+	// Opening a missing file would create an empty database.
+	if _, err := os.Stat(cfg.DB); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolve returns the configuration given by the flags.
+func (dbf *dbFlags) resolve() (*config.Config, error) {
+	cfg := &config.Config{DB: dbf.db}
+	if dbf.config != "" {
+		var err error
+		if cfg, err = config.Load(dbf.config); err != nil {
+			return nil, err
+		}
+		if dbf.db != "" {
+			cfg.DB = dbf.db
+		}
+	}
+	if dbf.table != "" {
+		cfg.Table = dbf.table
+	}
+	if cfg.DB == "" {
+		return nil, errors.New("no database, set -db or -config")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func build(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("build", &dbf)
+	precision := fs.String("precision", "float32", "storage precision: int8, float16, float32 or float64")
+	compression := fs.String("compression", "none", "blob compression: none or zstd")
+	normalize := fs.Bool("normalize", false, "store unit-length vectors")
+	pca := fs.Int("pca", 0, "reduce the vectors to this many principal components")
+	maxWords := fs.Int64("max-words", 0, "import only the first n words")
+	lenient := fs.Bool("lenient", false, "skip or repair malformed lines instead of failing")
+	delimiter := fs.String("delimiter", "", "separator of the vector values, e.g. '\\t' (default a space)")
+	duplicates := fs.String("duplicates", "keep-first", "duplicate words: keep-first, overwrite, skip or error")
+	quiet := fs.Bool("q", false, "do not report progress")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: fasttext build [-precision p] [-compression c] [-normalize] [-pca dims] [-max-words n] [-lenient] [-delimiter d] [-duplicates policy] [-table name] -db model.sqlite file.vec")
+	}
+	cfg, err := dbf.resolve()
+	if err != nil {
+		return err
+	}
+	// The database is written, whatever mode the configuration serves
+	// it in.
+	cfg.InMemory, cfg.ReadOnly, cfg.Immutable, cfg.Replicas = false, false, false, nil
+	p, err := fasttext.ParsePrecision(*precision)
+	if err != nil {
+		return err
+	}
+	c, err := fasttext.ParseCompression(*compression)
+	if err != nil {
+		return err
+	}
+	conflict, err := fasttext.ParseConflictPolicy(*duplicates)
+	if err != nil {
+		return err
+	}
+	opts := []fasttext.BuildOption{fasttext.WithPrecision(p), fasttext.WithCompression(c),
+		fasttext.WithConflictPolicy(conflict)}
+	if *normalize {
+		opts = append(opts, fasttext.WithNormalizedVectors())
+	}
+	if *pca > 0 {
+		opts = append(opts, fasttext.WithPCA(*pca))
+	}
+	if *maxWords > 0 {
+		opts = append(opts, fasttext.WithMaxWords(*maxWords))
+	}
+	if *delimiter != "" {
+		sep, err := strconv.Unquote(`"` + *delimiter + `"`)
+		if err != nil {
+			return fmt.Errorf("invalid delimiter %q", *delimiter)
+		}
+		opts = append(opts, fasttext.WithDelimiter(sep))
+	}
+	if *lenient {
+		opts = append(opts, fasttext.WithParseMode(fasttext.ParseLenient),
+			fasttext.WithBadLineFunc(func(err *fasttext.ParseError, repaired bool) {
+				action := "skipped"
+				if repaired {
+					action = "repaired"
+				}
+				fmt.Fprintf(stderr, "\r%v (%s)\n", err, action)
+			}))
+	}
+	if !*quiet {
+		opts = append(opts, fasttext.WithProgress(func(words, bytes int64) {
+			fmt.Fprintf(stderr, "\r%d words, %d MB read", words, bytes>>20)
+		}))
+	}
+	ft := cfg.Open()
+	defer ft.Close()
+	if src := fs.Arg(0); strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+		err = ft.BuildDBFromURL(context.Background(), src, opts...)
+	} else {
+		err = ft.BuildDBFromFile(src, opts...)
+	}
+	if !*quiet {
+		fmt.Fprintln(stderr)
+	}
+	return err
+}
+
+func get(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("get", &dbf)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ft, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+	for _, word := range fs.Args() {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			return fmt.Errorf("%s: %w", word, err)
+		}
+		buf := []byte(word)
+		for _, v := range emb {
+			buf = append(buf, ' ')
+			buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+		}
+		fmt.Fprintln(stdout, string(buf))
+	}
+	return nil
+}
+
+func nn(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("nn", &dbf)
+	k := fs.Int("k", 10, "number of neighbors")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: fasttext nn [-k 10] -db model.sqlite word")
+	}
+	ft, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+	neighbors, err := ft.NearestNeighbors(fs.Arg(0), *k)
+	if err != nil {
+		return err
+	}
+	for _, n := range neighbors {
+		fmt.Fprintf(stdout, "%s\t%.4f\n", n.Word, n.Score)
+	}
+	return nil
+}
+
+func export(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("export", &dbf)
+	out := fs.String("o", "", "output file (default standard output)")
+	format := fs.String("format", "vec", "output format: vec, npy or npz")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ft, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+	w := stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		w = file
+	}
+	switch *format {
+	case "vec":
+		return ft.ExportVec(w)
+	case "npy":
+		return ft.ExportNpy(w, nil)
+	case "npz":
+		return ft.ExportNpz(w, nil)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+func stats(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("stats", &dbf)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	ft, err := dbf.open()
+	if err != nil {
+		return err
+	}
+	defer ft.Close()
+	s, err := ft.Stats()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "words\t%d\n", s.Words)
+	fmt.Fprintf(stdout, "dim\t%d\n", s.Dim)
+	fmt.Fprintf(stdout, "codec\t%v\n", s.Codec)
+	fmt.Fprintf(stdout, "size\t%d\n", s.Size)
+	if !s.BuiltAt.IsZero() {
+		fmt.Fprintf(stdout, "built\t%s\n", s.BuiltAt.Format(time.RFC3339))
+	}
+	casing, err := ft.CasingStats()
+	if err != nil {
+		return err
+	}
+	if casing != nil {
+		return casing.Report(stdout)
+	}
+	return nil
+}
+
+func serve(args []string) error {
+	var dbf dbFlags
+	fs := newFlagSet("serve", &dbf)
+	addr := fs.String("addr", "", "address to listen on (default from -config, or :8080)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	cfg, err := dbf.load()
+	if err != nil {
+		return err
+	}
+	if *addr != "" {
+		cfg.Server.Addr = *addr
+	}
+	if cfg.Server.Addr == "" {
+		cfg.Server.Addr = ":8080"
+	}
+	return server.ListenAndServe(cfg)
+}
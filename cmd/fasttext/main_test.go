@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testVec = "../../testdata/wiki.en.vec"
+
+// runOutput runs the command line, returning its standard output.
+func runOutput(args ...string) (string, error) {
+	var out bytes.Buffer
+	stdout, stderr = &out, ioutil.Discard
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+	err := run(args)
+	return out.String(), err
+}
+
+func Test_Args(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "model.sqlite")
+	for _, c := range []struct {
+		args []string
+		// err is the expected error: errUsage, a flagError, or any other
+		// error if not nil.
+		err error
+	}{
+		{nil, errUsage},
+		{[]string{"unknown"}, errUsage},
+		{[]string{"build", "-bogus"}, flagError{}},
+		{[]string{"build", "-h"}, flag.ErrHelp},
+		{[]string{"build", testVec}, errors.New("no -db")},
+		{[]string{"build", "-db", db}, errors.New("no file")},
+		{[]string{"build", "-precision", "int3", "-db", db, testVec}, errors.New("precision")},
+		{[]string{"build", "-duplicates", "bogus", "-db", db, testVec}, errors.New("duplicates")},
+		{[]string{"get", "the"}, errors.New("no database")},
+		{[]string{"get", "-db", filepath.Join(dir, "missing.sqlite"), "the"}, errors.New("missing database")},
+		{[]string{"nn", "-db", db}, errors.New("no word")},
+		{[]string{"nn", "-k", "x", "-db", db, "the"}, flagError{}},
+	} {
+		_, err := runOutput(c.args...)
+		var fe flagError
+		switch {
+		case c.err == errUsage:
+			if err != errUsage {
+				t.Errorf("%q: expected the usage, got %v", c.args, err)
+			}
+		case c.err == flag.ErrHelp:
+			if !errors.Is(err, flag.ErrHelp) {
+				t.Errorf("%q: expected the help, got %v", c.args, err)
+			}
+		case errors.As(c.err, &fe):
+			if !errors.As(err, &fe) {
+				t.Errorf("%q: expected a flag error, got %v", c.args, err)
+			}
+		case err == nil:
+			t.Errorf("%q: expected an error (%v)", c.args, c.err)
+		}
+	}
+	if _, err := os.Stat(db); !os.IsNotExist(err) {
+		t.Errorf("Expected no database built by invalid command lines, got %v", err)
+	}
+}
+
+func Test_BuildGetNN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "model.sqlite")
+	if _, err := runOutput("build", "-q", "-db", db, testVec); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runOutput("get", "-db", db, "the", "has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 embeddings, got %q", out)
+	}
+	for i, word := range []string{"the", "has"} {
+		fields := strings.Fields(lines[i])
+		if len(fields) != 301 || fields[0] != word {
+			t.Errorf("Expected %s with 300 values, got %d fields", word, len(fields))
+		}
+	}
+	if _, err := runOutput("get", "-db", db, "not-a-word"); err == nil {
+		t.Error("Expected an error for a word not in the vocabulary")
+	}
+
+	out, err = runOutput("nn", "-k", "3", "-db", db, "the")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 neighbors, got %q", out)
+	}
+	for _, line := range lines {
+		if fields := strings.Split(line, "\t"); len(fields) != 2 || fields[0] == "the" {
+			t.Errorf("Unexpected neighbor line %q", line)
+		}
+	}
+
+	out, err = runOutput("stats", "-db", db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "words\t49\n") || !strings.Contains(out, "dim\t300\n") {
+		t.Errorf("Unexpected stats %q", out)
+	}
+}
+
+func Test_BuildConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	db := filepath.Join(dir, "model.sqlite")
+	config := filepath.Join(dir, "fasttext.yaml")
+	yaml := "db: " + db + "\ntable: wiki\nwal: true\n"
+	if err := ioutil.WriteFile(config, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := runOutput("build", "-q", "-config", config, testVec); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := runOutput("get", "-config", config, "the")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields := strings.Fields(out); len(fields) != 301 || fields[0] != "the" {
+		t.Errorf("Expected the with 300 values, got %d fields", len(fields))
+	}
+	// The model was built in the table of the configuration.
+	if _, err := runOutput("get", "-db", db, "the"); err == nil {
+		t.Error("Expected no model in the default table")
+	}
+	if _, err := runOutput("get", "-config", config, "-table", "wiki;", "the"); err == nil {
+		t.Error("Expected an error for an invalid table name")
+	}
+}
@@ -0,0 +1,224 @@
+package fasttext
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// Codec encodes embedding vectors to and from the blobs stored in the
+// SQLite3 database. The codec of a database is chosen at build time and
+// recorded in its metadata table, so databases built with different
+// settings or by different versions of this package are decoded
+// correctly, or rejected instead of silently misdecoded.
+type Codec struct {
+	// Precision is the format of each value.
+	Precision Precision
+	// Order is the byte order of each value.
+	Order binary.ByteOrder
+}
+
+// DefaultCodec is the codec of databases without metadata, and the
+// default of BuildDB.
+var DefaultCodec = Codec{Precision: Float32, Order: binary.BigEndian}
+
+// Width returns the number of bytes per value.
+func (c Codec) Width() int {
+	return c.Precision.width()
+}
+
+// overhead returns the number of bytes of a blob besides the values:
+// the scale of an Int8 vector.
+func (c Codec) overhead() int {
+	if c.Precision == Int8 {
+		return 4
+	}
+	return 0
+}
+
+func (c Codec) String() string {
+	return fmt.Sprintf("%s/%s", c.Precision, byteOrderName(c.Order))
+}
+
+// Encode encodes the vector into a blob.
+func (c Codec) Encode(vec []float32) []byte {
+	if c.Precision == Int8 {
+		return c.encodeInt8(vec)
+	}
+	w := c.Width()
+	data := make([]byte, len(vec)*w)
+	for i, v := range vec {
+		b := data[i*w : (i+1)*w]
+		switch c.Precision {
+		case Float16:
+			c.Order.PutUint16(b, float32ToFloat16(v))
+		case Float64:
+			c.Order.PutUint64(b, math.Float64bits(float64(v)))
+		default:
+			c.Order.PutUint32(b, math.Float32bits(v))
+		}
+	}
+	return data
+}
+
+// Decode decodes a blob into a vector.
+func (c Codec) Decode(data []byte) ([]float32, error) {
+	n := len(data) - c.overhead()
+	if n < 0 || n%c.Width() != 0 {
+		return nil, fmt.Errorf("fasttext: %d bytes is not a whole number of %s values",
+			len(data), c.Precision)
+	}
+	vec := make([]float32, n/c.Width())
+	c.decodeInto(vec, data)
+	return vec, nil
+}
+
+// Validate checks that the blob holds a vector of the given dimension.
+func (c Codec) Validate(data []byte, dim int) error {
+	if len(data) != dim*c.Width()+c.overhead() {
+		return fmt.Errorf("fasttext: blob of %d bytes does not hold %d %s values",
+			len(data), dim, c.Precision)
+	}
+	return nil
+}
+
+// decodeInto decodes the blob into dst, which must have the right length.
+func (c Codec) decodeInto(dst []float32, data []byte) {
+	if c.Precision == Int8 {
+		c.decodeInt8(dst, data)
+		return
+	}
+	w := c.Width()
+	for i := range dst {
+		b := data[i*w : (i+1)*w]
+		switch c.Precision {
+		case Float16:
+			dst[i] = float16ToFloat32(c.Order.Uint16(b))
+		case Float64:
+			dst[i] = float32(math.Float64frombits(c.Order.Uint64(b)))
+		default:
+			dst[i] = math.Float32frombits(c.Order.Uint32(b))
+		}
+	}
+}
+
+// decodeAt decodes the i-th value of the blob, which must be in range.
+func (c Codec) decodeAt(data []byte, i int) float32 {
+	if c.Precision == Int8 {
+		return float32(int8(data[4+i])) * math.Float32frombits(c.Order.Uint32(data))
+	}
+	b := data[i*c.Width():]
+	switch c.Precision {
+	case Float16:
+		return float16ToFloat32(c.Order.Uint16(b))
+	case Float64:
+		return float32(math.Float64frombits(c.Order.Uint64(b)))
+	}
+	return math.Float32frombits(c.Order.Uint32(b))
+}
+
+// encodeInt8 encodes the scale of the vector, its largest absolute
+// value divided by 127, followed by the values divided by the scale
+// and rounded.
+func (c Codec) encodeInt8(vec []float32) []byte {
+	var max float64
+	for _, v := range vec {
+		max = math.Max(max, math.Abs(float64(v)))
+	}
+	scale := float32(max / 127)
+	data := make([]byte, 4+len(vec))
+	c.Order.PutUint32(data, math.Float32bits(scale))
+	if scale == 0 {
+		return data
+	}
+	for i, v := range vec {
+		data[4+i] = byte(int8(math.Round(float64(v / scale))))
+	}
+	return data
+}
+
+func (c Codec) decodeInt8(dst []float32, data []byte) {
+	scale := math.Float32frombits(c.Order.Uint32(data))
+	for i := range dst {
+		dst[i] = float32(int8(data[4+i])) * scale
+	}
+}
+
+// WithByteOrder sets the byte order of the stored vectors,
+// big-endian by default.
+func WithByteOrder(order binary.ByteOrder) BuildOption {
+	return func(c *buildConfig) {
+		c.codec.Order = order
+	}
+}
+
+// byteOrderSample is the number of vectors inspected by
+// detectByteOrder.
+const byteOrderSample = 16
+
+// detectByteOrder guesses the byte order of the float32 vectors of a
+// database of the first versions, which did not record it: decoded in
+// the wrong order, most values of word embeddings are tiny, huge or
+// not numbers. Ties, e.g. for an empty database, go to ByteOrder.
+func (ft *FastText) detectByteOrder(db queryer) (binary.ByteOrder, error) {
+	rows, err := db.Query(ft.sql(`SELECT emb FROM fasttext LIMIT ?;`), byteOrderSample)
+	if err != nil {
+		if isNoSuchTable(err) {
+			return ByteOrder, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	var big, little int
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		for i := 0; i+4 <= len(data); i += 4 {
+			if plausibleValue(binary.BigEndian.Uint32(data[i:])) {
+				big++
+			}
+			if plausibleValue(binary.LittleEndian.Uint32(data[i:])) {
+				little++
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	switch {
+	case big > little:
+		return binary.BigEndian, nil
+	case little > big:
+		return binary.LittleEndian, nil
+	}
+	return ByteOrder, nil
+}
+
+// plausibleValue returns whether the bits of a float32 value are a
+// likely value of a word embedding.
+func plausibleValue(bits uint32) bool {
+	v := math.Abs(float64(math.Float32frombits(bits)))
+	return v == 0 || (v >= 1e-8 && v <= 1e8)
+}
+
+func byteOrderName(order binary.ByteOrder) string {
+	switch order {
+	case binary.BigEndian:
+		return "big"
+	case binary.LittleEndian:
+		return "little"
+	}
+	return order.String()
+}
+
+func parseByteOrder(name string) (binary.ByteOrder, error) {
+	switch name {
+	case "big":
+		return binary.BigEndian, nil
+	case "little":
+		return binary.LittleEndian, nil
+	}
+	return nil, fmt.Errorf("fasttext: unknown byte order %q", name)
+}
@@ -0,0 +1,271 @@
+// Package config describes FastText sessions in YAML, TOML or JSON
+// files, so that deployments can be set up reproducibly instead of with
+// long lists of flags:
+//
+//	# fasttext.yaml
+//	db: /data/wiki.en.sqlite
+//	in_memory: false
+//	cache_size: 100000
+//	wal: true
+//	mmap_size: 1073741824
+//	query_timeout: 500
+//	oov:
+//	  - policy: fuzzy
+//	  - policy: subwords
+//	    minn: 3
+//	    maxn: 6
+//	  - policy: zero
+//	server:
+//	  addr: ":8080"
+//	  auth_keys: ["secret"]
+//
+// Unknown keys are rejected, so a misspelled key fails instead of being
+// silently ignored. Options taking Go values, such as fasttext.WithLogger,
+// fasttext.WithTracer or fasttext.OOVFunc, cannot be written in a file
+// and are passed to Open instead.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/ekzhu/go-fasttext"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is a declarative description of a FastText session.
+type Config struct {
+	// DB is the location of the SQLite3 database file.
+	DB string `json:"db" yaml:"db" toml:"db"`
+	// InMemory loads the database into memory as
+	// fasttext.NewFastTextInMem does.
+	InMemory bool `json:"in_memory" yaml:"in_memory" toml:"in_memory"`
+	// ReadOnly opens the database file read-only (see
+	// fasttext.WithReadOnly).
+	ReadOnly bool `json:"read_only" yaml:"read_only" toml:"read_only"`
+	// Immutable opens the database file as immutable (see
+	// fasttext.WithImmutable).
+	Immutable bool `json:"immutable" yaml:"immutable" toml:"immutable"`
+	// Table is the table of the model in the database file (see
+	// fasttext.WithTableName), fasttext.TableName if empty.
+	Table string `json:"table" yaml:"table" toml:"table"`
+	// CacheSize is the size of the LRU cache (see fasttext.WithCache),
+	// 0 disables it.
+	CacheSize int `json:"cache_size" yaml:"cache_size" toml:"cache_size"`
+	// WAL sets the journal mode of the database file to write-ahead
+	// logging (see fasttext.WithWAL).
+	WAL bool `json:"wal" yaml:"wal" toml:"wal"`
+	// MmapSize is the number of bytes of the database file SQLite may
+	// memory-map (see fasttext.WithMmapSize), 0 keeps SQLite's
+	// default.
+	MmapSize int64 `json:"mmap_size" yaml:"mmap_size" toml:"mmap_size"`
+	// PageCacheSize is the size in kilobytes of the page cache of each
+	// connection (see fasttext.WithPageCacheSize), 0 keeps SQLite's
+	// default.
+	PageCacheSize int `json:"page_cache_size" yaml:"page_cache_size" toml:"page_cache_size"`
+	// BusyTimeout is the number of milliseconds queries wait for locks
+	// (see fasttext.WithBusyTimeout).
+	BusyTimeout int `json:"busy_timeout" yaml:"busy_timeout" toml:"busy_timeout"`
+	// QueryTimeout is the number of milliseconds after which look-ups
+	// and exact neighbor searches fail (see
+	// fasttext.WithQueryTimeout), 0 for no timeout.
+	QueryTimeout int `json:"query_timeout" yaml:"query_timeout" toml:"query_timeout"`
+	// Replicas are identical copies of the database file the reads are
+	// spread across (see fasttext.WithReplicas).
+	Replicas []string `json:"replicas" yaml:"replicas" toml:"replicas"`
+	// DegradedMode serves cached or hashed vectors when the database is
+	// unavailable (see fasttext.WithDegradedMode).
+	DegradedMode bool `json:"degraded_mode" yaml:"degraded_mode" toml:"degraded_mode"`
+	// NNCache persists the neighbor lists (see fasttext.WithNNCache).
+	NNCache bool `json:"nn_cache" yaml:"nn_cache" toml:"nn_cache"`
+	// OOV is the chain of policies resolving the words missing from
+	// the vocabulary, tried in order (see fasttext.WithOOVPolicy and
+	// fasttext.OOVChain).
+	OOV []OOVConfig `json:"oov" yaml:"oov" toml:"oov"`
+	// Server configures serving the database over the network.
+	Server ServerConfig `json:"server" yaml:"server" toml:"server"`
+}
+
+// OOVConfig is a policy of the OOV chain of a Config.
+type OOVConfig struct {
+	// Policy is "error", "zero", "fuzzy" or "subwords", for
+	// fasttext.OOVError, OOVZeroVector, OOVFuzzy and OOVSubwords.
+	Policy string `json:"policy" yaml:"policy" toml:"policy"`
+	// MinN and MaxN are the lengths of the substrings of the "subwords"
+	// policy, 3 and 6 if not set.
+	MinN int `json:"minn" yaml:"minn" toml:"minn"`
+	MaxN int `json:"maxn" yaml:"maxn" toml:"maxn"`
+}
+
+// ServerConfig is the network part of a Config.
+type ServerConfig struct {
+	// Addr is the address of the HTTP server, e.g. ":8080".
+	Addr string `json:"addr" yaml:"addr" toml:"addr"`
+	// GRPCAddr is the address of the gRPC server, e.g. ":9090".
+	GRPCAddr string `json:"grpc_addr" yaml:"grpc_addr" toml:"grpc_addr"`
+	// AuthKeys are the accepted API keys. If empty, no authentication
+	// is required.
+	AuthKeys []string `json:"auth_keys" yaml:"auth_keys" toml:"auth_keys"`
+}
+
+// Load reads a configuration file, whose format (YAML, TOML or JSON)
+// is given by its extension.
+func Load(filename string) (*Config, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	cfg, err := Parse(file, strings.TrimPrefix(filepath.Ext(filename), "."))
+	if err != nil {
+		return nil, fmt.Errorf("config: %s: %w", filename, err)
+	}
+	return cfg, nil
+}
+
+// Parse reads a configuration in the given format: "yaml" (or "yml"),
+// "toml" or "json".
+func Parse(r io.Reader, format string) (*Config, error) {
+	cfg := &Config{}
+	var err error
+	switch strings.ToLower(format) {
+	case "yaml", "yml":
+		dec := yaml.NewDecoder(r)
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if err == io.EOF {
+			err = nil
+		}
+	case "toml":
+		var md toml.MetaData
+		md, err = toml.NewDecoder(r).Decode(cfg)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown keys %v", undecoded)
+		}
+	case "json":
+		dec := json.NewDecoder(r)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return nil, fmt.Errorf("config: unknown format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the values of the configuration that Options cannot
+// apply: the table name and the OOV policies. Parse validates the
+// configurations it reads.
+func (cfg *Config) Validate() error {
+	if cfg.Table != "" && !fasttext.ValidTableName(cfg.Table) {
+		return fmt.Errorf("config: invalid table name %q", cfg.Table)
+	}
+	for _, oov := range cfg.OOV {
+		if _, err := oov.policy(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// policy returns the OOV policy described.
+func (oov OOVConfig) policy() (fasttext.OOVPolicy, error) {
+	switch oov.Policy {
+	case "error":
+		return fasttext.OOVError(), nil
+	case "zero":
+		return fasttext.OOVZeroVector(), nil
+	case "fuzzy":
+		return fasttext.OOVFuzzy(), nil
+	case "subwords":
+		minn, maxn := oov.MinN, oov.MaxN
+		if minn == 0 {
+			minn = 3
+		}
+		if maxn == 0 {
+			maxn = 6
+		}
+		if minn < 1 || maxn < minn {
+			return nil, fmt.Errorf("config: invalid subwords lengths %d to %d", minn, maxn)
+		}
+		return fasttext.OOVSubwords(minn, maxn), nil
+	}
+	return nil, fmt.Errorf("config: unknown OOV policy %q", oov.Policy)
+}
+
+// Options returns the session options described by the configuration.
+// Like fasttext.WithTableName, it panics on an invalid table name, see
+// Validate.
+func (cfg *Config) Options() []fasttext.Option {
+	var opts []fasttext.Option
+	if cfg.Immutable {
+		opts = append(opts, fasttext.WithImmutable())
+	} else if cfg.ReadOnly {
+		opts = append(opts, fasttext.WithReadOnly())
+	}
+	if cfg.Table != "" {
+		opts = append(opts, fasttext.WithTableName(cfg.Table))
+	}
+	if cfg.CacheSize > 0 {
+		opts = append(opts, fasttext.WithCache(cfg.CacheSize))
+	}
+	if cfg.WAL {
+		opts = append(opts, fasttext.WithWAL())
+	}
+	if cfg.MmapSize > 0 {
+		opts = append(opts, fasttext.WithMmapSize(cfg.MmapSize))
+	}
+	if cfg.PageCacheSize > 0 {
+		opts = append(opts, fasttext.WithPageCacheSize(cfg.PageCacheSize))
+	}
+	if cfg.BusyTimeout > 0 {
+		opts = append(opts, fasttext.WithBusyTimeout(time.Duration(cfg.BusyTimeout)*time.Millisecond))
+	}
+	if cfg.QueryTimeout > 0 {
+		opts = append(opts, fasttext.WithQueryTimeout(time.Duration(cfg.QueryTimeout)*time.Millisecond))
+	}
+	if len(cfg.Replicas) > 0 {
+		opts = append(opts, fasttext.WithReplicas(cfg.Replicas...))
+	}
+	if cfg.DegradedMode {
+		opts = append(opts, fasttext.WithDegradedMode())
+	}
+	if cfg.NNCache {
+		opts = append(opts, fasttext.WithNNCache())
+	}
+	if len(cfg.OOV) > 0 {
+		policies := make([]fasttext.OOVPolicy, 0, len(cfg.OOV))
+		for _, oov := range cfg.OOV {
+			policy, err := oov.policy()
+			if err != nil {
+				// Only for configurations not read by Parse, which
+				// checks the policies: the look-ups fail instead.
+				policy = func(*fasttext.FastText, string) ([]float32, error) { return nil, err }
+			}
+			policies = append(policies, policy)
+		}
+		opts = append(opts, fasttext.WithOOVPolicy(fasttext.OOVChain(policies...)))
+	}
+	return opts
+}
+
+// Open starts the FastText session described by the configuration,
+// with the extra options applied after the configured ones.
+func (cfg *Config) Open(opts ...fasttext.Option) *fasttext.FastText {
+	opts = append(cfg.Options(), opts...)
+	if cfg.InMemory {
+		return fasttext.NewFastTextInMem(cfg.DB, opts...)
+	}
+	return fasttext.NewFastText(cfg.DB, opts...)
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+func Test_Parse(t *testing.T) {
+	configs := map[string]string{
+		"yaml": "db: /data/wiki.sqlite\ncache_size: 100\nserver:\n  addr: \":8080\"\n  auth_keys: [a, b]\n",
+		"toml": "db = \"/data/wiki.sqlite\"\ncache_size = 100\n[server]\naddr = \":8080\"\nauth_keys = [\"a\", \"b\"]\n",
+		"json": `{"db": "/data/wiki.sqlite", "cache_size": 100, "server": {"addr": ":8080", "auth_keys": ["a", "b"]}}`,
+	}
+	for format, data := range configs {
+		cfg, err := Parse(strings.NewReader(data), format)
+		if err != nil {
+			t.Errorf("%s: %v", format, err)
+			continue
+		}
+		if cfg.DB != "/data/wiki.sqlite" || cfg.CacheSize != 100 ||
+			cfg.Server.Addr != ":8080" || len(cfg.Server.AuthKeys) != 2 {
+			t.Errorf("%s: unexpected config %+v", format, cfg)
+		}
+		if len(cfg.Options()) != 1 {
+			t.Errorf("%s: expected 1 option", format)
+		}
+	}
+	if _, err := Parse(strings.NewReader(""), "ini"); err == nil {
+		t.Error("Should reject unknown format")
+	}
+	if _, err := Parse(strings.NewReader(`{"table": "wiki-en"}`), "json"); err == nil {
+		t.Error("Should reject an invalid table name")
+	}
+	if cfg, err := Parse(strings.NewReader(`{"table": "wiki_en"}`), "json"); err != nil {
+		t.Error(err)
+	} else if cfg.Table != "wiki_en" {
+		t.Errorf("Unexpected table %q", cfg.Table)
+	}
+}
+
+func Test_ParseUnknownKeys(t *testing.T) {
+	configs := map[string]string{
+		"yaml": "db: /data/wiki.sqlite\ncache_szie: 100\n",
+		"toml": "db = \"/data/wiki.sqlite\"\n[server]\nadr = \":8080\"\n",
+		"json": `{"db": "/data/wiki.sqlite", "oov": [{"policy": "zero", "min": 2}]}`,
+	}
+	for format, data := range configs {
+		if _, err := Parse(strings.NewReader(data), format); err == nil {
+			t.Errorf("%s: expected an error for an unknown key", format)
+		}
+	}
+}
+
+func Test_ParseOOV(t *testing.T) {
+	data := "db: wiki.sqlite\noov:\n  - policy: subwords\n    minn: 2\n  - policy: zero\n"
+	cfg, err := Parse(strings.NewReader(data), "yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.OOV) != 2 || cfg.OOV[0].MinN != 2 || cfg.OOV[1].Policy != "zero" {
+		t.Errorf("Unexpected OOV chain %+v", cfg.OOV)
+	}
+	for _, data := range []string{"oov:\n  - policy: guess\n", "oov:\n  - policy: subwords\n    minn: 5\n    maxn: 3\n"} {
+		if _, err := Parse(strings.NewReader(data), "yaml"); err == nil {
+			t.Errorf("Expected an error for %q", data)
+		}
+	}
+
+	ft := (&Config{DB: ":memory:", OOV: []OOVConfig{{Policy: "zero"}}}).Open()
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader("2 2\na 1 0\nb 0 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	emb, err := ft.GetEmb("c")
+	if err != nil || len(emb) != 2 || emb[0] != 0 || emb[1] != 0 {
+		t.Errorf("Expected a zero vector, got %v, %v", emb, err)
+	}
+
+	invalid := (&Config{DB: ":memory:", OOV: []OOVConfig{{Policy: "guess"}}}).Open()
+	defer invalid.Close()
+	if err := invalid.BuildDB(strings.NewReader("2 2\na 1 0\nb 0 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := invalid.GetEmb("c"); err == nil || errors.Is(err, fasttext.ErrNoEmbFound) {
+		t.Errorf("Expected the error of the invalid policy, got %v", err)
+	}
+}
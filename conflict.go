@@ -0,0 +1,139 @@
+package fasttext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ConflictPolicy decides what a build does with a word found again in
+// its input, e.g. a surface form of the .vec file only differing from
+// an earlier one by case once lowercased by WithLowercase.
+type ConflictPolicy int
+
+const (
+	// ConflictKeepFirst keeps the first embedding of the word, which in
+	// fastText files is the most frequent form. It is the default.
+	ConflictKeepFirst ConflictPolicy = iota
+	// ConflictOverwrite keeps the last embedding of the word, at the
+	// rank of the first one.
+	ConflictOverwrite
+	// ConflictSkip leaves the word out of the vocabulary.
+	ConflictSkip
+	// ConflictError keeps the first embedding of the word, and fails the
+	// build once the input is read with a *DuplicateWordsError listing
+	// all the duplicates. The build can be resumed with BuildDB and
+	// another policy.
+	ConflictError
+)
+
+var conflictPolicyNames = map[ConflictPolicy]string{
+	ConflictKeepFirst: "keep-first",
+	ConflictOverwrite: "overwrite",
+	ConflictSkip:      "skip",
+	ConflictError:     "error",
+}
+
+func (p ConflictPolicy) String() string {
+	if name, ok := conflictPolicyNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+}
+
+// ParseConflictPolicy returns the policy with the given name, as
+// returned by ConflictPolicy.String.
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	for p, n := range conflictPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("fasttext: unknown conflict policy %q", name)
+}
+
+// WithConflictPolicy sets what the build does with the words found
+// more than once in its input, after normalization, instead of keeping
+// the first one.
+func WithConflictPolicy(p ConflictPolicy) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.conflict = p
+	}
+}
+
+// DuplicateWordsError is returned by a build with ConflictError when
+// words are found more than once in its input.
+type DuplicateWordsError struct {
+	// Words are the duplicated words, after normalization, in order of
+	// their first duplicate.
+	Words []string
+	// Count is the number of duplicates, not counting the first
+	// occurrences of the words.
+	Count int
+}
+
+func (e *DuplicateWordsError) Error() string {
+	examples := e.Words
+	if len(examples) > 3 {
+		examples = examples[:3]
+	}
+	quoted := make([]string, len(examples))
+	for i, w := range examples {
+		quoted[i] = fmt.Sprintf("%q", w)
+	}
+	return fmt.Sprintf("fasttext: %d duplicates of %d words in the input, e.g. %s",
+		e.Count, len(e.Words), strings.Join(quoted, ", "))
+}
+
+// buildWords tracks the words of a build to resolve the duplicates.
+type buildWords struct {
+	policy ConflictPolicy
+	// ids are the ids of the rows of the words, 0 for words skipped by
+	// the filter or left out by ConflictSkip.
+	ids map[string]int64
+	// dups is the report of ConflictError, and reported the words in
+	// it.
+	dups     DuplicateWordsError
+	reported map[string]bool
+}
+
+func newBuildWords(policy ConflictPolicy) *buildWords {
+	return &buildWords{policy: policy, ids: make(map[string]int64), reported: make(map[string]bool)}
+}
+
+// resolve applies the policy to the embedding of a word of the build
+// found again. The changes to the rows are idempotent, so that a resumed
+// build applies them again.
+func (bw *buildWords) resolve(ft *FastText, db execer, format vecFormat, emb *wordEmb) error {
+	id := bw.ids[emb.Word]
+	if id == 0 {
+		return nil
+	}
+	switch bw.policy {
+	case ConflictOverwrite:
+		vec, err := format.input(emb)
+		if err != nil {
+			return err
+		}
+		_, err = db.Exec(ft.sql(`UPDATE fasttext SET emb = ? WHERE id = ?;`), format.encode(vec), id)
+		return err
+	case ConflictSkip:
+		bw.ids[emb.Word] = 0
+		_, err := db.Exec(ft.sql(`DELETE FROM fasttext WHERE id = ?;`), id)
+		return err
+	case ConflictError:
+		if !bw.reported[emb.Word] {
+			bw.reported[emb.Word] = true
+			bw.dups.Words = append(bw.dups.Words, emb.Word)
+		}
+		bw.dups.Count++
+	}
+	return nil
+}
+
+// err returns the report of ConflictError, if any.
+func (bw *buildWords) err() error {
+	if bw.dups.Count == 0 {
+		return nil
+	}
+	return &bw.dups
+}
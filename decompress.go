@@ -0,0 +1,165 @@
+package fasttext
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// BuildDBFromFile initializes the SQLite3 database from a word
+// embedding file, which may be plain text, gzip compressed (.vec.gz),
+// a zip archive (.zip) containing the .vec file, a word2vec binary file
+// (see BuildDBFromWord2Vec) or a fastText binary model (.bin or .ftz,
+// see BuildDBFromModel).
+func (ft *FastText) BuildDBFromFile(filename string, opts ...BuildOption) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ft.buildFromFile(file, filename, opts)
+}
+
+// BuildDBFromFS initializes the SQLite3 database from a word embedding
+// file of a file system, e.g. an embed.FS compiled into a test binary
+// or an fstest.MapFS, in the formats of BuildDBFromFile.
+func (ft *FastText) BuildDBFromFS(fsys fs.FS, path string, opts ...BuildOption) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ft.buildFromFile(file, path, opts)
+}
+
+// buildFromFile builds the database from the open file of the given
+// name, detecting its format.
+func (ft *FastText) buildFromFile(file fs.File, name string, opts []BuildOption) error {
+	br := bufio.NewReader(file)
+	magic, err := br.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if len(magic) == len(zipMagic) && binary.LittleEndian.Uint32(magic) == ftModelMagic {
+		m, err := readFTModel(br)
+		if err != nil {
+			return fmt.Errorf("fasttext: reading %s: %w", name, err)
+		}
+		cfg := ft.newBuildConfig(opts)
+		return ft.build(m.wordEmbs(cfg.done), cfg)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	ra, ok := file.(io.ReaderAt)
+	if !bytes.Equal(magic, zipMagic) || !ok {
+		// A zip stream is read without random access by BuildDB.
+		return ft.BuildDB(br, opts...)
+	}
+	archive, err := zip.NewReader(ra, info.Size())
+	if err != nil {
+		return err
+	}
+	entry, err := zipEmbEntry(archive)
+	if err != nil {
+		return err
+	}
+	r, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return ft.BuildDB(r, opts...)
+}
+
+// zipEmbEntry picks the word embedding file of a zip archive: the first
+// .vec or .txt file, or else the first file.
+func zipEmbEntry(archive *zip.Reader) (*zip.File, error) {
+	var first *zip.File
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(f.Name)) {
+		case ".vec", ".txt":
+			return f, nil
+		}
+		if first == nil {
+			first = f
+		}
+	}
+	if first == nil {
+		return nil, errors.New("fasttext: empty zip archive")
+	}
+	return first, nil
+}
+
+// decompress sniffs the magic bytes of r and transparently decompresses
+// gzip streams and the first file of zip streams.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, zipMagic):
+		return zipFirstEntry(br)
+	}
+	return br, nil
+}
+
+// zipFirstEntry reads the local header of the first file of a zip
+// stream and returns a reader of its content. Unlike archive/zip, it
+// does not need random access to the central directory at the end.
+func zipFirstEntry(r io.Reader) (io.Reader, error) {
+	var header struct {
+		Signature        uint32
+		Version          uint16
+		Flags            uint16
+		Method           uint16
+		ModTime, ModDate uint16
+		CRC32            uint32
+		CompressedSize   uint32
+		UncompressedSize uint32
+		NameLen          uint16
+		ExtraLen         uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, err
+	}
+	if _, err := io.CopyN(ioutil.Discard, r, int64(header.NameLen)+int64(header.ExtraLen)); err != nil {
+		return nil, err
+	}
+	switch header.Method {
+	case zip.Deflate:
+		// The deflate stream ends by itself, even when its size is only
+		// given after the data.
+		return flate.NewReader(r), nil
+	case zip.Store:
+		if header.Flags&0x8 != 0 {
+			return nil, errors.New("fasttext: unsupported streamed zip entry of unknown size")
+		}
+		return io.LimitReader(r, int64(header.UncompressedSize)), nil
+	}
+	return nil, fmt.Errorf("fasttext: unsupported zip compression method %d", header.Method)
+}
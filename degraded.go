@@ -0,0 +1,102 @@
+package fasttext
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sync"
+)
+
+// WithDegradedMode makes look-ups survive a temporarily unavailable
+// database (e.g. a network file system blip or a replaced file):
+// instead of failing, GetEmb serves the embedding from the cache if
+// enabled, or else a deterministic hashed vector (see HashedVector).
+// Database errors are counted in DegradedStats. Cancelled and timed out
+// queries still fail with their error.
+// Words known to be missing from the vocabulary still return
+// ErrNoEmbFound, and Contains still returns the database errors, which
+// false would report as words missing from the vocabulary.
+func WithDegradedMode() Option {
+	return func(ft *FastText) {
+		ft.degraded = &degradedState{}
+	}
+}
+
+// DegradedStats counts the database errors absorbed in degraded mode.
+type DegradedStats struct {
+	// DBErrors is the number of failed database look-ups.
+	DBErrors int64
+	// HashedVectors is the number of hashed vectors served in place
+	// of the stored embeddings.
+	HashedVectors int64
+	// LastError is the most recent database error.
+	LastError error
+}
+
+type degradedState struct {
+	mu    sync.Mutex
+	stats DegradedStats
+}
+
+// DegradedStats returns the errors absorbed so far in degraded mode.
+func (ft *FastText) DegradedStats() DegradedStats {
+	if ft.degraded == nil {
+		return DegradedStats{}
+	}
+	ft.degraded.mu.Lock()
+	defer ft.degraded.mu.Unlock()
+	return ft.degraded.stats
+}
+
+// absorb records a database error in degraded mode, returning whether
+// the error should be hidden from the caller. The errors of cancelled
+// and timed out queries are not database errors.
+func (ft *FastText) absorb(err error) bool {
+	if ft.degraded == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	ft.degraded.mu.Lock()
+	defer ft.degraded.mu.Unlock()
+	ft.degraded.stats.DBErrors++
+	ft.degraded.stats.LastError = err
+	return true
+}
+
+// hashedFallback returns the hashed vector served for the word in
+// place of its stored embedding, of the dimension of the stored
+// vectors if known.
+func (ft *FastText) hashedFallback(word string) []float32 {
+	ft.degraded.mu.Lock()
+	ft.degraded.stats.HashedVectors++
+	ft.degraded.mu.Unlock()
+	dim := Dim
+	if f, err := ft.vecFormat(); err == nil && f.dim != 0 {
+		dim = f.dim
+	}
+	return HashedVector(word, dim)
+}
+
+// HashedVector returns a pseudo-random unit vector of the given
+// dimension derived from the hash of the word, so the same word always
+// gets the same vector.
+func HashedVector(word string, dim int) []float32 {
+	h := fnv.New64a()
+	h.Write([]byte(word))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	vec := make([]float32, dim)
+	var s float64
+	for i := range vec {
+		x := r.NormFloat64()
+		vec[i] = float32(x)
+		s += x * x
+	}
+	if s > 0 {
+		n := float32(math.Sqrt(s))
+		for i := range vec {
+			vec[i] /= n
+		}
+	}
+	return vec
+}
@@ -0,0 +1,119 @@
+package fasttext
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// GetEmbDims returns the values of the given dimensions of the word
+// embedding of the given word, in the order of dims. Only these values
+// are decoded from the stored blob, except for compressed vectors,
+// which are decompressed whole. Words missing from the vocabulary are
+// resolved by the OOV policy, as with GetEmb.
+func (ft *FastText) GetEmbDims(word string, dims []int) ([]float32, error) {
+	start := time.Now()
+	vec, source, err := ft.getEmbDims(word, dims)
+	if ft.observing() {
+		ft.observeLookup(start, word, source, err)
+	}
+	return vec, err
+}
+
+// GetEmbPrefix returns the first n values of the word embedding of the
+// given word, e.g. the main components of vectors built with WithPCA.
+// See GetEmbDims.
+func (ft *FastText) GetEmbPrefix(word string, n int) ([]float32, error) {
+	dims := make([]int, n)
+	for i := range dims {
+		dims[i] = i
+	}
+	return ft.GetEmbDims(word, dims)
+}
+
+// getEmbDims is GetEmbDims, also returning where the embedding was
+// found.
+func (ft *FastText) getEmbDims(word string, dims []int) ([]float32, string, error) {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, d := range dims {
+		if d < 0 || (f.dim != 0 && d >= f.dim) {
+			return nil, "", fmt.Errorf("fasttext: dimension %d out of range [0, %d)", d, f.dim)
+		}
+	}
+	input := word
+	word = ft.normalize(word)
+	if vec, ok, err := ft.preloaded(word); err != nil {
+		return nil, "", err
+	} else if ok {
+		return pickDims(vec, dims), SourcePreload, nil
+	}
+	if ft.cache != nil {
+		if vec, ok := ft.cache.get(word); ok {
+			return pickDims(vec, dims), SourceCache, nil
+		}
+	}
+	var rows *sql.Rows
+	stmt, err := ft.getEmbStmt()
+	if err == nil {
+		rows, err = stmt.Query(word)
+	}
+	if err != nil {
+		if ft.absorb(err) {
+			return pickDims(ft.hashedFallback(word), dims), SourceHashed, nil
+		}
+		return nil, "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return nil, "", err
+		}
+		if ft.oovPolicy == nil {
+			return nil, "", &WordNotFoundError{Word: word}
+		}
+		vec, err := ft.resolveOOV(input)
+		if err != nil {
+			return nil, "", err
+		}
+		return pickDims(vec, dims), SourceOOV, nil
+	}
+	var data sql.RawBytes
+	if err := rows.Scan(&data); err != nil {
+		return nil, "", err
+	}
+	if f.zstd != nil {
+		vec, err := f.decode(data)
+		if err != nil {
+			return nil, "", err
+		}
+		return pickDims(vec, dims), SourceDatabase, nil
+	}
+	n := (len(data) - f.codec.overhead()) / f.codec.Width()
+	if f.dim != 0 {
+		if err := f.codec.Validate(data, f.dim); err != nil {
+			return nil, "", err
+		}
+	}
+	vec := make([]float32, len(dims))
+	for i, d := range dims {
+		if d >= n {
+			return nil, "", fmt.Errorf("fasttext: dimension %d out of range [0, %d)", d, n)
+		}
+		vec[i] = f.codec.decodeAt(data, d)
+	}
+	return vec, SourceDatabase, nil
+}
+
+// pickDims returns the values of the given dimensions of vec.
+func pickDims(vec []float32, dims []int) []float32 {
+	out := make([]float32, len(dims))
+	for i, d := range dims {
+		if d < len(vec) {
+			out[i] = vec[d]
+		}
+	}
+	return out
+}
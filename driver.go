@@ -0,0 +1,85 @@
+package fasttext
+
+import (
+	"database/sql"
+	"database/sql/driver"
+)
+
+// WithDriver opens the database with the registered database/sql
+// driver of the given name instead of the built-in mattn/go-sqlite3
+// one, e.g. "sqlite" for the pure-Go modernc.org/sqlite, which allows
+// building with CGO_ENABLED=0:
+//
+//	import _ "modernc.org/sqlite"
+//	...
+//	ft := fasttext.NewFastText("/path/to/sqlite3/file", fasttext.WithDriver("sqlite"))
+//
+// The driver must be an SQLite3 one. The cosine SQL function of
+// QueryRaw is only available with the built-in driver.
+func WithDriver(name string) Option {
+	return func(ft *FastText) {
+		ft.driverName = name
+	}
+}
+
+// NewFastTextFromDB starts a new FastText session on an already open
+// SQLite3 database, with any driver (see WithDriver). The session does
+// not own the database: Close leaves it open.
+func NewFastTextFromDB(db *sql.DB, opts ...Option) *FastText {
+	ft := &FastText{db: db, sharedDB: true, table: TableName, src: newDBSource("", "")}
+	for _, opt := range opts {
+		opt(ft)
+	}
+	return ft
+}
+
+// WithOpenDB opens the database of the session with open instead of
+// sql.OpenDB, e.g. to wrap its connector for the OpenTelemetry
+// instrumentation of database/sql:
+//
+//	ft := fasttext.NewFastText("/path/to/sqlite3/file", fasttext.WithOpenDB(otelsql.OpenDB))
+//
+// The connections of the wrapped connector must still unwrap to those
+// of the session for Backup, which needs the built-in driver. The limit
+// of idle connections is set by WithMaxIdleConns.
+func WithOpenDB(open func(c driver.Connector) *sql.DB) Option {
+	return func(ft *FastText) {
+		ft.openWith = open
+	}
+}
+
+// WithMaxIdleConns sets the number of idle connections kept in the pool
+// of the session, 2 by default as in database/sql. Reload empties the
+// pool and then restores this limit.
+func WithMaxIdleConns(n int) Option {
+	return func(ft *FastText) {
+		ft.maxIdleConns = n
+	}
+}
+
+// openDB opens the database of the session.
+func (ft *FastText) openDB() *sql.DB {
+	var db *sql.DB
+	if ft.openWith != nil {
+		db = ft.openWith(ft.connector(ft.src))
+	} else {
+		db = sql.OpenDB(ft.connector(ft.src))
+	}
+	db.SetMaxIdleConns(ft.maxIdleConns)
+	return db
+}
+
+// connector returns the connector of the session to the database of
+// src.
+func (ft *FastText) connector(src *dbSource) driver.Connector {
+	if ft.driverName == "" {
+		return newSQLiteConnector(src, ft)
+	}
+	db, err := sql.Open(ft.driverName, src.dsn)
+	if err != nil {
+		panic(err)
+	}
+	drv := db.Driver()
+	db.Close()
+	return &pragmaConnector{src: src, driver: drv, pragmas: ft.pragmas}
+}
@@ -0,0 +1,72 @@
+package fasttext
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// WordNotFoundError is returned when a word is not in the vocabulary.
+// It matches ErrNoEmbFound with errors.Is.
+type WordNotFoundError struct {
+	Word string
+}
+
+func (e *WordNotFoundError) Error() string {
+	return fmt.Sprintf("%v: %q", ErrNoEmbFound, e.Word)
+}
+
+// Is reports whether target is ErrNoEmbFound.
+func (e *WordNotFoundError) Is(target error) bool {
+	return target == ErrNoEmbFound
+}
+
+// IDNotFoundError is returned when no word has the ID. It matches
+// ErrNoEmbFound with errors.Is.
+type IDNotFoundError struct {
+	ID int64
+}
+
+func (e *IDNotFoundError) Error() string {
+	return fmt.Sprintf("%v: ID %d", ErrNoEmbFound, e.ID)
+}
+
+// Is reports whether target is ErrNoEmbFound.
+func (e *IDNotFoundError) Is(target error) bool {
+	return target == ErrNoEmbFound
+}
+
+// ParseError is returned when a line of a word embedding file cannot be
+// parsed.
+type ParseError struct {
+	// Line is the line number, starting at 1.
+	Line int
+	Word string
+	Err  error
+	// repaired is set for lines repaired by the lenient parser.
+	repaired bool
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("fasttext: line %d, word %q: %v", e.Line, e.Word, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// QueryTimeoutError is returned when a query runs longer than the
+// timeout of WithQueryTimeout. It matches context.DeadlineExceeded with
+// errors.Is.
+type QueryTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *QueryTimeoutError) Error() string {
+	return fmt.Sprintf("fasttext: query timed out after %v", e.Timeout)
+}
+
+// Is reports whether target is context.DeadlineExceeded.
+func (e *QueryTimeoutError) Is(target error) bool {
+	return target == context.DeadlineExceeded
+}
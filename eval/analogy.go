@@ -0,0 +1,213 @@
+package eval
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+// Question is an analogy question "A is to B as C is to D".
+type Question struct {
+	// Section is the section of the question in the dataset, e.g.
+	// "capital-common-countries".
+	Section    string
+	A, B, C, D string
+}
+
+// ReadAnalogies reads the Google analogy dataset, questions-words.txt:
+// sections starting with a ": name" line, then four words per line.
+func ReadAnalogies(r io.Reader) ([]Question, error) {
+	var questions []Question
+	var section string
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, ":") {
+			section = strings.TrimSpace(text[1:])
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("eval: line %d: expected 4 words, got %d", line, len(fields))
+		}
+		questions = append(questions, Question{
+			Section: section, A: fields[0], B: fields[1], C: fields[2], D: fields[3],
+		})
+	}
+	return questions, s.Err()
+}
+
+// SectionResult is the outcome of the questions of a section.
+type SectionResult struct {
+	Name string
+	// Questions is the number of questions of the section, Covered the
+	// number of them whose words are all in the vocabulary and Correct
+	// the number of those answered with D.
+	Questions, Covered, Correct int
+}
+
+// Accuracy returns the fraction of the covered questions answered
+// correctly.
+func (r SectionResult) Accuracy() float64 {
+	if r.Covered == 0 {
+		return 0
+	}
+	return float64(r.Correct) / float64(r.Covered)
+}
+
+// AnalogyResult is the outcome of Analogies.
+type AnalogyResult struct {
+	// Total sums the sections, whose name is empty.
+	Total SectionResult
+	// Sections holds the results by section, in the order of the
+	// dataset.
+	Sections []SectionResult
+}
+
+type analogyConfig struct {
+	restrict int
+}
+
+// AnalogyOption configures Analogies.
+type AnalogyOption func(*analogyConfig)
+
+// WithRestrictVocab answers the questions among the n most frequent
+// words only, loaded in memory, as the original word2vec evaluation does
+// with 30000 words. Questions with words outside of them are not
+// covered. Without it, each question scans the whole vocabulary.
+func WithRestrictVocab(n int) AnalogyOption {
+	return func(cfg *analogyConfig) {
+		cfg.restrict = n
+	}
+}
+
+// Analogies answers the analogy questions with FastText.Analogy,
+// counting a question as correct if the best answer is D.
+func Analogies(ft *fasttext.FastText, questions []Question, opts ...AnalogyOption) (AnalogyResult, error) {
+	cfg := &analogyConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	answer := func(q Question) (string, bool, error) {
+		if _, err := ft.GetEmb(q.D); err != nil {
+			return "", false, err
+		}
+		nn, err := ft.Analogy(q.A, q.B, q.C, 1)
+		if err != nil || len(nn) == 0 {
+			return "", false, err
+		}
+		return nn[0].Word, true, nil
+	}
+	if cfg.restrict > 0 {
+		v, err := loadVocab(ft, cfg.restrict)
+		if err != nil {
+			return AnalogyResult{}, err
+		}
+		answer = v.answer
+	}
+	var r AnalogyResult
+	for _, q := range questions {
+		if len(r.Sections) == 0 || r.Sections[len(r.Sections)-1].Name != q.Section {
+			r.Sections = append(r.Sections, SectionResult{Name: q.Section})
+		}
+		s := &r.Sections[len(r.Sections)-1]
+		s.Questions++
+		word, ok, err := answer(q)
+		if errors.Is(err, fasttext.ErrNoEmbFound) || (err == nil && !ok) {
+			continue
+		}
+		if err != nil {
+			return r, err
+		}
+		s.Covered++
+		if word == q.D {
+			s.Correct++
+		}
+	}
+	for _, s := range r.Sections {
+		r.Total.Questions += s.Questions
+		r.Total.Covered += s.Covered
+		r.Total.Correct += s.Correct
+	}
+	return r, nil
+}
+
+// vocab holds the unit vectors of the most frequent words.
+type vocab struct {
+	words []string
+	index map[string]int
+	unit  [][]float32
+}
+
+func loadVocab(ft *fasttext.FastText, n int) (*vocab, error) {
+	it, err := ft.IterTop(n)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	v := &vocab{index: make(map[string]int, n)}
+	for it.Next() {
+		v.index[it.Word()] = len(v.words)
+		v.words = append(v.words, it.Word())
+		v.unit = append(v.unit, unitVec(it.Emb()))
+	}
+	return v, it.Err()
+}
+
+// answer returns the word closest to B - A + C among the words, other
+// than A, B and C, or false if a word of the question is not one of them.
+func (v *vocab) answer(q Question) (string, bool, error) {
+	var rows [3]int
+	for i, word := range []string{q.A, q.B, q.C} {
+		row, ok := v.index[word]
+		if !ok {
+			return "", false, nil
+		}
+		rows[i] = row
+	}
+	if _, ok := v.index[q.D]; !ok {
+		return "", false, nil
+	}
+	query := make([]float32, len(v.unit[rows[0]]))
+	for i := range query {
+		query[i] = v.unit[rows[1]][i] - v.unit[rows[0]][i] + v.unit[rows[2]][i]
+	}
+	best, bestScore := "", math.Inf(-1)
+	for i, vec := range v.unit {
+		if i == rows[0] || i == rows[1] || i == rows[2] {
+			continue
+		}
+		var score float64
+		for j := range vec {
+			score += float64(vec[j]) * float64(query[j])
+		}
+		if score > bestScore {
+			best, bestScore = v.words[i], score
+		}
+	}
+	return best, best != "", nil
+}
+
+func unitVec(vec []float32) []float32 {
+	var sum float64
+	for _, v := range vec {
+		sum += float64(v) * float64(v)
+	}
+	out := make([]float32, len(vec))
+	if sum == 0 {
+		return out
+	}
+	norm := math.Sqrt(sum)
+	for i, v := range vec {
+		out[i] = float32(float64(v) / norm)
+	}
+	return out
+}
@@ -0,0 +1,165 @@
+// Package eval measures the quality of the embeddings of a fastText
+// database on standard benchmarks, e.g. to check that an import, or a
+// build with a lower precision or PCA, preserved the quality of the
+// model:
+//
+//	pairs, err := eval.ReadSimLex999(f)
+//	r, err := eval.Similarity(ft, pairs)
+//	fmt.Printf("Spearman %.3f on %d of %d pairs\n", r.Spearman, r.Covered, r.Pairs)
+//
+// The datasets are not bundled: WordSim-353, SimLex-999 and the Google
+// analogy set (questions-words.txt) are read in their distributed
+// formats. As is customary, questions with words missing from the
+// vocabulary are skipped and counted apart.
+package eval
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+// Pair is a pair of words rated by humans for similarity or relatedness.
+type Pair struct {
+	Word1, Word2 string
+	Score        float64
+}
+
+// ReadWordSim353 reads the WordSim-353 dataset, e.g. combined.csv or
+// combined.tab: two words and their mean score per line.
+func ReadWordSim353(r io.Reader) ([]Pair, error) {
+	return ReadPairs(r, 2)
+}
+
+// ReadSimLex999 reads the SimLex-999 dataset, SimLex-999.txt: two
+// words, their part of speech and their SimLex-999 score first on each
+// line.
+func ReadSimLex999(r io.Reader) ([]Pair, error) {
+	return ReadPairs(r, 3)
+}
+
+// ReadPairs reads a word similarity dataset of two words per line, then
+// other fields of which the score is the one at the given index from 0,
+// separated by tabs, commas or spaces. A first line without a score,
+// e.g. a header, is skipped, as are empty lines and lines starting with
+// "#".
+func ReadPairs(r io.Reader, scoreField int) ([]Pair, error) {
+	if scoreField < 2 {
+		return nil, errors.New("eval: the score follows the two words")
+	}
+	var pairs []Pair
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(text, func(c rune) bool {
+			return c == '\t' || c == ',' || c == ' '
+		})
+		if len(fields) <= scoreField {
+			return nil, fmt.Errorf("eval: line %d: expected at least %d fields, got %d", line, scoreField+1, len(fields))
+		}
+		score, err := strconv.ParseFloat(fields[scoreField], 64)
+		if err != nil {
+			if len(pairs) == 0 && line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("eval: line %d: %w", line, err)
+		}
+		pairs = append(pairs, Pair{Word1: fields[0], Word2: fields[1], Score: score})
+	}
+	return pairs, s.Err()
+}
+
+// SimilarityResult is the outcome of Similarity.
+type SimilarityResult struct {
+	// Pairs is the number of pairs of the dataset, and Covered the
+	// number of them whose words are both in the vocabulary.
+	Pairs, Covered int
+	// Spearman is the Spearman rank correlation between the human scores
+	// and the cosine similarities of the covered pairs.
+	Spearman float64
+}
+
+// Similarity correlates the cosine similarities of the embeddings of the
+// pairs with their human scores.
+func Similarity(ft *fasttext.FastText, pairs []Pair) (SimilarityResult, error) {
+	r := SimilarityResult{Pairs: len(pairs)}
+	var human, model []float64
+	for _, p := range pairs {
+		sim, err := ft.Similarity(p.Word1, p.Word2)
+		if errors.Is(err, fasttext.ErrNoEmbFound) {
+			continue
+		}
+		if err != nil {
+			return r, err
+		}
+		human = append(human, p.Score)
+		model = append(model, sim)
+	}
+	r.Covered = len(human)
+	r.Spearman = Spearman(human, model)
+	return r, nil
+}
+
+// Spearman returns the Spearman rank correlation of x and y, of the
+// same length, with tied values ranked by their mean rank. It returns
+// NaN for fewer than 2 values or constant ones.
+func Spearman(x, y []float64) float64 {
+	if len(x) != len(y) || len(x) < 2 {
+		return math.NaN()
+	}
+	return pearson(ranks(x), ranks(y))
+}
+
+// ranks returns the ranks from 1 of the values, the mean rank for ties.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	r := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i + 1
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+		// Ranks i+1 to j, averaged.
+		mean := float64(i+1+j) / 2
+		for _, k := range order[i:j] {
+			r[k] = mean
+		}
+		i = j
+	}
+	return r
+}
+
+func pearson(x, y []float64) float64 {
+	var mx, my float64
+	for i := range x {
+		mx += x[i]
+		my += y[i]
+	}
+	mx /= float64(len(x))
+	my /= float64(len(y))
+	var sxy, sxx, syy float64
+	for i := range x {
+		dx, dy := x[i]-mx, y[i]-my
+		sxy += dx * dy
+		sxx += dx * dx
+		syy += dy * dy
+	}
+	if sxx == 0 || syy == 0 {
+		return math.NaN()
+	}
+	return sxy / math.Sqrt(sxx*syy)
+}
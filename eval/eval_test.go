@@ -0,0 +1,102 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+func newTestFastText(t *testing.T) *fasttext.FastText {
+	ft := fasttext.NewFastText(":memory:")
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	return ft
+}
+
+func Test_Spearman(t *testing.T) {
+	if s := Spearman([]float64{1, 2, 3, 4}, []float64{10, 20, 30, 40}); math.Abs(s-1) > 1e-12 {
+		t.Errorf("Expected 1, got %v", s)
+	}
+	if s := Spearman([]float64{1, 2, 3, 4}, []float64{4, 3, 2, 1}); math.Abs(s+1) > 1e-12 {
+		t.Errorf("Expected -1, got %v", s)
+	}
+	// Ties get their mean rank: ranks 1.5, 1.5, 3 and 1, 2, 3.
+	if s := Spearman([]float64{1, 1, 2}, []float64{1, 2, 3}); math.Abs(s-math.Sqrt(3)/2) > 1e-12 {
+		t.Errorf("Expected %v, got %v", math.Sqrt(3)/2, s)
+	}
+	if s := Spearman([]float64{1}, []float64{1}); !math.IsNaN(s) {
+		t.Errorf("Expected NaN, got %v", s)
+	}
+}
+
+func Test_Similarity(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	words := []string{"has", "have", "page", "but", "the"}
+	var data strings.Builder
+	data.WriteString("Word 1,Word 2,Human (mean)\n")
+	for i := 0; i+1 < len(words); i++ {
+		sim, err := ft.Similarity(words[i], words[i+1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Human scores ranking the pairs as the model does.
+		fmt.Fprintf(&data, "%s,%s,%.4f\n", words[i], words[i+1], 10*sim)
+	}
+	data.WriteString("has,not-a-word,5.0\n")
+	pairs, err := ReadWordSim353(strings.NewReader(data.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Similarity(ft, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Pairs != 5 || r.Covered != 4 || math.Abs(r.Spearman-1) > 1e-12 {
+		t.Errorf("Unexpected result %+v", r)
+	}
+
+	pairs, err = ReadSimLex999(strings.NewReader("word1\tword2\tPOS\tSimLex999\nold\tnew\tA\t1.58\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pairs) != 1 || pairs[0] != (Pair{Word1: "old", Word2: "new", Score: 1.58}) {
+		t.Errorf("Unexpected pairs %v", pairs)
+	}
+}
+
+func Test_Analogies(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	nn, err := ft.Analogy("has", "have", "page", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := fmt.Sprintf(": first\nhas have page %s\nhas have page but\n: second\nhas have page not-a-word\n", nn[0].Word)
+	questions, err := ReadAnalogies(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(questions) != 3 || questions[2].Section != "second" {
+		t.Fatalf("Unexpected questions %v", questions)
+	}
+	for _, opts := range [][]AnalogyOption{nil, {WithRestrictVocab(1000)}} {
+		r, err := Analogies(ft, questions, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Total.Questions != 3 || r.Total.Covered != 2 || r.Total.Correct != 1 {
+			t.Errorf("Unexpected total %+v", r.Total)
+		}
+		if len(r.Sections) != 2 || r.Sections[0].Accuracy() != 0.5 || r.Sections[1].Covered != 0 {
+			t.Errorf("Unexpected sections %+v", r.Sections)
+		}
+	}
+	if _, err := ReadAnalogies(strings.NewReader("a b c\n")); err == nil {
+		t.Error("Expected an error for a malformed question")
+	}
+}
@@ -0,0 +1,126 @@
+package fasttext
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sources of a looked-up embedding reported by Explanation.
+const (
+	SourceCache    = "cache"
+	SourceDatabase = "database"
+	SourceHashed   = "hashed"
+	SourceOOV      = "oov"
+	SourcePreload  = "preload"
+)
+
+// Methods of a neighbor search reported by Explanation.
+const (
+	MethodExact = "exact"
+	MethodANN   = "ann"
+)
+
+// Explanation describes how the result of a look-up or search was
+// produced, for debugging quality and latency issues.
+type Explanation struct {
+	// Source is where the query embedding came from (SourcePreload,
+	// SourceCache, SourceDatabase, SourceHashed or SourceOOV).
+	Source string
+	// QueryPlan is SQLite's plan for the database look-up, showing
+	// whether the word index was used.
+	QueryPlan string
+	// Method is the neighbor search method (MethodExact).
+	Method string
+	// Candidates is the number of vocabulary words scored by a search.
+	Candidates int
+	// Workers is the number of goroutines used by a search.
+	Workers int
+	// Steps lists, in order, what was tried to produce the result.
+	Steps []string
+	// Duration is the total time taken.
+	Duration time.Duration
+}
+
+func (exp *Explanation) step(format string, args ...interface{}) {
+	if exp != nil {
+		exp.Steps = append(exp.Steps, fmt.Sprintf(format, args...))
+	}
+}
+
+func (exp *Explanation) setSource(source string) {
+	if exp != nil && exp.Source == "" {
+		exp.Source = source
+	}
+}
+
+// String formats the explanation on multiple lines.
+func (exp *Explanation) String() string {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "source: %s\n", exp.Source)
+	if exp.QueryPlan != "" {
+		fmt.Fprintf(&b, "query plan: %s\n", exp.QueryPlan)
+	}
+	if exp.Method != "" {
+		fmt.Fprintf(&b, "method: %s (%d candidates, %d workers)\n",
+			exp.Method, exp.Candidates, exp.Workers)
+	}
+	for _, s := range exp.Steps {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	fmt.Fprintf(&b, "duration: %s\n", exp.Duration)
+	return b.String()
+}
+
+// GetEmbExplain is GetEmb that also explains how the embedding was found.
+func (ft *FastText) GetEmbExplain(word string) ([]float32, *Explanation, error) {
+	exp := &Explanation{}
+	start := time.Now()
+	vec, err := ft.getEmb(context.Background(), word, exp)
+	exp.Duration = time.Since(start)
+	return vec, exp, err
+}
+
+// NearestNeighborsExplain is NearestNeighbors that also explains how
+// the neighbors were found.
+func (ft *FastText) NearestNeighborsExplain(word string, k int, opts ...SearchOption) ([]ScoredWord, *Explanation, error) {
+	exp := &Explanation{}
+	start := time.Now()
+	vec, err := ft.getEmb(context.Background(), word, exp)
+	if err != nil {
+		exp.Duration = time.Since(start)
+		return nil, exp, err
+	}
+	nn, err := ft.nearest(context.Background(), vec, k, keepWith(ft.excludeWords(word), opts), exp)
+	exp.Duration = time.Since(start)
+	return nn, exp, err
+}
+
+// queryPlan returns SQLite's query plan for the query, or the error
+// preventing its computation.
+func (ft *FastText) queryPlan(query string, args ...interface{}) string {
+	rows, err := ft.db.Query("EXPLAIN QUERY PLAN "+query, args...)
+	if err != nil {
+		return err.Error()
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return err.Error()
+	}
+	var details []string
+	for rows.Next() {
+		vals := make([]interface{}, len(cols))
+		for i := range vals {
+			vals[i] = new(interface{})
+		}
+		if err := rows.Scan(vals...); err != nil {
+			return err.Error()
+		}
+		// The detail is the last column in all SQLite versions.
+		details = append(details, fmt.Sprint(*vals[len(vals)-1].(*interface{})))
+	}
+	return strings.Join(details, "; ")
+}
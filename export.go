@@ -0,0 +1,230 @@
+package fasttext
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ExportVec writes the vocabulary in fastText .vec text format: a
+// "<vocabulary size> <dimension>" header line, then one word per line
+// followed by its vector. Values are written with the fewest digits
+// that read back to the same float32, so BuildDB on the output restores
+// the same embeddings.
+func (ft *FastText) ExportVec(w io.Writer) error {
+	count, err := ft.vocabSize()
+	if err != nil {
+		return err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	dim := f.dim
+	headerDone := false
+	var buf []byte
+	err = ft.ForEach(func(word string, emb []float32) error {
+		if !headerDone {
+			if dim == 0 {
+				dim = len(emb)
+			}
+			buf = strconv.AppendInt(buf[:0], int64(count), 10)
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, int64(dim), 10)
+			buf = append(buf, '\n')
+			if _, err := bw.Write(buf); err != nil {
+				return err
+			}
+			headerDone = true
+		}
+		buf = append(buf[:0], word...)
+		for _, v := range emb {
+			buf = append(buf, ' ')
+			buf = strconv.AppendFloat(buf, float64(v), 'g', -1, 32)
+		}
+		buf = append(buf, '\n')
+		_, err := bw.Write(buf)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if !headerDone {
+		if _, err := io.WriteString(bw, "0 "+strconv.Itoa(dim)+"\n"); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// npyExportBatch is the number of words looked up at once by ExportNpy.
+const npyExportBatch = 10000
+
+// ExportNpy writes the embeddings of the words as a NumPy .npy float32
+// matrix, one row per word in order, e.g. for numpy.load. With nil
+// words, it writes the whole vocabulary in the order of ForEach; use
+// ExportNpz to get the words along with it. It returns a
+// WordNotFoundError if a word is not in the vocabulary.
+func (ft *FastText) ExportNpy(w io.Writer, words []string) error {
+	_, err := ft.exportNpy(w, words)
+	return err
+}
+
+// exportNpy implements ExportNpy, returning the exported words.
+func (ft *FastText) exportNpy(w io.Writer, words []string) ([]string, error) {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	rows := len(words)
+	if words == nil {
+		if rows, err = ft.vocabSize(); err != nil {
+			return nil, err
+		}
+	}
+	bw := bufio.NewWriter(w)
+	dim := f.dim
+	if dim == 0 && rows > 0 {
+		// Databases without metadata: the first vector gives the dimension.
+		it, err := ft.Iter()
+		if err != nil {
+			return nil, err
+		}
+		if it.Next() {
+			dim = len(it.Emb())
+		}
+		it.Close()
+	}
+	if err := writeNpyHeader(bw, "<f4", rows, dim); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4*dim)
+	writeRow := func(emb []float32) error {
+		if len(emb) != dim {
+			return fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(emb), dim)
+		}
+		for i, v := range emb {
+			binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+		}
+		_, err := bw.Write(buf)
+		return err
+	}
+	if words == nil {
+		err = ft.ForEach(func(word string, emb []float32) error {
+			if len(words) == rows {
+				return errors.New("fasttext: vocabulary grew during the export")
+			}
+			words = append(words, word)
+			return writeRow(emb)
+		})
+		if err == nil && len(words) != rows {
+			err = errors.New("fasttext: vocabulary shrank during the export")
+		}
+		if err != nil {
+			return nil, err
+		}
+		return words, bw.Flush()
+	}
+	for start := 0; start < len(words); start += npyExportBatch {
+		batch := words[start:minInt(start+npyExportBatch, len(words))]
+		embs, err := ft.lookupEmbs(batch)
+		if err != nil {
+			return nil, err
+		}
+		for i, emb := range embs {
+			if emb == nil {
+				return nil, &WordNotFoundError{Word: batch[i]}
+			}
+			if err := writeRow(emb); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return words, bw.Flush()
+}
+
+// ExportNpz writes the embeddings of the words as a NumPy .npz archive,
+// as written by numpy.savez, of two arrays: "vectors", the float32
+// matrix written by ExportNpy, and "words", the words of its rows. With
+// nil words, it writes the whole vocabulary.
+func (ft *FastText) ExportNpz(w io.Writer, words []string) error {
+	zw := zip.NewWriter(w)
+	entry, err := zw.CreateHeader(&zip.FileHeader{Name: "vectors.npy", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if words, err = ft.exportNpy(entry, words); err != nil {
+		return err
+	}
+	entry, err = zw.CreateHeader(&zip.FileHeader{Name: "words.npy", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if err := writeNpyStrings(entry, words); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeNpyStrings writes the strings as a NumPy array of fixed-length
+// unicode strings, which numpy.load reads without pickle.
+func writeNpyStrings(w io.Writer, strs []string) error {
+	width := 1
+	for _, s := range strs {
+		if n := utf8.RuneCountInString(s); n > width {
+			width = n
+		}
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeNpyHeader(bw, "<U"+strconv.Itoa(width), len(strs), 0); err != nil {
+		return err
+	}
+	buf := make([]byte, 4*width)
+	for _, s := range strs {
+		for i := range buf {
+			buf[i] = 0
+		}
+		i := 0
+		for _, r := range s {
+			binary.LittleEndian.PutUint32(buf[4*i:], uint32(r))
+			i++
+		}
+		if _, err := bw.Write(buf); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// writeNpyHeader writes the header of a version 1.0 .npy file of a
+// matrix of the given NumPy type, or of a vector if cols is 0.
+func writeNpyHeader(w io.Writer, descr string, rows, cols int) error {
+	shape := fmt.Sprintf("(%d,)", rows)
+	if cols > 0 {
+		shape = fmt.Sprintf("(%d, %d)", rows, cols)
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': %s, }", descr, shape)
+	// The data is aligned on 64 bytes, after the magic, the version,
+	// the header length and the header ending with a newline.
+	prefix := len(npyMagic) + 2 + 2
+	pad := 63 - (prefix+len(header))%64
+	header += strings.Repeat(" ", pad) + "\n"
+	if len(header) > math.MaxUint16 {
+		return errors.New("fasttext: .npy header too long")
+	}
+	var buf []byte
+	buf = append(buf, npyMagic...)
+	buf = append(buf, 1, 0)
+	buf = append(buf, byte(len(header)), byte(len(header)>>8))
+	buf = append(buf, header...)
+	_, err := w.Write(buf)
+	return err
+}
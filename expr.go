@@ -0,0 +1,257 @@
+package fasttext
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Eval evaluates an arithmetic expression over word embeddings and
+// returns the resulting vector, e.g.
+//
+//	vec, err := ft.Eval("king - man + woman")
+//	vec, err := ft.Eval(`0.5 * (paris + "new york") - 2 * france / 3`)
+//
+// Words are looked up with GetEmb; words containing spaces or operator
+// characters can be double-quoted. Vectors can be added and subtracted,
+// and multiplied or divided by numbers.
+func (ft *FastText) Eval(expr string) ([]float32, error) {
+	vec, _, err := ft.eval(expr)
+	return vec, err
+}
+
+// EvalNearest evaluates the expression like Eval and returns the k
+// words nearest to the result, excluding the words of the expression.
+func (ft *FastText) EvalNearest(expr string, k int) ([]ScoredWord, error) {
+	vec, words, err := ft.eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	return ft.nearest(context.Background(), vec, k, ft.excludeWords(words...), nil)
+}
+
+func (ft *FastText) eval(expr string) ([]float32, []string, error) {
+	tokens, err := lexExpr(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &exprParser{ft: ft, tokens: tokens}
+	v, err := p.parseExpr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, nil, fmt.Errorf("fasttext: unexpected %q in expression", p.tokens[p.pos].text)
+	}
+	if v.vec == nil {
+		return nil, nil, fmt.Errorf("fasttext: expression %q is a number, not a vector", expr)
+	}
+	out := make([]float32, len(v.vec))
+	for i, x := range v.vec {
+		out[i] = float32(x)
+	}
+	return out, p.words, nil
+}
+
+type exprTokenKind int
+
+const (
+	tokNumber exprTokenKind = iota
+	tokWord
+	tokOp
+)
+
+type exprToken struct {
+	kind exprTokenKind
+	text string
+	num  float64
+}
+
+func lexExpr(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("+-*/()", r):
+			tokens = append(tokens, exprToken{kind: tokOp, text: string(r)})
+			i++
+		case r == '"':
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("fasttext: unterminated quote in expression %q", expr)
+			}
+			tokens = append(tokens, exprToken{kind: tokWord, text: string(runes[i+1 : end])})
+			i = end + 1
+		default:
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) &&
+				!strings.ContainsRune("+-*/()\"", runes[end]) {
+				end++
+			}
+			text := string(runes[i:end])
+			if num, err := strconv.ParseFloat(text, 64); err == nil && (unicode.IsDigit(r) || r == '.') {
+				tokens = append(tokens, exprToken{kind: tokNumber, text: text, num: num})
+			} else {
+				tokens = append(tokens, exprToken{kind: tokWord, text: text})
+			}
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+// exprValue is either a number (vec is nil) or a vector.
+type exprValue struct {
+	num float64
+	vec []float64
+}
+
+// exprParser is a recursive descent parser evaluating the grammar:
+//
+//	expr    = term { ("+" | "-") term }
+//	term    = unary { ("*" | "/") unary }
+//	unary   = "-" unary | primary
+//	primary = number | word | "(" expr ")"
+type exprParser struct {
+	ft     *FastText
+	tokens []exprToken
+	pos    int
+	words  []string
+}
+
+func (p *exprParser) peekOp(ops string) (string, bool) {
+	if p.pos < len(p.tokens) && p.tokens[p.pos].kind == tokOp &&
+		strings.Contains(ops, p.tokens[p.pos].text) {
+		return p.tokens[p.pos].text, true
+	}
+	return "", false
+}
+
+func (p *exprParser) parseExpr() (exprValue, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return left, err
+	}
+	for {
+		op, ok := p.peekOp("+-")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseTerm()
+		if err != nil {
+			return right, err
+		}
+		sign := 1.0
+		if op == "-" {
+			sign = -1
+		}
+		if left.vec == nil && right.vec == nil {
+			left.num += sign * right.num
+			continue
+		}
+		if left.vec == nil || right.vec == nil {
+			return left, fmt.Errorf("fasttext: cannot %s a number and a vector", op)
+		}
+		if len(left.vec) != len(right.vec) {
+			return left, fmt.Errorf("fasttext: vectors of different lengths")
+		}
+		sum := make([]float64, len(left.vec))
+		for i := range sum {
+			sum[i] = left.vec[i] + sign*right.vec[i]
+		}
+		left = exprValue{vec: sum}
+	}
+}
+
+func (p *exprParser) parseTerm() (exprValue, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return left, err
+	}
+	for {
+		op, ok := p.peekOp("*/")
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return right, err
+		}
+		if right.vec != nil && (op == "/" || left.vec != nil) {
+			return left, fmt.Errorf("fasttext: cannot %s by a vector", op)
+		}
+		if right.vec != nil {
+			left, right = right, left
+		}
+		factor := right.num
+		if op == "/" {
+			factor = 1 / factor
+		}
+		left = left.scale(factor)
+	}
+}
+
+func (p *exprParser) parseUnary() (exprValue, error) {
+	if _, ok := p.peekOp("-"); ok {
+		p.pos++
+		v, err := p.parseUnary()
+		return v.scale(-1), err
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprValue, error) {
+	if p.pos >= len(p.tokens) {
+		return exprValue{}, fmt.Errorf("fasttext: unexpected end of expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case tokNumber:
+		return exprValue{num: tok.num}, nil
+	case tokWord:
+		emb, err := p.ft.GetEmb(tok.text)
+		if err != nil {
+			return exprValue{}, fmt.Errorf("fasttext: %q: %w", tok.text, err)
+		}
+		p.words = append(p.words, tok.text)
+		vec := make([]float64, len(emb))
+		for i, x := range emb {
+			vec[i] = float64(x)
+		}
+		return exprValue{vec: vec}, nil
+	}
+	if tok.text != "(" {
+		return exprValue{}, fmt.Errorf("fasttext: unexpected %q in expression", tok.text)
+	}
+	v, err := p.parseExpr()
+	if err != nil {
+		return v, err
+	}
+	if _, ok := p.peekOp(")"); !ok {
+		return v, fmt.Errorf("fasttext: missing closing parenthesis in expression")
+	}
+	p.pos++
+	return v, nil
+}
+
+func (v exprValue) scale(factor float64) exprValue {
+	if v.vec == nil {
+		return exprValue{num: v.num * factor}
+	}
+	out := make([]float64, len(v.vec))
+	for i, x := range v.vec {
+		out[i] = x * factor
+	}
+	return exprValue{vec: out}
+}
@@ -41,32 +41,48 @@ For faster querying during runtime, you can use an in-memory database.
 This creates an in-memory SQLite3 database which is a copy of the
 on-disk one. Using the in-memory version makes query time much faster,
 but takes a few minutes to load the database.
+
+If most look-ups hit a small set of frequent words, an in-process
+LRU cache gives most of the speed-up without loading the whole database:
+
+	ft := NewFastText("/path/to/sqlite3/file", WithCache(10000))
 */
 package fasttext
 
 import (
 	"bufio"
+	"context"
 	"database/sql"
+	"database/sql/driver"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
-	// TableName used in SQLite3
+	// TableName used in SQLite3, unless set with WithTableName
 	TableName = "fasttext"
 	// Dim is the number of dimensions in FastText word embedding vectors
 	Dim = 300
 )
 
 var (
-	// ErrNoEmbFound ...
+	// ErrNoEmbFound is matched by the errors of look-ups of words that
+	// are not in the vocabulary, see WordNotFoundError.
 	ErrNoEmbFound = errors.New("No embedding found for the given word")
-	// ByteOrder is for the serialization of the embedding vector in
-	// SQLite3 database.
+	// ByteOrder is the default byte order of the vectors of BuildDB.
+	//
+	// Deprecated: the byte order of a database is recorded in its
+	// metadata at build time, and detected from the vectors of databases
+	// built before, so sessions on databases of different byte orders
+	// decode each correctly. Use WithByteOrder to choose it at build time.
 	ByteOrder = binary.BigEndian
 )
 
@@ -75,19 +91,124 @@ var (
 // FastText session. A single FastText session cannot be shared
 // among multiple threads.
 type FastText struct {
-	db *sql.DB
+	db       *sql.DB
+	cache    *lruCache
+	degraded *degradedState
+	preload  *preloadState
+
+	// embStmt is the prepared look-up of an embedding, see getEmbStmt.
+	stmtMu  sync.Mutex
+	embStmt *sql.Stmt
+
+	formatMu sync.Mutex
+	format   *vecFormat
+
+	// src is the database of the connections, see Reload.
+	src      *dbSource
+	annMu    sync.Mutex
+	ann      *hnsw
+	efSearch int
+
+	memBudget int
+
+	readOnly  bool
+	immutable bool
+	// replicas are the copies of the database file, see WithReplicas.
+	replicas []string
+	// localCopy is set by WithLocalCopy.
+	localCopy *localCopy
+
+	langDetector LangDetector
+	langPriority []string
+
+	normalizers  []func(string) string
+	tokenizer    Tokenizer
+	projection   *linearProjection
+	oovPolicy    OOVPolicy
+	metrics      Metrics
+	logger       Logger
+	tracer       Tracer
+	slowQuery    time.Duration
+	queryTimeout time.Duration
+	nnCache      bool
+
+	// pragmas are set on each connection, see WithPragma.
+	pragmas []string
+
+	table      string
+	rankCol    string
+	freqStats  *freqStats
+	negSampler *NegativeSampler
+	driverName string
+	// openWith opens the database, see WithOpenDB.
+	openWith func(driver.Connector) *sql.DB
+	// maxIdleConns bounds the idle connections of the pool, see
+	// WithMaxIdleConns.
+	maxIdleConns int
+	// sharedDB is set if the database is owned by the caller.
+	sharedDB bool
 }
 
+// Option configures a FastText session.
+type Option func(*FastText)
+
+// WithCache enables an in-process LRU cache holding the embeddings
+// of up to size most recently looked-up words, so repeated look-ups
+// skip the SQLite3 database.
+func WithCache(size int) Option {
+	return func(ft *FastText) {
+		if size > 0 {
+			ft.cache = newLRUCache(size)
+		}
+	}
+}
+
+// openFastText starts a session on the SQLite3 database given by dsn,
+// stored in the file path if not in memory.
+func openFastText(dsn, path string, opts []Option) *FastText {
+	ft := &FastText{table: TableName, maxIdleConns: defaultMaxIdleConns}
+	for _, opt := range opts {
+		opt(ft)
+	}
+	if dsn == path && path != "" && ft.localCopy != nil {
+		local, err := ft.localCopy.copyLocal(path)
+		if err != nil {
+			panic(err)
+		}
+		ft.logInfo("fasttext: copied the database", "path", path, "copy", local)
+		path, dsn = local, local
+	}
+	if dsn == path {
+		dsn = ft.fileDSN(path)
+	}
+	ft.src = newDBSource(path, dsn)
+	if len(ft.replicas) > 0 && path != "" && dsn == ft.fileDSN(path) {
+		ft.addReplicas()
+	}
+	ft.db = ft.openDB()
+	return ft
+}
+
+// memDBCount numbers the private in-memory databases opened by NewFastText.
+var memDBCount int64
+
 // NewFastText starts a new FastText session given the location
 // of the SQLite3 database file.
-func NewFastText(dbFilename string) *FastText {
-	db, err := sql.Open("sqlite3", dbFilename)
-	if err != nil {
-		panic(err)
-	}
-	return &FastText{
-		db: db,
+// The special file name ":memory:" creates a private in-memory database
+// that is shared by all connections of this session.
+func NewFastText(dbFilename string, opts ...Option) *FastText {
+	if dbFilename == ":memory:" {
+		// Every new connection to ":memory:" would otherwise see its own
+		// empty database, which breaks concurrent scans.
+		return openFastText(memDSN(), "", opts)
 	}
+	return openFastText(dbFilename, dbFilename, opts)
+}
+
+// memDSN returns the name of a new private in-memory database.
+func memDSN() string {
+	return fmt.Sprintf("file:fasttext-mem-%d?mode=memory&cache=shared",
+		atomic.AddInt64(&memDBCount, 1))
 }
 
 // NewFastTextInMem creates a new FastText session that uses
@@ -95,123 +216,471 @@ func NewFastText(dbFilename string) *FastText {
 // The on-disk SQLite3 database (given by dbFilename) will be loaded into
 // an in-memory SQLite3 database in this function, which
 // will take a few miniutes to finish.
-func NewFastTextInMem(dbFilename string) *FastText {
-	db, err := sql.Open("sqlite3", "file::memory:?cache=shared")
-	_, err = db.Exec(fmt.Sprintf(`ATTACH DATABASE '%s' AS disk;`, dbFilename))
+func NewFastTextInMem(dbFilename string, opts ...Option) *FastText {
+	ft := openFastText(memDSN(), dbFilename, opts)
+	_, err := ft.db.Exec(`ATTACH DATABASE ? AS disk;`, ft.fileDSN(dbFilename))
 	if err != nil {
 		panic(err)
 	}
-	_, err = db.Exec(`CREATE TABLE fasttext AS SELECT * FROM disk.fasttext;`)
-	if err != nil {
+	if err := ft.copyFromDisk(); err != nil {
 		panic(err)
 	}
-	return &FastText{
-		db: db,
+	return ft
+}
+
+// copyFromDisk copies the tables and indexes of the attached disk
+// database, e.g. the metadata along with the embeddings.
+func (ft *FastText) copyFromDisk() error {
+	rows, err := ft.db.Query(`SELECT type, name, sql FROM disk.sqlite_master
+		WHERE sql IS NOT NULL AND type IN ('table', 'index') AND name NOT LIKE 'sqlite_%'
+		ORDER BY type = 'index';`)
+	if err != nil {
+		return err
 	}
+	var tables, indexes []string
+	var creates []string
+	for rows.Next() {
+		var typ, name, create string
+		if err := rows.Scan(&typ, &name, &create); err != nil {
+			rows.Close()
+			return err
+		}
+		if typ == "table" {
+			tables = append(tables, name)
+			creates = append(creates, create)
+		} else {
+			indexes = append(indexes, create)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for i, table := range tables {
+		if _, err := ft.db.Exec(creates[i]); err != nil {
+			return err
+		}
+		if _, err := ft.db.Exec(fmt.Sprintf(`INSERT INTO main."%s" SELECT * FROM disk."%s";`,
+			table, table)); err != nil {
+			return err
+		}
+	}
+	// Indexes are faster to create once the tables are filled.
+	for _, create := range indexes {
+		if _, err := ft.db.Exec(create); err != nil {
+			return err
+		}
+	}
+	return ft.copySequences()
+}
+
+// copySequences copies the AUTOINCREMENT counters of the attached disk
+// database, so that the IDs of words deleted from it are not reused.
+func (ft *FastText) copySequences() error {
+	var n int
+	err := ft.db.QueryRow(`SELECT COUNT(*) FROM disk.sqlite_master WHERE name = 'sqlite_sequence';`).Scan(&n)
+	if err != nil || n == 0 {
+		return err
+	}
+	if _, err := ft.db.Exec(`DELETE FROM main.sqlite_sequence;`); err != nil {
+		return err
+	}
+	_, err = ft.db.Exec(`INSERT INTO main.sqlite_sequence SELECT * FROM disk.sqlite_sequence;`)
+	return err
 }
 
 // Close must be called before finishing using this FastText
 // session.
 func (ft *FastText) Close() error {
-	return ft.db.Close()
+	ft.stmtMu.Lock()
+	if ft.embStmt != nil {
+		ft.embStmt.Close()
+		ft.embStmt = nil
+	}
+	ft.stmtMu.Unlock()
+	if ft.sharedDB {
+		return nil
+	}
+	err := ft.db.Close()
+	if ft.localCopy != nil && ft.localCopy.tmp != "" {
+		if rmErr := os.RemoveAll(ft.localCopy.tmp); err == nil {
+			err = rmErr
+		}
+	}
+	return err
 }
 
-// GetEmb returns the word embedding of the given word.
+// GetEmb returns the word embedding of the given word. The result is
+// always a float32 vector, whatever the stored precision: the vectors
+// stored as float64 are narrowed to float32 on read.
 func (ft *FastText) GetEmb(word string) ([]float32, error) {
+	return ft.GetEmbContext(context.Background(), word)
+}
+
+// GetEmbContext is GetEmb with a context, which cancels the look-up and
+// is the parent of its span, see WithTracer.
+func (ft *FastText) GetEmbContext(ctx context.Context, word string) (vec []float32, err error) {
+	ctx, end := ft.startSpan(ctx, "fasttext.GetEmb", "words", 1)
+	defer func() { end(err) }()
+	return ft.getEmb(ctx, word, nil)
+}
+
+// getEmb looks up the embedding of the word, resolving it with the OOV
+// policy if it is missing, and recording how it was found in exp if it
+// is not nil.
+func (ft *FastText) getEmb(ctx context.Context, word string, exp *Explanation) ([]float32, error) {
+	start := time.Now()
+	vec, source, err := ft.lookupEmb(ctx, word, exp)
+	if ft.observing() {
+		defer ft.observeLookup(start, word, source, err)
+	}
+	if ft.oovPolicy == nil || !errors.Is(err, ErrNoEmbFound) {
+		return vec, err
+	}
+	vec, err = ft.resolveOOV(word)
+	if err == nil {
+		exp.step("%q resolved by the OOV policy", word)
+		exp.setSource(SourceOOV)
+	}
+	return vec, err
+}
+
+// lookupEmb looks up the embedding of the word in the vocabulary,
+// without the OOV policy, and returns where it was found.
+func (ft *FastText) lookupEmb(ctx context.Context, word string, exp *Explanation) ([]float32, string, error) {
+	word = ft.normalize(word)
+	if vec, ok, err := ft.preloaded(word); err != nil {
+		return nil, "", err
+	} else if ok {
+		exp.step("%q preloaded in memory", word)
+		exp.setSource(SourcePreload)
+		return vec, SourcePreload, nil
+	}
+	if ft.cache != nil {
+		if vec, ok := ft.cache.get(word); ok {
+			exp.step("cache hit for %q", word)
+			exp.setSource(SourceCache)
+			return vec, SourceCache, nil
+		}
+		exp.step("cache miss for %q", word)
+	}
+	if exp != nil {
+		exp.QueryPlan = ft.queryPlan(ft.sql(`SELECT emb FROM fasttext WHERE word=?;`), word)
+	}
 	var binVec []byte
-	err := ft.db.QueryRow(`SELECT emb FROM fasttext WHERE word=?;`, word).Scan(&binVec)
+	ctx, cancel := ft.queryContext(ctx)
+	defer cancel()
+	stmt, err := ft.getEmbStmt()
+	if err == nil {
+		err = ft.timedOut(ctx, stmt.QueryRowContext(ctx, word).Scan(&binVec))
+	}
 	if err == sql.ErrNoRows {
-		return nil, ErrNoEmbFound
+		exp.step("%q not found in database", word)
+		return nil, "", &WordNotFoundError{Word: word}
 	}
 	if err != nil {
-		panic(err)
+		if ft.absorb(err) {
+			exp.step("database error for %q, serving hashed vector: %v", word, err)
+			exp.setSource(SourceHashed)
+			return ft.hashedFallback(word), SourceHashed, nil
+		}
+		return nil, "", err
+	}
+	exp.step("%q found in database", word)
+	exp.setSource(SourceDatabase)
+	vec, err := ft.decode(binVec)
+	if err != nil {
+		return nil, "", err
+	}
+	if ft.cache != nil {
+		ft.cache.add(word, vec)
 	}
-	return bytesToVec(binVec, ByteOrder)
+	return vec, SourceDatabase, nil
 }
 
-// BuildDB initializes the SQLite3 database by importing the word embeddings
-// from the .vec file downloaded from
-// https://fasttext.cc/docs/en/crawl-vectors.html
-func (ft *FastText) BuildDB(wordEmbFile io.Reader) error {
-	_, err := ft.db.Exec(`
-	CREATE TABLE fasttext(
-		word TEXT UNIQUE,
-		emb BLOB
-	);`)
+// getEmbStmt returns the look-up of an embedding, prepared on first use
+// and reused by the following look-ups.
+func (ft *FastText) getEmbStmt() (*sql.Stmt, error) {
+	ft.stmtMu.Lock()
+	defer ft.stmtMu.Unlock()
+	if ft.embStmt == nil {
+		stmt, err := ft.db.Prepare(ft.sql(`SELECT emb FROM fasttext WHERE word=?;`))
+		if err != nil {
+			return nil, err
+		}
+		ft.embStmt = stmt
+	}
+	return ft.embStmt, nil
+}
+
+// GetEmbInto decodes the word embedding of the given word into dst,
+// which must have the dimension of the vectors, without allocating a
+// new vector. Reusing dst keeps the hot path of token-level pipelines
+// free of most allocations, except for compressed vectors.
+func (ft *FastText) GetEmbInto(word string, dst []float32) error {
+	start := time.Now()
+	source, err := ft.getEmbInto(word, dst)
+	if ft.observing() {
+		ft.observeLookup(start, word, source, err)
+	}
+	return err
+}
+
+// getEmbInto is GetEmbInto, also returning where the embedding was
+// found.
+func (ft *FastText) getEmbInto(word string, dst []float32) (string, error) {
+	f, err := ft.vecFormat()
 	if err != nil {
-		return err
+		return "", err
+	}
+	if f.dim != 0 && len(dst) != f.dim {
+		return "", fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(dst), f.dim)
+	}
+	input := word
+	word = ft.normalize(word)
+	if vec, ok, err := ft.preloaded(word); err != nil {
+		return "", err
+	} else if ok {
+		copy(dst, vec)
+		return SourcePreload, nil
+	}
+	if ft.cache != nil {
+		if vec, ok := ft.cache.get(word); ok {
+			copy(dst, vec)
+			return SourceCache, nil
+		}
+	}
+	var rows *sql.Rows
+	ctx, cancel := ft.queryContext(context.Background())
+	defer cancel()
+	stmt, err := ft.getEmbStmt()
+	if err == nil {
+		rows, err = stmt.QueryContext(ctx, word)
+		err = ft.timedOut(ctx, err)
 	}
-	tx, err := ft.db.Begin()
 	if err != nil {
-		return err
+		if ft.absorb(err) {
+			copy(dst, ft.hashedFallback(word))
+			return SourceHashed, nil
+		}
+		return "", err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", ft.timedOut(ctx, err)
+		}
+		if ft.oovPolicy == nil {
+			return "", &WordNotFoundError{Word: word}
+		}
+		vec, err := ft.resolveOOV(input)
+		if err != nil {
+			return "", err
+		}
+		copy(dst, vec)
+		return SourceOOV, nil
+	}
+	// The blob is decoded in place, before the next call to rows.Next.
+	var data sql.RawBytes
+	if err := rows.Scan(&data); err != nil {
+		return "", err
 	}
-	stmt, err := tx.Prepare(`INSERT INTO fasttext(word, emb) VALUES(?, ?);`)
+	return SourceDatabase, f.decodeInto(dst, data)
+}
+
+// Contains returns whether the given word is in the vocabulary, without
+// fetching and decoding its embedding.
+func (ft *FastText) Contains(word string) (bool, error) {
+	word = ft.normalize(word)
+	if _, ok, err := ft.preloaded(word); err != nil || ok {
+		return ok, err
+	}
+	if ft.cache != nil {
+		if _, ok := ft.cache.get(word); ok {
+			return true, nil
+		}
+	}
+	var one int
+	ctx, cancel := ft.queryContext(context.Background())
+	defer cancel()
+	err := ft.db.QueryRowContext(ctx, ft.sql(`SELECT 1 FROM fasttext WHERE word=?;`), word).Scan(&one)
+	err = ft.timedOut(ctx, err)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// BuildDB initializes the SQLite3 database by importing the word embeddings
+// from the .vec file downloaded from
+// https://fasttext.cc/docs/en/crawl-vectors.html
+// Gzip compressed and zipped files are decompressed transparently, and
+// word2vec binary files are detected and read as by BuildDBFromWord2Vec.
+// If a previous build of the database was interrupted, calling BuildDB
+// again with the same file resumes it.
+func (ft *FastText) BuildDB(wordEmbFile io.Reader, opts ...BuildOption) error {
+	cfg := ft.newBuildConfig(opts)
+	wordEmbFile, err := decompress(cfg.progress.reader(wordEmbFile))
 	if err != nil {
 		return err
 	}
-	defer stmt.Close()
-	for emb := range readwordEmbdFile(wordEmbFile) {
-		binVec := vecToBytes(emb.Vec, ByteOrder)
-		if _, err := stmt.Exec(emb.Word, binVec); err != nil {
-			return err
-		}
+	br := bufio.NewReaderSize(wordEmbFile, word2VecSniffSize)
+	if isWord2VecBinary(br) {
+		return ft.build(readWord2VecBinary(br, cfg.done), cfg)
 	}
-	tx.Commit()
-	return nil
+	return ft.build(readwordEmbdFile(br, &cfg.parse, cfg.done), cfg)
 }
 
 type wordEmb struct {
 	Word string
 	Vec  []float32
+	// Err, if set, stops the build with this error.
+	Err error
+	// bad, if set, is the problem of a line skipped, if Vec is nil, or
+	// repaired by the lenient parser.
+	bad *ParseError
+	// flushed, if set, marks a Flush of a Writer rather than a word:
+	// the build commits the words received so far and replies.
+	flushed chan error
 }
 
-func readwordEmbdFile(wordEmbFile io.Reader) chan *wordEmb {
+// readwordEmbdFile parses word embeddings in text format: fastText .vec
+// and word2vec files start with a "<vocabulary size> <dimension>"
+// header line, while GloVe files have no header at all. The format is
+// detected from the first line. The lines are split by one goroutine
+// and parsed in batches by the workers of the configuration, then sent
+// in order. Parsing stops when done is closed.
+func readwordEmbdFile(wordEmbFile io.Reader, cfg *parseConfig, done <-chan struct{}) chan *wordEmb {
+	workers := cfg.workers
+	if workers < 1 {
+		workers = 1
+	}
 	out := make(chan *wordEmb)
+	jobs := make(chan *parseBatch)
+	// pending holds the batches in input order, for the sender.
+	pending := make(chan *parseBatch, 2*workers)
 	go func() {
-		defer close(out)
+		defer close(jobs)
+		defer close(pending)
 		scanner := bufio.NewScanner(wordEmbFile)
 		var embSize int
 		var line int
+		batch := &parseBatch{first: 1}
+		flush := func() bool {
+			if len(batch.lines) == 0 {
+				return true
+			}
+			batch.embSize = embSize
+			batch.result = make(chan []*wordEmb, 1)
+			select {
+			case pending <- batch:
+			case <-done:
+				return false
+			}
+			select {
+			case jobs <- batch:
+			case <-done:
+				return false
+			}
+			batch = &parseBatch{first: line + 1}
+			return true
+		}
 		for scanner.Scan() {
+			if stopped(done) {
+				return
+			}
 			line++
 			data := scanner.Text()
+			if line == 1 {
+				if size, ok := parseHeader(data); ok {
+					embSize = size
+					batch.first = 2
+					continue
+				}
+			}
 			if embSize == 0 {
-				var err error
-				embSize, err = strconv.Atoi(strings.Split(data, " ")[1])
-				if err != nil {
-					panic(err)
+				// No header (GloVe) or no dimension in the header:
+				// the first vector gives the dimension.
+				if _, values, ok := cfg.split(data); ok {
+					embSize = len(values)
 				}
-				continue
 			}
-			// Get the word
-			items := strings.SplitN(data, " ", 2)
-			word := items[0]
-			if word == "" {
-				word = " "
+			batch.lines = append(batch.lines, data)
+			if len(batch.lines) == parseBatchLines && !flush() {
+				return
 			}
-			// Get the vec
-			vecStrs := strings.Split(strings.TrimSpace(items[1]), " ")
-			if len(vecStrs) != embSize {
-				msg := fmt.Sprintf("Embedding vec size not same: expected %d, got %d. Loc: line %d, word %s",
-					embSize, len(vecStrs), line, word)
-				panic(msg)
+		}
+		if !flush() {
+			return
+		}
+		if err := scanner.Err(); err != nil {
+			// E.g. a failed download, which must not crash the program.
+			batch.err = err
+			batch.result = make(chan []*wordEmb, 1)
+			batch.result <- nil
+			select {
+			case pending <- batch:
+			case <-done:
 			}
-			vec := make([]float32, embSize)
-			for i := 0; i < embSize; i++ {
-				sf, err := strconv.ParseFloat(vecStrs[i], 32)
+		}
+	}()
+	for i := 0; i < workers; i++ {
+		go func() {
+			for batch := range jobs {
+				embs, err := batch.parse(cfg)
 				if err != nil {
-					panic(err)
+					embs = append(embs, &wordEmb{Err: err})
 				}
-				vec[i] = float32(sf)
+				batch.result <- embs
 			}
-			out <- &wordEmb{
-				Word: word,
-				Vec:  vec,
+		}()
+	}
+	go func() {
+		defer close(out)
+		for batch := range pending {
+			var embs []*wordEmb
+			select {
+			case embs = <-batch.result:
+			case <-done:
+				return
+			}
+			for _, emb := range embs {
+				if emb.bad != nil {
+					cfg.report(emb.bad)
+					if emb.Vec == nil {
+						continue
+					}
+				}
+				if !send(out, emb, done) {
+					return
+				}
+			}
+			if batch.err != nil {
+				send(out, &wordEmb{Err: batch.err}, done)
+				return
 			}
-		}
-		if err := scanner.Err(); err != nil {
-			panic(err)
 		}
 	}()
 	return out
 }
+
+// parseHeader returns whether the line is a header made of one or two
+// integers, and the dimension it gives (0 if unknown).
+func parseHeader(data string) (int, bool) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 || len(fields) > 2 {
+		return 0, false
+	}
+	nums := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return 0, false
+		}
+		nums[i] = n
+	}
+	if len(nums) == 1 {
+		return 0, true
+	}
+	return nums[1], true
+}
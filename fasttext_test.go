@@ -1,8 +1,35 @@
 package fasttext
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+	"unicode"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -33,9 +60,4942 @@ func Test_BuildDB_and_GetEmb(t *testing.T) {
 	notExist := []string{"NotExist1", "Happiness"}
 	for _, word := range notExist {
 		_, err := ft.GetEmb(word)
-		if err != ErrNoEmbFound {
+		if !errors.Is(err, ErrNoEmbFound) {
 			t.Error("Should return not found")
 		}
 	}
 }
 
+func Test_Contains(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	for word, want := range map[string]bool{"has": true, "#": true, "NotExist1": false} {
+		got, err := ft.Contains(word)
+		if err != nil {
+			t.Error(err)
+		}
+		if got != want {
+			t.Errorf("Contains(%q): expected %v, got %v", word, want, got)
+		}
+	}
+}
+
+// newTestFastText returns an in-memory session built from the test data.
+func newTestFastText(t *testing.T, opts ...Option) *FastText {
+	ft := NewFastText(":memory:", opts...)
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file); err != nil {
+		t.Fatal(err)
+	}
+	return ft
+}
+
+func Test_GetEmb_WithCache(t *testing.T) {
+	ft := newTestFastText(t, WithCache(2))
+	defer ft.Close()
+
+	for _, word := range []string{"has", "but", "has", "page"} {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Error(err)
+		}
+		if len(emb) != Dim {
+			t.Errorf("Wrong embedding size for %s: %d", word, len(emb))
+		}
+	}
+	if n := ft.cache.len(); n != 2 {
+		t.Errorf("Cache should hold 2 words, got %d", n)
+	}
+	if _, ok := ft.cache.get("but"); ok {
+		t.Error("Least recently used word should have been evicted")
+	}
+	if _, ok := ft.cache.get("has"); !ok {
+		t.Error("Recently used word should be cached")
+	}
+}
+
+func Test_NearestNeighbors(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	nn, err := ft.NearestNeighbors("has", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 5 {
+		t.Fatalf("Expected 5 neighbors, got %d", len(nn))
+	}
+	for i, s := range nn {
+		if s.Word == "has" {
+			t.Error("Query word should be excluded")
+		}
+		if i > 0 && s.Score > nn[i-1].Score {
+			t.Error("Neighbors should be sorted by descending score")
+		}
+	}
+	t.Log(nn)
+
+	if _, err := ft.NearestNeighbors("NotExist1", 5); !errors.Is(err, ErrNoEmbFound) {
+		t.Error("Should return not found")
+	}
+}
+
+func Test_Iter(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	it, err := ft.Iter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var count int
+	for it.Next() {
+		if len(it.Emb()) != Dim {
+			t.Errorf("Wrong embedding size for %s: %d", it.Word(), len(it.Emb()))
+		}
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Error(err)
+	}
+	if count != 49 {
+		t.Errorf("Expected 49 words, got %d", count)
+	}
+}
+
+func Test_TopK(t *testing.T) {
+	top := NewTopK(3)
+	for i, w := range []string{"a", "b", "c", "d", "e"} {
+		top.Push(ScoredWord{Word: w, Score: float64(i % 4)})
+	}
+	got := top.Sorted()
+	want := []string{"d", "c", "b"}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d words, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Word != want[i] {
+			t.Errorf("Position %d: expected %s, got %s", i, want[i], got[i].Word)
+		}
+	}
+	if min, _ := top.Min(); min.Word != "b" {
+		t.Errorf("Expected min b, got %s", min.Word)
+	}
+	if top.mayKeep(0.5) || !top.mayKeep(1) || !NewTopK(1).mayKeep(-1) || NewTopK(0).mayKeep(1) {
+		t.Error("Unexpected candidates of a full TopK")
+	}
+}
+
+func Test_NearestNeighborsPage(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	all, err := ft.NearestNeighbors("has", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err := ft.NearestNeighborsPage("has", 5, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := ft.NearestNeighborsAfter("has", all[4], 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if page[i] != all[5+i] {
+			t.Errorf("Page position %d: expected %v, got %v", i, all[5+i], page[i])
+		}
+		if next[i] != all[5+i] {
+			t.Errorf("Cursor position %d: expected %v, got %v", i, all[5+i], next[i])
+		}
+	}
+}
+
+func Test_Similarity(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	self, err := ft.Similarity("has", "has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(self-1) > 1e-6 {
+		t.Errorf("Self similarity should be 1, got %f", self)
+	}
+	nn, err := ft.NearestNeighbors("has", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim, err := ft.Similarity("has", nn[0].Word)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(sim-nn[0].Score) > 1e-9 {
+		t.Errorf("Expected similarity %f, got %f", nn[0].Score, sim)
+	}
+	if _, err := ft.Similarity("has", "NotExist1"); !errors.Is(err, ErrNoEmbFound) {
+		t.Error("Should return not found")
+	}
+	if d := CosineDistance([]float32{1, 0}, []float32{0, 1}); d != 1 {
+		t.Errorf("Orthogonal vectors should have distance 1, got %f", d)
+	}
+}
+
+func Test_Explain(t *testing.T) {
+	ft := newTestFastText(t, WithCache(10))
+	defer ft.Close()
+
+	_, exp, err := ft.GetEmbExplain("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Source != SourceDatabase {
+		t.Errorf("Expected source %s, got %s", SourceDatabase, exp.Source)
+	}
+	if !strings.Contains(exp.QueryPlan, "INDEX") {
+		t.Errorf("Look-up should use the word index, got plan %q", exp.QueryPlan)
+	}
+	nn, exp, err := ft.NearestNeighborsExplain("has", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 3 {
+		t.Errorf("Expected 3 neighbors, got %d", len(nn))
+	}
+	if exp.Source != SourceCache || exp.Method != MethodExact || exp.Candidates != 49 {
+		t.Errorf("Unexpected explanation:\n%s", exp)
+	}
+	t.Log(exp)
+}
+
+func Test_Analogy(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	res, err := ft.Analogy("is", "has", "was", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 3 {
+		t.Fatalf("Expected 3 results, got %d", len(res))
+	}
+	for _, s := range res {
+		if s.Word == "is" || s.Word == "has" || s.Word == "was" {
+			t.Errorf("Query word %s should be excluded", s.Word)
+		}
+	}
+	t.Log(res)
+
+	// Zero vectors are left out instead of making the scores NaN.
+	zero := newTestFastText(t, WithOOVPolicy(OOVZeroVector()))
+	defer zero.Close()
+	res, err = zero.Analogy("is", "has", "not-a-word", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range res {
+		if math.IsNaN(s.Score) {
+			t.Errorf("Unexpected NaN score for %s", s.Word)
+		}
+	}
+}
+
+func Test_DegradedMode(t *testing.T) {
+	ft := newTestFastText(t, WithCache(10), WithDegradedMode())
+	cached, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make the database unavailable.
+	ft.db.Close()
+
+	emb, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CosineSimilarity(emb, cached) < 0.999 {
+		t.Error("Should serve the cached embedding")
+	}
+	h1, err := ft.GetEmb("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h2 := HashedVector("page", Dim)
+	if CosineSimilarity(h1, h2) < 0.999 {
+		t.Error("Should serve the hashed vector")
+	}
+	stats := ft.DegradedStats()
+	if stats.DBErrors != 1 || stats.HashedVectors != 1 || stats.LastError == nil {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+	if ok, err := ft.Contains("page"); ok || err == nil {
+		t.Errorf("Expected the database error from Contains, got %v, %v", ok, err)
+	}
+
+	// Cancelled queries are not absorbed.
+	live := newTestFastText(t, WithDegradedMode())
+	defer live.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := live.GetEmbContext(ctx, "page"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if stats := live.DegradedStats(); stats.DBErrors != 0 || stats.HashedVectors != 0 {
+		t.Errorf("Expected no absorbed error, got %+v", stats)
+	}
+
+	// The hashed vectors have the dimension of the stored vectors.
+	small := NewFastText(":memory:", WithDegradedMode())
+	if err := small.BuildDB(strings.NewReader("2 2\na 1 0\nb 0 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := small.GetEmb("a"); err != nil {
+		t.Fatal(err)
+	}
+	small.db.Close()
+	if emb, err := small.GetEmb("c"); err != nil || len(emb) != 2 {
+		t.Errorf("Expected a hashed vector of dimension 2, got %d, %v", len(emb), err)
+	}
+}
+
+func Test_GetEmbs(t *testing.T) {
+	ft := newTestFastText(t, WithCache(1))
+	defer ft.Close()
+
+	words := []string{"has", "NotExist1", "but", "has"}
+	embs, err := ft.GetEmbs(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, word := range words {
+		if word == "NotExist1" {
+			if embs[i] != nil {
+				t.Error("Missing word should have a nil embedding")
+			}
+			continue
+		}
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if CosineSimilarity(emb, embs[i]) < 0.999 {
+			t.Errorf("Wrong embedding for %s", word)
+		}
+	}
+}
+
+func Test_GetSentenceEmb(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	tokens := []string{"has", "but", "NotExist1"}
+	avg, err := ft.GetSentenceEmb(tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, _ := ft.GetEmb("has")
+	but, _ := ft.GetEmb("but")
+	for i := range avg {
+		if math.Abs(float64(avg[i])-(float64(has[i])+float64(but[i]))/2) > 1e-6 {
+			t.Fatalf("Wrong average at dimension %d", i)
+		}
+	}
+	unit, err := ft.GetSentenceEmb(tokens, WithL2Normalize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(l2norm(unit)-1) > 1e-6 {
+		t.Errorf("Expected unit length, got %f", l2norm(unit))
+	}
+	if _, err := ft.GetSentenceEmb(tokens, WithSentenceOOV(OOVFail)); !errors.Is(err, ErrNoEmbFound) {
+		t.Error("Should fail on out-of-vocabulary token")
+	}
+	if _, err := ft.GetSentenceEmb([]string{"NotExist1"}); err != ErrAllOOV {
+		t.Error("Should fail when all tokens are out of vocabulary")
+	}
+	if _, err := ft.GetSentenceEmb(tokens, WithSentenceOOVPolicy(OOVError())); !errors.Is(err, ErrNoEmbFound) {
+		t.Error("Should fail on out-of-vocabulary token with OOVError")
+	}
+	zero, err := ft.GetSentenceEmb(tokens, WithSentenceOOVPolicy(OOVZeroVector()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range zero {
+		if math.Abs(float64(zero[i])-(float64(has[i])+float64(but[i]))/3) > 1e-6 {
+			t.Fatalf("Wrong average with a zero vector at dimension %d", i)
+		}
+	}
+
+	// The policy of the session applies first.
+	skip := newTestFastText(t, WithOOVPolicy(OOVSkipWord()))
+	defer skip.Close()
+	if _, err := skip.GetEmb("NotExist1"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected OOVSkipWord to report a missing word, got %v", err)
+	}
+	zeros := newTestFastText(t, WithOOVPolicy(OOVZeroVector()))
+	defer zeros.Close()
+	if _, err := zeros.GetSentenceEmb(tokens, WithSentenceOOVPolicy(OOVError())); err != nil {
+		t.Errorf("Expected the token resolved by the session, got %v", err)
+	}
+}
+
+func Test_CasingStats(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+
+	vec := " 0.1 0.2\n"
+	data := "5 2\nParis" + vec + "paris" + vec + "PARIS" + vec + "café" + vec + "cafe" + vec
+	var report bytes.Buffer
+	if err := ft.BuildDB(strings.NewReader(data), WithCasingStats(&report)); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ft.CasingStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CasingStats{Words: 5, CaseGroups: 1, CaseVariants: 3,
+		DiacriticGroups: 1, DiacriticVariants: 2}
+	if stats == nil || *stats != want {
+		t.Errorf("Expected %+v, got %+v", want, stats)
+	}
+	t.Log(report.String())
+}
+
+func Test_BuildDBFromFile_Compressed(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(data)
+	gw.Close()
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	fw, _ := zw.Create("wiki.en.vec")
+	fw.Write(data)
+	zw.Close()
+
+	for name, content := range map[string][]byte{
+		"wiki.en.vec.gz":  gz.Bytes(),
+		"wiki.en.vec.zip": zipped.Bytes(),
+	} {
+		filename := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(filename, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+		// Both from the file and from a stream.
+		for _, fromFile := range []bool{true, false} {
+			ft := NewFastText(":memory:")
+			if fromFile {
+				err = ft.BuildDBFromFile(filename)
+			} else {
+				err = ft.BuildDB(bytes.NewReader(content))
+			}
+			if err != nil {
+				t.Errorf("%s: %v", name, err)
+			} else if _, err := ft.GetEmb("page"); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+			ft.Close()
+		}
+	}
+}
+
+func Test_Eval(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	has, _ := ft.GetEmb("has")
+	but, _ := ft.GetEmb("but")
+	page, _ := ft.GetEmb("page")
+	vec, err := ft.Eval(`has - 0.5 * (but + "page") / 2 + -page`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range vec {
+		want := float64(has[i]) - 0.25*(float64(but[i])+float64(page[i])) - float64(page[i])
+		if math.Abs(float64(vec[i])-want) > 1e-5 {
+			t.Fatalf("Dimension %d: expected %f, got %f", i, want, vec[i])
+		}
+	}
+	nn, err := ft.EvalNearest("has - is + was", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range nn {
+		if s.Word == "has" || s.Word == "is" || s.Word == "was" {
+			t.Errorf("Expression word %s should be excluded", s.Word)
+		}
+	}
+	for _, bad := range []string{"2 * 3", "has + 1", "has * but", "(has", "has )", `"has`, "NotExist1"} {
+		if _, err := ft.Eval(bad); err == nil {
+			t.Errorf("Expression %q should fail", bad)
+		}
+	}
+}
+
+func Test_BuildDB_Formats(t *testing.T) {
+	body := "king 0.1 0.2 0.3\nqueen 0.2 0.3 0.4\n"
+	for name, data := range map[string]string{
+		"vec":        "2 3\n" + body,
+		"glove":      body,
+		"count only": "2\n" + body,
+		"padded":     " 2  3 \n" + body,
+	} {
+		ft := NewFastText(":memory:")
+		if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		emb, err := ft.GetEmb("king")
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+		} else if len(emb) != 3 || emb[2] != 0.3 {
+			t.Errorf("%s: wrong embedding %v", name, emb)
+		}
+		ft.Close()
+	}
+}
+
+func Test_QueryRaw(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	q, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var words []string
+	err = ft.QueryRaw(func(rows *sql.Rows) error {
+		for rows.Next() {
+			var word string
+			var sim float64
+			if err := rows.Scan(&word, &sim); err != nil {
+				return err
+			}
+			words = append(words, word)
+		}
+		return rows.Err()
+	}, `SELECT word, cosine(emb, ?) AS sim FROM fasttext
+		WHERE word LIKE 'ha%' AND sim > 0.5 ORDER BY sim DESC;`, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 2 || words[0] != "has" || words[1] != "have" {
+		t.Errorf("Unexpected result %v", words)
+	}
+	err = ft.QueryRaw(func(rows *sql.Rows) error {
+		for rows.Next() {
+		}
+		return rows.Err()
+	}, `DELETE FROM fasttext;`)
+	if err == nil {
+		t.Error("Should refuse to modify the database")
+	}
+	if ok, _ := ft.Contains("has"); !ok {
+		t.Error("Database should not be modified")
+	}
+}
+
+func Test_BuildDB_WithPrecision(t *testing.T) {
+	ref := newTestFastText(t)
+	defer ref.Close()
+	for _, prec := range []Precision{Float16, Float32, Float64} {
+		ft := NewFastText(":memory:")
+		file, err := os.Open("./testdata/wiki.en.vec")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ft.BuildDB(file, WithPrecision(prec))
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Reopen the session so the precision is read from the metadata.
+		ft.format = nil
+		for _, word := range []string{"has", "page"} {
+			emb, err := ft.GetEmb(word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, _ := ref.GetEmb(word)
+			for i := range emb {
+				if math.Abs(float64(emb[i]-want[i])) > 1e-3 {
+					t.Errorf("%s: %s dimension %d: expected %f, got %f", prec, word, i, want[i], emb[i])
+					break
+				}
+			}
+		}
+		ft.Close()
+	}
+}
+
+func Test_Float16(t *testing.T) {
+	for _, f := range []float32{0, 1, -2.5, 0.1, 65504, 1e-7, float32(math.Inf(1))} {
+		got := float16ToFloat32(float32ToFloat16(f))
+		if math.Abs(float64(got-f)) > math.Abs(float64(f))*1e-3+1e-7 && !math.IsInf(float64(f), 0) {
+			t.Errorf("Round trip of %g gave %g", f, got)
+		}
+	}
+	if h := float32ToFloat16(1e6); h != 0x7c00 {
+		t.Errorf("Overflow should give infinity, got %x", h)
+	}
+}
+
+func Test_Codec(t *testing.T) {
+	vec := []float32{0.5, -1.25, 3, 0}
+	for _, prec := range []Precision{Float16, Float32, Float64} {
+		for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+			c := Codec{Precision: prec, Order: order}
+			data := c.Encode(vec)
+			if len(data) != len(vec)*c.Width() {
+				t.Errorf("%s: wrong blob size %d", c, len(data))
+			}
+			if err := c.Validate(data, len(vec)); err != nil {
+				t.Errorf("%s: %v", c, err)
+			}
+			if err := c.Validate(data, len(vec)+1); err == nil {
+				t.Errorf("%s: should reject wrong dimension", c)
+			}
+			got, err := c.Decode(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range vec {
+				if got[i] != vec[i] {
+					t.Errorf("%s: expected %v, got %v", c, vec, got)
+					break
+				}
+			}
+		}
+	}
+	if _, err := DefaultCodec.Decode(make([]byte, 7)); err == nil {
+		t.Error("Should reject truncated blob")
+	}
+}
+
+func Test_BuildDB_WithByteOrder(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "2 3\nking 0.1 0.2 0.3\nqueen 0.2 0.3 0.4\n"
+	err := ft.BuildDB(strings.NewReader(data), WithByteOrder(binary.LittleEndian), WithPrecision(Float64))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft.format = nil
+	codec, err := ft.Codec()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codec.Order != binary.LittleEndian || codec.Precision != Float64 {
+		t.Errorf("Unexpected codec %s", codec)
+	}
+	emb, err := ft.GetEmb("king")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emb[2] != 0.3 {
+		t.Errorf("Wrong embedding %v", emb)
+	}
+	// A blob of the wrong size must be rejected, not misdecoded.
+	if _, err := ft.db.Exec(`UPDATE fasttext SET emb=? WHERE word='queen';`, make([]byte, 12)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("queen"); err == nil {
+		t.Error("Should reject blob of the wrong size")
+	}
+}
+
+func Test_Sync(t *testing.T) {
+	origin := newTestFastText(t)
+	defer origin.Close()
+	edge := newTestFastText(t, WithCache(10))
+	defer edge.Close()
+
+	// Update the origin database.
+	newEmb, _ := origin.GetEmb("page")
+	if _, err := origin.db.Exec(`DELETE FROM fasttext WHERE word IN ('has', 'page');`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := origin.db.Exec(`INSERT INTO fasttext(word, emb) VALUES('newword', ?);`,
+		DefaultCodec.Encode(newEmb)); err != nil {
+		t.Fatal(err)
+	}
+	if err := origin.PutEmb("but", newEmb); err != nil {
+		t.Fatal(err)
+	}
+	edge.GetEmb("has")
+
+	manifest, err := edge.SyncManifest(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := origin.SyncDelta(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Changed) == 0 || len(delta.Changed) > 4 || len(delta.Rows) >= 49 {
+		t.Errorf("Delta should be small: %d buckets, %d rows", len(delta.Changed), len(delta.Rows))
+	}
+	if err := edge.ApplySyncDelta(delta); err != nil {
+		t.Fatal(err)
+	}
+	for word, want := range map[string]bool{"has": false, "page": false, "newword": true, "but": true} {
+		if ok, _ := edge.Contains(word); ok != want {
+			t.Errorf("After sync, Contains(%q) should be %v", word, want)
+		}
+	}
+	after, err := edge.SyncManifest(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err = origin.SyncDelta(after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(delta.Changed) != 0 {
+		t.Errorf("Databases should be in sync, %d buckets differ", len(delta.Changed))
+	}
+	if emb, err := edge.GetEmb("but"); err != nil || CosineSimilarity(emb, newEmb) < 0.999 {
+		t.Errorf("Expected the updated embedding of but after sync, got %v", err)
+	}
+	// The replica keeps the IDs and ranks of the origin.
+	for _, word := range []string{"newword", "but", "the"} {
+		wantID, _ := origin.GetID(word)
+		wantRank, _ := origin.GetRank(word)
+		id, err := edge.GetID(word)
+		if err != nil || id != wantID {
+			t.Errorf("Expected ID %d for %s after sync, got %d, %v", wantID, word, id, err)
+		}
+		if rank, err := edge.GetRank(word); err != nil || rank != wantRank {
+			t.Errorf("Expected rank %d for %s after sync, got %d, %v", wantRank, word, rank, err)
+		}
+	}
+
+	// Malformed manifests and deltas are rejected.
+	codec := manifest.Codec
+	if _, err := origin.SyncDelta(&SyncManifest{Codec: codec}); err == nil {
+		t.Error("Expected an error for a manifest without buckets")
+	}
+	for _, d := range []*SyncDelta{
+		{Codec: codec},
+		{Codec: codec, NumBuckets: 4, Changed: []int{4}},
+		{Codec: codec, NumBuckets: 1, Changed: []int{0}, Rows: []SyncRow{{Word: "a"}, {Word: "a"}}},
+		{Codec: codec, NumBuckets: 64, Rows: []SyncRow{{Word: "a"}}},
+	} {
+		if err := edge.ApplySyncDelta(d); err == nil {
+			t.Errorf("Expected an error applying %+v", d)
+		}
+	}
+}
+
+func Test_BuildDBFromGensim(t *testing.T) {
+	want := map[string][]float32{
+		"king":     {0.1, 0.2, 0.3, 0.4},
+		"queen":    {0.5, -0.5, 1.0, 2.0},
+		"New_York": {-1.0, 0.0, 0.25, 3.5},
+	}
+	for _, kv := range []string{"./testdata/gensim4.kv", "./testdata/gensim3.kv"} {
+		ft := NewFastText(":memory:")
+		if err := ft.BuildDBFromGensim(kv); err != nil {
+			t.Fatalf("%s: %v", kv, err)
+		}
+		for word, vec := range want {
+			emb, err := ft.GetEmb(word)
+			if err != nil {
+				t.Fatalf("%s: %v", kv, err)
+			}
+			for i := range vec {
+				if emb[i] != vec[i] {
+					t.Errorf("%s: %s: expected %v, got %v", kv, word, vec, emb)
+					break
+				}
+			}
+		}
+		ft.Close()
+	}
+	// Corrupt lengths of strings and bytes fail before allocating.
+	for _, data := range []string{
+		"\x80\x04\x8d\xff\xff\xff\xff\xff\xff\xff\xff",
+		"\x80\x04\x8e\x00\x00\x00\x00\x00\x01\x00\x00",
+	} {
+		if _, err := unpickle(strings.NewReader(data)); err == nil {
+			t.Errorf("Expected an error for the pickle %q", data)
+		}
+	}
+}
+
+func Test_BuildDBFromNpy(t *testing.T) {
+	vocab, err := ioutil.TempFile("", "vocab")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(vocab.Name())
+	vocab.WriteString("king\nqueen\nNew_York\n")
+	vocab.Close()
+
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromNpy("./testdata/gensim4.kv.vectors.npy", vocab.Name()); err != nil {
+		t.Fatal(err)
+	}
+	emb, err := ft.GetEmb("New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emb[3] != 3.5 {
+		t.Errorf("Wrong embedding %v", emb)
+	}
+}
+
+func Test_NearestByVector(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	vec, err := ft.GetSentenceEmb([]string{"has", "have"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nn, err := ft.NearestByVector(vec, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 2 || (nn[0].Word != "has" && nn[0].Word != "have") {
+		t.Errorf("Unexpected neighbors %v", nn)
+	}
+	if _, err := ft.NearestByVector([]float32{1, 2}, 2); err == nil {
+		t.Error("Should reject vector of the wrong dimension")
+	}
+}
+
+func Test_ANN(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbFilename := filepath.Join(dir, "wiki.sqlite")
+	ft := NewFastText(dbFilename)
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighborsANN("has", 5); err != ErrNoANNIndex {
+		t.Errorf("Expected ErrNoANNIndex, got %v", err)
+	}
+	if err := ft.BuildANNIndex(WithANNM(4)); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+
+	// The index is loaded from disk by a new session.
+	ft = NewFastText(dbFilename)
+	defer ft.Close()
+	for _, word := range []string{"has", "page", "but"} {
+		exact, err := ft.NearestNeighbors(word, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		approx, err := ft.NearestNeighborsANN(word, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(approx) != 5 {
+			t.Fatalf("Expected 5 neighbors, got %d", len(approx))
+		}
+		// The vocabulary is small enough for the search to be exact.
+		for i := range exact {
+			if exact[i].Word != approx[i].Word || math.Abs(exact[i].Score-approx[i].Score) > 1e-5 {
+				t.Errorf("%s: expected %v, got %v", word, exact, approx)
+				break
+			}
+		}
+	}
+	// Changing a vector makes the index stale until it is rebuilt.
+	if err := ft.PutEmb("has", make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighborsANN("page", 5); err == nil || !strings.Contains(err.Error(), "stale") {
+		t.Errorf("Expected a stale index error, got %v", err)
+	}
+	if err := ft.BuildANNIndex(WithANNM(4)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighborsANN("page", 5); err != nil {
+		t.Error(err)
+	}
+
+	for _, opt := range []ANNOption{WithANNM(1), WithANNEfConstruction(0)} {
+		if err := ft.BuildANNIndex(opt); err == nil {
+			t.Error("Expected an error for invalid HNSW parameters")
+		}
+	}
+	invalid := NewFastText(dbFilename, WithANNEfSearch(0))
+	defer invalid.Close()
+	if err := invalid.BuildANNIndex(); err == nil {
+		t.Error("Expected an error for an invalid efSearch")
+	}
+	if _, err := invalid.NearestNeighborsANN("page", 5); err == nil {
+		t.Error("Expected an error searching with an invalid efSearch")
+	}
+}
+
+func Test_ReadHNSWCorrupt(t *testing.T) {
+	h := newHNSW(3, 2, 8, 1)
+	h.generation = 7
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 30; i++ {
+		h.add(fmt.Sprintf("w%d", i), []float32{r.Float32() - 0.5, r.Float32() - 0.5, r.Float32() - 0.5})
+	}
+	var buf bytes.Buffer
+	if err := h.writeTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	read, err := readHNSW(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read.words) != 30 || read.generation != 7 || read.entry != h.entry {
+		t.Errorf("Unexpected graph of %d words, generation %d", len(read.words), read.generation)
+	}
+	for n := 0; n < len(data); n++ {
+		if _, err := readHNSW(bytes.NewReader(data[:n]), int64(n)); err == nil {
+			t.Fatalf("Expected an error for a file truncated to %d bytes", n)
+		}
+	}
+	// Corrupt files fail or load a graph that can be searched.
+	corrupt := make([]byte, len(data))
+	for i := range data {
+		for _, b := range []byte{0x80, 0xff} {
+			copy(corrupt, data)
+			corrupt[i] ^= b
+			if g, err := readHNSW(bytes.NewReader(corrupt), int64(len(corrupt))); err == nil {
+				g.search([]float32{1, 0, 0}, 5)
+			}
+		}
+	}
+}
+
+func Test_NearestNeighborsSpill(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ft := NewFastText(filepath.Join(dir, "wiki.sqlite"), WithMemoryBudget(1))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighborsSpill("has", 5); err != ErrNoSpillFile {
+		t.Errorf("Expected ErrNoSpillFile, got %v", err)
+	}
+	if err := ft.BuildSpillFile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range []string{"has", "page", "but"} {
+		exact, err := ft.NearestNeighbors(word, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		spilled, err := ft.NearestNeighborsSpill(word, 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(spilled) != len(exact) {
+			t.Fatalf("Expected %d neighbors, got %d", len(exact), len(spilled))
+		}
+		for i := range exact {
+			if exact[i].Word != spilled[i].Word || math.Abs(exact[i].Score-spilled[i].Score) > 1e-9 {
+				t.Errorf("%s: expected %v, got %v", word, exact, spilled)
+				break
+			}
+		}
+	}
+
+	// Words too long for the file are rejected, and no file is left.
+	if err := ft.PutEmb(strings.Repeat("a", math.MaxUint16+1), make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.BuildSpillFile(); err == nil {
+		t.Error("Expected an error for a word too long")
+	}
+	if _, err := ft.NearestNeighborsSpill("has", 5); err != ErrNoSpillFile {
+		t.Errorf("Expected ErrNoSpillFile, got %v", err)
+	}
+}
+
+func Test_Payload(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	entry, err := ft.Lookup("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.Payload != nil || len(entry.Emb) != 300 {
+		t.Errorf("Unexpected entry without payload: %v", entry.Payload)
+	}
+	type tags struct {
+		POS []string `json:"pos"`
+	}
+	if err := ft.SetPayloadJSON("has", tags{POS: []string{"VERB"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.SetPayload("not-a-word", []byte("x")); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	entry, err = ft.Lookup("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got tags
+	if err := entry.UnmarshalPayload(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.POS) != 1 || got.POS[0] != "VERB" {
+		t.Errorf("Unexpected payload %s", entry.Payload)
+	}
+	if err := ft.DeletePayload("has"); err != nil {
+		t.Fatal(err)
+	}
+	if payload, err := ft.Payload("has"); err != nil || payload != nil {
+		t.Errorf("Expected no payload, got %s, %v", payload, err)
+	}
+}
+
+func Test_BuildDBWithProgress(t *testing.T) {
+	f, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	var calls []int64
+	var lastBytes int64
+	err = ft.BuildDBWithProgress(f, func(words, bytes int64) {
+		calls = append(calls, words)
+		lastBytes = bytes
+	}, WithProgressInterval(20))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 3 || calls[0] != 20 || calls[1] != 40 || calls[2] != 49 {
+		t.Errorf("Unexpected progress calls %v", calls)
+	}
+	if lastBytes != info.Size() {
+		t.Errorf("Expected %d bytes read, got %d", info.Size(), lastBytes)
+	}
+
+	// Filtered and duplicate words are not counted.
+	data := "4 2\na 1 0\nbb 0 1\na 1 1\ncc 1 1\n"
+	var last int64
+	ft2 := NewFastText(":memory:")
+	defer ft2.Close()
+	err = ft2.BuildDB(strings.NewReader(data), WithMinWordLength(2), WithProgress(func(words, _ int64) {
+		last = words
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last != 2 {
+		t.Errorf("Expected 2 words inserted, got %d", last)
+	}
+}
+
+func Test_NearestNeighborsFilter(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	all, err := ft.NearestNeighbors("has", 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	banned := map[string]bool{all[0].Word: true, all[2].Word: true}
+	nn, err := ft.NearestNeighbors("has", 5, WithFilter(func(word string, _ float64) bool {
+		return !banned[word]
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 5 {
+		t.Fatalf("Expected 5 neighbors, got %d", len(nn))
+	}
+	expected := []ScoredWord{all[1], all[3], all[4], all[5], all[6]}
+	for i := range nn {
+		if nn[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, nn)
+			break
+		}
+	}
+}
+
+func Test_BuildDBResume(t *testing.T) {
+	defer func(n int64) { buildBatchSize = n }(buildBatchSize)
+	buildBatchSize = 10
+	expected := newTestFastText(t)
+	defer expected.Close()
+
+	// Interrupt a build after 25 words: the first two batches are kept.
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data, err := ioutil.ReadFile("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	embs := make(chan *wordEmb)
+	go func() {
+		defer close(embs)
+		n := 0
+		for emb := range readwordEmbdFile(bytes.NewReader(data), &parseConfig{workers: 1}, nil) {
+			if n++; n > 25 {
+				embs <- &wordEmb{Err: io.ErrUnexpectedEOF}
+				return
+			}
+			embs <- emb
+		}
+	}()
+	if err := ft.build(embs, ft.newBuildConfig(nil)); err != io.ErrUnexpectedEOF {
+		t.Fatalf("Expected interrupted build, got %v", err)
+	}
+	if err := ft.AppendDB(bytes.NewReader(data)); err != ErrBuildIncomplete {
+		t.Errorf("Expected ErrBuildIncomplete, got %v", err)
+	}
+	if err := ft.BuildDB(bytes.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM fasttext;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 49 {
+		t.Errorf("Expected 49 words, got %d", count)
+	}
+	emb, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := expected.GetEmb("has")
+	for i := range want {
+		if emb[i] != want[i] {
+			t.Fatalf("Resumed build has a different embedding for %q", "has")
+		}
+	}
+	if err := ft.BuildDB(bytes.NewReader(data)); err == nil {
+		t.Error("Expected an error building a complete database")
+	}
+}
+
+func Test_AppendDB(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader(strings.Join(lines[:21], ""))); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := ft.Contains(strings.Fields(lines[30])[0]); ok {
+		t.Fatal("Word unexpectedly found before append")
+	}
+	// Overlapping words are kept once.
+	if err := ft.AppendDB(strings.NewReader(strings.Join(lines[10:], ""))); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM fasttext;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 49 {
+		t.Errorf("Expected 49 words, got %d", count)
+	}
+	if err := ft.AppendDB(strings.NewReader("extra 1 2 3\n")); err == nil {
+		t.Error("Expected a dimension error")
+	}
+}
+
+func Test_SelfTest(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	report, err := ft.SelfTest(WithSample(20), WithSimilarityCheck("has", "have", 0.3),
+		WithSimilarityCheck("has", "page", 0.99))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := map[string]bool{}
+	for _, c := range report.Checks {
+		if !c.OK {
+			failed[c.Detail] = true
+		}
+	}
+	if len(failed) != 1 || report.Passed() {
+		var buf bytes.Buffer
+		report.WriteTo(&buf)
+		t.Errorf("Expected only the has/page similarity to fail:\n%s", buf.String())
+	}
+
+	if _, err := ft.db.Exec(`UPDATE fasttext SET emb = x'00' WHERE word = 'has';`); err != nil {
+		t.Fatal(err)
+	}
+	report, err = ft.SelfTest(WithSample(500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Passed() {
+		t.Error("Expected the sample check to fail on a corrupted row")
+	}
+}
+
+func Test_Verify(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	data, err := ioutil.ReadFile("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	verify := func() map[string]bool {
+		report, err := ft.Verify(WithSourceCheck(bytes.NewReader(data), 1000))
+		if err != nil {
+			t.Fatal(err)
+		}
+		failed := map[string]bool{}
+		for _, c := range report.Checks {
+			if !c.OK {
+				failed[c.Name] = true
+			}
+		}
+		return failed
+	}
+	if failed := verify(); len(failed) != 0 {
+		t.Errorf("Unexpected failed checks %v", failed)
+	}
+
+	if _, err := ft.db.Exec(`UPDATE fasttext SET emb = (SELECT emb FROM fasttext WHERE word = 'but') WHERE word = 'has';`); err != nil {
+		t.Fatal(err)
+	}
+	if failed := verify(); len(failed) != 1 || !failed["source"] {
+		t.Errorf("Expected only the source check to fail on a swapped vector, got %v", failed)
+	}
+	if _, err := ft.db.Exec(`UPDATE fasttext SET emb = x'00' WHERE word = 'has';`); err != nil {
+		t.Fatal(err)
+	}
+	if failed := verify(); !failed["rows"] {
+		t.Errorf("Expected the rows check to fail on a corrupted row, got %v", failed)
+	}
+}
+
+func Test_PutEmb(t *testing.T) {
+	ft := newTestFastText(t, WithCache(10))
+	defer ft.Close()
+	vec := make([]float32, 300)
+	vec[0] = 1
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.PutEmbs([]string{"has", "newterm"}, [][]float32{vec, vec}); err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range []string{"has", "newterm"} {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emb[0] != 1 || emb[1] != 0 {
+			t.Errorf("Unexpected embedding for %q after PutEmbs", word)
+		}
+	}
+	if err := ft.PutEmb("short", []float32{1, 2}); err == nil {
+		t.Error("Expected a dimension error")
+	}
+	if ok, _ := ft.Contains("short"); ok {
+		t.Error("Vector of the wrong dimension was inserted")
+	}
+}
+
+func Test_DeleteEmb(t *testing.T) {
+	ft := newTestFastText(t, WithCache(10))
+	defer ft.Close()
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.SetPayload("has", []byte("x")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.DeleteEmb("has"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("has"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound after delete, got %v", err)
+	}
+	if payload, _ := ft.Payload("has"); payload != nil {
+		t.Error("Payload not deleted")
+	}
+	if err := ft.DeleteEmb("has"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if err := ft.DeleteEmbs([]string{"page", "not-a-word", "but"}); err != nil {
+		t.Fatal(err)
+	}
+	embs, err := ft.GetEmbs([]string{"page", "but", "the"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embs[0] != nil || embs[1] != nil || embs[2] == nil {
+		t.Error("Unexpected vocabulary after DeleteEmbs")
+	}
+}
+
+func Test_ExportVec(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	if err := ft.DeleteEmb("has"); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ft.ExportVec(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if header := strings.SplitN(buf.String(), "\n", 2)[0]; header != "48 300" {
+		t.Errorf("Unexpected header %q", header)
+	}
+	exported := NewFastText(":memory:")
+	defer exported.Close()
+	if err := exported.BuildDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	err := ft.ForEach(func(word string, emb []float32) error {
+		got, err := exported.GetEmb(word)
+		if err != nil {
+			return err
+		}
+		for i := range emb {
+			if got[i] != emb[i] {
+				t.Fatalf("Exported embedding of %q differs at %d", word, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ReadOnly(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbFilename := filepath.Join(dir, "wiki.sqlite")
+	ft := NewFastText(dbFilename)
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+	for _, opt := range []Option{WithReadOnly(), WithImmutable()} {
+		for _, ft := range []*FastText{NewFastText(dbFilename, opt), NewFastTextInMem(dbFilename, opt)} {
+			if _, err := ft.GetEmb("has"); err != nil {
+				t.Error(err)
+			}
+			ft.Close()
+		}
+		ft := NewFastText(dbFilename, opt)
+		if err := ft.DeleteEmb("has"); err == nil {
+			t.Error("Expected a write error on a read-only database")
+		}
+		ft.Close()
+	}
+}
+
+func Test_NewFastTextFromDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbFilename := filepath.Join(dir, "wiki.sqlite")
+	ft := NewFastText(dbFilename, WithDriver("sqlite3"))
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+
+	db, err := sql.Open("sqlite3", dbFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ft = NewFastTextFromDB(db)
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+	if nn, err := ft.NearestNeighbors("has", 3); err != nil || len(nn) != 3 {
+		t.Errorf("Unexpected neighbors %v, %v", nn, err)
+	}
+	ft.Close()
+	if err := db.Ping(); err != nil {
+		t.Errorf("Close closed the caller's database: %v", err)
+	}
+}
+
+func Test_Flat(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "wiki.flat")
+	if err := ft.CompileFlat(filename); err != nil {
+		t.Fatal(err)
+	}
+	fl, err := OpenFlat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fl.Close()
+	if fl.Len() != 49 || fl.Dim() != 300 {
+		t.Errorf("Unexpected flat file of %d words of dimension %d", fl.Len(), fl.Dim())
+	}
+	err = ft.ForEach(func(word string, emb []float32) error {
+		got, err := fl.GetEmb(word)
+		if err != nil {
+			return err
+		}
+		for i := range emb {
+			if got[i] != emb[i] {
+				t.Fatalf("Flat embedding of %q differs at %d", word, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fl.GetEmb("not-a-word"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	exact, _ := ft.NearestNeighbors("has", 5)
+	nn, err := fl.NearestNeighbors("has", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range exact {
+		if exact[i] != nn[i] {
+			t.Errorf("Expected %v, got %v", exact, nn)
+			break
+		}
+	}
+}
+
+func Test_Preload(t *testing.T) {
+	ft := newTestFastText(t, WithPreload(10), WithPreloadWords("page"))
+	defer ft.Close()
+	n, err := ft.PreloadedLen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 11 {
+		t.Errorf("Expected 11 preloaded words, got %d", n)
+	}
+	_, exp, err := ft.GetEmbExplain("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Source != SourcePreload {
+		t.Errorf("Expected %q preloaded, got source %s", "page", exp.Source)
+	}
+	_, exp, err = ft.GetEmbExplain("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp.Source != SourceDatabase {
+		t.Errorf("Expected %q from the database, got source %s", "has", exp.Source)
+	}
+	if err := ft.DeleteEmb("page"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("page"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Deleted word still preloaded: %v", err)
+	}
+
+	// A budget of 1 MB holds about 850 vectors of 300 float32.
+	budget := newTestFastText(t, WithPreload(10000), WithPreloadBudget(1))
+	defer budget.Close()
+	if n, err := budget.PreloadedLen(); err != nil || n != 49 {
+		t.Errorf("Expected 49 preloaded words, got %d, %v", n, err)
+	}
+}
+
+func Test_SaveTo(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file, WithPrecision(Float16)); err != nil {
+		t.Fatal(err)
+	}
+	dbFilename := filepath.Join(dir, "saved.sqlite")
+	if err := ft.SaveTo(dbFilename); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.SaveTo(dbFilename); err == nil {
+		t.Error("Expected an error saving over an existing file")
+	}
+	want, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Both sessions on the saved file, and two in-memory copies at once.
+	saved := NewFastText(dbFilename)
+	defer saved.Close()
+	inMem1 := NewFastTextInMem(dbFilename)
+	defer inMem1.Close()
+	inMem2 := NewFastTextInMem(dbFilename)
+	defer inMem2.Close()
+	for _, s := range []*FastText{saved, inMem1, inMem2} {
+		codec, err := s.Codec()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if codec.Precision != Float16 {
+			t.Errorf("Expected float16 vectors, got %v", codec)
+		}
+		got, err := s.GetEmb("has")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("Saved embedding differs at %d", i)
+			}
+		}
+	}
+	if plan := inMem1.queryPlan(`SELECT emb FROM fasttext WHERE word=?;`, "has"); !strings.Contains(plan, "INDEX") {
+		t.Errorf("In-memory copy does not use the word index: %s", plan)
+	}
+}
+
+func Test_Stats(t *testing.T) {
+	before := time.Now().Add(-time.Second)
+	ft := newTestFastText(t)
+	defer ft.Close()
+	s, err := ft.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Words != 49 || s.Dim != 300 || s.FloatWidth != 4 || s.Codec != DefaultCodec {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	if s.Size < 49*300*4 {
+		t.Errorf("Database size %d too small", s.Size)
+	}
+	if s.BuiltAt.Before(before) || s.BuiltAt.After(time.Now()) {
+		t.Errorf("Unexpected build time %v", s.BuiltAt)
+	}
+}
+
+func Test_Normalization(t *testing.T) {
+	data := "3 2\nParis 1 0\nparis 0 1\ncafé 1 1\n"
+	ft := NewFastText(":memory:", WithUnicodeNFC(), WithLowercase())
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	// The first of Paris and paris is kept.
+	for _, word := range []string{"Paris", "PARIS", "paris"} {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emb[0] != 1 || emb[1] != 0 {
+			t.Errorf("Unexpected embedding %v for %q", emb, word)
+		}
+	}
+	if ok, err := ft.Contains("cafe\u0301"); err != nil || !ok {
+		t.Errorf("Decomposed form not found: %v", err)
+	}
+	embs, err := ft.GetEmbs([]string{"CAFÉ", "nope"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if embs[0] == nil || embs[1] != nil {
+		t.Errorf("Unexpected batch %v", embs)
+	}
+	nn, err := ft.NearestNeighbors("PARIS", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 1 || nn[0].Word != "café" {
+		t.Errorf("Unexpected neighbors %v", nn)
+	}
+}
+
+func Test_GetEmbFuzzy(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	m, _, err := ft.GetEmbFuzzy("Page!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Word != "page" || m.Method != MatchPunctuation {
+		t.Errorf("Unexpected match %+v", m)
+	}
+	if _, _, err := ft.GetEmbFuzzy("pgae"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound without index, got %v", err)
+	}
+	if err := ft.BuildFuzzyIndex(2); err != nil {
+		t.Fatal(err)
+	}
+	for query, expected := range map[string]FuzzyMatch{
+		"pgae":    {Word: "page", Method: MatchEditDist, Distance: 1},
+		"fisrtt":  {Word: "first", Method: MatchEditDist, Distance: 2},
+		"Whic":    {Word: "which", Method: MatchEditDist, Distance: 1},
+		"THE":     {Word: "the", Method: MatchLowercase},
+		"zzzzzzz": {},
+	} {
+		m, emb, err := ft.GetEmbFuzzy(query)
+		if expected.Word == "" {
+			if !errors.Is(err, ErrNoEmbFound) {
+				t.Errorf("%s: expected ErrNoEmbFound, got %v", query, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m != expected || len(emb) != 300 {
+			t.Errorf("%s: expected %+v, got %+v", query, expected, m)
+		}
+	}
+}
+
+func Test_editDistance(t *testing.T) {
+	for _, c := range []struct {
+		a, b string
+		d    int
+	}{
+		{"", "", 0}, {"abc", "", 3}, {"page", "pgae", 1}, {"kitten", "sitting", 3}, {"café", "cafe", 1},
+	} {
+		if d := editDistance(c.a, c.b); d != c.d {
+			t.Errorf("editDistance(%q, %q) = %d, expected %d", c.a, c.b, d, c.d)
+		}
+	}
+}
+
+func Test_TableName(t *testing.T) {
+	dbFile := filepath.Join(t.TempDir(), "models.sqlite")
+	models := map[string]string{
+		"wiki_en": "2 2\nking 1 0\nqueen 0 1\n",
+		"crawl":   "2 2\nking 0 1\nprince 1 1\n",
+	}
+	for table, data := range models {
+		ft := NewFastText(dbFile, WithTableName(table))
+		if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		if err := ft.SetPayload("king", []byte(table)); err != nil {
+			t.Fatal(err)
+		}
+		ft.Close()
+	}
+	ft := NewFastText(dbFile, WithTableName("crawl"))
+	defer ft.Close()
+	names, err := ft.ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, ",") != "crawl,wiki_en" {
+		t.Errorf("Unexpected models %v", names)
+	}
+	emb, err := ft.GetEmb("king")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emb[0] != 0 || emb[1] != 1 {
+		t.Errorf("Unexpected embedding %v", emb)
+	}
+	if _, err := ft.GetEmb("queen"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if p, err := ft.Payload("king"); err != nil || string(p) != "crawl" {
+		t.Errorf("Unexpected payload %q: %v", p, err)
+	}
+	if s, err := ft.Stats(); err != nil || s.Words != 2 || s.Dim != 2 {
+		t.Errorf("Unexpected stats %+v: %v", s, err)
+	}
+	if r, err := ft.SelfTest(); err != nil || !r.Passed() {
+		t.Errorf("Self-test failed: %v", err)
+	}
+}
+
+func Test_Lang(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildLangDB("en", strings.NewReader("3 2\ncat 1 0\ndog 0 1\nkitten 0.9 0.1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.BuildLangDB("fr", strings.NewReader("2 2\nchat 1 0.05\nchien 0.05 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.BuildLangDB("de", strings.NewReader("1 3\nKatze 1 0 0\n")); err == nil {
+		t.Error("Expected an error for a different dimension")
+	}
+	langs, err := ft.Langs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(langs, ",") != "en,fr" {
+		t.Errorf("Unexpected languages %v", langs)
+	}
+	if _, err := ft.GetEmbLang("fr", "cat"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	nn, err := ft.NearestNeighborsLang("en", "cat", "fr", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 1 || nn[0].Word != "chat" {
+		t.Errorf("Unexpected translation %v", nn)
+	}
+	nn, err = ft.NearestNeighborsLang("en", "cat", "en", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 2 || nn[0].Word != "kitten" {
+		t.Errorf("Unexpected neighbors %v", nn)
+	}
+	models, err := ft.ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(models) != 0 {
+		t.Errorf("Unexpected models %v", models)
+	}
+}
+
+func Test_LoadMatrix(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 49 || m.Dim() != 300 || len(m.Data) != 49*300 {
+		t.Fatalf("Unexpected matrix of %d rows, %d dimensions", m.Len(), m.Dim())
+	}
+	emb, err := ft.GetEmb("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	row, err := m.Get("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range emb {
+		if emb[i] != row[i] {
+			t.Fatalf("Row differs from GetEmb at %d", i)
+		}
+	}
+	if _, err := m.Get("nope"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	dots, err := m.Dot(emb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	i, _ := m.Index("page")
+	if n := l2norm(emb); math.Abs(float64(dots[i])-n*n) > 1e-3 {
+		t.Errorf("Unexpected dot product %v, expected %v", dots[i], n*n)
+	}
+	expected, err := ft.NearestNeighbors("page", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nn, err := m.NearestNeighbors("page", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range expected {
+		if nn[i].Word != expected[i].Word {
+			t.Errorf("Unexpected neighbors %v, expected %v", nn, expected)
+			break
+		}
+	}
+}
+
+func Test_kernels(t *testing.T) {
+	a := make([]float32, 301)
+	b := make([]float32, 301)
+	var expected float64
+	for i := range a {
+		a[i] = float32(i%7) - 3
+		b[i] = float32(i%5)/2 - 1
+		expected += float64(a[i]) * float64(b[i])
+	}
+	if d := dot(a, b); math.Abs(d-expected) > 1e-3 {
+		t.Errorf("dot = %v, expected %v", d, expected)
+	}
+	y := copyVec(b)
+	axpy(2, a, y)
+	for i := range y {
+		if y[i] != b[i]+2*a[i] {
+			t.Fatalf("axpy differs at %d: %v", i, y[i])
+		}
+	}
+	scale(0.5, y)
+	if y[10] != (b[10]+2*a[10])/2 {
+		t.Errorf("scale: unexpected %v", y[10])
+	}
+}
+
+func Test_NearestNeighborsBatch(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	words := []string{"page", "nope", "first"}
+	batch, err := ft.NearestNeighborsBatch(words, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch) != 3 || batch[1] != nil {
+		t.Fatalf("Unexpected batch %v", batch)
+	}
+	for _, q := range []int{0, 2} {
+		expected, err := ft.NearestNeighbors(words[q], 5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch[q]) != len(expected) {
+			t.Fatalf("Unexpected neighbors %v of %s, expected %v", batch[q], words[q], expected)
+		}
+		for i := range expected {
+			if batch[q][i] != expected[i] {
+				t.Errorf("Unexpected neighbors %v of %s, expected %v", batch[q], words[q], expected)
+				break
+			}
+		}
+	}
+}
+
+func Test_Int8(t *testing.T) {
+	c := Codec{Precision: Int8, Order: binary.LittleEndian}
+	vec := []float32{0.5, -1.27, 0.01, 0}
+	data := c.Encode(vec)
+	if len(data) != 4+len(vec) || c.Validate(data, len(vec)) != nil {
+		t.Fatalf("Wrong blob size %d", len(data))
+	}
+	got, err := c.Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range vec {
+		if math.Abs(float64(got[i]-vec[i])) > 0.01/2+1e-6 {
+			t.Errorf("Expected %v, got %v", vec, got)
+			break
+		}
+	}
+	ref := newTestFastText(t)
+	defer ref.Close()
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file, WithPrecision(Int8)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := ft.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Codec.Precision != Int8 || s.FloatWidth != 1 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	for _, pair := range [][2]string{{"has", "page"}, {"first", "which"}} {
+		want, _ := ref.Similarity(pair[0], pair[1])
+		sim, err := ft.Similarity(pair[0], pair[1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(sim-want) > 0.01 {
+			t.Errorf("Similarity of %v: expected %f, got %f", pair, want, sim)
+		}
+	}
+}
+
+// writeFTModel writes a fastText model of the given words without
+// n-grams, with a quantized input matrix of 2 sub-vectors if pq is set.
+func writeFTModel(t *testing.T, words []string, rows [][]float32, pq bool) string {
+	var buf bytes.Buffer
+	w := func(v interface{}) {
+		if err := binary.Write(&buf, binary.LittleEndian, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dim := int32(len(rows[0]))
+	w([]int32{ftModelMagic, ftModelVersion})
+	w([]int32{dim, 5, 5, 1, 5, 1, 2, 2, 0, 0, 0, 100})
+	w(float64(1e-4))
+	w([]int32{int32(len(words)), int32(len(words)), 0})
+	w([]int64{100, -1})
+	for _, word := range words {
+		buf.WriteString(word)
+		buf.WriteByte(0)
+		w(int64(10))
+		w(int8(ftEntryWord))
+	}
+	w(pq)
+	if !pq {
+		w([]int64{int64(len(rows)), int64(dim)})
+		for _, row := range rows {
+			w(row)
+		}
+	} else {
+		// Each half of a row is its own centroid.
+		w(false)
+		w([]int64{int64(len(rows)), int64(dim)})
+		w(int32(2 * len(rows)))
+		for i := range rows {
+			w([]uint8{uint8(i), uint8(i)})
+		}
+		w([]int32{dim, 2, dim / 2, dim / 2})
+		centroids := make([]float32, dim*ftKsub)
+		for m := 0; m < 2; m++ {
+			for i, row := range rows {
+				copy(centroids[(m*ftKsub+i)*int(dim/2):], row[m*int(dim/2):(m+1)*int(dim/2)])
+			}
+		}
+		w(centroids)
+	}
+	filename := filepath.Join(t.TempDir(), "model.ftz")
+	if err := ioutil.WriteFile(filename, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func Test_BuildDBFromModel(t *testing.T) {
+	words := []string{"</s>", "cat", "dog"}
+	rows := [][]float32{{0, 0, 0, 1}, {1, 2, 3, 4}, {-1, 0.5, 0, 2}}
+	for _, pq := range []bool{false, true} {
+		ft := NewFastText(":memory:")
+		if err := ft.BuildDBFromFile(writeFTModel(t, words, rows, pq)); err != nil {
+			t.Fatal(err)
+		}
+		for i, word := range words {
+			emb, err := ft.GetEmb(word)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for j := range emb {
+				if emb[j] != rows[i][j] {
+					t.Errorf("Quantized %v: expected %v for %s, got %v", pq, rows[i], word, emb)
+					break
+				}
+			}
+		}
+		ft.Close()
+	}
+	if h := ftHash("a"); h != 0xe40c292c {
+		t.Errorf("Unexpected hash %x", h)
+	}
+	m := &ftModel{words: []string{"</s>", "cat"}, nwords: 2, minn: 3, maxn: 3, bucket: 10}
+	if rows := m.subwords(0); len(rows) != 1 {
+		t.Errorf("Unexpected subwords of </s>: %v", rows)
+	}
+	// <ca, cat and at>.
+	if rows := m.subwords(1); len(rows) != 4 || rows[0] != 1 || rows[3] < 2 {
+		t.Errorf("Unexpected subwords of cat: %v", rows)
+	}
+}
+
+func Test_BuildDB_WithCompression(t *testing.T) {
+	ref := newTestFastText(t)
+	defer ref.Close()
+	dbFile := filepath.Join(t.TempDir(), "zstd.sqlite")
+	ft := NewFastText(dbFile)
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec", WithCompression(Zstd)); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+	ft = NewFastText(dbFile)
+	defer ft.Close()
+	s, err := ft.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Compression != Zstd || s.Words != 49 {
+		t.Errorf("Unexpected stats %+v", s)
+	}
+	var size int
+	if err := ft.db.QueryRow(`SELECT SUM(LENGTH(emb)) FROM fasttext;`).Scan(&size); err != nil {
+		t.Fatal(err)
+	}
+	if size >= 49*300*4 {
+		t.Errorf("Blobs of %d bytes are not compressed", size)
+	}
+	for _, word := range []string{"has", "page"} {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, _ := ref.GetEmb(word)
+		for i := range emb {
+			if emb[i] != want[i] {
+				t.Fatalf("%s: expected %v, got %v", word, want, emb)
+			}
+		}
+	}
+	if err := ft.PutEmb("new", make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if emb, err := ft.GetEmb("new"); err != nil || len(emb) != 300 {
+		t.Errorf("Unexpected embedding %v: %v", emb, err)
+	}
+}
+
+func Test_Rank(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "3 2\nthe 1 0\nof 0 1\nand 1 1\n"
+	if err := ft.BuildDB(strings.NewReader(data), WithFrequencies(strings.NewReader("the 100\nand 10\n"))); err != nil {
+		t.Fatal(err)
+	}
+	if rank, err := ft.GetRank("of"); err != nil || rank != 2 {
+		t.Errorf("Unexpected rank %d: %v", rank, err)
+	}
+	if _, err := ft.GetRank("nope"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if freq, err := ft.GetFreq("and"); err != nil || freq != 10 {
+		t.Errorf("Unexpected frequency %d: %v", freq, err)
+	}
+	if freq, err := ft.GetFreq("of"); err != nil || freq != 0 {
+		t.Errorf("Unexpected frequency %d: %v", freq, err)
+	}
+	// Replacing an embedding keeps the rank, new words have none.
+	if err := ft.PutEmbs([]string{"the", "new"}, [][]float32{{2, 0}, {0, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if rank, err := ft.GetRank("the"); err != nil || rank != 1 {
+		t.Errorf("Unexpected rank %d: %v", rank, err)
+	}
+	if rank, err := ft.GetRank("new"); err != nil || rank != 0 {
+		t.Errorf("Unexpected rank %d: %v", rank, err)
+	}
+	words, err := ft.TopKWords(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, ",") != "the,of,and" {
+		t.Errorf("Unexpected top words %v", words)
+	}
+	it, err := ft.IterTop(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	var n int
+	for it.Next() {
+		n++
+	}
+	if it.Err() != nil || n != 2 {
+		t.Errorf("Iterated over %d words: %v", n, it.Err())
+	}
+}
+
+func Test_GetSentenceEmbSIF(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "4 2\nthe 1 0\na 0.9 0.1\ncat 0 1\ndog 0.1 1\n"
+	freqs := "the 1000\na 800\ncat 1\ndog 1\n"
+	if err := ft.BuildDB(strings.NewReader(data), WithFrequencies(strings.NewReader(freqs))); err != nil {
+		t.Fatal(err)
+	}
+	avg, err := ft.GetSentenceEmb([]string{"the", "cat"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sif, err := ft.GetSentenceEmbSIF([]string{"the", "cat"}, DefaultSIFWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The frequent word counts less.
+	if sif[1] <= sif[0] || avg[1] != avg[0] {
+		t.Errorf("Unexpected embeddings %v (SIF) and %v (average)", sif, avg)
+	}
+	vecs, u, err := ft.GetSentenceEmbsSIF([][]string{{"the", "cat"}, {"a", "dog"}, {"nope"}}, DefaultSIFWeight)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vecs) != 3 || vecs[2] != nil || math.Abs(l2norm(u)-1) > 1e-4 {
+		t.Fatalf("Unexpected embeddings %v and component %v", vecs, u)
+	}
+	for _, vec := range vecs[:2] {
+		if d := dot(vec, u); math.Abs(d) > 1e-4 {
+			t.Errorf("Component not removed from %v: %v", vec, d)
+		}
+	}
+	again, err := ft.GetSentenceEmbSIF([]string{"a", "dog"}, DefaultSIFWeight, WithCommonComponent(u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range again {
+		if math.Abs(float64(again[i]-vecs[1][i])) > 1e-5 {
+			t.Errorf("Expected %v, got %v", vecs[1], again)
+			break
+		}
+	}
+}
+
+func Test_WordsWithPrefix(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "6 1\nwalk 1\nwalked 1\nwalking 1\nwall 1\nwalkers 1\ntalk 1\n"
+	if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	words, err := ft.WordsWithPrefix("walk", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, ",") != "walk,walked,walkers" {
+		t.Errorf("Unexpected words %v", words)
+	}
+	if words, err = ft.WordsWithPrefix("", 10); err != nil || len(words) != 6 {
+		t.Errorf("Unexpected words %v: %v", words, err)
+	}
+	if words, err = ft.WordsMatching("?alk*", 10); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, ",") != "talk,walk,walked,walkers,walking" {
+		t.Errorf("Unexpected words %v", words)
+	}
+	if _, ok := prefixEnd("\xff\xff"); ok {
+		t.Error("Expected no end for a prefix of 0xff bytes")
+	}
+	if end, _ := prefixEnd("a\xff"); end != "b" {
+		t.Errorf("Unexpected end %q", end)
+	}
+}
+
+func Test_Vector(t *testing.T) {
+	v := Vector{3, 0}
+	w := Vector{1, 2}
+	for _, c := range []struct {
+		got, expected Vector
+	}{
+		{v.Add(w), Vector{4, 2}},
+		{v.Sub(w), Vector{2, -2}},
+		{v.Scale(2), Vector{6, 0}},
+		{v.Normalize(), Vector{1, 0}},
+		{Vector{0, 0}.Normalize(), Vector{0, 0}},
+	} {
+		if len(c.got) != len(c.expected) || c.got[0] != c.expected[0] || c.got[1] != c.expected[1] {
+			t.Errorf("Expected %v, got %v", c.expected, c.got)
+		}
+	}
+	if v[0] != 3 || v[1] != 0 {
+		t.Errorf("Operand modified: %v", v)
+	}
+	if v.Dot(w) != 3 || v.Norm() != 3 || math.Abs(v.Cosine(w)-1/math.Sqrt(5)) > 1e-6 {
+		t.Errorf("Unexpected products %v, %v, %v", v.Dot(w), v.Norm(), v.Cosine(w))
+	}
+	if d := v.Distance(w); math.Abs(d-math.Sqrt(8)) > 1e-6 {
+		t.Errorf("Unexpected distance %v", d)
+	}
+	ft := newTestFastText(t)
+	defer ft.Close()
+	vec, err := ft.GetVector("page")
+	if err != nil {
+		t.Fatal(err)
+	}
+	nn, err := ft.NearestByVector(vec.Scale(2), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nn[0].Word != "page" {
+		t.Errorf("Unexpected neighbors %v", nn)
+	}
+}
+
+func Test_WithNormalizedVectors(t *testing.T) {
+	ref := newTestFastText(t)
+	defer ref.Close()
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file, WithNormalizedVectors()); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := ft.Normalized(); err != nil || !ok {
+		t.Fatalf("Expected normalized vectors, got %v, %v", ok, err)
+	}
+	if ok, err := ref.Normalized(); err != nil || ok {
+		t.Errorf("Expected unnormalized vectors, got %v, %v", ok, err)
+	}
+	emb, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := l2norm(emb); math.Abs(n-1) > 1e-5 {
+		t.Errorf("Expected a unit vector, got norm %v", n)
+	}
+	if err := ft.PutEmb("new", []float32{3, 4}); err == nil {
+		t.Error("Expected a dimension error")
+	}
+	vec := make([]float32, 300)
+	vec[0] = 5
+	if err := ft.PutEmb("new", vec); err != nil {
+		t.Fatal(err)
+	}
+	if emb, err := ft.GetEmb("new"); err != nil || emb[0] != 1 {
+		t.Errorf("Expected the inserted vector to be normalized, got %v, %v", emb, err)
+	}
+	want, err := ref.NearestNeighbors("has", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ft.NearestNeighbors("has", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i].Word != want[i].Word || math.Abs(got[i].Score-want[i].Score) > 1e-5 {
+			t.Errorf("Expected %v, got %v", want, got)
+			break
+		}
+	}
+}
+
+func Test_WithPCA(t *testing.T) {
+	ref := newTestFastText(t)
+	defer ref.Close()
+	dbPath := filepath.Join(t.TempDir(), "pca.db")
+	ft := NewFastText(dbPath)
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file, WithPCA(20)); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+	ft = NewFastText(dbPath)
+	defer ft.Close()
+	s, err := ft.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Dim != 20 {
+		t.Errorf("Expected 20 dimensions, got %d", s.Dim)
+	}
+	emb, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, err := ref.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := ft.Project(orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range emb {
+		if math.Abs(float64(emb[i]-proj[i])) > 1e-4 {
+			t.Fatalf("Expected the stored vector %v, got %v", emb, proj)
+		}
+	}
+	if _, err := ft.Project(emb); err == nil {
+		t.Error("Expected a dimension error")
+	}
+	// The first component captures the most variance.
+	var first, last float64
+	words, err := ft.TopKWords(48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, word := range words {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		first += float64(emb[0] * emb[0])
+		last += float64(emb[19] * emb[19])
+	}
+	if first < last {
+		t.Errorf("Expected decreasing variance, got %v < %v", first, last)
+	}
+	if _, err := fitPCA([][]float32{{1, 2}, {3, 4}}, 3); err == nil {
+		t.Error("Expected an error for too many components")
+	}
+}
+
+func Test_VocabFilters(t *testing.T) {
+	for _, c := range []struct {
+		opts  []BuildOption
+		check func(words []string) bool
+	}{
+		{[]BuildOption{WithMaxWords(10)}, func(words []string) bool { return len(words) == 10 }},
+		{[]BuildOption{WithMinWordLength(4)}, func(words []string) bool {
+			for _, word := range words {
+				if len([]rune(word)) < 4 {
+					return false
+				}
+			}
+			return len(words) > 0
+		}},
+		{[]BuildOption{WithExcludePattern(regexp.MustCompile(`^[a-m]`))}, func(words []string) bool {
+			for _, word := range words {
+				if word[0] <= 'm' && word[0] >= 'a' {
+					return false
+				}
+			}
+			return len(words) > 0
+		}},
+		{[]BuildOption{WithVocabulary([]string{"page", "has", "missing"})}, func(words []string) bool {
+			return strings.Join(words, " ") == "has page"
+		}},
+	} {
+		ft := NewFastText(":memory:")
+		file, err := os.Open("./testdata/wiki.en.vec")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ft.BuildDB(file, c.opts...); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+		words, err := ft.TopKWords(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.check(words) {
+			t.Errorf("Unexpected vocabulary %v", words)
+		}
+		ft.Close()
+	}
+	ref := newTestFastText(t)
+	defer ref.Close()
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file, WithVocabulary([]string{"page"})); err != nil {
+		t.Fatal(err)
+	}
+	want, _ := ref.GetRank("page")
+	if rank, err := ft.GetRank("page"); err != nil || rank != want {
+		t.Errorf("Expected rank %d, got %d, %v", want, rank, err)
+	}
+}
+
+func Test_BuildDBFromURL(t *testing.T) {
+	data, err := ioutil.ReadFile("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(d time.Duration) { urlRetryDelay = d }(urlRetryDelay)
+	urlRetryDelay = time.Millisecond
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/wiki.en.vec" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Fail in the middle of the first download.
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data[:len(data)/2])
+			panic(http.ErrAbortHandler)
+		}
+		http.ServeContent(w, r, "wiki.en.vec", time.Time{}, bytes.NewReader(data))
+	}))
+	defer server.Close()
+	ref := newTestFastText(t)
+	defer ref.Close()
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromURL(context.Background(), server.URL+"/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected the download to resume once, got %d requests", n)
+	}
+	want, _ := ref.GetEmb("page")
+	emb, err := ft.GetEmb("page")
+	if err != nil || len(emb) != len(want) || emb[0] != want[0] {
+		t.Errorf("Unexpected embedding of page: %v", err)
+	}
+	ft2 := NewFastText(":memory:")
+	defer ft2.Close()
+	if err := ft2.BuildDBFromURL(context.Background(), server.URL+"/missing.vec"); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func Test_readwordEmbdFile_Parallel(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("1000 3\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&buf, "w%d %d 0.5 -1\n", i, i)
+	}
+	i := 0
+	for emb := range readwordEmbdFile(bytes.NewReader(buf.Bytes()), &parseConfig{workers: 4}, nil) {
+		if emb.Err != nil {
+			t.Fatal(emb.Err)
+		}
+		if emb.Word != fmt.Sprintf("w%d", i) || emb.Vec[0] != float32(i) || len(emb.Vec) != 3 {
+			t.Fatalf("Expected w%d at position %d, got %s %v", i, i, emb.Word, emb.Vec)
+		}
+		i++
+	}
+	if i != 1000 {
+		t.Errorf("Expected 1000 words, got %d", i)
+	}
+	// Stopping the parsing must not block.
+	done := make(chan struct{})
+	embs := readwordEmbdFile(bytes.NewReader(buf.Bytes()), &parseConfig{workers: 4}, done)
+	<-embs
+	close(done)
+	for range embs {
+	}
+}
+
+func Test_TypedErrors(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	_, err := ft.GetEmb("NotExist1")
+	var nf *WordNotFoundError
+	if !errors.As(err, &nf) || nf.Word != "NotExist1" || !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected a WordNotFoundError, got %v", err)
+	}
+	for _, c := range []struct {
+		data string
+		line int
+		word string
+	}{
+		{"2 3\nfoo 1 2 3\nbar 1 2\n", 3, "bar"},
+		{"foo 1 2 3\nbar 1 x 3\n", 2, "bar"},
+		{"foo 1 2 3\nbaz\n", 2, "baz"},
+	} {
+		ft := NewFastText(":memory:")
+		err := ft.BuildDB(strings.NewReader(c.data))
+		ft.Close()
+		var pe *ParseError
+		if !errors.As(err, &pe) || pe.Line != c.line || pe.Word != c.word {
+			t.Errorf("Expected a parse error at line %d, word %s, got %v", c.line, c.word, err)
+		}
+	}
+	var numErr *strconv.NumError
+	ft2 := NewFastText(":memory:")
+	defer ft2.Close()
+	if err := ft2.BuildDB(strings.NewReader("foo 1 x\n")); !errors.As(err, &numErr) {
+		t.Errorf("Expected a wrapped NumError, got %v", err)
+	}
+	// The errors of expressions and importers wrap their cause.
+	_, err = ft.Eval("the - NotExist1")
+	if !errors.As(err, &nf) || nf.Word != "NotExist1" || !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected a WordNotFoundError from Eval, got %v", err)
+	}
+	ft3 := NewFastText(":memory:")
+	defer ft3.Close()
+	if err := ft3.BuildDBFromWord2Vec(strings.NewReader("2 3\nfoo \x00\x00")); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Expected a wrapped io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+func Test_ParseMode(t *testing.T) {
+	data := "5 3\nfoo 1 2 3\nnew york 4 5 6\nbar 1 NaN 3\nshort 1 2\nbaz 7 8 9\n"
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader(data)); err == nil {
+		t.Error("Expected a parse error in strict mode")
+	}
+	var repaired, skipped []int
+	ft2 := NewFastText(":memory:")
+	defer ft2.Close()
+	err := ft2.BuildDB(strings.NewReader(data), WithParseMode(ParseLenient),
+		WithBadLineFunc(func(err *ParseError, ok bool) {
+			if ok {
+				repaired = append(repaired, err.Line)
+			} else {
+				skipped = append(skipped, err.Line)
+			}
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repaired) != 1 || repaired[0] != 3 || len(skipped) != 2 || skipped[0] != 4 || skipped[1] != 5 {
+		t.Errorf("Unexpected repaired lines %v and skipped lines %v", repaired, skipped)
+	}
+	words, err := ft2.TopKWords(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(words, ",") != "foo,new york,baz" {
+		t.Errorf("Unexpected vocabulary %q", words)
+	}
+	s, err := ft2.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.BadLines != 3 {
+		t.Errorf("Expected 3 bad lines, got %d", s.BadLines)
+	}
+}
+
+func Test_Delimiters(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		opts []BuildOption
+	}{
+		{"foo\t1\t2\t3\nnew york\t4\t5\t6\n", []BuildOption{WithDelimiter("\t")}},
+		{"foo\t1 2 3\nnew york\t4 5 6\n", []BuildOption{WithWordSeparator("\t")}},
+		{"foo,1,2,3\r\nnew york,4,5,6\r\n", []BuildOption{WithDelimiter(",")}},
+	} {
+		ft := NewFastText(":memory:")
+		if err := ft.BuildDB(strings.NewReader(c.data), c.opts...); err != nil {
+			t.Fatal(err)
+		}
+		emb, err := ft.GetEmb("new york")
+		if err != nil || len(emb) != 3 || emb[2] != 6 {
+			t.Errorf("Unexpected embedding %v, %v", emb, err)
+		}
+		ft.Close()
+	}
+}
+
+func Test_GetPhraseEmb(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "new 1 0 0\nyork 0 1 0\nnew_york 0 0 1\nlos 1 1 0\nangeles 1 -1 0\n"
+	if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	emb, err := ft.GetPhraseEmb([]string{"new", "york"})
+	if err != nil || emb[2] != 1 {
+		t.Errorf("Expected the embedding of new_york, got %v, %v", emb, err)
+	}
+	emb, err = ft.GetPhraseEmb([]string{"los", "angeles"})
+	if err != nil || emb[0] != 1 || emb[1] != 0 {
+		t.Errorf("Expected the average embedding, got %v, %v", emb, err)
+	}
+	if _, err := ft.GetPhraseEmb([]string{"foo", "bar"}); err != ErrAllOOV {
+		t.Errorf("Expected ErrAllOOV, got %v", err)
+	}
+}
+
+func Test_EmbedText(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	want, err := ft.GetSentenceEmb([]string{"has", "page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	vec, hits, err := ft.EmbedTextDetails("has: page, xyzzy!")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if vec[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, vec)
+		}
+	}
+	if len(hits) != 3 || !hits[0].Found || !hits[1].Found || hits[2].Found || hits[2].Token != "xyzzy" {
+		t.Errorf("Unexpected token hits %v", hits)
+	}
+	ft2 := newTestFastText(t, WithTokenizer(TokenizerFunc(strings.Fields)))
+	defer ft2.Close()
+	if _, err := ft2.EmbedText("has: page,"); err != ErrAllOOV {
+		t.Errorf("Expected ErrAllOOV, got %v", err)
+	}
+}
+
+func Test_ClusterVocab(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	var buf bytes.Buffer
+	// Two well separated groups of words.
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&buf, "a%d %d 10 0\n", i, i%3)
+		fmt.Fprintf(&buf, "b%d %d -10 0\n", i, i%3)
+	}
+	if err := ft.BuildDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetCluster("a0"); err == nil {
+		t.Error("Expected an error without stored clusters")
+	}
+	clusters, err := ft.ClusterVocab(2, WithPersistClusters(), WithClusterBatchSize(8))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clusters) != 2 || clusters[0].Size != 20 || clusters[1].Size != 20 {
+		t.Fatalf("Unexpected clusters %v", clusters)
+	}
+	a, err := ft.GetCluster("a5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ft.GetCluster("b5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || math.Abs(float64(clusters[a].Centroid[1]-10)) > 1e-3 {
+		t.Errorf("Unexpected clusters %d and %d: %v", a, b, clusters)
+	}
+	if _, err := ft.ClusterVocab(41); err == nil {
+		t.Error("Expected an error for too many clusters")
+	}
+	models, err := ft.ListModels()
+	if err != nil || len(models) != 1 {
+		t.Errorf("Expected the clusters table not to be a model, got %v, %v", models, err)
+	}
+}
+
+func Test_ExportNpy(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	var buf bytes.Buffer
+	if err := ft.ExportNpy(&buf, []string{"page", "has"}); err != nil {
+		t.Fatal(err)
+	}
+	if (buf.Len()-2*300*4)%64 != 0 {
+		t.Errorf("Expected the data aligned on 64 bytes, got %d bytes", buf.Len())
+	}
+	a, err := readNpyHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.rows != 2 || a.cols != 300 {
+		t.Fatalf("Unexpected shape (%d, %d)", a.rows, a.cols)
+	}
+	for _, word := range []string{"page", "has"} {
+		want, _ := ft.GetEmb(word)
+		row, err := a.readRow()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if row[0] != want[0] || row[299] != want[299] {
+			t.Errorf("Unexpected row for %s", word)
+		}
+	}
+	if err := ft.ExportNpy(ioutil.Discard, []string{"nope"}); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	buf.Reset()
+	if err := ft.ExportNpz(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 2 || archive.File[0].Name != "vectors.npy" || archive.File[1].Name != "words.npy" {
+		t.Fatalf("Unexpected archive entries")
+	}
+	r, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	n, err := ft.vocabSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, err = readNpyHeader(r); err != nil || a.rows != n {
+		t.Errorf("Unexpected vectors: %v", err)
+	}
+	r2, err := archive.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r2.Close()
+	data, err := ioutil.ReadAll(r2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte("'descr': '<U")) || !bytes.Contains(data, []byte(fmt.Sprintf("'shape': (%d,)", n))) {
+		t.Errorf("Unexpected words header %q", data[:64])
+	}
+}
+
+func Test_ExportFAISS(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	var index, vocab bytes.Buffer
+	if err := ft.ExportFAISS(&index, &vocab, FAISSInnerProduct); err != nil {
+		t.Fatal(err)
+	}
+	words := strings.Split(strings.TrimSpace(vocab.String()), "\n")
+	data := index.Bytes()
+	if string(data[:4]) != "IxFI" || binary.LittleEndian.Uint32(data[4:]) != 300 ||
+		binary.LittleEndian.Uint64(data[8:]) != uint64(len(words)) {
+		t.Fatalf("Unexpected header %q", data[:16])
+	}
+	// fourcc, d, ntotal, 2 dummies, is_trained, metric, size, vectors.
+	if len(data) != 4+4+8+16+1+4+8+4*300*len(words) {
+		t.Errorf("Unexpected index size %d", len(data))
+	}
+	want, _ := ft.GetEmb(words[1])
+	if v := math.Float32frombits(binary.LittleEndian.Uint32(data[45+4*300:])); v != want[0] {
+		t.Errorf("Expected %v, got %v", want[0], v)
+	}
+}
+
+func Test_ExportAnnoy(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	var buf bytes.Buffer
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&buf, "w%d %v %v %v %v\n", i, rng.NormFloat64(), rng.NormFloat64(),
+			rng.NormFloat64(), rng.NormFloat64())
+	}
+	if err := ft.BuildDB(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var index, vocab bytes.Buffer
+	if err := ft.ExportAnnoy(&index, &vocab, 3); err != nil {
+		t.Fatal(err)
+	}
+	const dim = 4
+	n := len(strings.Split(strings.TrimSpace(vocab.String()), "\n"))
+	size := 4 * (3 + dim)
+	data := index.Bytes()
+	if n != 500 || len(data)%size != 0 {
+		t.Fatalf("Index size %d is not a multiple of the node size", len(data))
+	}
+	node := func(i int) []byte { return data[i*size : (i+1)*size] }
+	u32 := func(b []byte, i int) int { return int(binary.LittleEndian.Uint32(b[4*i:])) }
+	nodes := len(data) / size
+	// The roots are found at the end, as by AnnoyIndex::load: the copies
+	// of the 3 roots, preceded by the root of the last tree.
+	var roots []int
+	for i := nodes - 1; i >= 0 && u32(node(i), 0) == n; i-- {
+		roots = append(roots, i)
+	}
+	if len(roots) != 4 {
+		t.Fatalf("Expected 4 roots, got %d", len(roots))
+	}
+	for _, root := range roots[:3] {
+		seen := make(map[int]bool)
+		var walk func(i int)
+		walk = func(i int) {
+			if i < n {
+				seen[i] = true
+				return
+			}
+			b := node(i)
+			if k := u32(b, 0); k <= dim+2 {
+				for j := 0; j < k; j++ {
+					seen[u32(b, 1+j)] = true
+				}
+				return
+			}
+			walk(u32(b, 1))
+			walk(u32(b, 2))
+		}
+		walk(root)
+		if len(seen) != n {
+			t.Errorf("Expected %d items in the tree, got %d", n, len(seen))
+		}
+	}
+}
+
+func Test_StreamEmbs(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	words := make(chan string)
+	input := []string{"has", "NotExist1", "but"}
+	go func() {
+		defer close(words)
+		for i := 0; i < 1000; i++ {
+			words <- input[i%len(input)]
+		}
+	}()
+	i := 0
+	for r := range ft.StreamEmbs(context.Background(), words) {
+		if r.Word != input[i%len(input)] {
+			t.Fatalf("Result %d is for %q, expected %q", i, r.Word, input[i%len(input)])
+		}
+		if r.Word == "NotExist1" {
+			if !errors.Is(r.Err, ErrNoEmbFound) {
+				t.Errorf("Expected ErrNoEmbFound, got %v", r.Err)
+			}
+		} else if r.Err != nil || len(r.Emb) != 300 {
+			t.Errorf("Wrong result for %s: %v", r.Word, r.Err)
+		}
+		i++
+	}
+	if i != 1000 {
+		t.Errorf("Expected 1000 results, got %d", i)
+	}
+
+	// Cancelling the context closes the results while words is open.
+	ctx, cancel := context.WithCancel(context.Background())
+	words = make(chan string, 1)
+	words <- "has"
+	results := ft.StreamEmbs(ctx, words)
+	if r := <-results; r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	cancel()
+	for range results {
+	}
+
+	// The look-ups are done in the context of the stream.
+	tr := &testTracer{}
+	traced := newTestFastText(t, WithTracer(tr))
+	defer traced.Close()
+	words = make(chan string, len(input))
+	for _, word := range input {
+		words <- word
+	}
+	close(words)
+	ctx = context.WithValue(context.Background(), testSpanKey{}, "stream")
+	for range traced.StreamEmbs(ctx, words) {
+	}
+	for _, s := range tr.spans {
+		if s.name == "fasttext.GetEmbs" && s.parent.Value(testSpanKey{}) != "stream" {
+			t.Error("Expected the GetEmbs spans to be children of the context of the stream")
+		}
+	}
+	if len(tr.spans) == 0 {
+		t.Error("Expected GetEmbs spans")
+	}
+}
+
+func Test_GetEmbInto(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	dst := make([]float32, 300)
+	for _, word := range []string{"has", "but", "has"} {
+		if err := ft.GetEmbInto(word, dst); err != nil {
+			t.Fatal(err)
+		}
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range emb {
+			if emb[i] != dst[i] {
+				t.Fatalf("Wrong embedding for %s", word)
+			}
+		}
+	}
+	if err := ft.GetEmbInto("NotExist1", dst); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if err := ft.GetEmbInto("has", make([]float32, 10)); err == nil {
+		t.Error("Expected an error for a vector of the wrong dimension")
+	}
+	into := testing.AllocsPerRun(100, func() {
+		ft.GetEmbInto("has", dst)
+	})
+	get := testing.AllocsPerRun(100, func() {
+		ft.GetEmb("has")
+	})
+	if into >= get {
+		t.Errorf("GetEmbInto made %v allocations, GetEmb %v", into, get)
+	}
+}
+
+func Test_Warm(t *testing.T) {
+	ft := newTestFastText(t, WithCache(10))
+	defer ft.Close()
+
+	if err := ft.WarmFromReader(strings.NewReader("has\nbut\n\nNotExist1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n := ft.cache.len(); n != 2 {
+		t.Errorf("Expected 2 cached words, got %d", n)
+	}
+
+	ft = newTestFastText(t, WithPreloadWords("has"))
+	defer ft.Close()
+	if err := ft.Warm([]string{"but"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := ft.PreloadedLen(); err != nil || n != 2 {
+		t.Errorf("Expected 2 preloaded words, got %d, %v", n, err)
+	}
+
+	ft = newTestFastText(t)
+	defer ft.Close()
+	if err := ft.Warm([]string{"has"}); err == nil {
+		t.Error("Expected an error without cache")
+	}
+}
+
+func Test_Pragmas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ft := NewFastText(filepath.Join(dir, "wiki.sqlite"), WithWAL(), WithMmapSize(1048576),
+		WithBusyTimeout(2500*time.Millisecond))
+	defer ft.Close()
+	file, err := os.Open("./testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := ft.BuildDB(file); err != nil {
+		t.Fatal(err)
+	}
+	pragmas := map[string]string{"journal_mode": "wal", "mmap_size": "1048576", "busy_timeout": "2500"}
+	for name, expected := range pragmas {
+		var value string
+		if err := ft.db.QueryRow(`PRAGMA ` + name + `;`).Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		if value != expected {
+			t.Errorf("Expected %s = %s, got %s", name, expected, value)
+		}
+	}
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_Migrate(t *testing.T) {
+	// A database of the first version of the package.
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if _, err := ft.db.Exec(`CREATE TABLE fasttext(word TEXT UNIQUE, emb BLOB);`); err != nil {
+		t.Fatal(err)
+	}
+	words := []string{"has", "but", "the"}
+	for i, word := range words {
+		vec := make([]float32, 300)
+		vec[i] = 1
+		if _, err := ft.db.Exec(`INSERT INTO fasttext(word, emb) VALUES(?, ?);`,
+			word, DefaultCodec.Encode(vec)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if col, err := ft.rankColumn(); err != nil || col != "rowid" {
+		t.Fatalf("Expected ranks by rowid, got %q, %v", col, err)
+	}
+
+	if err := ft.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := ft.schemaVersion(); err != nil || version != len(migrations) {
+		t.Errorf("Expected schema version %d, got %d, %v", len(migrations), version, err)
+	}
+	if dim, ok, _ := ft.getMeta(metaDim); !ok || dim != "300" {
+		t.Errorf("Expected dimension 300, got %q", dim)
+	}
+	for i, word := range words {
+		if rank, err := ft.GetRank(word); err != nil || rank != i+1 {
+			t.Errorf("Expected rank %d for %s, got %d, %v", i+1, word, rank, err)
+		}
+		emb, err := ft.GetEmb(word)
+		if err != nil || emb[i] != 1 {
+			t.Errorf("Wrong embedding for %s: %v", word, err)
+		}
+	}
+	if col, err := ft.rankColumn(); err != nil || col != "rank" {
+		t.Errorf("Expected rank column, got %q, %v", col, err)
+	}
+	if freq, err := ft.GetFreq("has"); err != nil || freq != 0 {
+		t.Errorf("Expected no frequency, got %d, %v", freq, err)
+	}
+	// Migrating again does nothing.
+	if err := ft.Migrate(); err != nil {
+		t.Error(err)
+	}
+
+	// New databases are up to date.
+	ft = newTestFastText(t)
+	defer ft.Close()
+	if version, err := ft.schemaVersion(); err != nil || version != len(migrations) {
+		t.Errorf("Expected schema version %d, got %d, %v", len(migrations), version, err)
+	}
+}
+
+func Test_IDs(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	words, err := ft.TopKWords(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, word := range words {
+		id, err := ft.GetID(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i+1) {
+			t.Errorf("Expected ID %d for %s, got %d", i+1, word, id)
+		}
+		if w, err := ft.GetWordByID(id); err != nil || w != word {
+			t.Errorf("Expected %s for ID %d, got %q, %v", word, id, w, err)
+		}
+		byID, err := ft.GetEmbByID(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if CosineSimilarity(emb, byID) < 0.999 {
+			t.Errorf("Wrong embedding for ID %d", id)
+		}
+	}
+	// Updates keep the IDs.
+	if err := ft.PutEmb(words[0], make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := ft.GetID(words[0]); err != nil || id != 1 {
+		t.Errorf("Expected ID 1 after update, got %d, %v", id, err)
+	}
+	if _, err := ft.GetID("NotExist1"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if _, err := ft.GetEmbByID(1 << 40); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+}
+
+func Test_NearestNeighborsFiltered(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	all, err := ft.NearestNeighbors("has", 48)
+	if err != nil {
+		t.Fatal(err)
+	}
+	domain := map[string]bool{all[1].Word: true, all[4].Word: true, all[7].Word: true}
+	nn, err := ft.NearestNeighborsFiltered("has", 2, func(word string) bool {
+		return domain[word]
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 2 || nn[0] != all[1] || nn[1] != all[4] {
+		t.Errorf("Expected %v, got %v", []ScoredWord{all[1], all[4]}, nn)
+	}
+
+	candidates := []string{all[7].Word, "NotExist1", "has", all[1].Word, all[4].Word, all[1].Word}
+	nn, err = ft.NearestNeighborsAmong("has", candidates, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 2 || nn[0].Word != all[1].Word || nn[1].Word != all[4].Word ||
+		math.Abs(nn[0].Score-all[1].Score) > 1e-6 {
+		t.Errorf("Expected %v, got %v", []ScoredWord{all[1], all[4]}, nn)
+	}
+}
+
+func Test_SimilarityMatrix(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	words := []string{"has", "but", "the", "has"}
+	m, err := ft.SimilarityMatrix(words)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range words {
+		for j := range words {
+			sim, err := ft.Similarity(words[i], words[j])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(m[i][j]-sim) > 1e-5 {
+				t.Errorf("Similarity of %s and %s: expected %f, got %f", words[i], words[j], sim, m[i][j])
+			}
+		}
+	}
+	if _, err := ft.SimilarityMatrix([]string{"has", "NotExist1"}); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+}
+
+func Test_OOVPolicy(t *testing.T) {
+	ft := newTestFastText(t, WithOOVPolicy(OOVZeroVector()))
+	defer ft.Close()
+
+	emb, err := ft.GetEmb("NotExist1")
+	if err != nil || len(emb) != 300 || l2norm(emb) != 0 {
+		t.Errorf("Expected a zero vector, got %v", err)
+	}
+	embs, err := ft.GetEmbs([]string{"has", "NotExist1"})
+	if err != nil || embs[0] == nil || len(embs[1]) != 300 {
+		t.Errorf("Expected a zero vector, got %v", err)
+	}
+	// The zero vector counts in the average.
+	sent, err := ft.GetSentenceEmb([]string{"has", "NotExist1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(l2norm(sent)-l2norm(embs[0])/2) > 1e-4 {
+		t.Error("Expected the average with a zero vector")
+	}
+	dst := make([]float32, 300)
+	if err := ft.GetEmbInto("NotExist1", dst); err != nil {
+		t.Error(err)
+	}
+
+	// Of the 3-grams of "xhasx", only "has" is in the vocabulary.
+	ft = newTestFastText(t, WithOOVPolicy(OOVChain(
+		OOVError(),
+		OOVSubwords(3, 3),
+		OOVFunc(func(word string) ([]float32, error) {
+			if word == "fallback" {
+				return make([]float32, 300), nil
+			}
+			return nil, &WordNotFoundError{Word: word}
+		}))))
+	defer ft.Close()
+	has, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	emb, err = ft.GetEmb("xhasx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if CosineSimilarity(emb, has) < 0.999 {
+		t.Error("Expected the embedding of the subword has")
+	}
+	if _, err := ft.GetEmb("fallback"); err != nil {
+		t.Error(err)
+	}
+	if _, err := ft.GetEmb("NotExist1"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+}
+
+func Test_Metrics(t *testing.T) {
+	c := NewCounters()
+	ft := newTestFastText(t, WithMetrics(c), WithCache(10))
+	defer ft.Close()
+
+	ft.GetEmb("has")
+	ft.GetEmb("has")
+	ft.GetEmb("NotExist1")
+	ft.GetEmbs([]string{"has", "but", "NotExist1"})
+	ft.GetEmbInto("but", make([]float32, 300))
+	if _, err := ft.NearestNeighbors("has", 3); err != nil {
+		t.Fatal(err)
+	}
+	// The search looks up its query word too.
+	expected := CounterValues{Lookups: 6, Words: 8, MemoryHits: 4, Misses: 2, Searches: 1}
+	if v := c.Values(); v != expected {
+		t.Errorf("Expected %+v, got %+v", expected, v)
+	}
+	var total int64
+	for _, n := range c.LookupLatency.Counts() {
+		total += n
+	}
+	if total != 6 {
+		t.Errorf("Expected 6 latencies, got %d", total)
+	}
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(c.String()), &v); err != nil {
+		t.Error(err)
+	}
+}
+
+// testLogger records the messages of a Logger.
+type testLogger struct {
+	messages []string
+}
+
+func (l *testLogger) Info(msg string, args ...interface{}) {
+	l.messages = append(l.messages, msg)
+}
+
+func (l *testLogger) Warn(msg string, args ...interface{}) {
+	l.messages = append(l.messages, msg)
+}
+
+func Test_Logger(t *testing.T) {
+	logger := &testLogger{}
+	ft := NewFastText(":memory:", WithLogger(logger), WithSlowQueryLog(time.Nanosecond))
+	defer ft.Close()
+	data := "3 3\nfoo 1 2 3\nbar 1 NaN 3\nbaz 7 8 9\n"
+	if err := ft.BuildDB(strings.NewReader(data), WithParseMode(ParseLenient)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighbors("foo", 1); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"fasttext: starting build",
+		"fasttext: malformed line",
+		"fasttext: build complete",
+		"fasttext: slow look-up",
+		"fasttext: slow look-up",
+		"fasttext: slow neighbor search",
+	}
+	if strings.Join(logger.messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected messages %q, got %q", expected, logger.messages)
+	}
+}
+
+func Test_Optimize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ft := NewFastText(filepath.Join(dir, "wiki.sqlite"))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec", WithVacuum()); err != nil {
+		t.Fatal(err)
+	}
+	var stats int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM sqlite_stat1 WHERE tbl = 'fasttext';`).Scan(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats == 0 {
+		t.Error("Expected the build to gather statistics")
+	}
+	pages := func() int {
+		var n int
+		if err := ft.db.QueryRow(`PRAGMA page_count;`).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	if _, err := ft.db.Exec(`DELETE FROM fasttext WHERE word != 'has';`); err != nil {
+		t.Fatal(err)
+	}
+	before := pages()
+	if err := ft.Optimize(); err != nil {
+		t.Fatal(err)
+	}
+	if after := pages(); after >= before {
+		t.Errorf("Expected Optimize to compact the file, got %d pages from %d", after, before)
+	}
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_SampleWords(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	// Leave gaps in the rowids.
+	if _, err := ft.db.Exec(`DELETE FROM fasttext WHERE rowid % 3 = 0;`); err != nil {
+		t.Fatal(err)
+	}
+	var count int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM fasttext;`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	words, err := ft.SampleWords(20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 20 {
+		t.Fatalf("Expected 20 words, got %d", len(words))
+	}
+	seen := map[string]bool{}
+	for _, word := range words {
+		if seen[word] {
+			t.Errorf("Duplicate word %q", word)
+		}
+		seen[word] = true
+		if ok, _ := ft.Contains(word); !ok {
+			t.Errorf("Sampled word %q not in the vocabulary", word)
+		}
+	}
+	again, err := ft.SampleWords(20, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ft.SampleWords(20, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(again, " ") != strings.Join(words, " ") {
+		t.Error("Expected the same sample for the same seed")
+	}
+	if strings.Join(other, " ") == strings.Join(words, " ") {
+		t.Error("Expected another sample for another seed")
+	}
+	all, err := ft.SampleWords(count+10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != count {
+		t.Errorf("Expected the %d words of the vocabulary, got %d", count, len(all))
+	}
+
+	words, embs, err := ft.SampleEmbs(5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 5 || len(embs) != 5 {
+		t.Fatalf("Expected 5 embeddings, got %d", len(embs))
+	}
+	for i, word := range words {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if CosineSimilarity(emb, embs[i]) < 0.999 {
+			t.Errorf("Wrong embedding for %q", word)
+		}
+	}
+}
+
+func Test_Projection(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	if _, err := ft.GetEmbProjected("has"); err != ErrNoProjection {
+		t.Errorf("Expected ErrNoProjection, got %v", err)
+	}
+	ft.Close()
+
+	// Reversing the dimensions is a rotation.
+	reverse := make([][]float32, Dim)
+	for i := range reverse {
+		reverse[i] = make([]float32, Dim)
+		reverse[i][Dim-1-i] = 1
+	}
+	ft = newTestFastText(t, WithProjection(reverse))
+	defer ft.Close()
+	emb, err := ft.GetEmb("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proj, err := ft.GetEmbProjected("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range proj {
+		if proj[i] != emb[Dim-1-i] {
+			t.Fatalf("Wrong projection at %d", i)
+		}
+	}
+	nn, err := ft.NearestByVectorProjected(proj, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 3 || nn[0].Word != "has" || math.Abs(nn[0].Score-1) > 1e-5 {
+		t.Errorf("Unexpected projected neighbors %v", nn)
+	}
+	if _, err := ft.NearestByVectorProjected(emb[:2], 3); err == nil {
+		t.Error("Expected a dimension error")
+	}
+
+	// A projection to 2 dimensions read from a .npy file.
+	var buf bytes.Buffer
+	if err := ft.ExportNpy(&buf, []string{"page", "has"}); err != nil {
+		t.Fatal(err)
+	}
+	w, err := ReadProjection(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft2 := newTestFastText(t, WithProjection(w))
+	defer ft2.Close()
+	proj, err = ft2.GetEmbProjected("has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(proj) != 2 || math.Abs(float64(proj[1])-dot(emb, emb)) > 1e-3 {
+		t.Errorf("Wrong projection %v", proj)
+	}
+	nn, err = ft2.NearestByVectorProjected(proj, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 3 || nn[0].Score < 0.9999 {
+		t.Errorf("Unexpected projected neighbors %v", nn)
+	}
+}
+
+func Test_Versions(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	const current = "4 2\ncat 1 0\ndog 0 1\nkitten 0.9 0.1\npuppy 0.1 0.9\n"
+	if err := ft.BuildDB(strings.NewReader(current)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.BuildVersionDB("v1", strings.NewReader(current)); err != nil {
+		t.Fatal(err)
+	}
+	// A rotated space with kitten closer to dog than to cat.
+	if err := ft.BuildVersionDB("v2", strings.NewReader("3 2\ncat 0 1\ndog 1 0\nkitten 0.9 0.1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.BuildVersionDB("v3", strings.NewReader("1 3\ncat 1 0 0\n")); err == nil {
+		t.Error("Expected an error for a different dimension")
+	}
+	tags, err := ft.Versions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "v1,v2" {
+		t.Errorf("Unexpected versions %v", tags)
+	}
+	emb, err := ft.GetEmbVersion("cat", "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emb[0] != 0 || emb[1] != 1 {
+		t.Errorf("Wrong embedding %v", emb)
+	}
+	if _, err := ft.GetEmbVersion("puppy", "v2"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+
+	drifts, err := ft.CompareVersions("cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 2 || drifts[0].Tag != "v1" || drifts[1].Tag != "v2" {
+		t.Fatalf("Unexpected drifts %v", drifts)
+	}
+	if math.Abs(drifts[0].Cosine-1) > 1e-6 || drifts[0].NeighborOverlap != 1 {
+		t.Errorf("Expected no drift for the same version, got %+v", drifts[0])
+	}
+	if math.Abs(drifts[1].Cosine) > 1e-6 || drifts[1].NeighborOverlap == 1 {
+		t.Errorf("Expected a drift, got %+v", drifts[1])
+	}
+	drifts, err = ft.CompareVersions("puppy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].Tag != "v1" {
+		t.Errorf("Expected the versions with the word only, got %v", drifts)
+	}
+
+	if err := ft.DeleteVersion("v1"); err != nil {
+		t.Fatal(err)
+	}
+	if tags, _ := ft.Versions(); strings.Join(tags, ",") != "v2" {
+		t.Errorf("Unexpected versions %v after DeleteVersion", tags)
+	}
+	models, err := ft.ListModels()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(models, ",") != TableName {
+		t.Errorf("Unexpected models %v", models)
+	}
+}
+
+func Test_Reload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	oldPath, newPath := filepath.Join(dir, "old.sqlite"), filepath.Join(dir, "new.sqlite")
+	build := NewFastText(newPath)
+	if err := build.BuildDB(strings.NewReader("2 2\ncat 1 0\ndog 0 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	build.Close()
+
+	ft := NewFastText(oldPath, WithCache(10))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Fatal(err)
+	}
+	it, err := ft.Iter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	if err := ft.Reload(filepath.Join(dir, "missing.sqlite")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+	done := make(chan error)
+	go func() {
+		done <- ft.Reload(newPath)
+	}()
+	// The new queries run on the new database while the iterator keeps
+	// the old one open.
+	for {
+		if emb, err := ft.GetEmb("cat"); err == nil {
+			if len(emb) != 2 {
+				t.Errorf("Unexpected embedding %v", emb)
+			}
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Reload returned before the old queries were done: %v", err)
+	default:
+	}
+	for it.Next() {
+		if len(it.Emb()) != Dim {
+			t.Fatalf("Unexpected embedding of %q from the old database", it.Word())
+		}
+	}
+	it.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("has"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected the cache to be reset, got %v", err)
+	}
+
+	mem := NewFastText(":memory:")
+	defer mem.Close()
+	if err := mem.Reload(newPath); err == nil {
+		t.Error("Expected an error for an in-memory database")
+	}
+
+	// The idle connections limit of the session is restored.
+	pooled := NewFastText(oldPath, WithMaxIdleConns(4))
+	defer pooled.Close()
+	idle := func() int {
+		conns := make([]*sql.Conn, 4)
+		for i := range conns {
+			if conns[i], err = pooled.db.Conn(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, c := range conns {
+			c.Close()
+		}
+		return pooled.db.Stats().Idle
+	}
+	if n := idle(); n != 4 {
+		t.Fatalf("Expected 4 idle connections, got %d", n)
+	}
+	if err := pooled.Reload(newPath); err != nil {
+		t.Fatal(err)
+	}
+	if n := idle(); n != 4 {
+		t.Errorf("Expected 4 idle connections after Reload, got %d", n)
+	}
+}
+
+func Test_BuildDBFromWord2Vec(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w2v := NewFastText(":memory:")
+	defer w2v.Close()
+	if err := w2v.BuildDBFromWord2Vec(bytes.NewReader(writeTestWord2Vec(m))); err != nil {
+		t.Fatal(err)
+	}
+	checkSameEmbs(t, w2v, m)
+
+	truncated := bytes.NewBufferString("2 3\nking ")
+	if err := NewFastText(":memory:").BuildDBFromWord2Vec(truncated); err == nil {
+		t.Error("Should fail on a truncated file")
+	}
+}
+
+// writeTestHDF5 writes the words as variable-length strings and the
+// vectors as a contiguous float32 dataset to an HDF5 file with a root
+// group of the original format, as h5py does with libver="earliest".
+func writeTestHDF5(t *testing.T, words []string, vecs [][]float32) string {
+	var b []byte
+	alloc := func(n int) int {
+		for len(b)%8 != 0 {
+			b = append(b, 0)
+		}
+		at := len(b)
+		b = append(b, make([]byte, n)...)
+		return at
+	}
+	put := func(at int, vs ...interface{}) {
+		var buf bytes.Buffer
+		for _, v := range vs {
+			binary.Write(&buf, binary.LittleEndian, v)
+		}
+		copy(b[at:], buf.Bytes())
+	}
+	undef := ^uint64(0)
+	dim := len(vecs[0])
+
+	// Superblock version 0 with 8-byte addresses and lengths.
+	super := alloc(96)
+	copy(b, "\x89HDF\r\n\x1a\n")
+	put(super+13, uint8(8), uint8(8), uint8(0), uint16(4), uint16(16))
+	put(super+24, uint64(0), undef)
+
+	// Local heap of the names of the members of the root group.
+	names := []byte("\x00\x00\x00\x00\x00\x00\x00\x00vectors\x00words\x00\x00\x00")
+	heapData := alloc(len(names))
+	copy(b[heapData:], names)
+	heap := alloc(32)
+	copy(b[heap:], "HEAP")
+	put(heap+8, uint64(len(names)), undef, uint64(heapData))
+
+	// Global heap of the words.
+	objs := 0
+	for _, word := range words {
+		objs += 16 + (len(word)+7)&^7
+	}
+	gcol := alloc(16 + objs + 16)
+	copy(b[gcol:], "GCOL")
+	put(gcol+4, uint8(1))
+	put(gcol+8, uint64(16+objs+16))
+	at := gcol + 16
+	for i, word := range words {
+		put(at, uint16(i+1), uint16(1), uint32(0), uint64(len(word)))
+		copy(b[at+16:], word)
+		at += 16 + (len(word)+7)&^7
+	}
+
+	// Raw data of the datasets.
+	wordsData := alloc(16 * len(words))
+	for i := range words {
+		put(wordsData+16*i, uint32(len(words[i])), uint64(gcol), uint32(i+1))
+	}
+	vecsData := alloc(4 * dim * len(vecs))
+	for i, vec := range vecs {
+		put(vecsData+4*dim*i, vec)
+	}
+
+	// Object headers of version 1 of the datasets.
+	dataset := func(dims []uint64, dtype []byte, addr, size int) int {
+		space := []byte{1, byte(len(dims)), 0, 0, 0, 0, 0, 0}
+		for _, d := range dims {
+			space = append(space, make([]byte, 8)...)
+			binary.LittleEndian.PutUint64(space[len(space)-8:], d)
+		}
+		layout := make([]byte, 24)
+		layout[0], layout[1] = 3, 1
+		binary.LittleEndian.PutUint64(layout[2:], uint64(addr))
+		binary.LittleEndian.PutUint64(layout[10:], uint64(size))
+		msgs := []struct {
+			typ  uint16
+			data []byte
+		}{{0x01, space}, {0x03, dtype}, {0x08, layout}}
+		n := 0
+		for _, m := range msgs {
+			n += 8 + (len(m.data)+7)&^7
+		}
+		oh := alloc(16 + n)
+		put(oh, uint8(1), uint8(0), uint16(len(msgs)), uint32(1), uint32(n))
+		at := oh + 16
+		for _, m := range msgs {
+			size := (len(m.data) + 7) &^ 7
+			put(at, m.typ, uint16(size))
+			copy(b[at+8:], m.data)
+			at += 8 + size
+		}
+		return oh
+	}
+	// A variable-length string of 1-byte characters.
+	vlen := []byte{0x19, 0x01, 0, 0, 16, 0, 0, 0, 0x10, 0, 0, 0, 1, 0, 0, 0, 0, 0, 8, 0}
+	float := []byte{0x11, 0x20, 0x1f, 0, 4, 0, 0, 0, 0, 0, 32, 0, 23, 8, 0, 23, 127, 0, 0, 0}
+	wordsOH := dataset([]uint64{uint64(len(words))}, vlen, wordsData, 16*len(words))
+	vecsOH := dataset([]uint64{uint64(len(vecs)), uint64(dim)}, float, vecsData, 4*dim*len(vecs))
+
+	// Symbol table node and B-tree of the root group.
+	snod := alloc(8 + 2*40)
+	copy(b[snod:], "SNOD")
+	put(snod+4, uint8(1), uint8(0), uint16(2))
+	put(snod+8, uint64(8), uint64(vecsOH))
+	put(snod+48, uint64(16), uint64(wordsOH))
+	tree := alloc(24 + 24)
+	copy(b[tree:], "TREE")
+	put(tree+4, uint8(0), uint8(0), uint16(1), undef, undef)
+	put(tree+24, uint64(0), uint64(snod), uint64(16))
+
+	// Object header of the root group.
+	root := alloc(16 + 24)
+	put(root, uint8(1), uint8(0), uint16(1), uint32(1), uint32(24))
+	put(root+16, uint16(0x11), uint16(16), uint32(0), uint64(tree), uint64(heap))
+
+	// The end of file address and the root symbol table entry.
+	put(super+40, uint64(len(b)), undef)
+	put(super+56, uint64(0), uint64(root), uint32(1), uint32(0), uint64(tree), uint64(heap))
+
+	file, err := ioutil.TempFile("", "test.h5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if _, err := file.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	return file.Name()
+}
+
+func Test_BuildDBFromHDF5(t *testing.T) {
+	words := []string{"king", "queen", "New_York"}
+	vecs := [][]float32{{0.1, 0.2, 0.3, 0.4}, {0.5, -0.5, 1.0, 2.0}, {-1.0, 0.0, 0.25, 3.5}}
+	filename := writeTestHDF5(t, words, vecs)
+	defer os.Remove(filename)
+
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromHDF5(filename, "/words", "/vectors"); err != nil {
+		t.Fatal(err)
+	}
+	for i, word := range words {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j := range vecs[i] {
+			if emb[j] != vecs[i][j] {
+				t.Errorf("%s: expected %v, got %v", word, vecs[i], emb)
+				break
+			}
+		}
+	}
+	if err := NewFastText(":memory:").BuildDBFromHDF5(filename, "words", "missing"); err == nil {
+		t.Error("Should fail on a missing dataset")
+	}
+}
+
+func Test_HDF5Malformed(t *testing.T) {
+	filename := writeTestHDF5(t, []string{"king", "queen"}, [][]float32{{0.1, 0.2}, {0.3, 0.4}})
+	defer os.Remove(filename)
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	read := func(b []byte) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		f, err := openHDF5(bytes.NewReader(b))
+		if err != nil {
+			return err
+		}
+		if _, err := f.readStrings("words"); err != nil {
+			return err
+		}
+		_, err = f.readMatrix("vectors")
+		return err
+	}
+	if err := read(data); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < len(data); n++ {
+		if err := read(data[:n]); err == nil || strings.HasPrefix(err.Error(), "panic") {
+			t.Fatalf("Truncated to %d bytes: expected an error, got %v", n, err)
+		}
+	}
+	// Clearing or setting any byte, e.g. the sizes of the messages,
+	// fails or not but does not panic.
+	b := make([]byte, len(data))
+	for i := range data {
+		for _, v := range []byte{0, 0xff} {
+			copy(b, data)
+			b[i] = v
+			if err := read(b); err != nil && strings.HasPrefix(err.Error(), "panic") {
+				t.Fatalf("Byte %d set to %#x: %v", i, v, err)
+			}
+		}
+	}
+}
+
+func writeTestWord2Vec(m *Matrix) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%d %d\n", m.Len(), m.Dim())
+	for i, word := range m.Words {
+		buf.WriteString(word + " ")
+		binary.Write(&buf, binary.LittleEndian, m.Row(i))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func checkSameEmbs(t *testing.T, ft *FastText, m *Matrix) {
+	for i, word := range m.Words {
+		emb, err := ft.GetEmb(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, v := range m.Row(i) {
+			if emb[j] != v {
+				t.Fatalf("%s: expected %v, got %v", word, m.Row(i), emb)
+			}
+		}
+	}
+}
+
+func Test_BuildDB_Word2VecBinary(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	m, err := ft.LoadMatrix()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(writeTestWord2Vec(m))
+	zw.Close()
+	file, err := ioutil.TempFile("", "vectors.bin.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.Write(gz.Bytes())
+	file.Close()
+
+	w2v := NewFastText(":memory:")
+	defer w2v.Close()
+	if err := w2v.BuildDBFromFile(file.Name()); err != nil {
+		t.Fatal(err)
+	}
+	checkSameEmbs(t, w2v, m)
+
+	if !isWord2VecBinary(bufio.NewReader(bytes.NewReader([]byte("1 2\nword \x00\x00\x80?\x00\x00\x00@")))) {
+		t.Error("Should detect a word2vec binary file")
+	}
+	if isWord2VecBinary(bufio.NewReader(bytes.NewReader([]byte("1 2\nword 1.5 -2e-3\n")))) {
+		t.Error("Should not detect a text file as binary")
+	}
+}
+
+func Test_GetEmbDims(t *testing.T) {
+	for _, opts := range [][]BuildOption{
+		nil,
+		{WithPrecision(Float16), WithByteOrder(binary.LittleEndian)},
+		{WithPrecision(Int8)},
+		{WithCompression(Zstd)},
+	} {
+		ft := NewFastText(":memory:")
+		file, err := os.Open("./testdata/wiki.en.vec")
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ft.BuildDB(file, opts...)
+		file.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		emb, err := ft.GetEmb("has")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dims := []int{299, 0, 42, 0}
+		vec, err := ft.GetEmbDims("has", dims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, d := range dims {
+			if vec[i] != emb[d] {
+				t.Errorf("Dimension %d: expected %v, got %v", d, emb[d], vec[i])
+			}
+		}
+		prefix, err := ft.GetEmbPrefix("has", 50)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prefix) != 50 || prefix[49] != emb[49] {
+			t.Errorf("Unexpected prefix %v", prefix)
+		}
+		if _, err := ft.GetEmbDims("has", []int{300}); err == nil {
+			t.Error("Should reject a dimension out of range")
+		}
+		if _, err := ft.GetEmbPrefix("nonexistent", 10); !errors.Is(err, ErrNoEmbFound) {
+			t.Errorf("Expected ErrNoEmbFound, got %v", err)
+		}
+		ft.Close()
+	}
+}
+
+func Test_Replicas(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	primary := filepath.Join(dir, "primary.sqlite")
+	build := NewFastText(primary)
+	if err := build.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	build.Close()
+	data, err := ioutil.ReadFile(primary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, corrupt := filepath.Join(dir, "copy.sqlite"), filepath.Join(dir, "corrupt.sqlite")
+	ioutil.WriteFile(copied, data, 0644)
+	ioutil.WriteFile(corrupt, bytes.Repeat([]byte("garbage!"), 1024), 0644)
+
+	ft := NewFastText(primary, WithReplicas(copied, corrupt, filepath.Join(dir, "missing.sqlite")))
+	defer ft.Close()
+	var conns []*sql.Conn
+	for i := 0; i < 4; i++ {
+		conn, err := ft.db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		conns = append(conns, conn)
+	}
+	status := ft.Replicas()
+	if len(status) != 4 || status[0].Path != primary {
+		t.Fatalf("Unexpected replicas %+v", status)
+	}
+	if status[0].Conns == 0 || status[1].Conns == 0 {
+		t.Errorf("Expected connections to both copies, got %+v", status)
+	}
+	if status[3].Healthy || status[3].LastError == nil {
+		t.Errorf("Expected the missing copy to fail, got %+v", status[3])
+	}
+	for _, conn := range conns {
+		conn.Close()
+	}
+
+	errs := make(chan error, 8)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			for j := 0; j < 20; j++ {
+				if _, err := ft.GetEmb("has"); err != nil {
+					errs <- err
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if status := ft.Replicas(); status[2].LastError == nil {
+		t.Errorf("Expected the corrupt copy to fail, got %+v", status[2])
+	}
+	if err := ft.Reload(copied); err == nil {
+		t.Error("Should not reload a session with replicas")
+	}
+}
+
+func Test_BuildDBFromFS(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFS(os.DirFS("testdata"), "wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+	if err := ft.BuildDBFromFS(os.DirFS("testdata"), "missing.vec"); err == nil {
+		t.Error("Should fail on a missing file")
+	}
+}
+
+func Test_Centroid(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+
+	centroid, err := ft.Centroid([]string{"has", "have"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	has, _ := ft.GetEmb("has")
+	have, _ := ft.GetEmb("have")
+	for i := range centroid {
+		if math.Abs(float64(centroid[i]-(has[i]+have[i])/2)) > 1e-6 {
+			t.Fatalf("Wrong centroid at %d: %v", i, centroid[i])
+		}
+	}
+	if _, err := ft.Centroid([]string{"has", "nonexistent"}); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+
+	medoid, err := ft.Medoid([]string{"has", "have"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if medoid != "has" && medoid != "have" {
+		t.Errorf("Unexpected medoid %s", medoid)
+	}
+	medoid, err = ft.Medoid([]string{"has", "have"}, WithFilter(func(word string, _ float64) bool {
+		return word != "has" && word != "have"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if medoid == "has" || medoid == "have" {
+		t.Errorf("Expected the words to be excluded, got %s", medoid)
+	}
+}
+
+func Test_DoesntMatch(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "5 2\nbreakfast 1 0.1\nlunch 1 0.2\ndinner 0.9 0.1\ncereal 0.2 1\nmilk 0.3 1\n"
+	if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	odd, err := ft.DoesntMatch([]string{"breakfast", "cereal", "dinner", "lunch", "nonexistent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if odd != "cereal" {
+		t.Errorf("Expected cereal, got %s", odd)
+	}
+	if _, err := ft.DoesntMatch([]string{"nonexistent"}); err != ErrAllOOV {
+		t.Errorf("Expected ErrAllOOV, got %v", err)
+	}
+}
+
+func Test_DetectByteOrder(t *testing.T) {
+	for _, order := range []binary.ByteOrder{binary.BigEndian, binary.LittleEndian} {
+		// A database of the first version of the package, without metadata.
+		ft := NewFastText(":memory:")
+		if _, err := ft.db.Exec(`CREATE TABLE fasttext(word TEXT UNIQUE, emb BLOB);`); err != nil {
+			t.Fatal(err)
+		}
+		codec := Codec{Precision: Float32, Order: order}
+		vec := []float32{0.25, -1.5, 0.003, 12}
+		if _, err := ft.db.Exec(`INSERT INTO fasttext(word, emb) VALUES(?, ?);`,
+			"word", codec.Encode(vec)); err != nil {
+			t.Fatal(err)
+		}
+		emb, err := ft.GetEmb("word")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range vec {
+			if emb[i] != vec[i] {
+				t.Errorf("%s: expected %v, got %v", order, vec, emb)
+				break
+			}
+		}
+		if err := ft.Migrate(); err != nil {
+			t.Fatal(err)
+		}
+		if recorded, _, _ := ft.getMeta(metaByteOrder); recorded != byteOrderName(order) {
+			t.Errorf("Expected %s byte order recorded, got %q", order, recorded)
+		}
+		ft.Close()
+	}
+}
+
+func Test_LocalCopy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "remote.sqlite")
+	build := NewFastText(path)
+	if err := build.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	build.Close()
+	ioutil.WriteFile(path+".sidecar", []byte("sidecar"), 0644)
+
+	local := filepath.Join(dir, "local")
+	os.Mkdir(local, 0755)
+	var copied, total int64
+	ft := NewFastText(path, WithLocalCopy(local), WithLocalCopyProgress(func(c, t int64) {
+		copied, total = c, t
+	}))
+	if copied == 0 || copied != total {
+		t.Errorf("Expected the copy to complete, got %d of %d bytes", copied, total)
+	}
+	copyPath := ft.dbPath()
+	if filepath.Dir(filepath.Dir(copyPath)) != local {
+		t.Errorf("Expected a copy in %s, got %s", local, copyPath)
+	}
+	if data, err := ioutil.ReadFile(copyPath + ".sidecar"); err != nil || string(data) != "sidecar" {
+		t.Errorf("Expected the sidecar to be copied, got %q, %v", data, err)
+	}
+	// The session does not depend on the original file.
+	os.Remove(path)
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+	if err := ft.Close(); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(copyPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the copy to be removed, got %v", err)
+	}
+}
+
+func Test_InstallDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	built := filepath.Join(dir, "built.sqlite")
+	build := NewFastText(built)
+	if err := build.BuildDB(strings.NewReader("2 2\ncat 1 0\ndog 0 1\n")); err != nil {
+		t.Fatal(err)
+	}
+	build.Close()
+	data, err := ioutil.ReadFile(built)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(data)
+	zw.Close()
+
+	path := filepath.Join(dir, "installed.sqlite")
+	if err := InstallDB(&gz, path); err != nil {
+		t.Fatal(err)
+	}
+	ft := NewFastText(path)
+	defer ft.Close()
+	if emb, err := ft.GetEmb("dog"); err != nil || emb[1] != 1 {
+		t.Errorf("Unexpected embedding %v, %v", emb, err)
+	}
+
+	if err := InstallDB(strings.NewReader("not a database"), path); err == nil {
+		t.Error("Should reject a file that is not a database")
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 2 {
+		t.Errorf("Expected no temporary file left, got %d files", len(entries))
+	}
+	if _, err := ft.GetEmb("cat"); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_GetEmbAuto(t *testing.T) {
+	detect := ScriptLangDetector(map[*unicode.RangeTable]string{unicode.Cyrillic: "ru"})
+	ft := NewFastText(":memory:", WithLangDetector(detect), WithLangPriority("fr", "en"))
+	defer ft.Close()
+	for lang, data := range map[string]string{
+		"en": "2 2\nchat 1 0\ncat 1 1\n",
+		"fr": "2 2\nchat 0 1\nchien 0 2\n",
+		"ru": "1 2\nкот 2 2\n",
+	} {
+		if err := ft.BuildLangDB(lang, strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for word, want := range map[string]string{"chat": "fr", "cat": "en", "кот": "ru"} {
+		lang, emb, err := ft.GetEmbAuto(word)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lang != want || len(emb) != 2 {
+			t.Errorf("%s: expected %s, got %s %v", word, want, lang, emb)
+		}
+	}
+	if _, _, err := ft.GetEmbAuto("nonexistent"); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+}
+
+func Test_ExportKNNGraph(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	data := "4 2\ncat 1 0.1\ndog 1 0.2\nred 0.1 1\nblue 0.2 1\n"
+	if err := ft.BuildDB(strings.NewReader(data)); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := ft.ExportKNNGraph(&buf, 1, GraphEdgeList); err != nil {
+		t.Fatal(err)
+	}
+	edges := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			t.Fatalf("Malformed edge %q", line)
+		}
+		edges[fields[0]] = fields[1]
+	}
+	if len(edges) != 4 || edges["cat"] != "dog" || edges["blue"] != "red" {
+		t.Errorf("Unexpected edges %v", edges)
+	}
+
+	buf.Reset()
+	if err := ft.ExportKNNGraph(&buf, 2, GraphML, WithGraphWords([]string{"cat", "dog", "red", "nonexistent"})); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Nodes []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"graph>node"`
+		Edges []struct {
+			Source string  `xml:"source,attr"`
+			Target string  `xml:"target,attr"`
+			Weight float64 `xml:"data"`
+		} `xml:"graph>edge"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Nodes) != 3 || len(doc.Edges) != 6 {
+		t.Errorf("Expected 3 nodes and 6 edges, got %d and %d", len(doc.Nodes), len(doc.Edges))
+	}
+	if doc.Edges[0].Source != "cat" || doc.Edges[0].Target != "dog" || doc.Edges[0].Weight < 0.9 {
+		t.Errorf("Unexpected edge %+v", doc.Edges[0])
+	}
+}
+
+func Test_EmbedDocument(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader("3 2\nthe 1 0\ncat 0 1\nsat 0 2\n")); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{"the": 3, "cat": 1, "sat": 1, "nonexistent": 2}
+	idf := map[string]float64{"the": 0.1, "cat": 2}
+	vec, err := ft.EmbedDocument(counts, idf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sat gets the largest IDF, 2.
+	total := 3*0.1 + 2 + 2
+	want := []float64{3 * 0.1 / total, (2 + 2*2) / total}
+	for i := range want {
+		if math.Abs(float64(vec[i])-want[i]) > 1e-6 {
+			t.Fatalf("Expected %v, got %v", want, vec)
+		}
+	}
+	vec, err = ft.EmbedDocument(counts, idf, WithDefaultIDF(0), WithSentenceOOV(OOVZero))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The missing word and sat weigh nothing.
+	if math.Abs(float64(vec[0])-0.3/2.3) > 1e-6 {
+		t.Errorf("Unexpected embedding %v", vec)
+	}
+	if _, err := ft.EmbedDocument(counts, idf, WithSentenceOOV(OOVFail)); !errors.Is(err, ErrNoEmbFound) {
+		t.Errorf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if _, err := ft.EmbedDocument(map[string]int{"nonexistent": 1}, idf); err != ErrAllOOV {
+		t.Errorf("Expected ErrAllOOV, got %v", err)
+	}
+}
+
+func Test_QueryTimeout(t *testing.T) {
+	ft := NewFastText(":memory:", WithQueryTimeout(time.Minute))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("the"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighbors("the", 3); err != nil {
+		t.Fatal(err)
+	}
+	// Every query runs past a timeout of a nanosecond.
+	ft.queryTimeout = time.Nanosecond
+	_, err := ft.GetEmb("the")
+	var timeout *QueryTimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a QueryTimeoutError, got %v", err)
+	}
+	if _, err := ft.Contains("the"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout of Contains, got %v", err)
+	}
+	if _, err := ft.GetEmbs([]string{"the", "of"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout of GetEmbs, got %v", err)
+	}
+	if _, err := ft.NearestByVector(make([]float32, Dim), 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected a timeout of NearestByVector, got %v", err)
+	}
+}
+
+func Test_ConflictPolicy(t *testing.T) {
+	data := "4 2\nParis 1 0\nrome 0 1\nparis 2 0\nPARIS 3 0\n"
+	for _, c := range []struct {
+		policy ConflictPolicy
+		want   float32
+	}{
+		{ConflictKeepFirst, 1},
+		{ConflictOverwrite, 3},
+		{ConflictSkip, 0},
+	} {
+		ft := NewFastText(":memory:", WithLowercase())
+		if err := ft.BuildDB(strings.NewReader(data), WithConflictPolicy(c.policy)); err != nil {
+			t.Fatalf("%v: %v", c.policy, err)
+		}
+		emb, err := ft.GetEmb("paris")
+		if c.want == 0 {
+			if !errors.Is(err, ErrNoEmbFound) {
+				t.Errorf("%v: expected paris to be left out, got %v, %v", c.policy, emb, err)
+			}
+		} else if err != nil || emb[0] != c.want {
+			t.Errorf("%v: expected %v, got %v, %v", c.policy, c.want, emb, err)
+		}
+		if _, err := ft.GetEmb("rome"); err != nil {
+			t.Errorf("%v: %v", c.policy, err)
+		}
+		ft.Close()
+	}
+
+	// Without normalizers, the duplicates of the input are resolved too.
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	err := ft.BuildDB(strings.NewReader("3 2\nthe 1 0\nthe 2 0\nof 0 1\n"), WithConflictPolicy(ConflictError))
+	var dups *DuplicateWordsError
+	if !errors.As(err, &dups) || dups.Count != 1 || len(dups.Words) != 1 || dups.Words[0] != "the" {
+		t.Fatalf("Expected a report of the duplicate, got %v", err)
+	}
+	// The build is resumed with another policy.
+	if err := ft.BuildDB(strings.NewReader("3 2\nthe 1 0\nthe 2 0\nof 0 1\n"),
+		WithConflictPolicy(ConflictOverwrite)); err != nil {
+		t.Fatal(err)
+	}
+	if emb, err := ft.GetEmb("the"); err != nil || emb[0] != 2 {
+		t.Errorf("Expected the last embedding, got %v, %v", emb, err)
+	}
+	var n int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM fasttext;`).Scan(&n); err != nil || n != 2 {
+		t.Errorf("Expected 2 words, got %d, %v", n, err)
+	}
+}
+
+func Test_GetEmbRaw(t *testing.T) {
+	for _, c := range []Compression{NoCompression, Zstd} {
+		ft := NewFastText(":memory:", WithCache(10))
+		if err := ft.BuildDBFromFile("./testdata/wiki.en.vec", WithCompression(c)); err != nil {
+			t.Fatal(err)
+		}
+		want, err := ft.GetEmb("the")
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ft.GetEmbRaw("the")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := make([]float32, len(want))
+		if err := ft.DecodeVec(data, dst); err != nil {
+			t.Fatal(err)
+		}
+		for i := range want {
+			if dst[i] != want[i] {
+				t.Fatalf("%v: expected %v, got %v", c, want, dst)
+			}
+		}
+		if c == NoCompression {
+			codec, _ := ft.Codec()
+			if vec, err := codec.Decode(data); err != nil || vec[0] != want[0] {
+				t.Errorf("Expected the blob to be decoded by the codec, got %v, %v", vec, err)
+			}
+		}
+		if _, err := ft.GetEmbRaw("nonexistent"); !errors.Is(err, ErrNoEmbFound) {
+			t.Errorf("Expected ErrNoEmbFound, got %v", err)
+		}
+		if err := ft.DecodeVec(data, dst[:1]); err == nil {
+			t.Error("Expected an error for a vector of the wrong dimension")
+		}
+		ft.Close()
+	}
+}
+
+func Test_Writer(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	w := ft.NewWriter(WithPrecision(Float16))
+	vec := []float32{1, 0, 0}
+	for i := 0; i < 100; i++ {
+		vec[0] = float32(i)
+		if err := w.Put(fmt.Sprintf("word%d", i), vec); err != nil {
+			t.Fatal(err)
+		}
+		if i == 50 {
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Put("late", vec); err != ErrWriterClosed {
+		t.Errorf("Expected ErrWriterClosed, got %v", err)
+	}
+	emb, err := ft.GetEmb("word42")
+	if err != nil || emb[0] != 42 {
+		t.Errorf("Expected the embedding put, got %v, %v", emb, err)
+	}
+	if codec, _ := ft.Codec(); codec.Precision != Float16 {
+		t.Errorf("Expected float16 vectors, got %v", codec)
+	}
+
+	bad := NewFastText(":memory:")
+	defer bad.Close()
+	w = bad.NewWriter()
+	w.Put("a", []float32{1, 2})
+	w.Put("b", []float32{1, 2, 3})
+	if err := w.Close(); err == nil {
+		t.Error("Expected an error for vectors of different dimensions")
+	}
+}
+
+func Test_SimilarWordsAbove(t *testing.T) {
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	all, err := ft.NearestNeighbors("the", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	min := all[len(all)/2].Score
+	var want []ScoredWord
+	for _, s := range all {
+		if s.Score > min {
+			want = append(want, s)
+		}
+	}
+	got, err := ft.SimilarWordsAbove("the", min, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Expected %d words, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Expected %v at %d, got %v", want[i], i, got[i])
+		}
+	}
+	got, err = ft.SimilarWordsAbove("the", min, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Expected %v, got %v", want[:2], got)
+	}
+}
+
+func Test_NNCache(t *testing.T) {
+	ft := NewFastText(":memory:", WithNNCache())
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	want, err := ft.NearestNeighbors("the", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tamper with the cached list to check it is served.
+	if _, err := ft.db.Exec(`UPDATE fasttext_nn_cache SET neighbors = ? WHERE word = 'the';`,
+		`[{"Word":"a","Score":0.9},{"Word":"b","Score":0.8}]`); err != nil {
+		t.Fatal(err)
+	}
+	nn, err := ft.NearestNeighbors("the", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn) != 1 || nn[0].Word != "a" {
+		t.Errorf("Expected the cached neighbors, got %v", nn)
+	}
+	// A longer list is not in the cache.
+	if nn, err = ft.NearestNeighbors("the", 6); err != nil || len(nn) != 6 || nn[0] != want[0] {
+		t.Errorf("Expected the computed neighbors, got %v, %v", nn, err)
+	}
+	if err := ft.PutEmb("new", make([]float32, Dim)); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := ft.db.QueryRow(`SELECT COUNT(*) FROM fasttext_nn_cache;`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("Expected the cache to be cleared, got %d, %v", n, err)
+	}
+
+	// Lists cached on a replica are dropped by a sync.
+	origin := newTestFastText(t)
+	defer origin.Close()
+	replica := newTestFastText(t, WithNNCache())
+	defer replica.Close()
+	before, err := replica.NearestNeighbors("has", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := origin.DeleteEmb(before[0].Word); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := replica.SyncManifest(64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	delta, err := origin.SyncDelta(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gen, _, _ := replica.getMeta(metaGeneration)
+	if err := replica.ApplySyncDelta(delta); err != nil {
+		t.Fatal(err)
+	}
+	if after, _, _ := replica.getMeta(metaGeneration); after == gen {
+		t.Errorf("Expected a new generation after the sync, got %s", after)
+	}
+	nn, err = replica.NearestNeighbors("has", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sw := range nn {
+		if sw.Word == before[0].Word {
+			t.Errorf("Expected %s deleted by the sync to be gone, got %v", sw.Word, nn)
+		}
+	}
+}
+
+func Test_Backup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	// A reader is in the middle of an iteration.
+	it, err := ft.Iter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer it.Close()
+	if !it.Next() {
+		t.Fatal(it.Err())
+	}
+	path := filepath.Join(dir, "backup.sqlite")
+	var done, total int
+	if err := ft.Backup(context.Background(), path, func(d, t int) { done, total = d, t }); err != nil {
+		t.Fatal(err)
+	}
+	if total == 0 || done != total {
+		t.Errorf("Expected the progress to reach the total, got %d of %d", done, total)
+	}
+	backup := NewFastText(path)
+	defer backup.Close()
+	want, err := ft.GetEmb("the")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := backup.GetEmb("the")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("Expected %v, got %v", want, got)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := ft.Backup(ctx, filepath.Join(dir, "canceled.sqlite"), nil); err == nil {
+		t.Error("Expected an error for a canceled backup")
+	}
+}
+
+func Test_SampleNegatives(t *testing.T) {
+	prob, alias := aliasTable([]float64{1, 2, 3, 4}, 10)
+	s := &NegativeSampler{words: []string{"a", "b", "c", "d"}, prob: prob, alias: alias}
+	rng := rand.New(rand.NewSource(1))
+	counts := make(map[string]int)
+	for i := 0; i < 100000; i++ {
+		counts[s.Draw(rng)]++
+	}
+	for i, word := range s.words {
+		if p := float64(counts[word]) / 100000; math.Abs(p-float64(i+1)/10) > 0.01 {
+			t.Errorf("Expected %s drawn with probability %v, got %v", word, float64(i+1)/10, p)
+		}
+	}
+
+	ft := newTestFastText(t)
+	defer ft.Close()
+	top, err := ft.TopKWords(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words, err := ft.SampleNegatives(10000, top[:1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(words) != 10000 {
+		t.Fatalf("Expected 10000 words, got %d", len(words))
+	}
+	counts = make(map[string]int)
+	for _, word := range words {
+		counts[word]++
+	}
+	if counts[top[0]] != 0 {
+		t.Errorf("Expected %q to be excluded", top[0])
+	}
+	// The second most frequent word is drawn more than the average.
+	if counts[top[1]] <= 10000/len(counts) {
+		t.Errorf("Expected %q to be drawn often, got %d", top[1], counts[top[1]])
+	}
+}
+
+func Test_ExtractFeatures(t *testing.T) {
+	ft := newTestFastText(t)
+	defer ft.Close()
+	docs := [][]string{{"the", "of"}, {"nonexistent"}, {"and"}, {"the", "nonexistent"}}
+	in := make(chan []string)
+	go func() {
+		for i := 0; i < 25; i++ {
+			for _, doc := range docs {
+				in <- doc
+			}
+		}
+		close(in)
+	}()
+	var n int
+	for dv := range ft.ExtractFeatures(context.Background(), in, 3, WithL2Normalize()) {
+		if dv.Index != n {
+			t.Fatalf("Expected document %d, got %d", n, dv.Index)
+		}
+		doc := docs[n%len(docs)]
+		want, err := ft.GetSentenceEmb(doc, WithL2Normalize())
+		if err != dv.Err {
+			t.Errorf("Document %d: expected error %v, got %v", n, err, dv.Err)
+		}
+		for i := range want {
+			if dv.Vec[i] != want[i] {
+				t.Errorf("Document %d: unexpected vector", n)
+				break
+			}
+		}
+		n++
+	}
+	if n != 25*len(docs) {
+		t.Errorf("Expected %d documents, got %d", 25*len(docs), n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range ft.ExtractFeatures(ctx, make(chan []string), 0) {
+	}
+
+	// The documents are embedded in the context of the extraction.
+	tr := &testTracer{}
+	traced := newTestFastText(t, WithTracer(tr))
+	defer traced.Close()
+	in = make(chan []string, len(docs))
+	for _, doc := range docs {
+		in <- doc
+	}
+	close(in)
+	ctx = context.WithValue(context.Background(), testSpanKey{}, "features")
+	for range traced.ExtractFeatures(ctx, in, 2) {
+	}
+	for _, s := range tr.spans {
+		if s.name == "fasttext.GetEmbs" && s.parent.Value(testSpanKey{}) != "features" {
+			t.Error("Expected the GetEmbs spans to be children of the context of the extraction")
+		}
+	}
+	if len(tr.spans) != len(docs) {
+		t.Errorf("Expected %d GetEmbs spans, got %d", len(docs), len(tr.spans))
+	}
+}
+
+type testSpan struct {
+	name   string
+	attrs  []interface{}
+	err    error
+	parent context.Context
+}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []*testSpan
+}
+
+type testSpanKey struct{}
+
+func (tr *testTracer) Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, func(error)) {
+	s := &testSpan{name: name, attrs: attrs, parent: ctx}
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, s)
+	tr.mu.Unlock()
+	return context.WithValue(ctx, testSpanKey{}, s), func(err error) { s.err = err }
+}
+
+func Test_Tracing(t *testing.T) {
+	tr := &testTracer{}
+	var opened int
+	ft := newTestFastText(t, WithTracer(tr), WithOpenDB(func(c driver.Connector) *sql.DB {
+		opened++
+		return sql.OpenDB(c)
+	}))
+	defer ft.Close()
+	if opened != 1 {
+		t.Errorf("Expected the database opened once by WithOpenDB, got %d", opened)
+	}
+
+	if _, err := ft.GetEmb("the"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("nonexistent"); !errors.Is(err, ErrNoEmbFound) {
+		t.Fatalf("Expected ErrNoEmbFound, got %v", err)
+	}
+	if _, err := ft.GetEmbs([]string{"the", "of", "and"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.NearestNeighbors("the", 3); err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		name  string
+		words int
+	}{
+		{"fasttext.GetEmb", 1},
+		{"fasttext.GetEmb", 1},
+		{"fasttext.GetEmbs", 3},
+		{"fasttext.NearestNeighbors", 1},
+		{"fasttext.GetEmb", 1},
+	}
+	if len(tr.spans) != len(want) {
+		t.Fatalf("Expected %d spans, got %d", len(want), len(tr.spans))
+	}
+	for i, w := range want {
+		s := tr.spans[i]
+		if s.name != w.name || len(s.attrs) < 2 || s.attrs[0] != "words" || s.attrs[1] != w.words {
+			t.Errorf("Span %d: expected %s with %d words, got %s %v", i, w.name, w.words, s.name, s.attrs)
+		}
+	}
+	if !errors.Is(tr.spans[1].err, ErrNoEmbFound) {
+		t.Errorf("Expected the span of a missing word to end with ErrNoEmbFound, got %v", tr.spans[1].err)
+	}
+	if tr.spans[4].parent.Value(testSpanKey{}) != tr.spans[3] {
+		t.Error("Expected the GetEmb span to be a child of the NearestNeighbors span")
+	}
+	if nn := tr.spans[3].attrs; len(nn) != 4 || nn[2] != "k" || nn[3] != 3 {
+		t.Errorf("Expected the k attribute of NearestNeighbors, got %v", nn)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ft.NearestNeighborsContext(ctx, "the", 3); err == nil {
+		t.Error("Expected an error from a canceled context")
+	}
+}
+
+func Test_WithPayloads(t *testing.T) {
+	data := "4 2\nParis 1 0\nrome 0 1\nparis 2 0\nPARIS 3 0\n"
+	type meta struct {
+		Calls int `json:"calls"`
+	}
+	for _, c := range []struct {
+		policy ConflictPolicy
+		want   int
+	}{
+		{ConflictKeepFirst, 1},
+		{ConflictOverwrite, 3},
+		{ConflictSkip, 0},
+	} {
+		calls := make(map[string]int)
+		payloads := func(word string) ([]byte, error) {
+			calls[word]++
+			if word == "rome" {
+				return nil, nil
+			}
+			return json.Marshal(meta{Calls: calls[word]})
+		}
+		ft := NewFastText(":memory:", WithLowercase())
+		if err := ft.BuildDB(strings.NewReader(data), WithConflictPolicy(c.policy), WithPayloads(payloads)); err != nil {
+			t.Fatalf("%v: %v", c.policy, err)
+		}
+		var got meta
+		emb, err := ft.GetEmbWithMeta("Paris", &got)
+		if c.want == 0 {
+			if p, err := ft.Payload("paris"); err != nil || p != nil {
+				t.Errorf("%v: expected the payload of paris left out, got %s, %v", c.policy, p, err)
+			}
+		} else if err != nil || len(emb) != 2 || got.Calls != c.want {
+			t.Errorf("%v: expected payload %d, got %v, %v, %v", c.policy, c.want, got, emb, err)
+		}
+		got = meta{Calls: -1}
+		if _, err := ft.GetEmbWithMeta("rome", &got); err != nil || got.Calls != -1 {
+			t.Errorf("%v: expected rome without payload, got %v, %v", c.policy, got, err)
+		}
+		ft.Close()
+	}
+
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	errPayload := errors.New("payload")
+	err := ft.BuildDB(strings.NewReader(data), WithPayloads(func(string) ([]byte, error) {
+		return nil, errPayload
+	}))
+	if !errors.Is(err, errPayload) {
+		t.Errorf("Expected the error of the payload function, got %v", err)
+	}
+}
+
+func Test_IDsNotReused(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fasttext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	dbFilename := filepath.Join(dir, "wiki.sqlite")
+	ft := NewFastText(dbFilename)
+	if err := ft.BuildDBFromFile("./testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	var last string
+	var maxID int64
+	if err := ft.db.QueryRow(`SELECT word, id FROM fasttext ORDER BY id DESC LIMIT 1;`).Scan(&last, &maxID); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.DeleteEmb(last); err != nil {
+		t.Fatal(err)
+	}
+	if err := ft.PutEmb("brandnew", make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := ft.GetID("brandnew"); err != nil || id != maxID+1 {
+		t.Errorf("Expected new ID %d after deleting %s, got %d, %v", maxID+1, last, id, err)
+	}
+	if err := ft.DeleteEmb("brandnew"); err != nil {
+		t.Fatal(err)
+	}
+	ft.Close()
+
+	// The in-memory copy keeps the counter of the file.
+	inMem := NewFastTextInMem(dbFilename)
+	defer inMem.Close()
+	if err := inMem.PutEmb("brandnew", make([]float32, 300)); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := inMem.GetID("brandnew"); err != nil || id != maxID+2 {
+		t.Errorf("Expected new ID %d in memory, got %d, %v", maxID+2, id, err)
+	}
+}
+
+func Test_NearestPruning(t *testing.T) {
+	// Enough words for several workers to scan chunks of the table.
+	const n, dim = 3 * scanChunkSize, 8
+	rng := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %d\n", n, dim)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "w%d", i)
+		for j := 0; j < dim; j++ {
+			fmt.Fprintf(&b, " %f", rng.NormFloat64())
+		}
+		b.WriteString("\n")
+	}
+	ft := NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDB(strings.NewReader(b.String())); err != nil {
+		t.Fatal(err)
+	}
+	words := make([]string, 0, n)
+	embs := make([][]float32, 0, n)
+	if err := ft.ForEach(func(word string, emb []float32) error {
+		words = append(words, word)
+		embs = append(embs, emb)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	even := func(word string, score float64) bool {
+		i, _ := strconv.Atoi(strings.TrimPrefix(word, "w"))
+		return i%2 == 0
+	}
+	threshold := func(word string, score float64) bool { return score >= 0.5 }
+	for _, c := range []struct {
+		name string
+		keep func(word string, score float64) bool
+	}{
+		{"all", nil},
+		{"even", even},
+		{"threshold", threshold},
+	} {
+		for _, k := range []int{1, 10, 100, n} {
+			for _, query := range []int{0, 1, n - 1} {
+				vec := embs[query]
+				var want []ScoredWord
+				for i, emb := range embs {
+					score := CosineSimilarity(vec, emb)
+					if c.keep == nil || c.keep(words[i], score) {
+						want = append(want, ScoredWord{Word: words[i], Score: score})
+					}
+				}
+				sort.Slice(want, func(i, j int) bool { return want[i].Score > want[j].Score })
+				if len(want) > k {
+					want = want[:k]
+				}
+				var opts []SearchOption
+				if c.keep != nil {
+					opts = append(opts, WithFilter(c.keep))
+				}
+				got, err := ft.NearestByVector(vec, k, opts...)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(got) != len(want) {
+					t.Fatalf("%s, k=%d: expected %d neighbors, got %d", c.name, k, len(want), len(got))
+				}
+				for i := range want {
+					if got[i].Word != want[i].Word || math.Abs(got[i].Score-want[i].Score) > 1e-6 {
+						t.Errorf("%s, k=%d: neighbor %d: expected %v, got %v", c.name, k, i, want[i], got[i])
+						break
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+// Package fasttexttest provides synthetic word embeddings for testing
+// code that depends on fasttext, without shipping real model files:
+//
+//	ft := fasttexttest.New(t, 50,
+//		[]string{"cat", "dog", "horse"},
+//		[]string{"red", "green", "blue"},
+//	)
+//	nn, err := ft.NearestNeighbors("cat", 2) // dog and horse
+//
+// The words of a group get nearby vectors, far from the other groups,
+// so tests of neighbors and similarities have predictable results. The
+// vectors only depend on the words and their groups.
+package fasttexttest
+
+import (
+	"bufio"
+	"bytes"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+// Spread is the distance of the words of a group from its center,
+// relative to the distance between the centers of the groups.
+const Spread = 0.1
+
+// Vectors returns the word embeddings of the words of the groups, in
+// order, with the given dimension.
+func Vectors(dim int, groups ...[]string) (words []string, vecs [][]float32) {
+	for _, group := range groups {
+		if len(group) == 0 {
+			continue
+		}
+		center := randomVec(dim, "group:"+group[0], 1)
+		for _, word := range group {
+			vec := randomVec(dim, "word:"+word, Spread)
+			for i := range vec {
+				vec[i] += center[i]
+			}
+			words = append(words, word)
+			vecs = append(vecs, vec)
+		}
+	}
+	return words, vecs
+}
+
+// randomVec returns a vector of normally distributed values with the
+// given standard deviation, seeded by the key.
+func randomVec(dim int, key string, stddev float64) []float32 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	r := rand.New(rand.NewSource(int64(h.Sum64())))
+	vec := make([]float32, dim)
+	for i := range vec {
+		vec[i] = float32(r.NormFloat64() * stddev)
+	}
+	return vec
+}
+
+// WriteVec writes the word embeddings of the groups (see Vectors) as a
+// .vec file.
+func WriteVec(w io.Writer, dim int, groups ...[]string) error {
+	words, vecs := Vectors(dim, groups...)
+	bw := bufio.NewWriter(w)
+	bw.WriteString(strconv.Itoa(len(words)) + " " + strconv.Itoa(dim) + "\n")
+	for i, word := range words {
+		bw.WriteString(word)
+		for _, v := range vecs[i] {
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// Vec returns the .vec file written by WriteVec, e.g. as the content of
+// a file of an fstest.MapFS for BuildDBFromFS.
+func Vec(dim int, groups ...[]string) []byte {
+	var buf bytes.Buffer
+	WriteVec(&buf, dim, groups...)
+	return buf.Bytes()
+}
+
+// New returns a session on a private in-memory database built from the
+// word embeddings of the groups (see Vectors), which is closed at the
+// end of the test.
+func New(tb testing.TB, dim int, groups ...[]string) *fasttext.FastText {
+	tb.Helper()
+	ft := fasttext.NewFastText(":memory:")
+	tb.Cleanup(func() { ft.Close() })
+	if err := ft.BuildDB(bytes.NewReader(Vec(dim, groups...))); err != nil {
+		tb.Fatal(err)
+	}
+	return ft
+}
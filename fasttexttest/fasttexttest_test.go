@@ -0,0 +1,60 @@
+package fasttexttest
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+var groups = [][]string{
+	{"cat", "dog", "horse"},
+	{"red", "green", "blue"},
+	{"paris"},
+}
+
+func Test_New(t *testing.T) {
+	ft := New(t, 50, groups...)
+	nn, err := ft.NearestNeighbors("cat", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nn {
+		if n.Word != "dog" && n.Word != "horse" {
+			t.Errorf("Unexpected neighbors of cat %v", nn)
+		}
+	}
+	emb, err := ft.GetEmb("paris")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emb) != 50 {
+		t.Errorf("Wrong dimension %d", len(emb))
+	}
+}
+
+func Test_Vectors(t *testing.T) {
+	words, a := Vectors(10, groups...)
+	_, b := Vectors(10, groups[1], groups[0])
+	if len(words) != 7 || words[0] != "cat" {
+		t.Fatalf("Unexpected words %v", words)
+	}
+	// The vectors do not depend on the order of the groups.
+	for i := range a[0] {
+		if a[0][i] != b[3][i] {
+			t.Fatalf("Expected %v, got %v", a[0], b[3])
+		}
+	}
+}
+
+func Test_BuildDBFromFS(t *testing.T) {
+	fsys := fstest.MapFS{"model/test.vec": {Data: Vec(20, groups...)}}
+	ft := fasttext.NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFS(fsys, "model/test.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if sim, err := ft.Similarity("red", "blue"); err != nil || sim < 0.9 {
+		t.Errorf("Expected similar words, got %v, %v", sim, err)
+	}
+}
@@ -0,0 +1,104 @@
+package fasttext
+
+import (
+	"context"
+	"runtime"
+)
+
+// DocVector is the embedding of a document computed by ExtractFeatures.
+type DocVector struct {
+	// Index is the position of the document in the input, from 0.
+	Index int
+	Vec   []float32
+	// Err is the error of the document, e.g. ErrAllOOV if none of its
+	// tokens is in the vocabulary.
+	Err error
+}
+
+// docJob is a document embedded by ExtractFeatures.
+type docJob struct {
+	index  int
+	tokens []string
+	vec    []float32
+	err    error
+	// done is closed once the document is embedded.
+	done chan struct{}
+}
+
+// ExtractFeatures embeds the tokenized documents received from docs as
+// GetSentenceEmb does with the given options, e.g. for batch inference
+// over a corpus, and sends their vectors on the returned channel in the
+// order of the documents. The documents are spread across workers
+// goroutines, GOMAXPROCS if workers is not positive, sharing the cache
+// and the connection pool of the session. The error of a document does
+// not stop the others. The returned channel is closed once docs is
+// closed and all the vectors are sent, or when the context is
+// cancelled, after which docs is no longer read and the look-ups in
+// flight are cancelled.
+func (ft *FastText) ExtractFeatures(ctx context.Context, docs <-chan []string, workers int,
+	opts ...SentenceOption) <-chan DocVector {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	cfg := newSentenceConfig(opts)
+	jobs := make(chan *docJob)
+	// pending holds the documents in flight in order.
+	pending := make(chan *docJob, workers)
+	out := make(chan DocVector, workers)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for i := 0; ; i++ {
+			job := &docJob{index: i, done: make(chan struct{})}
+			select {
+			case tokens, ok := <-docs:
+				if !ok {
+					return
+				}
+				job.tokens = tokens
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case pending <- job:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				var embs [][]float32
+				if embs, job.err = ft.GetEmbsContext(ctx, job.tokens); job.err == nil {
+					job.vec, job.err = ft.averageEmbs(job.tokens, embs, cfg)
+				}
+				close(job.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		for job := range pending {
+			select {
+			case <-job.done:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case out <- DocVector{Index: job.index, Vec: job.vec, Err: job.err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
@@ -0,0 +1,282 @@
+package fasttext
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"unsafe"
+)
+
+// The flat file layout, all integers little-endian:
+//
+//	magic   "FTFLAT1\n"
+//	header  n, dim, words offset, index offset, matrix offset (uint64)
+//	words   the n words, sorted, concatenated
+//	index   n+1 uint64 offsets of the words in the words section
+//	matrix  n*dim float32, row i is the vector of the i-th word,
+//	        aligned on 64 bytes
+var flatMagic = []byte("FTFLAT1\n")
+
+const flatHeaderSize = 8 + 5*8
+
+// CompileFlat writes the vocabulary to a flat file that OpenFlat maps
+// in memory: a sorted word index and a contiguous float32 matrix.
+// Look-ups in it are a binary search and a slice of the mapped matrix,
+// with no allocation and no start-up cost, for read-heavy serving.
+func (ft *FastText) CompileFlat(filename string) error {
+	var words []string
+	err := ft.QueryRaw(func(rows *sql.Rows) error {
+		for rows.Next() {
+			var w string
+			if err := rows.Scan(&w); err != nil {
+				return err
+			}
+			words = append(words, w)
+		}
+		return rows.Err()
+	}, ft.sql(`SELECT word FROM fasttext ORDER BY word;`))
+	if err != nil {
+		return err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	dim := f.dim
+	if dim == 0 && len(words) > 0 {
+		emb, err := ft.GetEmb(words[0])
+		if err != nil {
+			return err
+		}
+		dim = len(emb)
+	}
+	var wordsSize uint64
+	for _, w := range words {
+		wordsSize += uint64(len(w))
+	}
+	n := uint64(len(words))
+	wordsOffset := uint64(flatHeaderSize)
+	indexOffset := wordsOffset + wordsSize
+	matrixOffset := (indexOffset + 8*(n+1) + 63) &^ 63
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	w.Write(flatMagic)
+	binary.Write(w, binary.LittleEndian, []uint64{n, uint64(dim), wordsOffset, indexOffset, matrixOffset})
+	for _, word := range words {
+		w.WriteString(word)
+	}
+	var off uint64
+	for _, word := range words {
+		binary.Write(w, binary.LittleEndian, off)
+		off += uint64(len(word))
+	}
+	binary.Write(w, binary.LittleEndian, off)
+	w.Write(make([]byte, matrixOffset-indexOffset-8*(n+1)))
+	buf := make([]byte, 4*dim)
+	for start := 0; start < len(words); start += maxBatchVars {
+		batch := words[start:minInt(start+maxBatchVars, len(words))]
+		embs, err := ft.lookupEmbs(batch)
+		if err != nil {
+			return err
+		}
+		for i, emb := range embs {
+			if len(emb) != dim {
+				return fmt.Errorf("fasttext: embedding of %q has %d dimensions, expected %d",
+					batch[i], len(emb), dim)
+			}
+			for j, v := range emb {
+				binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(v))
+			}
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// Flat is a read-only vocabulary mapped from a file compiled by
+// CompileFlat. It is safe for concurrent use.
+type Flat struct {
+	data   []byte
+	unmap  func() error
+	n      int
+	dim    int
+	words  []byte
+	index  []byte
+	matrix []float32
+	// raw is the matrix section, decoded on look-up when the host is
+	// not little-endian.
+	raw []byte
+}
+
+// OpenFlat maps a file compiled by CompileFlat. On platforms without
+// mmap the file is read in memory.
+func OpenFlat(filename string) (*Flat, error) {
+	data, unmap, err := mapFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	fl, err := newFlat(data)
+	if err != nil {
+		unmap()
+		return nil, err
+	}
+	fl.unmap = unmap
+	return fl, nil
+}
+
+var errNotFlat = errors.New("fasttext: not a flat file")
+
+func newFlat(data []byte) (*Flat, error) {
+	if len(data) < flatHeaderSize || string(data[:len(flatMagic)]) != string(flatMagic) {
+		return nil, errNotFlat
+	}
+	h := make([]uint64, 5)
+	for i := range h {
+		h[i] = binary.LittleEndian.Uint64(data[len(flatMagic)+8*i:])
+	}
+	n, dim, wordsOffset, indexOffset, matrixOffset := h[0], h[1], h[2], h[3], h[4]
+	if wordsOffset > indexOffset || indexOffset+8*(n+1) > matrixOffset ||
+		matrixOffset+4*n*dim != uint64(len(data)) {
+		return nil, errNotFlat
+	}
+	fl := &Flat{
+		data:  data,
+		n:     int(n),
+		dim:   int(dim),
+		words: data[wordsOffset:indexOffset],
+		index: data[indexOffset : indexOffset+8*(n+1)],
+		raw:   data[matrixOffset:],
+	}
+	if littleEndianHost && n*dim > 0 && uintptr(unsafe.Pointer(&fl.raw[0]))%4 == 0 {
+		fl.matrix = unsafe.Slice((*float32)(unsafe.Pointer(&fl.raw[0])), n*dim)
+	}
+	return fl, nil
+}
+
+var littleEndianHost = func() bool {
+	x := uint16(1)
+	return *(*byte)(unsafe.Pointer(&x)) == 1
+}()
+
+// Len returns the number of words.
+func (fl *Flat) Len() int {
+	return fl.n
+}
+
+// Dim returns the dimension of the vectors.
+func (fl *Flat) Dim() int {
+	return fl.dim
+}
+
+// Word returns the i-th word in sorted order.
+func (fl *Flat) Word(i int) string {
+	start := binary.LittleEndian.Uint64(fl.index[8*i:])
+	end := binary.LittleEndian.Uint64(fl.index[8*i+8:])
+	return string(fl.words[start:end])
+}
+
+// wordBytes returns the i-th word without copying it.
+func (fl *Flat) wordBytes(i int) []byte {
+	start := binary.LittleEndian.Uint64(fl.index[8*i:])
+	end := binary.LittleEndian.Uint64(fl.index[8*i+8:])
+	return fl.words[start:end]
+}
+
+// find returns the row of the word, or -1.
+func (fl *Flat) find(word string) int {
+	i := sort.Search(fl.n, func(i int) bool {
+		return string(fl.wordBytes(i)) >= word
+	})
+	if i < fl.n && string(fl.wordBytes(i)) == word {
+		return i
+	}
+	return -1
+}
+
+// row returns the vector of row i, a view of the mapped matrix when
+// possible.
+func (fl *Flat) row(i int) []float32 {
+	if fl.matrix != nil {
+		return fl.matrix[i*fl.dim : (i+1)*fl.dim : (i+1)*fl.dim]
+	}
+	vec := make([]float32, fl.dim)
+	for j := range vec {
+		vec[j] = math.Float32frombits(binary.LittleEndian.Uint32(fl.raw[4*(i*fl.dim+j):]))
+	}
+	return vec
+}
+
+// GetEmb returns the embedding of the word. The slice is a view of the
+// mapped file and must not be modified; it is invalid after Close.
+func (fl *Flat) GetEmb(word string) ([]float32, error) {
+	i := fl.find(word)
+	if i < 0 {
+		return nil, &WordNotFoundError{Word: word}
+	}
+	return fl.row(i), nil
+}
+
+// Contains returns whether the word is in the vocabulary.
+func (fl *Flat) Contains(word string) bool {
+	return fl.find(word) >= 0
+}
+
+// NearestNeighbors returns the k words most similar to the given word
+// by cosine similarity, as FastText.NearestNeighbors does.
+func (fl *Flat) NearestNeighbors(word string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	vec, err := fl.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	return fl.nearest(vec, k, keepWith(excludeWords(word), opts))
+}
+
+// NearestByVector returns the k words whose embeddings are most similar
+// to the given vector, as FastText.NearestByVector does.
+func (fl *Flat) NearestByVector(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	if len(vec) != fl.dim {
+		return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), fl.dim)
+	}
+	return fl.nearest(vec, k, keepWith(excludeWords(), opts))
+}
+
+func (fl *Flat) nearest(vec []float32, k int, keep func(word string, score float64) bool) ([]ScoredWord, error) {
+	top := NewTopK(k)
+	qnorm := l2norm(vec)
+	for i := 0; i < fl.n; i++ {
+		score := cosine(vec, fl.row(i), qnorm)
+		if min, ok := top.Min(); ok && top.Len() == k && score < min.Score {
+			continue
+		}
+		word := fl.Word(i)
+		if keep(word, score) {
+			top.Push(ScoredWord{Word: word, Score: score})
+		}
+	}
+	return top.Sorted(), nil
+}
+
+// Close unmaps the file.
+func (fl *Flat) Close() error {
+	if fl.unmap == nil {
+		return nil
+	}
+	err := fl.unmap()
+	fl.unmap = nil
+	return err
+}
@@ -0,0 +1,368 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fastText binary model format, as written by fasttext save-model and
+// fasttext quantize.
+const (
+	ftModelMagic   = 793712314
+	ftModelVersion = 12
+	// ftEntryWord is the type of the dictionary entries that are words,
+	// as opposed to labels.
+	ftEntryWord = 0
+	// ftEOS is the end of sentence token, which has no subwords.
+	ftEOS = "</s>"
+	// ftKsub is the number of centroids of each product quantizer.
+	ftKsub = 256
+)
+
+// BuildDBFromModel initializes the SQLite3 database from a fastText
+// binary model, either a full .bin model or a quantized .ftz model as
+// released on https://fasttext.cc. The embedding of each word of the
+// dictionary is computed as fastText does, averaging the rows of the
+// word and of its character n-grams in the input matrix, which is
+// dequantized for .ftz models. Labels of supervised models are skipped.
+// The input matrix is loaded in memory, which for .bin models takes
+// several gigabytes.
+func (ft *FastText) BuildDBFromModel(filename string, opts ...BuildOption) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	m, err := readFTModel(bufio.NewReader(file))
+	if err != nil {
+		return fmt.Errorf("fasttext: reading %s: %w", filename, err)
+	}
+	cfg := ft.newBuildConfig(opts)
+	return ft.build(m.wordEmbs(cfg.done), cfg)
+}
+
+// ftModel is the part of a fastText model needed for word vectors.
+type ftModel struct {
+	dim, bucket, minn, maxn int
+	words                   []string
+	nwords                  int
+	// pruneidx maps the n-gram buckets kept by quantization to their
+	// row offset, if the dictionary was pruned.
+	pruneidx map[int32]int32
+	pruned   bool
+	input    ftMatrix
+}
+
+// ftMatrix is the input matrix of a model.
+type ftMatrix interface {
+	// addRow adds the row i to vec.
+	addRow(vec []float32, i int)
+	rows() int
+}
+
+type ftReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *ftReader) read(v interface{}) {
+	if r.err == nil {
+		r.err = binary.Read(r.r, binary.LittleEndian, v)
+	}
+}
+
+func (r *ftReader) int32() int32 {
+	var v int32
+	r.read(&v)
+	return v
+}
+
+func (r *ftReader) int64() int64 {
+	var v int64
+	r.read(&v)
+	return v
+}
+
+func (r *ftReader) bool() bool {
+	var v uint8
+	r.read(&v)
+	return v != 0
+}
+
+// string reads a NUL-terminated string.
+func (r *ftReader) string() string {
+	var b []byte
+	var c [1]byte
+	for r.err == nil {
+		if _, r.err = io.ReadFull(r.r, c[:]); r.err != nil || c[0] == 0 {
+			break
+		}
+		b = append(b, c[0])
+	}
+	return string(b)
+}
+
+// float32s reads n values, checking n first so a corrupt size fails
+// instead of exhausting memory.
+func (r *ftReader) float32s(n int64) []float32 {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > 1<<34 {
+		r.err = fmt.Errorf("invalid matrix size %d", n)
+		return nil
+	}
+	v := make([]float32, n)
+	r.read(v)
+	return v
+}
+
+func (r *ftReader) bytes(n int64) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > 1<<34 {
+		r.err = fmt.Errorf("invalid code size %d", n)
+		return nil
+	}
+	v := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, v)
+	return v
+}
+
+// readFTModel reads the header, the dictionary and the input matrix of
+// a fastText model.
+func readFTModel(rd io.Reader) (*ftModel, error) {
+	r := &ftReader{r: rd}
+	if magic := r.int32(); r.err == nil && magic != ftModelMagic {
+		return nil, errors.New("not a fastText model")
+	}
+	version := r.int32()
+	if r.err == nil && version > ftModelVersion {
+		return nil, fmt.Errorf("unsupported fastText model version %d", version)
+	}
+	// The training arguments: dim, ws, epoch, minCount, neg, wordNgrams,
+	// loss, model, bucket, minn, maxn, lrUpdateRate and t.
+	var args [12]int32
+	r.read(&args)
+	var t float64
+	r.read(&t)
+	m := &ftModel{
+		dim:    int(args[0]),
+		bucket: int(args[8]),
+		minn:   int(args[9]),
+		maxn:   int(args[10]),
+	}
+	if version == 11 && args[7] == 3 {
+		// Supervised models of version 11 have no n-grams.
+		m.maxn = 0
+	}
+	size := r.int32()
+	m.nwords = int(r.int32())
+	r.int32() // nlabels
+	r.int64() // ntokens
+	pruneidxSize := r.int64()
+	if r.err != nil {
+		return nil, r.err
+	}
+	if size < 0 || m.nwords < 0 || int(size) < m.nwords {
+		return nil, fmt.Errorf("invalid dictionary of %d entries and %d words", size, m.nwords)
+	}
+	for i := int32(0); i < size; i++ {
+		word := r.string()
+		r.int64() // count
+		var typ int8
+		r.read(&typ)
+		if typ == ftEntryWord {
+			m.words = append(m.words, word)
+		}
+	}
+	if r.err == nil && len(m.words) != m.nwords {
+		return nil, fmt.Errorf("dictionary has %d words, expected %d", len(m.words), m.nwords)
+	}
+	if pruneidxSize >= 0 {
+		m.pruned = true
+		m.pruneidx = make(map[int32]int32)
+		for i := int64(0); i < pruneidxSize && r.err == nil; i++ {
+			from := r.int32()
+			m.pruneidx[from] = r.int32()
+		}
+	}
+	if r.bool() {
+		m.input = readFTQuantMatrix(r)
+	} else {
+		rows, cols := r.int64(), r.int64()
+		if r.err == nil && cols != int64(m.dim) {
+			return nil, fmt.Errorf("input matrix of %d columns, expected %d", cols, m.dim)
+		}
+		m.input = &ftDenseMatrix{dim: m.dim, data: r.float32s(rows * cols)}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if m.input.rows() < m.nwords {
+		return nil, fmt.Errorf("input matrix of %d rows for %d words", m.input.rows(), m.nwords)
+	}
+	return m, nil
+}
+
+type ftDenseMatrix struct {
+	dim  int
+	data []float32
+}
+
+func (d *ftDenseMatrix) rows() int {
+	return len(d.data) / d.dim
+}
+
+func (d *ftDenseMatrix) addRow(vec []float32, i int) {
+	axpy(1, d.data[i*d.dim:(i+1)*d.dim], vec)
+}
+
+// ftProductQuantizer splits vectors in nsubq sub-vectors of dsub
+// dimensions, the last one of lastdsub, each coded by one of 256
+// centroids.
+type ftProductQuantizer struct {
+	dim, nsubq, dsub, lastdsub int
+	centroids                  []float32
+}
+
+func readFTProductQuantizer(r *ftReader) *ftProductQuantizer {
+	pq := &ftProductQuantizer{
+		dim:      int(r.int32()),
+		nsubq:    int(r.int32()),
+		dsub:     int(r.int32()),
+		lastdsub: int(r.int32()),
+	}
+	pq.centroids = r.float32s(int64(pq.dim) * ftKsub)
+	if r.err == nil && (pq.nsubq <= 0 || pq.dsub*(pq.nsubq-1)+pq.lastdsub != pq.dim) {
+		r.err = fmt.Errorf("invalid product quantizer of %d dimensions", pq.dim)
+	}
+	return pq
+}
+
+// centroid returns the centroid of the code for the sub-vector m.
+func (pq *ftProductQuantizer) centroid(m int, code byte) []float32 {
+	if m == pq.nsubq-1 {
+		start := m*ftKsub*pq.dsub + int(code)*pq.lastdsub
+		return pq.centroids[start : start+pq.lastdsub]
+	}
+	start := (m*ftKsub + int(code)) * pq.dsub
+	return pq.centroids[start : start+pq.dsub]
+}
+
+// ftQuantMatrix is the product quantized input matrix of .ftz models,
+// with the norms of the rows optionally quantized separately.
+type ftQuantMatrix struct {
+	n         int
+	codes     []byte
+	pq        *ftProductQuantizer
+	normCodes []byte
+	npq       *ftProductQuantizer
+}
+
+func readFTQuantMatrix(r *ftReader) *ftQuantMatrix {
+	qnorm := r.bool()
+	q := &ftQuantMatrix{}
+	q.n = int(r.int64())
+	r.int64() // dimension
+	q.codes = r.bytes(int64(r.int32()))
+	q.pq = readFTProductQuantizer(r)
+	if qnorm {
+		q.normCodes = r.bytes(int64(q.n))
+		q.npq = readFTProductQuantizer(r)
+	}
+	if r.err == nil && len(q.codes) != q.n*q.pq.nsubq {
+		r.err = fmt.Errorf("%d codes for %d rows of %d sub-vectors", len(q.codes), q.n, q.pq.nsubq)
+	}
+	return q
+}
+
+func (q *ftQuantMatrix) rows() int {
+	return q.n
+}
+
+func (q *ftQuantMatrix) addRow(vec []float32, i int) {
+	var alpha float32 = 1
+	if q.normCodes != nil {
+		alpha = q.npq.centroid(0, q.normCodes[i])[0]
+	}
+	codes := q.codes[i*q.pq.nsubq : (i+1)*q.pq.nsubq]
+	for m, code := range codes {
+		axpy(alpha, q.pq.centroid(m, code), vec[m*q.pq.dsub:])
+	}
+}
+
+// ftHash is the FNV-1a hash of fastText, which sign-extends the bytes.
+func ftHash(s string) uint32 {
+	h := uint32(2166136261)
+	for i := 0; i < len(s); i++ {
+		h ^= uint32(int8(s[i]))
+		h *= 16777619
+	}
+	return h
+}
+
+// subwords returns the rows of the word with index w: its own and
+// those of its character n-grams.
+func (m *ftModel) subwords(w int) []int {
+	rows := []int{w}
+	word := m.words[w]
+	if word == ftEOS || m.maxn <= 0 || m.bucket <= 0 {
+		return rows
+	}
+	word = "<" + word + ">"
+	for i := 0; i < len(word); i++ {
+		if word[i]&0xc0 == 0x80 {
+			// Not the start of a UTF-8 character.
+			continue
+		}
+		j := i
+		for n := 1; j < len(word) && n <= m.maxn; n++ {
+			j++
+			for j < len(word) && word[j]&0xc0 == 0x80 {
+				j++
+			}
+			if n >= m.minn && !(n == 1 && (i == 0 || j == len(word))) {
+				id := int32(ftHash(word[i:j]) % uint32(m.bucket))
+				if m.pruned {
+					var ok bool
+					if id, ok = m.pruneidx[id]; !ok {
+						continue
+					}
+				}
+				rows = append(rows, m.nwords+int(id))
+			}
+		}
+	}
+	return rows
+}
+
+// wordEmbs computes the embeddings of the words, sending them to a
+// channel for build until done is closed.
+func (m *ftModel) wordEmbs(done <-chan struct{}) chan *wordEmb {
+	out := make(chan *wordEmb)
+	go func() {
+		defer close(out)
+		for i, word := range m.words {
+			vec := make([]float32, m.dim)
+			rows := m.subwords(i)
+			for _, row := range rows {
+				if row >= m.input.rows() {
+					send(out, &wordEmb{Err: fmt.Errorf("fasttext: row %d of %q out of the input matrix", row, word)}, done)
+					return
+				}
+				m.input.addRow(vec, row)
+			}
+			scale(float32(1/float64(len(rows))), vec)
+			if !send(out, &wordEmb{Word: word, Vec: vec}, done) {
+				return
+			}
+		}
+	}()
+	return out
+}
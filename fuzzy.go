@@ -0,0 +1,263 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FuzzyTableName is the SQLite3 table holding the index of
+// BuildFuzzyIndex for the default model, "<name>_fuzzy" for the others.
+const FuzzyTableName = "fasttext_fuzzy"
+
+// metaFuzzyDistance is the metadata key of the maximum edit distance of
+// the fuzzy index.
+const metaFuzzyDistance = "fuzzy_distance"
+
+// Methods of a fuzzy match reported by FuzzyMatch.
+const (
+	MatchExact       = "exact"
+	MatchLowercase   = "lowercase"
+	MatchPunctuation = "punctuation"
+	MatchEditDist    = "edit_distance"
+)
+
+// FuzzyMatch is the vocabulary word matched by GetEmbFuzzy.
+type FuzzyMatch struct {
+	// Word is the matched vocabulary word.
+	Word string
+	// Method is how the word was matched (MatchExact, MatchLowercase,
+	// MatchPunctuation or MatchEditDist).
+	Method string
+	// Distance is the edit distance between the query and the word,
+	// for MatchEditDist.
+	Distance int
+}
+
+// BuildFuzzyIndex precomputes the index used by GetEmbFuzzy to find
+// the words within maxDistance edits (insertions, deletions,
+// substitutions and transpositions) of a missing word. It is a SymSpell
+// index of the words obtained by deleting up to maxDistance characters
+// from each vocabulary word, so its size grows quickly with
+// maxDistance; 1 or 2 are typical. It must be rebuilt when the
+// vocabulary changes.
+func (ft *FastText) BuildFuzzyIndex(maxDistance int) error {
+	if maxDistance < 1 {
+		maxDistance = 1
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, q := range []string{
+		ft.sql(`DROP TABLE IF EXISTS fasttext_fuzzy;`),
+		ft.sql(`CREATE TABLE fasttext_fuzzy(key TEXT, word TEXT);`),
+	} {
+		if _, err := tx.Exec(q); err != nil {
+			return err
+		}
+	}
+	stmt, err := tx.Prepare(ft.sql(`INSERT INTO fasttext_fuzzy(key, word) VALUES(?, ?);`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	rows, err := tx.Query(ft.sql(`SELECT word FROM fasttext;`))
+	if err != nil {
+		return err
+	}
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			rows.Close()
+			return err
+		}
+		words = append(words, word)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, word := range words {
+		for key := range deletes(word, maxDistance) {
+			if _, err := stmt.Exec(key, word); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.Exec(ft.sql(`CREATE INDEX fasttext_fuzzy_key ON fasttext_fuzzy(key);`)); err != nil {
+		return err
+	}
+	if err := ft.createMetaTable(tx); err != nil {
+		return err
+	}
+	if err := ft.setMeta(tx, metaFuzzyDistance, strconv.Itoa(maxDistance)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deletes returns the strings obtained by deleting up to d runes from
+// word, including word itself.
+func deletes(word string, d int) map[string]bool {
+	out := map[string]bool{word: true}
+	level := []string{word}
+	for ; d > 0; d-- {
+		var next []string
+		for _, w := range level {
+			runes := []rune(w)
+			for i := range runes {
+				del := string(runes[:i]) + string(runes[i+1:])
+				if !out[del] {
+					out[del] = true
+					next = append(next, del)
+				}
+			}
+		}
+		level = next
+	}
+	return out
+}
+
+// GetEmbFuzzy returns the embedding of the word, or when it is not in
+// the vocabulary, of the first vocabulary word matched by trying in
+// order its lowercase form, the word without leading and trailing
+// punctuation, and if BuildFuzzyIndex was called, the closest words by
+// edit distance to the word or to its lowercase form without
+// punctuation, the most frequent first among equally close words.
+// It returns ErrNoEmbFound if nothing matches.
+func (ft *FastText) GetEmbFuzzy(word string) (FuzzyMatch, []float32, error) {
+	tries := []FuzzyMatch{
+		{Word: word, Method: MatchExact},
+		{Word: strings.ToLower(word), Method: MatchLowercase},
+		{Word: strings.TrimFunc(word, unicode.IsPunct), Method: MatchPunctuation},
+		{Word: strings.ToLower(strings.TrimFunc(word, unicode.IsPunct)), Method: MatchPunctuation},
+	}
+	tried := make(map[string]bool)
+	for _, m := range tries {
+		if m.Word == "" || tried[m.Word] {
+			continue
+		}
+		tried[m.Word] = true
+		vec, _, err := ft.lookupEmb(context.Background(), m.Word, nil)
+		if err == nil {
+			return m, vec, nil
+		}
+		if !errors.Is(err, ErrNoEmbFound) {
+			return FuzzyMatch{}, nil, err
+		}
+	}
+	m, err := ft.closestWord(word)
+	if err != nil && !errors.Is(err, ErrNoEmbFound) {
+		return FuzzyMatch{}, nil, err
+	}
+	// The lowercase form without punctuation may be closer.
+	if cleaned := tries[len(tries)-1].Word; cleaned != word && cleaned != "" {
+		mc, errc := ft.closestWord(cleaned)
+		if errc != nil && !errors.Is(errc, ErrNoEmbFound) {
+			return FuzzyMatch{}, nil, errc
+		}
+		if errc == nil && (err != nil || mc.Distance < m.Distance) {
+			m, err = mc, nil
+		}
+	}
+	if err != nil {
+		return FuzzyMatch{}, nil, err
+	}
+	vec, _, err := ft.lookupEmb(context.Background(), m.Word, nil)
+	if err != nil {
+		return FuzzyMatch{}, nil, err
+	}
+	return m, vec, nil
+}
+
+// closestWord looks up the fuzzy index for the closest word.
+func (ft *FastText) closestWord(word string) (FuzzyMatch, error) {
+	value, ok, err := ft.getMeta(metaFuzzyDistance)
+	if err != nil {
+		return FuzzyMatch{}, err
+	}
+	if !ok {
+		return FuzzyMatch{}, &WordNotFoundError{Word: word}
+	}
+	maxDistance, err := strconv.Atoi(value)
+	if err != nil {
+		return FuzzyMatch{}, err
+	}
+	word = ft.normalize(word)
+	var args []interface{}
+	for key := range deletes(word, maxDistance) {
+		args = append(args, key)
+	}
+	best := FuzzyMatch{Method: MatchEditDist, Distance: maxDistance + 1}
+	var bestRank int64
+	for start := 0; start < len(args); start += maxBatchVars {
+		batch := args[start:minInt(start+maxBatchVars, len(args))]
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",")
+		rows, err := ft.db.Query(ft.sql(`SELECT DISTINCT t.rowid, t.word FROM fasttext_fuzzy f
+			JOIN fasttext t ON t.word = f.word WHERE f.key IN (`)+placeholders+`);`, batch...)
+		if err != nil {
+			return FuzzyMatch{}, err
+		}
+		err = scanCandidates(rows, func(rank int64, candidate string) {
+			d := editDistance(word, candidate)
+			if d < best.Distance || (d == best.Distance && rank < bestRank) {
+				best.Word, best.Distance, bestRank = candidate, d, rank
+			}
+		})
+		if err != nil {
+			return FuzzyMatch{}, err
+		}
+	}
+	if best.Word == "" {
+		return FuzzyMatch{}, &WordNotFoundError{Word: word}
+	}
+	return best, nil
+}
+
+func scanCandidates(rows *sql.Rows, fn func(rank int64, word string)) error {
+	defer rows.Close()
+	for rows.Next() {
+		var rank int64
+		var word string
+		if err := rows.Scan(&rank, &word); err != nil {
+			return err
+		}
+		fn(rank, word)
+	}
+	return rows.Err()
+}
+
+// editDistance returns the optimal string alignment distance between
+// a and b: the number of rune insertions, deletions, substitutions and
+// transpositions of adjacent runes turning a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	// Three rows of the dynamic programming matrix.
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				cur[j] = minInt(cur[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, cur = prev, cur, prev2
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,182 @@
+package fasttext
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// BuildDBFromGensim initializes the SQLite3 database from a gensim
+// KeyedVectors model saved in Python with
+//
+//	model.wv.save("model.kv")
+//
+// The vectors are read from the model file itself, or from the
+// model.kv.vectors.npy file next to it that gensim writes for large
+// models. Both gensim 3 and 4 models are supported.
+func (ft *FastText) BuildDBFromGensim(kvFilename string, opts ...BuildOption) error {
+	file, err := os.Open(kvFilename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	v, err := unpickle(file)
+	if err != nil {
+		return fmt.Errorf("fasttext: reading %s: %w", kvFilename, err)
+	}
+	kv, ok := v.(*pyObject)
+	if !ok {
+		return fmt.Errorf("fasttext: %s is not a gensim KeyedVectors model", kvFilename)
+	}
+	var words []string
+	for _, attr := range []string{"index_to_key", "index2word", "index2entity"} {
+		if l, ok := kv.attr(attr); ok {
+			if words, err = pyStrings(l); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if words == nil {
+		return fmt.Errorf("fasttext: no vocabulary in %s (%s)", kvFilename, kv.className())
+	}
+	for _, attr := range []string{"vectors", "syn0"} {
+		vectors, ok := kv.attr(attr)
+		if !ok {
+			continue
+		}
+		if arr, ok := vectors.(*pyObject); ok {
+			a, err := pyNdarray(arr)
+			if err != nil {
+				return err
+			}
+			cfg := ft.newBuildConfig(opts)
+			return ft.build(zipWords(words, a, cfg.done), cfg)
+		}
+		// Stored separately by gensim.
+		npy, err := os.Open(kvFilename + "." + attr + ".npy")
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		defer npy.Close()
+		a, err := readNpyHeader(npy)
+		if err != nil {
+			return err
+		}
+		cfg := ft.newBuildConfig(opts)
+		return ft.build(zipWords(words, a, cfg.done), cfg)
+	}
+	return fmt.Errorf("fasttext: no vectors found for %s", kvFilename)
+}
+
+// BuildDBFromNpy initializes the SQLite3 database from a NumPy .npy
+// matrix of vectors and a vocabulary text file listing the words of the
+// matrix rows in order, one per line.
+func (ft *FastText) BuildDBFromNpy(npyFilename, vocabFilename string, opts ...BuildOption) error {
+	vocab, err := os.Open(vocabFilename)
+	if err != nil {
+		return err
+	}
+	defer vocab.Close()
+	var words []string
+	scanner := bufio.NewScanner(vocab)
+	for scanner.Scan() {
+		words = append(words, strings.TrimRight(scanner.Text(), "\r"))
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	npy, err := os.Open(npyFilename)
+	if err != nil {
+		return err
+	}
+	defer npy.Close()
+	a, err := readNpyHeader(npy)
+	if err != nil {
+		return err
+	}
+	cfg := ft.newBuildConfig(opts)
+	return ft.build(zipWords(words, a, cfg.done), cfg)
+}
+
+// pyStrings converts a pickled list of strings.
+func pyStrings(v interface{}) ([]string, error) {
+	l, ok := v.(*pyList)
+	if !ok {
+		return nil, fmt.Errorf("fasttext: expected a list of words, got %T", v)
+	}
+	words := make([]string, len(l.items))
+	for i, item := range l.items {
+		switch w := item.(type) {
+		case string:
+			words[i] = w
+		case []byte:
+			words[i] = string(w)
+		default:
+			return nil, fmt.Errorf("fasttext: expected a word, got %T", item)
+		}
+	}
+	return words, nil
+}
+
+// pyNdarray reads a pickled 2-dimensional NumPy array, whose state is
+// (version, shape, dtype, is_fortran, data).
+func pyNdarray(o *pyObject) (*npyArray, error) {
+	state, ok := o.state.(pyTuple)
+	if !ok || len(state) != 5 {
+		return nil, fmt.Errorf("fasttext: unexpected pickled %s", o.className())
+	}
+	shape, ok := state[1].(pyTuple)
+	if !ok || len(shape) != 2 {
+		return nil, errors.New("fasttext: expected a 2-dimensional array")
+	}
+	rows, ok1 := shape[0].(int64)
+	cols, ok2 := shape[1].(int64)
+	dtype, ok3 := state[2].(*pyObject)
+	if !ok1 || !ok2 || !ok3 || len(dtype.args) == 0 {
+		return nil, errors.New("fasttext: malformed pickled array")
+	}
+	if fortran, _ := state[3].(bool); fortran {
+		return nil, errors.New("fasttext: Fortran-ordered arrays are not supported")
+	}
+	descr, _ := dtype.args[0].(string)
+	order := "<"
+	if dstate, ok := dtype.state.(pyTuple); ok && len(dstate) > 1 {
+		if s, ok := dstate[1].(string); ok {
+			order = s
+		}
+	}
+	data, err := pyBytes(state[4])
+	if err != nil {
+		return nil, err
+	}
+	return newNpyArray(bytes.NewReader(data), order+descr, int(rows), int(cols))
+}
+
+// pyBytes converts pickled bytes. Python 3 pickles bytes with protocol
+// 2 as _codecs.encode(latin-1 string, "latin1").
+func pyBytes(v interface{}) ([]byte, error) {
+	switch b := v.(type) {
+	case []byte:
+		return b, nil
+	case string:
+		return []byte(b), nil
+	case *pyObject:
+		if b.className() == "_codecs.encode" && len(b.args) == 2 {
+			if s, ok := b.args[0].(string); ok {
+				out := make([]byte, 0, len(s))
+				for _, r := range s {
+					out = append(out, byte(r))
+				}
+				return out, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("fasttext: unsupported pickled bytes %T", v)
+}
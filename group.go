@@ -0,0 +1,68 @@
+package fasttext
+
+import "math"
+
+// Centroid returns the mean of the word embeddings of the words, looked
+// up in a single batch, e.g. the prototype of a topic given by seed
+// words. It returns a *WordNotFoundError if a word is missing from the
+// vocabulary, and ErrAllOOV if there are no words.
+func (ft *FastText) Centroid(words []string) ([]float32, error) {
+	embs, err := ft.GetEmbs(words)
+	if err != nil {
+		return nil, err
+	}
+	return ft.averageEmbs(words, embs, &sentenceConfig{oov: OOVError()})
+}
+
+// Medoid returns the word of the vocabulary most similar to the
+// centroid of the words by cosine similarity, see Centroid, which may
+// be one of the words unless excluded with the options.
+func (ft *FastText) Medoid(words []string, opts ...SearchOption) (string, error) {
+	centroid, err := ft.Centroid(words)
+	if err != nil {
+		return "", err
+	}
+	nn, err := ft.NearestByVector(centroid, 1, opts...)
+	if err != nil {
+		return "", err
+	}
+	if len(nn) == 0 {
+		return "", ErrNoEmbFound
+	}
+	return nn[0].Word, nil
+}
+
+// DoesntMatch returns the word least similar by cosine similarity to
+// the centroid of the unit vectors of the words, e.g. "cereal" among
+// "breakfast cereal dinner lunch", as gensim's doesnt_match does. Words
+// missing from the vocabulary are left out, and ErrAllOOV is returned
+// if all are.
+func (ft *FastText) DoesntMatch(words []string) (string, error) {
+	embs, err := ft.GetEmbs(words)
+	if err != nil {
+		return "", err
+	}
+	var found []int
+	var mean []float32
+	for i, emb := range embs {
+		if emb == nil {
+			continue
+		}
+		if mean == nil {
+			mean = make([]float32, len(emb))
+		}
+		axpy(1, unitVec(emb), mean)
+		found = append(found, i)
+	}
+	if len(found) == 0 {
+		return "", ErrAllOOV
+	}
+	norm := l2norm(mean)
+	odd, worst := found[0], math.Inf(1)
+	for _, i := range found {
+		if sim := cosine(mean, embs[i], norm); sim < worst {
+			odd, worst = i, sim
+		}
+	}
+	return words[odd], nil
+}
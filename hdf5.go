@@ -0,0 +1,808 @@
+package fasttext
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var hdf5Signature = []byte("\x89HDF\r\n\x1a\n")
+
+// BuildDBFromHDF5 initializes the SQLite3 database from an HDF5 file
+// holding the vocabulary as a 1-dimensional dataset of strings and the
+// vectors as a 2-dimensional dataset of floats, one row per word, at
+// the given paths, e.g. as written with h5py:
+//
+//	with h5py.File("model.h5", "w") as f:
+//	    f["words"] = words
+//	    f["vectors"] = vectors
+//
+// Only contiguous or compact datasets are supported, the layout h5py
+// uses without chunking or compression, and groups of the original file
+// format or with compact link storage. The strings may be fixed-length
+// or variable-length.
+func (ft *FastText) BuildDBFromHDF5(filename, wordsPath, vectorsPath string, opts ...BuildOption) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f, err := openHDF5(file)
+	if err != nil {
+		return fmt.Errorf("fasttext: reading %s: %w", filename, err)
+	}
+	words, err := f.readStrings(wordsPath)
+	if err != nil {
+		return fmt.Errorf("fasttext: reading %s of %s: %w", wordsPath, filename, err)
+	}
+	a, err := f.readMatrix(vectorsPath)
+	if err != nil {
+		return fmt.Errorf("fasttext: reading %s of %s: %w", vectorsPath, filename, err)
+	}
+	cfg := ft.newBuildConfig(opts)
+	return ft.build(zipWords(words, a, cfg.done), cfg)
+}
+
+// hdf5File reads the objects of an HDF5 file, following the HDF5 File
+// Format Specification.
+type hdf5File struct {
+	r io.ReaderAt
+	// offsets and lengths are the sizes of addresses and lengths.
+	offsets, lengths int
+	// base is the absolute address the addresses are relative to.
+	base int64
+	root int64
+	// heaps caches the objects of the global heap collections read.
+	heaps map[int64]map[uint16][]byte
+}
+
+// HDF5 object header message types.
+const (
+	h5MsgNil          = 0x00
+	h5MsgDataspace    = 0x01
+	h5MsgLinkInfo     = 0x02
+	h5MsgDatatype     = 0x03
+	h5MsgLink         = 0x06
+	h5MsgLayout       = 0x08
+	h5MsgContinuation = 0x10
+	h5MsgSymbolTable  = 0x11
+)
+
+// HDF5 datatype classes.
+const (
+	h5ClassFloat  = 1
+	h5ClassString = 3
+	h5ClassVLen   = 9
+)
+
+type h5Message struct {
+	typ   uint16
+	flags byte
+	data  []byte
+}
+
+func openHDF5(r io.ReaderAt) (*hdf5File, error) {
+	f := &hdf5File{r: r, heaps: make(map[int64]map[uint16][]byte)}
+	// The superblock is at 0, 512, 1024, 2048... bytes into the file.
+	for pos := int64(0); ; pos = maxInt64(2*pos, 512) {
+		sig := make([]byte, len(hdf5Signature))
+		if _, err := r.ReadAt(sig, pos); err != nil {
+			return nil, errors.New("not an HDF5 file")
+		}
+		if bytes.Equal(sig, hdf5Signature) {
+			return f, f.readSuperblock(pos)
+		}
+	}
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func (f *hdf5File) readSuperblock(pos int64) error {
+	head, err := f.readAt(pos+8, 16)
+	if err != nil {
+		return err
+	}
+	switch version := head[0]; version {
+	case 0, 1:
+		f.offsets, f.lengths = int(head[5]), int(head[6])
+		if err := f.checkSizes(); err != nil {
+			return err
+		}
+		// The addresses follow the fields of 16 bytes, 20 in version 1.
+		at := pos + 24
+		if version == 1 {
+			at += 4
+		}
+		b, err := f.readAt(at, 4*f.offsets+2*f.offsets+24)
+		if err != nil {
+			return err
+		}
+		f.base = int64(f.uint(b, f.offsets))
+		// The root group symbol table entry follows the base, free
+		// space, end of file and driver addresses.
+		entry := b[4*f.offsets:]
+		f.root = f.base + int64(f.uint(entry[f.offsets:], f.offsets))
+	case 2, 3:
+		f.offsets, f.lengths = int(head[1]), int(head[2])
+		if err := f.checkSizes(); err != nil {
+			return err
+		}
+		b, err := f.readAt(pos+12, 4*f.offsets)
+		if err != nil {
+			return err
+		}
+		// Base, superblock extension, end of file and root addresses.
+		f.base = int64(f.uint(b, f.offsets))
+		f.root = f.base + int64(f.uint(b[3*f.offsets:], f.offsets))
+	default:
+		return fmt.Errorf("unsupported superblock version %d", version)
+	}
+	return nil
+}
+
+func (f *hdf5File) checkSizes() error {
+	for _, n := range []int{f.offsets, f.lengths} {
+		if n != 2 && n != 4 && n != 8 {
+			return fmt.Errorf("unsupported address size %d", n)
+		}
+	}
+	return nil
+}
+
+func (f *hdf5File) readAt(pos int64, n int) ([]byte, error) {
+	if n < 0 || n > 1<<30 {
+		return nil, fmt.Errorf("invalid size %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := f.r.ReadAt(b, pos); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// uint decodes a little-endian unsigned integer of n bytes.
+func (f *hdf5File) uint(b []byte, n int) uint64 {
+	var v uint64
+	for i := n - 1; i >= 0; i-- {
+		v = v<<8 | uint64(b[i])
+	}
+	return v
+}
+
+// addr decodes an address, returning false for the undefined address.
+func (f *hdf5File) addr(b []byte) (int64, bool) {
+	v := f.uint(b, f.offsets)
+	if v == 1<<(8*uint(f.offsets))-1 || (f.offsets == 8 && v == ^uint64(0)) {
+		return 0, false
+	}
+	return f.base + int64(v), true
+}
+
+// messages reads the messages of the object header at pos, of version
+// 1 or 2, following the continuation messages.
+func (f *hdf5File) messages(pos int64) ([]h5Message, error) {
+	sig, err := f.readAt(pos, 4)
+	if err != nil {
+		return nil, err
+	}
+	if string(sig) == "OHDR" {
+		return f.messagesV2(pos)
+	}
+	head, err := f.readAt(pos, 16)
+	if err != nil {
+		return nil, err
+	}
+	if head[0] != 1 {
+		return nil, fmt.Errorf("unsupported object header version %d", head[0])
+	}
+	size := binary.LittleEndian.Uint32(head[8:])
+	// The messages start after the header of 12 bytes, aligned to 8.
+	blocks := []struct {
+		pos  int64
+		size int
+	}{{pos + 16, int(size)}}
+	var msgs []h5Message
+	for i := 0; i < len(blocks); i++ {
+		b, err := f.readAt(blocks[i].pos, blocks[i].size)
+		if err != nil {
+			return nil, err
+		}
+		for len(b) >= 8 {
+			m := h5Message{typ: binary.LittleEndian.Uint16(b), flags: b[4]}
+			n := int(binary.LittleEndian.Uint16(b[2:]))
+			if 8+n > len(b) {
+				return nil, errors.New("malformed object header")
+			}
+			m.data = b[8 : 8+n]
+			b = b[8+n:]
+			if m.typ == h5MsgContinuation {
+				if len(m.data) < f.offsets+f.lengths {
+					return nil, errors.New("malformed continuation message")
+				}
+				at, _ := f.addr(m.data)
+				blocks = append(blocks, struct {
+					pos  int64
+					size int
+				}{at, int(f.uint(m.data[f.offsets:], f.lengths))})
+				continue
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
+
+func (f *hdf5File) messagesV2(pos int64) ([]h5Message, error) {
+	head, err := f.readAt(pos, 6)
+	if err != nil {
+		return nil, err
+	}
+	if head[4] != 2 {
+		return nil, fmt.Errorf("unsupported object header version %d", head[4])
+	}
+	flags := head[5]
+	at := pos + 6
+	if flags&0x20 != 0 {
+		// Access, modification, change and birth times.
+		at += 16
+	}
+	if flags&0x10 != 0 {
+		// Maximum compact and minimum dense numbers of attributes.
+		at += 4
+	}
+	width := 1 << (flags & 3)
+	b, err := f.readAt(at, width)
+	if err != nil {
+		return nil, err
+	}
+	blocks := []struct {
+		pos  int64
+		size int
+	}{{at + int64(width), int(f.uint(b, width))}}
+	headerSize := 4
+	if flags&0x04 != 0 {
+		// The creation order of the messages.
+		headerSize += 2
+	}
+	var msgs []h5Message
+	for i := 0; i < len(blocks); i++ {
+		b, err := f.readAt(blocks[i].pos, blocks[i].size)
+		if err != nil {
+			return nil, err
+		}
+		// The space left at the end of a block, smaller than a message
+		// header, is a gap.
+		for len(b) >= headerSize {
+			m := h5Message{typ: uint16(b[0]), flags: b[3]}
+			n := int(binary.LittleEndian.Uint16(b[1:]))
+			if headerSize+n > len(b) {
+				return nil, errors.New("malformed object header")
+			}
+			m.data = b[headerSize : headerSize+n]
+			b = b[headerSize+n:]
+			if m.typ == h5MsgContinuation {
+				if len(m.data) < f.offsets+f.lengths {
+					return nil, errors.New("malformed continuation message")
+				}
+				// The block has a signature and a checksum.
+				at, _ := f.addr(m.data)
+				size := int(f.uint(m.data[f.offsets:], f.lengths))
+				blocks = append(blocks, struct {
+					pos  int64
+					size int
+				}{at + 4, size - 8})
+				continue
+			}
+			msgs = append(msgs, m)
+		}
+	}
+	return msgs, nil
+}
+
+// lookup returns the address of the object header of the object at the
+// path, e.g. "/embeddings/vectors".
+func (f *hdf5File) lookup(path string) (int64, error) {
+	pos := f.root
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		msgs, err := f.messages(pos)
+		if err != nil {
+			return 0, err
+		}
+		next, found, err := f.link(msgs, name)
+		if err != nil {
+			return 0, err
+		}
+		if !found {
+			return 0, fmt.Errorf("no object %q", name)
+		}
+		pos = next
+	}
+	return pos, nil
+}
+
+// link returns the address of the object header of the member of the
+// group with the given messages.
+func (f *hdf5File) link(msgs []h5Message, name string) (int64, bool, error) {
+	for _, m := range msgs {
+		switch m.typ {
+		case h5MsgSymbolTable:
+			if len(m.data) < 2*f.offsets {
+				return 0, false, errors.New("malformed symbol table message")
+			}
+			tree, _ := f.addr(m.data)
+			heap, _ := f.addr(m.data[f.offsets:])
+			return f.symbol(tree, heap, name)
+		case h5MsgLinkInfo:
+			// The fractal heap of dense link storage, if defined.
+			if len(m.data) < 2 {
+				return 0, false, errors.New("malformed link info message")
+			}
+			at := 2
+			if m.data[1]&0x01 != 0 {
+				at += 8
+			}
+			if len(m.data) < at+f.offsets {
+				return 0, false, errors.New("malformed link info message")
+			}
+			if _, ok := f.addr(m.data[at:]); ok {
+				return 0, false, errors.New("dense link storage is not supported")
+			}
+		case h5MsgLink:
+			linkName, target, ok, err := f.parseLink(m.data)
+			if err != nil {
+				return 0, false, err
+			}
+			if ok && linkName == name {
+				return target, true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// parseLink parses a hard link message, returning false for other links.
+func (f *hdf5File) parseLink(b []byte) (string, int64, bool, error) {
+	malformed := errors.New("malformed link message")
+	if len(b) < 2 {
+		return "", 0, false, malformed
+	}
+	flags := b[1]
+	at := 2
+	if flags&0x08 != 0 {
+		if len(b) <= at {
+			return "", 0, false, malformed
+		}
+		if b[at] != 0 {
+			return "", 0, false, nil
+		}
+		at++
+	}
+	if flags&0x04 != 0 {
+		// The creation order.
+		at += 8
+	}
+	if flags&0x10 != 0 {
+		// The character set.
+		at++
+	}
+	width := 1 << (flags & 3)
+	if len(b) < at+width {
+		return "", 0, false, malformed
+	}
+	n := int(f.uint(b[at:], width))
+	at += width
+	if n < 0 || n > len(b)-at-f.offsets {
+		return "", 0, false, malformed
+	}
+	name := string(b[at : at+n])
+	target, _ := f.addr(b[at+n:])
+	return name, target, true, nil
+}
+
+// symbol finds the entry of the name in the version 1 B-tree of a group
+// and its local heap of names.
+func (f *hdf5File) symbol(tree, heap int64, name string) (int64, bool, error) {
+	h, err := f.readAt(heap, 8+2*f.lengths+f.offsets)
+	if err != nil {
+		return 0, false, err
+	}
+	if string(h[:4]) != "HEAP" {
+		return 0, false, errors.New("malformed local heap")
+	}
+	names, _ := f.addr(h[8+2*f.lengths:])
+	node, err := f.readAt(tree, 8+2*f.offsets)
+	if err != nil {
+		return 0, false, err
+	}
+	if string(node[:4]) != "TREE" || node[4] != 0 {
+		return 0, false, errors.New("malformed group B-tree")
+	}
+	level := node[5]
+	entries := int(binary.LittleEndian.Uint16(node[6:]))
+	b, err := f.readAt(tree+8+2*int64(f.offsets), entries*(f.lengths+f.offsets)+f.lengths)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := 0; i < entries; i++ {
+		child, _ := f.addr(b[i*(f.lengths+f.offsets)+f.lengths:])
+		var pos int64
+		var found bool
+		if level > 0 {
+			pos, found, err = f.symbol(child, heap, name)
+		} else {
+			pos, found, err = f.symbolNode(child, names, name)
+		}
+		if err != nil || found {
+			return pos, found, err
+		}
+	}
+	return 0, false, nil
+}
+
+// symbolNode finds the entry of the name in a symbol table node.
+func (f *hdf5File) symbolNode(pos, names int64, name string) (int64, bool, error) {
+	head, err := f.readAt(pos, 8)
+	if err != nil {
+		return 0, false, err
+	}
+	if string(head[:4]) != "SNOD" {
+		return 0, false, errors.New("malformed symbol table node")
+	}
+	count := int(binary.LittleEndian.Uint16(head[6:]))
+	size := 2*f.offsets + 24
+	b, err := f.readAt(pos+8, count*size)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := 0; i < count; i++ {
+		entry := b[i*size:]
+		entryName, err := f.cstring(names + int64(f.uint(entry, f.offsets)))
+		if err != nil {
+			return 0, false, err
+		}
+		if entryName == name {
+			target, _ := f.addr(entry[f.offsets:])
+			return target, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// cstring reads a null-terminated string.
+func (f *hdf5File) cstring(pos int64) (string, error) {
+	var buf []byte
+	chunk := make([]byte, 64)
+	for {
+		n, err := f.r.ReadAt(chunk, pos)
+		if i := bytes.IndexByte(chunk[:n], 0); i >= 0 {
+			return string(append(buf, chunk[:i]...)), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		buf = append(buf, chunk[:n]...)
+		pos += int64(n)
+	}
+}
+
+// h5Dataset is the description of a dataset.
+type h5Dataset struct {
+	dims  []int
+	dtype []byte
+	// data is the data of a compact dataset, else the data is at pos.
+	data []byte
+	pos  int64
+	// allocated is false if the data was never written.
+	allocated bool
+}
+
+func (d *h5Dataset) len() int {
+	n := 1
+	for _, dim := range d.dims {
+		n *= dim
+	}
+	return n
+}
+
+func (f *hdf5File) dataset(path string) (*h5Dataset, error) {
+	pos, err := f.lookup(path)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := f.messages(pos)
+	if err != nil {
+		return nil, err
+	}
+	d := &h5Dataset{}
+	var layout []byte
+	for _, m := range msgs {
+		if m.flags&0x02 != 0 && (m.typ == h5MsgDatatype || m.typ == h5MsgDataspace) {
+			return nil, errors.New("shared datatypes are not supported")
+		}
+		switch m.typ {
+		case h5MsgDataspace:
+			if d.dims, err = f.parseDataspace(m.data); err != nil {
+				return nil, err
+			}
+		case h5MsgDatatype:
+			d.dtype = m.data
+		case h5MsgLayout:
+			layout = m.data
+		}
+	}
+	if d.dims == nil || d.dtype == nil || layout == nil {
+		return nil, errors.New("not a dataset")
+	}
+	// The class, version and bit fields, and the size of the elements.
+	if len(d.dtype) < 8 {
+		return nil, errors.New("malformed datatype message")
+	}
+	return d, f.parseLayout(d, layout)
+}
+
+// maxHDF5Elements bounds the number of elements of a dataset.
+const maxHDF5Elements = 1 << 40
+
+func (f *hdf5File) parseDataspace(b []byte) ([]int, error) {
+	if len(b) < 2 {
+		return nil, errors.New("malformed dataspace message")
+	}
+	rank := int(b[1])
+	var at int
+	switch b[0] {
+	case 1:
+		at = 8
+	case 2:
+		at = 4
+	default:
+		return nil, fmt.Errorf("unsupported dataspace version %d", b[0])
+	}
+	if len(b) < at+rank*f.lengths {
+		return nil, errors.New("malformed dataspace message")
+	}
+	dims := make([]int, rank)
+	n := uint64(1)
+	for i := range dims {
+		dim := f.uint(b[at+i*f.lengths:], f.lengths)
+		if dim != 0 && n > maxHDF5Elements/dim {
+			return nil, errors.New("malformed dataspace message: too many elements")
+		}
+		n *= dim
+		dims[i] = int(dim)
+	}
+	return dims, nil
+}
+
+func (f *hdf5File) parseLayout(d *h5Dataset, b []byte) error {
+	malformed := errors.New("malformed layout message")
+	if len(b) < 2 {
+		return malformed
+	}
+	var class byte
+	switch version := b[0]; version {
+	case 1, 2:
+		if len(b) < 8 {
+			return malformed
+		}
+		rank := int(b[1])
+		class = b[2]
+		at := 8
+		switch class {
+		case 0:
+			at += 4 * rank
+			if len(b) < at+4 {
+				return malformed
+			}
+			n := int(binary.LittleEndian.Uint32(b[at:]))
+			if n > len(b)-at-4 {
+				return malformed
+			}
+			d.data = b[at+4 : at+4+n]
+			d.allocated = true
+		case 1:
+			if len(b) < at+f.offsets {
+				return malformed
+			}
+			d.pos, d.allocated = f.addr(b[at:])
+		}
+	case 3, 4:
+		class = b[1]
+		switch class {
+		case 0:
+			if len(b) < 4 {
+				return malformed
+			}
+			n := int(binary.LittleEndian.Uint16(b[2:]))
+			if n > len(b)-4 {
+				return malformed
+			}
+			d.data = b[4 : 4+n]
+			d.allocated = true
+		case 1:
+			if len(b) < 2+f.offsets {
+				return malformed
+			}
+			d.pos, d.allocated = f.addr(b[2:])
+		}
+	default:
+		return fmt.Errorf("unsupported layout version %d", version)
+	}
+	if class > 1 {
+		return errors.New("chunked datasets are not supported, save the dataset without chunks or compression")
+	}
+	return nil
+}
+
+// readStrings reads a 1-dimensional dataset of strings.
+func (f *hdf5File) readStrings(path string) ([]string, error) {
+	d, err := f.dataset(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.dims) != 1 {
+		return nil, fmt.Errorf("expected a 1-dimensional dataset, got %d dimensions", len(d.dims))
+	}
+	class := d.dtype[0] & 0x0f
+	size := int(binary.LittleEndian.Uint32(d.dtype[4:]))
+	switch {
+	case class == h5ClassString:
+		if size == 0 {
+			return nil, errors.New("malformed string datatype")
+		}
+	case class == h5ClassVLen && d.dtype[1]&0x0f == 1:
+		// The length and the global heap ID of the string.
+		if size < 4+f.offsets+4 {
+			return nil, errors.New("malformed variable-length string datatype")
+		}
+	default:
+		return nil, errors.New("expected a dataset of strings")
+	}
+	data, err := f.data(d, size)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]string, d.dims[0])
+	for i := range words {
+		elem := data[i*size : (i+1)*size]
+		if class == h5ClassString {
+			// Null-terminated, null-padded or space-padded.
+			if j := bytes.IndexByte(elem, 0); j >= 0 {
+				elem = elem[:j]
+			}
+			if d.dtype[1]&0x0f == 2 {
+				elem = bytes.TrimRight(elem, " ")
+			}
+			words[i] = string(elem)
+			continue
+		}
+		n := int(binary.LittleEndian.Uint32(elem))
+		collection, _ := f.addr(elem[4:])
+		index := uint16(binary.LittleEndian.Uint32(elem[4+f.offsets:]))
+		obj, err := f.heapObject(collection, index)
+		if err != nil {
+			return nil, err
+		}
+		if n > len(obj) {
+			return nil, errors.New("malformed variable-length string")
+		}
+		words[i] = string(obj[:n])
+	}
+	return words, nil
+}
+
+// data returns the data of the dataset of elements of the given size.
+func (f *hdf5File) data(d *h5Dataset, size int) ([]byte, error) {
+	if !d.allocated {
+		return nil, errors.New("dataset without data")
+	}
+	if d.len() > (1<<30)/size {
+		return nil, errors.New("dataset too large")
+	}
+	n := d.len() * size
+	if d.data != nil {
+		if len(d.data) < n {
+			return nil, errors.New("malformed compact dataset")
+		}
+		return d.data, nil
+	}
+	return f.readAt(d.pos, n)
+}
+
+// heapObject returns an object of a global heap collection.
+func (f *hdf5File) heapObject(collection int64, index uint16) ([]byte, error) {
+	objs, ok := f.heaps[collection]
+	if !ok {
+		head, err := f.readAt(collection, 8+f.lengths)
+		if err != nil {
+			return nil, err
+		}
+		if string(head[:4]) != "GCOL" {
+			return nil, errors.New("malformed global heap")
+		}
+		size := int(f.uint(head[8:], f.lengths))
+		b, err := f.readAt(collection, size)
+		if err != nil {
+			return nil, err
+		}
+		objs = make(map[uint16][]byte)
+		for at := 8 + f.lengths; at+8+f.lengths <= len(b); {
+			i := binary.LittleEndian.Uint16(b[at:])
+			if i == 0 {
+				// The free space ends the collection.
+				break
+			}
+			n := int(f.uint(b[at+8:], f.lengths))
+			at += 8 + f.lengths
+			if n < 0 || n > len(b)-at {
+				return nil, errors.New("malformed global heap")
+			}
+			objs[i] = b[at : at+n]
+			// The objects are aligned to 8 bytes.
+			at += (n + 7) &^ 7
+		}
+		f.heaps[collection] = objs
+	}
+	obj, ok := objs[index]
+	if !ok {
+		return nil, errors.New("missing global heap object")
+	}
+	return obj, nil
+}
+
+// readMatrix returns a reader of the rows of a 2-dimensional dataset of
+// floats.
+func (f *hdf5File) readMatrix(path string) (*npyArray, error) {
+	d, err := f.dataset(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.dims) != 2 {
+		return nil, fmt.Errorf("expected a 2-dimensional dataset, got %d dimensions", len(d.dims))
+	}
+	if d.dtype[0]&0x0f != h5ClassFloat {
+		return nil, errors.New("expected a dataset of floats")
+	}
+	size := int(binary.LittleEndian.Uint32(d.dtype[4:]))
+	if size != 2 && size != 4 && size != 8 {
+		return nil, fmt.Errorf("unsupported float size %d", size)
+	}
+	order := "<"
+	if d.dtype[1]&0x01 != 0 {
+		order = ">"
+	}
+	if !d.allocated {
+		return nil, errors.New("dataset without data")
+	}
+	n := d.len() * size
+	var r io.Reader
+	if d.data != nil {
+		if len(d.data) < n {
+			return nil, errors.New("malformed compact dataset")
+		}
+		r = bytes.NewReader(d.data)
+	} else {
+		// The rows are streamed, but must be in the file.
+		if n > 0 {
+			if _, err := f.readAt(d.pos+int64(n)-1, 1); err != nil {
+				return nil, errors.New("malformed dataset: data past the end of the file")
+			}
+		}
+		r = io.NewSectionReader(f.r, d.pos, int64(n))
+	}
+	return newNpyArray(r, fmt.Sprintf("%sf%d", order, size), d.dims[0], d.dims[1])
+}
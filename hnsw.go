@@ -0,0 +1,392 @@
+package fasttext
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// hnswMagic starts the files of persisted HNSW indexes.
+const hnswMagic = "FTHNSW1\n"
+
+// hnswMaxLevel bounds the top level of a persisted graph: levels are
+// drawn with probability m^-level, so real graphs stay far below.
+const hnswMaxLevel = 64
+
+// hnsw is a Hierarchical Navigable Small World graph over unit-length
+// vectors (Malkov & Yashunin, 2016), for approximate nearest neighbor
+// search by cosine similarity.
+type hnsw struct {
+	m              int
+	efConstruction int
+	dim            int
+	words          []string
+	// vecs holds the unit-length vectors of all nodes, contiguously.
+	vecs []float32
+	// links[node][level] lists the neighbors of node at level.
+	links    [][][]int32
+	entry    int32
+	maxLevel int
+	rng      *rand.Rand
+	// generation is the generation of the vocabulary indexed.
+	generation int64
+}
+
+func newHNSW(dim, m, efConstruction int, seed int64) *hnsw {
+	return &hnsw{
+		m:              m,
+		efConstruction: efConstruction,
+		dim:            dim,
+		entry:          -1,
+		rng:            rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (h *hnsw) vec(node int32) []float32 {
+	return h.vecs[int(node)*h.dim : int(node+1)*h.dim]
+}
+
+// distance is the cosine distance between the unit-length query and
+// the vector of the node.
+func (h *hnsw) distance(q []float32, node int32) float32 {
+	return 1 - float32(dot(q, h.vec(node)))
+}
+
+func (h *hnsw) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * h.m
+	}
+	return h.m
+}
+
+// add inserts the word with its vector into the graph.
+func (h *hnsw) add(word string, vec []float32) {
+	node := int32(len(h.words))
+	h.words = append(h.words, word)
+	h.vecs = append(h.vecs, unitVec(vec)...)
+	q := h.vec(node)
+	level := int(math.Floor(-math.Log(1-h.rng.Float64()) / math.Log(float64(h.m))))
+	h.links = append(h.links, make([][]int32, level+1))
+	if h.entry < 0 {
+		h.entry, h.maxLevel = node, level
+		return
+	}
+	ep := h.entry
+	for l := h.maxLevel; l > level; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	for l := minInt(level, h.maxLevel); l >= 0; l-- {
+		candidates := h.searchLevel(q, []int32{ep}, h.efConstruction, l)
+		neighbors := h.selectNeighbors(candidates, h.m)
+		h.links[node][l] = neighbors
+		for _, n := range neighbors {
+			h.connect(n, node, l)
+		}
+		ep = candidates[0].node
+	}
+	if level > h.maxLevel {
+		h.entry, h.maxLevel = node, level
+	}
+}
+
+// connect adds a link from node to other at the level, pruning the
+// links of node if it has too many.
+func (h *hnsw) connect(node, other int32, level int) {
+	links := append(h.links[node][level], other)
+	if len(links) <= h.maxLinks(level) {
+		h.links[node][level] = links
+		return
+	}
+	q := h.vec(node)
+	candidates := make([]hnswCandidate, len(links))
+	for i, n := range links {
+		candidates[i] = hnswCandidate{node: n, dist: h.distance(q, n)}
+	}
+	sortCandidates(candidates)
+	h.links[node][level] = h.selectNeighbors(candidates, h.maxLinks(level))
+}
+
+// selectNeighbors picks up to m of the candidates, sorted by distance,
+// with the heuristic favoring neighbors in diverse directions.
+func (h *hnsw) selectNeighbors(candidates []hnswCandidate, m int) []int32 {
+	selected := make([]int32, 0, m)
+	var skipped []int32
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+		good := true
+		for _, s := range selected {
+			if h.distance(h.vec(c.node), s) < c.dist {
+				good = false
+				break
+			}
+		}
+		if good {
+			selected = append(selected, c.node)
+		} else {
+			skipped = append(skipped, c.node)
+		}
+	}
+	// Fill up with the closest skipped candidates.
+	for _, n := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, n)
+	}
+	return selected
+}
+
+// greedy walks the level from ep towards the node closest to q.
+func (h *hnsw) greedy(q []float32, ep int32, level int) int32 {
+	best := h.distance(q, ep)
+	for changed := true; changed; {
+		changed = false
+		for _, n := range h.links[ep][level] {
+			if d := h.distance(q, n); d < best {
+				best, ep, changed = d, n, true
+			}
+		}
+	}
+	return ep
+}
+
+type hnswCandidate struct {
+	node int32
+	dist float32
+}
+
+// searchLevel returns the ef nodes closest to q found at the level from
+// the entry points, sorted by increasing distance.
+func (h *hnsw) searchLevel(q []float32, eps []int32, ef, level int) []hnswCandidate {
+	visited := map[int32]bool{}
+	candidates := &candidateHeap{}
+	results := &candidateHeap{max: true}
+	for _, ep := range eps {
+		c := hnswCandidate{node: ep, dist: h.distance(q, ep)}
+		visited[ep] = true
+		heap.Push(candidates, c)
+		heap.Push(results, c)
+	}
+	for candidates.Len() > 0 {
+		c := heap.Pop(candidates).(hnswCandidate)
+		if c.dist > results.items[0].dist && results.Len() >= ef {
+			break
+		}
+		for _, n := range h.links[c.node][level] {
+			if visited[n] {
+				continue
+			}
+			visited[n] = true
+			d := h.distance(q, n)
+			if results.Len() < ef || d < results.items[0].dist {
+				heap.Push(candidates, hnswCandidate{node: n, dist: d})
+				heap.Push(results, hnswCandidate{node: n, dist: d})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	out := results.items
+	sortCandidates(out)
+	return out
+}
+
+// search returns the approximately nearest nodes to vec, with the
+// search list size ef.
+func (h *hnsw) search(vec []float32, ef int) []hnswCandidate {
+	if h.entry < 0 {
+		return nil
+	}
+	q := unitVec(vec)
+	ep := h.entry
+	for l := h.maxLevel; l > 0; l-- {
+		ep = h.greedy(q, ep, l)
+	}
+	return h.searchLevel(q, []int32{ep}, ef, 0)
+}
+
+// candidateHeap is a min-heap of candidates by distance, or a max-heap
+// if max is set.
+type candidateHeap struct {
+	items []hnswCandidate
+	max   bool
+}
+
+func (c *candidateHeap) Len() int { return len(c.items) }
+func (c *candidateHeap) Less(i, j int) bool {
+	if c.max {
+		return c.items[i].dist > c.items[j].dist
+	}
+	return c.items[i].dist < c.items[j].dist
+}
+func (c *candidateHeap) Swap(i, j int)      { c.items[i], c.items[j] = c.items[j], c.items[i] }
+func (c *candidateHeap) Push(x interface{}) { c.items = append(c.items, x.(hnswCandidate)) }
+func (c *candidateHeap) Pop() interface{} {
+	x := c.items[len(c.items)-1]
+	c.items = c.items[:len(c.items)-1]
+	return x
+}
+
+// sortCandidates sorts by increasing distance.
+func sortCandidates(c []hnswCandidate) {
+	sort.Slice(c, func(i, j int) bool { return c[i].dist < c[j].dist })
+}
+
+// writeTo persists the graph.
+func (h *hnsw) writeTo(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	le := binary.LittleEndian
+	bw.WriteString(hnswMagic)
+	header := []int64{int64(h.m), int64(h.efConstruction), int64(h.dim),
+		int64(len(h.words)), int64(h.entry), int64(h.maxLevel), h.generation}
+	if err := binary.Write(bw, le, header); err != nil {
+		return err
+	}
+	for _, word := range h.words {
+		binary.Write(bw, le, uint32(len(word)))
+		bw.WriteString(word)
+	}
+	if err := binary.Write(bw, le, h.vecs); err != nil {
+		return err
+	}
+	for _, levels := range h.links {
+		binary.Write(bw, le, uint32(len(levels)))
+		for _, links := range levels {
+			binary.Write(bw, le, uint32(len(links)))
+			if err := binary.Write(bw, le, links); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// readHNSW loads a graph persisted by writeTo from a file of the given
+// size, checking the sizes and the links against the file so a
+// truncated or corrupt file fails instead of exhausting memory or
+// breaking the searches.
+func readHNSW(r io.Reader, fileSize int64) (*hnsw, error) {
+	br := bufio.NewReader(r)
+	le := binary.LittleEndian
+	magic := make([]byte, len(hnswMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != hnswMagic {
+		return nil, errors.New("fasttext: not an HNSW index file")
+	}
+	header := make([]int64, 7)
+	if err := binary.Read(br, le, header); err != nil {
+		return nil, err
+	}
+	m, efConstruction, dim, n := header[0], header[1], header[2], header[3]
+	entry, maxLevel, generation := header[4], header[5], header[6]
+	// Every word takes at least its 4 bytes of length, and every
+	// vector 4 bytes per value.
+	switch {
+	case m < 2 || m > fileSize || efConstruction <= 0 || efConstruction > math.MaxInt32:
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: invalid parameters m=%d, efConstruction=%d",
+			m, efConstruction)
+	case n < 0 || n > fileSize/4 || dim < 0 || (n > 0 && (dim == 0 || dim > fileSize/4/n)):
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: invalid size of %d vectors of %d values", n, dim)
+	case n == 0 && entry != -1, n > 0 && (entry < 0 || entry >= n):
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: invalid entry point %d of %d nodes", entry, n)
+	case maxLevel < 0 || maxLevel > hnswMaxLevel:
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: invalid top level %d", maxLevel)
+	case generation < 0:
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: invalid generation %d", generation)
+	}
+	h := newHNSW(int(dim), int(m), int(efConstruction), 0)
+	h.entry, h.maxLevel, h.generation = int32(entry), int(maxLevel), generation
+	h.words = make([]string, n)
+	var size uint32
+	for i := range h.words {
+		if err := binary.Read(br, le, &size); err != nil {
+			return nil, err
+		}
+		if int64(size) > fileSize {
+			return nil, fmt.Errorf("fasttext: corrupt HNSW index: word %d of %d bytes", i, size)
+		}
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		h.words[i] = string(buf)
+	}
+	h.vecs = make([]float32, n*dim)
+	if err := binary.Read(br, le, h.vecs); err != nil {
+		return nil, err
+	}
+	h.links = make([][][]int32, n)
+	for i := range h.links {
+		if err := binary.Read(br, le, &size); err != nil {
+			return nil, err
+		}
+		if size == 0 || int(size) > h.maxLevel+1 {
+			return nil, fmt.Errorf("fasttext: corrupt HNSW index: node %d has %d levels", i, size)
+		}
+		h.links[i] = make([][]int32, size)
+		for l := range h.links[i] {
+			if err := binary.Read(br, le, &size); err != nil {
+				return nil, err
+			}
+			if int64(size) > n || int(size) > h.maxLinks(l) {
+				return nil, fmt.Errorf("fasttext: corrupt HNSW index: node %d has %d links at level %d", i, size, l)
+			}
+			h.links[i][l] = make([]int32, size)
+			if err := binary.Read(br, le, h.links[i][l]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if n > 0 && len(h.links[h.entry]) != h.maxLevel+1 {
+		return nil, fmt.Errorf("fasttext: corrupt HNSW index: entry point %d is not at the top level", h.entry)
+	}
+	// The searches follow the links of a level to nodes they expect
+	// on the level.
+	for i, levels := range h.links {
+		for l, links := range levels {
+			for _, node := range links {
+				if node < 0 || int64(node) >= n || len(h.links[node]) <= l {
+					return nil, fmt.Errorf("fasttext: corrupt HNSW index: node %d links to %d at level %d", i, node, l)
+				}
+			}
+		}
+	}
+	return h, nil
+}
+
+// unitVec returns a copy of the vector scaled to unit length.
+func unitVec(vec []float32) []float32 {
+	out := make([]float32, len(vec))
+	n := l2norm(vec)
+	if n == 0 {
+		return out
+	}
+	copy(out, vec)
+	scale(float32(1/n), out)
+	return out
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
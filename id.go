@@ -0,0 +1,46 @@
+package fasttext
+
+import (
+	"database/sql"
+)
+
+// GetID returns the ID of the word. BuildDB assigns each word an
+// integer ID, starting at 1 in the order of the input, e.g. for
+// downstream models storing compact token IDs instead of strings. IDs
+// are the rowids of the table, declared as its AUTOINCREMENT primary
+// key so that they stay stable: words added later get new IDs, never
+// those of deleted words, and updated words keep theirs. The rowids of
+// databases built before IDs were recorded serve as IDs, but the IDs of
+// their deleted words may be reused, and vacuuming them may renumber
+// the words.
+func (ft *FastText) GetID(word string) (int64, error) {
+	var id int64
+	err := ft.db.QueryRow(ft.sql(`SELECT rowid FROM fasttext WHERE word=?;`), ft.normalize(word)).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, &WordNotFoundError{Word: word}
+	}
+	return id, err
+}
+
+// GetWordByID returns the word with the given ID.
+func (ft *FastText) GetWordByID(id int64) (string, error) {
+	var word string
+	err := ft.db.QueryRow(ft.sql(`SELECT word FROM fasttext WHERE rowid=?;`), id).Scan(&word)
+	if err == sql.ErrNoRows {
+		return "", &IDNotFoundError{ID: id}
+	}
+	return word, err
+}
+
+// GetEmbByID returns the word embedding of the word with the given ID.
+func (ft *FastText) GetEmbByID(id int64) ([]float32, error) {
+	var binVec []byte
+	err := ft.db.QueryRow(ft.sql(`SELECT emb FROM fasttext WHERE rowid=?;`), id).Scan(&binVec)
+	if err == sql.ErrNoRows {
+		return nil, &IDNotFoundError{ID: id}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ft.decode(binVec)
+}
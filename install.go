@@ -0,0 +1,48 @@
+package fasttext
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// InstallDB writes a prebuilt database read from r, e.g. downloaded by
+// a service at startup, to the file path. Gzip compressed and zipped
+// databases are decompressed transparently. The database is written to
+// a temporary file next to path and checked before it replaces path,
+// so a failed download never leaves a partial database behind.
+func InstallDB(r io.Reader, path string) error {
+	r, err := decompress(r)
+	if err != nil {
+		return err
+	}
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := os.CreateTemp(dir, base+".download-*")
+	if err != nil {
+		return err
+	}
+	tmp := file.Name()
+	defer os.Remove(tmp)
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	ft := NewFastText(tmp, WithReadOnly())
+	err = ft.checkDB(ft.fileDSN(tmp))
+	ft.Close()
+	if err != nil {
+		return fmt.Errorf("fasttext: not a fastText database: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,85 @@
+package fasttext
+
+import "database/sql"
+
+// EmbIterator streams the (word, embedding) pairs stored in the
+// database in insertion order. It must be closed after use.
+//
+//	it, err := ft.Iter()
+//	if err != nil {
+//		return err
+//	}
+//	defer it.Close()
+//	for it.Next() {
+//		fmt.Println(it.Word(), it.Emb())
+//	}
+//	return it.Err()
+type EmbIterator struct {
+	ft   *FastText
+	rows *sql.Rows
+	word string
+	emb  []float32
+	err  error
+}
+
+// Iter returns an iterator over the entire vocabulary.
+func (ft *FastText) Iter() (*EmbIterator, error) {
+	rows, err := ft.db.Query(ft.sql(`SELECT word, emb FROM fasttext ORDER BY rowid;`))
+	if err != nil {
+		return nil, err
+	}
+	return &EmbIterator{ft: ft, rows: rows}, nil
+}
+
+// Next advances the iterator to the next word embedding. It returns
+// false when the vocabulary is exhausted or an error occurred.
+func (it *EmbIterator) Next() bool {
+	if it.err != nil || !it.rows.Next() {
+		return false
+	}
+	var binVec []byte
+	if it.err = it.rows.Scan(&it.word, &binVec); it.err != nil {
+		return false
+	}
+	it.emb, it.err = it.ft.decode(binVec)
+	return it.err == nil
+}
+
+// Word returns the current word.
+func (it *EmbIterator) Word() string {
+	return it.word
+}
+
+// Emb returns the embedding of the current word.
+func (it *EmbIterator) Emb() []float32 {
+	return it.emb
+}
+
+// Err returns the error, if any, encountered during the iteration.
+func (it *EmbIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.rows.Err()
+}
+
+// Close releases the database resources held by the iterator.
+func (it *EmbIterator) Close() error {
+	return it.rows.Close()
+}
+
+// ForEach calls fn on every word embedding in the vocabulary, stopping
+// at the first error returned by fn.
+func (ft *FastText) ForEach(fn func(word string, emb []float32) error) error {
+	it, err := ft.Iter()
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.Next() {
+		if err := fn(it.Word(), it.Emb()); err != nil {
+			return err
+		}
+	}
+	return it.Err()
+}
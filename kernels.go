@@ -0,0 +1,23 @@
+package fasttext
+
+import "gonum.org/v1/gonum/blas/gonum"
+
+// blas32 provides the float32 kernels of the similarity computations.
+// Its level 1 routines are SIMD assembly on amd64 and arm64, several
+// times faster than a plain loop on 300-dimensional vectors.
+var blas32 gonum.Implementation
+
+// dot returns the dot product of two vectors of the same length.
+func dot(a, b []float32) float64 {
+	return float64(blas32.Sdot(len(a), a, 1, b, 1))
+}
+
+// axpy adds alpha times x to y, of the same length.
+func axpy(alpha float32, x, y []float32) {
+	blas32.Saxpy(len(x), alpha, x, 1, y, 1)
+}
+
+// scale multiplies the vector by alpha in place.
+func scale(alpha float32, x []float32) {
+	blas32.Sscal(len(x), alpha, x, 1)
+}
@@ -0,0 +1,161 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// GraphFormat is the file format of ExportKNNGraph.
+type GraphFormat int
+
+const (
+	// GraphEdgeList writes one "source target similarity" line per edge,
+	// read by networkx.read_weighted_edgelist or igraph's Read_Ncol.
+	GraphEdgeList GraphFormat = iota
+	// GraphML writes a GraphML document, with the similarities as the
+	// "weight" attribute of the edges.
+	GraphML
+)
+
+type graphConfig struct {
+	words []string
+	top   int
+}
+
+// GraphOption configures ExportKNNGraph.
+type GraphOption func(*graphConfig)
+
+// WithGraphWords restricts the graph to the given words found in the
+// vocabulary.
+func WithGraphWords(words []string) GraphOption {
+	return func(cfg *graphConfig) {
+		cfg.words = words
+	}
+}
+
+// WithGraphTop restricts the graph to the n most frequent words, see
+// IterTop.
+func WithGraphTop(n int) GraphOption {
+	return func(cfg *graphConfig) {
+		cfg.top = n
+	}
+}
+
+// ExportKNNGraph writes the directed k-nearest-neighbor graph of the
+// vocabulary, or of the subset given by the options, e.g. for community
+// detection: an edge goes from each word to each of its k most similar
+// words of the subset by cosine similarity, weighted by the similarity.
+// The vectors of the subset are read once and loaded in memory, and the
+// neighbors are searched exhaustively on all CPUs, in time quadratic in
+// the number of words.
+func (ft *FastText) ExportKNNGraph(w io.Writer, k int, format GraphFormat, opts ...GraphOption) error {
+	cfg := &graphConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	m, err := ft.graphMatrix(cfg)
+	if err != nil {
+		return err
+	}
+	edges := make([][]ScoredWord, m.Len())
+	rows := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range rows {
+				edges[row] = m.nearest(m.Row(row), k, excludeWords(m.Words[row]))
+			}
+		}()
+	}
+	for row := range edges {
+		rows <- row
+	}
+	close(rows)
+	wg.Wait()
+
+	bw := bufio.NewWriter(w)
+	switch format {
+	case GraphEdgeList:
+		var buf []byte
+		for row, nn := range edges {
+			for _, n := range nn {
+				buf = append(buf[:0], m.Words[row]...)
+				buf = append(buf, ' ')
+				buf = append(buf, n.Word...)
+				buf = append(buf, ' ')
+				buf = strconv.AppendFloat(buf, n.Score, 'g', 6, 64)
+				buf = append(buf, '\n')
+				bw.Write(buf)
+			}
+		}
+	case GraphML:
+		bw.WriteString(xml.Header)
+		bw.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+		bw.WriteString(`  <key id="weight" for="edge" attr.name="weight" attr.type="double"/>` + "\n")
+		bw.WriteString(`  <graph edgedefault="directed">` + "\n")
+		for _, word := range m.Words {
+			bw.WriteString(`    <node id="`)
+			xml.EscapeText(bw, []byte(word))
+			bw.WriteString("\"/>\n")
+		}
+		for row, nn := range edges {
+			for _, n := range nn {
+				bw.WriteString(`    <edge source="`)
+				xml.EscapeText(bw, []byte(m.Words[row]))
+				bw.WriteString(`" target="`)
+				xml.EscapeText(bw, []byte(n.Word))
+				fmt.Fprintf(bw, "\"><data key=\"weight\">%g</data></edge>\n", n.Score)
+			}
+		}
+		bw.WriteString("  </graph>\n</graphml>\n")
+	default:
+		return fmt.Errorf("fasttext: unknown graph format %d", format)
+	}
+	return bw.Flush()
+}
+
+// graphMatrix loads the vectors of the words of the graph.
+func (ft *FastText) graphMatrix(cfg *graphConfig) (*Matrix, error) {
+	if cfg.words == nil && cfg.top <= 0 {
+		return ft.LoadMatrix()
+	}
+	m := &Matrix{index: make(map[string]int)}
+	add := func(word string, emb []float32) {
+		if _, ok := m.index[word]; ok {
+			return
+		}
+		m.dim = len(emb)
+		m.index[word] = len(m.Words)
+		m.Words = append(m.Words, word)
+		m.Data = append(m.Data, emb...)
+		m.norms = append(m.norms, l2norm(emb))
+	}
+	if cfg.words != nil {
+		embs, err := ft.GetEmbs(cfg.words)
+		if err != nil {
+			return nil, err
+		}
+		for i, emb := range embs {
+			if emb != nil {
+				add(cfg.words[i], emb)
+			}
+		}
+		return m, nil
+	}
+	it, err := ft.IterTop(cfg.top)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	for it.Next() {
+		add(it.Word(), it.Emb())
+	}
+	return m, it.Err()
+}
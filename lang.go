@@ -0,0 +1,194 @@
+package fasttext
+
+import (
+	"database/sql"
+	"io"
+)
+
+// LangTableName is the SQLite3 table holding the aligned multilingual
+// vectors of the default model keyed by (lang, word), "<name>_lang" for
+// the others.
+const LangTableName = "fasttext_lang"
+
+func (ft *FastText) createLangTable(db execer) error {
+	_, err := db.Exec(ft.sql(`
+	CREATE TABLE IF NOT EXISTS fasttext_lang(
+		lang TEXT,
+		word TEXT,
+		emb BLOB,
+		PRIMARY KEY (lang, word)
+	);`))
+	return err
+}
+
+// BuildLangDB imports the word embeddings of one language of the
+// fastText aligned vectors (https://fasttext.cc/docs/en/aligned-vectors.html),
+// in any format supported by BuildDB, under the given language code,
+// e.g. "en" or "fr". Call it once per language: all languages share one
+// database and, being aligned, one vector space, so they must have the
+// same dimension. Words already imported for the language are replaced.
+func (ft *FastText) BuildLangDB(lang string, wordEmbFile io.Reader, opts ...BuildOption) error {
+	return ft.buildKeyed(ft.createLangTable,
+		`INSERT OR REPLACE INTO fasttext_lang(lang, word, emb) VALUES(?, ?, ?);`,
+		lang, wordEmbFile, opts)
+}
+
+// buildKeyed imports word embeddings under the key with the insert
+// statement of (key, word, emb) into the table created by create, in
+// the format of the database.
+func (ft *FastText) buildKeyed(create func(execer) error, insert, key string,
+	wordEmbFile io.Reader, opts []BuildOption) error {
+	cfg := ft.newBuildConfig(opts)
+	wordEmbFile, err := decompress(cfg.progress.reader(wordEmbFile))
+	if err != nil {
+		return err
+	}
+	defer cfg.stop()
+	embs := readwordEmbdFile(wordEmbFile, &cfg.parse, cfg.done)
+	format, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	if format.dim == 0 {
+		format.codec = cfg.codec
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := create(tx); err != nil {
+		return err
+	}
+	if err := ft.createMetaTable(tx); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(ft.sql(insert))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for emb := range embs {
+		if emb.Err != nil {
+			return emb.Err
+		}
+		vec, err := format.input(emb)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(key, ft.normalize(emb.Word), format.encode(vec)); err != nil {
+			return err
+		}
+		cfg.progress.inserted()
+	}
+	if err := ft.setVecFormat(tx, format); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	cfg.progress.done()
+	return nil
+}
+
+// Langs returns the language codes imported with BuildLangDB.
+func (ft *FastText) Langs() ([]string, error) {
+	rows, err := ft.db.Query(ft.sql(`SELECT DISTINCT lang FROM fasttext_lang ORDER BY lang;`))
+	if err != nil {
+		if isNoSuchTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	var langs []string
+	for rows.Next() {
+		var lang string
+		if err := rows.Scan(&lang); err != nil {
+			return nil, err
+		}
+		langs = append(langs, lang)
+	}
+	return langs, rows.Err()
+}
+
+// GetEmbLang returns the word embedding of the given word in the given
+// language.
+func (ft *FastText) GetEmbLang(lang, word string) ([]float32, error) {
+	var binVec []byte
+	err := ft.db.QueryRow(ft.sql(`SELECT emb FROM fasttext_lang WHERE lang=? AND word=?;`),
+		lang, ft.normalize(word)).Scan(&binVec)
+	if err == sql.ErrNoRows || (err != nil && isNoSuchTable(err)) {
+		return nil, &WordNotFoundError{Word: word}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ft.decode(binVec)
+}
+
+// NearestNeighborsLang returns the k words of language dstLang most
+// similar by cosine similarity to the given word of language srcLang,
+// in descending order of similarity, e.g. its translations. If the
+// languages are the same, the word itself is excluded.
+func (ft *FastText) NearestNeighborsLang(srcLang, word, dstLang string, k int,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	vec, err := ft.GetEmbLang(srcLang, word)
+	if err != nil {
+		return nil, err
+	}
+	keep := func(string, float64) bool { return true }
+	if srcLang == dstLang {
+		keep = ft.excludeWords(word)
+	}
+	return ft.nearestKeyed(`SELECT word, emb FROM fasttext_lang WHERE lang=?;`, dstLang,
+		vec, k, keepWith(keep, opts))
+}
+
+// NearestByVectorLang returns the k words of the given language whose
+// embeddings are most similar to the given vector by cosine similarity,
+// in descending order of similarity.
+func (ft *FastText) NearestByVectorLang(vec []float32, lang string, k int,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	if err := ft.checkDim(vec); err != nil {
+		return nil, err
+	}
+	return ft.nearestKeyed(`SELECT word, emb FROM fasttext_lang WHERE lang=?;`, lang,
+		vec, k, keepWith(ft.excludeWords(), opts))
+}
+
+// nearestKeyed scans the words and embeddings selected by the query of
+// the key, e.g. the words of a language, for the k words most similar
+// to vec among the candidates accepted by keep.
+func (ft *FastText) nearestKeyed(query, key string, vec []float32, k int,
+	keep func(word string, score float64) bool) ([]ScoredWord, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	rows, err := ft.db.Query(ft.sql(query), key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	qnorm := l2norm(vec)
+	top := NewTopK(k)
+	for rows.Next() {
+		var word string
+		var binVec []byte
+		if err := rows.Scan(&word, &binVec); err != nil {
+			return nil, err
+		}
+		emb, err := ft.decode(binVec)
+		if err != nil {
+			return nil, err
+		}
+		score := cosine(vec, emb, qnorm)
+		if keep(word, score) {
+			top.Push(ScoredWord{Word: word, Score: score})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return top.Sorted(), nil
+}
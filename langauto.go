@@ -0,0 +1,112 @@
+package fasttext
+
+import (
+	"sort"
+	"unicode"
+)
+
+// LangDetector guesses the language code of a word, e.g. "fr", or
+// returns "" if it cannot tell.
+type LangDetector func(word string) string
+
+// WithLangDetector sets the language identifier consulted first by
+// GetEmbAuto.
+func WithLangDetector(detect LangDetector) Option {
+	return func(ft *FastText) {
+		ft.langDetector = detect
+	}
+}
+
+// WithLangPriority sets the order in which GetEmbAuto tries the
+// languages of a word found in several, after the detected language.
+// The languages not listed are tried last, in alphabetical order.
+func WithLangPriority(langs ...string) Option {
+	return func(ft *FastText) {
+		ft.langPriority = langs
+	}
+}
+
+// ScriptLangDetector returns a language identifier guessing the
+// language from the writing system of the first letter of the word,
+// which is enough for the scripts of a single language of the database,
+// e.g.
+//
+//	fasttext.ScriptLangDetector(map[*unicode.RangeTable]string{
+//		unicode.Cyrillic: "ru",
+//		unicode.Greek:    "el",
+//		unicode.Hangul:   "ko",
+//	})
+func ScriptLangDetector(scripts map[*unicode.RangeTable]string) LangDetector {
+	return func(word string) string {
+		for _, r := range word {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			for script, lang := range scripts {
+				if unicode.Is(script, r) {
+					return lang
+				}
+			}
+			return ""
+		}
+		return ""
+	}
+}
+
+// GetEmbAuto returns the word embedding of the given word in the
+// languages imported with BuildLangDB, and the language it was found
+// in, for tokens not tagged with a language. The languages of the word
+// are tried in the order of the detected language (see
+// WithLangDetector), then of WithLangPriority, all in one query.
+func (ft *FastText) GetEmbAuto(word string) (string, []float32, error) {
+	rows, err := ft.db.Query(ft.sql(`SELECT lang, emb FROM fasttext_lang WHERE word=?;`),
+		ft.normalize(word))
+	if err != nil {
+		if isNoSuchTable(err) {
+			return "", nil, &WordNotFoundError{Word: word}
+		}
+		return "", nil, err
+	}
+	defer rows.Close()
+	found := make(map[string][]byte)
+	var langs []string
+	for rows.Next() {
+		var lang string
+		var data []byte
+		if err := rows.Scan(&lang, &data); err != nil {
+			return "", nil, err
+		}
+		found[lang] = data
+		langs = append(langs, lang)
+	}
+	if err := rows.Err(); err != nil {
+		return "", nil, err
+	}
+	if len(langs) == 0 {
+		return "", nil, &WordNotFoundError{Word: word}
+	}
+	lang := ft.pickLang(word, langs)
+	vec, err := ft.decode(found[lang])
+	return lang, vec, err
+}
+
+// pickLang returns the language of the word to use among the languages
+// it was found in.
+func (ft *FastText) pickLang(word string, langs []string) string {
+	has := make(map[string]bool)
+	for _, lang := range langs {
+		has[lang] = true
+	}
+	if ft.langDetector != nil {
+		if lang := ft.langDetector(word); has[lang] {
+			return lang
+		}
+	}
+	for _, lang := range ft.langPriority {
+		if has[lang] {
+			return lang
+		}
+	}
+	sort.Strings(langs)
+	return langs[0]
+}
@@ -0,0 +1,121 @@
+package fasttext
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// localCopy is the configuration of WithLocalCopy.
+type localCopy struct {
+	dir      string
+	progress func(copied, total int64)
+	// tmp is the directory of the copy, removed by Close.
+	tmp string
+}
+
+// localCopyChunk is the number of bytes copied between two calls of the
+// progress callback of WithLocalCopyProgress.
+const localCopyChunk = 4 << 20
+
+// WithLocalCopy copies the database file of NewFastText, along with the
+// files stored next to it such as the ANN index, to a new temporary
+// directory in dir (the default directory for temporary files if
+// empty) and opens the copy, which Close removes. SQLite locking is
+// unreliable on network file systems, e.g. NFS or FUSE mounts of object
+// storage, leading to "database is locked" errors; the copy is read
+// from a local disk instead. Changes to the copy are lost on Close.
+// Like NewFastTextInMem, NewFastText panics if the copy fails; see
+// NewFastTextInMem to copy the database in memory instead.
+func WithLocalCopy(dir string) Option {
+	return func(ft *FastText) {
+		if ft.localCopy == nil {
+			ft.localCopy = &localCopy{}
+		}
+		ft.localCopy.dir = dir
+	}
+}
+
+// WithLocalCopyProgress reports the progress of the copy of
+// WithLocalCopy to fn, with the number of bytes copied so far and the
+// total number of bytes to copy.
+func WithLocalCopyProgress(fn func(copied, total int64)) Option {
+	return func(ft *FastText) {
+		if ft.localCopy == nil {
+			ft.localCopy = &localCopy{}
+		}
+		ft.localCopy.progress = fn
+	}
+}
+
+// copyLocal copies the database file and its sidecar files to a new
+// temporary directory and returns the path of the copy of the database.
+func (c *localCopy) copyLocal(path string) (string, error) {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	var total int64
+	for _, entry := range entries {
+		name := entry.Name()
+		// The database, e.g. model.sqlite, and its sidecars, e.g.
+		// model.sqlite.hnsw and model.sqlite-wal.
+		if entry.IsDir() || !strings.HasPrefix(name, base) ||
+			(name != base && name[len(base)] != '.' && name[len(base)] != '-') {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return "", err
+		}
+		names = append(names, name)
+		total += info.Size()
+	}
+	if c.tmp, err = os.MkdirTemp(c.dir, "fasttext-"); err != nil {
+		return "", err
+	}
+	var copied int64
+	for _, name := range names {
+		if err := c.copyFile(filepath.Join(dir, name), filepath.Join(c.tmp, name), &copied, total); err != nil {
+			os.RemoveAll(c.tmp)
+			return "", err
+		}
+	}
+	if c.progress != nil {
+		c.progress(copied, total)
+	}
+	return filepath.Join(c.tmp, base), nil
+}
+
+func (c *localCopy) copyFile(src, dst string, copied *int64, total int64) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	for {
+		n, err := io.CopyN(out, in, localCopyChunk)
+		*copied += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			out.Close()
+			return err
+		}
+		if c.progress != nil {
+			c.progress(*copied, total)
+		}
+	}
+	return out.Close()
+}
@@ -0,0 +1,48 @@
+package fasttext
+
+import (
+	"time"
+)
+
+// Logger receives the structured log messages of a session: a message
+// followed by alternating keys and values. *slog.Logger implements it.
+type Logger interface {
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+}
+
+// WithLogger logs the progress of the builds, the malformed lines of
+// their input and the slow queries of WithSlowQueryLog to l.
+func WithLogger(l Logger) Option {
+	return func(ft *FastText) {
+		ft.logger = l
+	}
+}
+
+// WithSlowQueryLog logs the look-ups and neighbor searches taking at
+// least threshold as warnings to the logger of WithLogger.
+func WithSlowQueryLog(threshold time.Duration) Option {
+	return func(ft *FastText) {
+		ft.slowQuery = threshold
+	}
+}
+
+func (ft *FastText) logInfo(msg string, args ...interface{}) {
+	if ft.logger != nil {
+		ft.logger.Info(msg, args...)
+	}
+}
+
+// observing returns whether the durations of the queries are needed,
+// by the metrics or the slow query log.
+func (ft *FastText) observing() bool {
+	return ft.metrics != nil || (ft.logger != nil && ft.slowQuery > 0)
+}
+
+// logSlow logs the query if it took at least the threshold of
+// WithSlowQueryLog.
+func (ft *FastText) logSlow(msg string, d time.Duration, args ...interface{}) {
+	if ft.logger != nil && ft.slowQuery > 0 && d >= ft.slowQuery {
+		ft.logger.Warn(msg, append(args, "duration", d)...)
+	}
+}
@@ -0,0 +1,137 @@
+package fasttext
+
+import (
+	"fmt"
+
+	"gonum.org/v1/gonum/blas"
+)
+
+// Matrix is the whole vocabulary loaded in memory as a dense matrix,
+// e.g. to initialize the embedding layer of a model.
+type Matrix struct {
+	// Data holds the vectors contiguously in row-major order: the
+	// vector of row i is Data[i*Dim() : (i+1)*Dim()].
+	Data []float32
+	// Words holds the word of each row, in insertion order.
+	Words []string
+
+	dim   int
+	index map[string]int
+	norms []float64
+}
+
+// LoadMatrix loads the whole vocabulary into a Matrix. It needs about
+// 4 bytes per dimension per word of memory, 1.2GB for 1M words of 300
+// dimensions.
+func (ft *FastText) LoadMatrix() (*Matrix, error) {
+	count, err := ft.vocabSize()
+	if err != nil {
+		return nil, err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	m := &Matrix{
+		dim:   f.dim,
+		Data:  make([]float32, 0, count*f.dim),
+		Words: make([]string, 0, count),
+		index: make(map[string]int, count),
+	}
+	err = ft.ForEach(func(word string, emb []float32) error {
+		if m.dim == 0 {
+			m.dim = len(emb)
+		}
+		if len(emb) != m.dim {
+			return fmt.Errorf("fasttext: embedding of %q has %d dimensions, expected %d",
+				word, len(emb), m.dim)
+		}
+		m.index[word] = len(m.Words)
+		m.Words = append(m.Words, word)
+		m.Data = append(m.Data, emb...)
+		m.norms = append(m.norms, l2norm(emb))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Len returns the number of rows.
+func (m *Matrix) Len() int {
+	return len(m.Words)
+}
+
+// Dim returns the dimension of the vectors.
+func (m *Matrix) Dim() int {
+	return m.dim
+}
+
+// Row returns the vector of row i, a view of Data.
+func (m *Matrix) Row(i int) []float32 {
+	return m.Data[i*m.dim : (i+1)*m.dim : (i+1)*m.dim]
+}
+
+// Index returns the row of the word, and whether it is in the vocabulary.
+func (m *Matrix) Index(word string) (int, bool) {
+	i, ok := m.index[word]
+	return i, ok
+}
+
+// Get returns the embedding of the word, a view of Data.
+func (m *Matrix) Get(word string) ([]float32, error) {
+	i, ok := m.index[word]
+	if !ok {
+		return nil, &WordNotFoundError{Word: word}
+	}
+	return m.Row(i), nil
+}
+
+// Dot returns the dot products of every row with the vector, i.e. the
+// matrix-vector product, in row order.
+func (m *Matrix) Dot(vec []float32) ([]float32, error) {
+	if len(vec) != m.dim {
+		return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), m.dim)
+	}
+	out := make([]float32, m.Len())
+	blas32.Sgemv(blas.NoTrans, m.Len(), m.dim, 1, m.Data, m.dim, vec, 1, 0, out, 1)
+	return out, nil
+}
+
+// NearestNeighbors returns the k words most similar to the given word
+// by cosine similarity, as FastText.NearestNeighbors does.
+func (m *Matrix) NearestNeighbors(word string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	vec, err := m.Get(word)
+	if err != nil {
+		return nil, err
+	}
+	return m.nearest(vec, k, keepWith(excludeWords(word), opts)), nil
+}
+
+// Nearest returns the k words whose embeddings are most similar to the
+// given vector by cosine similarity, in descending order of similarity.
+func (m *Matrix) Nearest(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	if len(vec) != m.dim {
+		return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), m.dim)
+	}
+	return m.nearest(vec, k, keepWith(excludeWords(), opts)), nil
+}
+
+func (m *Matrix) nearest(vec []float32, k int, keep func(word string, score float64) bool) []ScoredWord {
+	if k <= 0 {
+		return nil
+	}
+	top := NewTopK(k)
+	qnorm := l2norm(vec)
+	for i, word := range m.Words {
+		var score float64
+		if qnorm != 0 && m.norms[i] != 0 {
+			score = dot(m.Row(i), vec) / (qnorm * m.norms[i])
+		}
+		if keep(word, score) {
+			top.Push(ScoredWord{Word: word, Score: score})
+		}
+	}
+	return top.Sorted()
+}
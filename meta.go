@@ -0,0 +1,313 @@
+package fasttext
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// MetaTableName is the SQLite3 table holding the metadata of the
+// default model as key-value pairs. The metadata of a model stored with
+// WithTableName is in the "<name>_meta" table.
+const MetaTableName = "fasttext_meta"
+
+// execer is implemented by both *sql.DB and *sql.Tx.
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (ft *FastText) createMetaTable(db execer) error {
+	_, err := db.Exec(ft.sql(`
+	CREATE TABLE IF NOT EXISTS fasttext_meta(
+		key TEXT PRIMARY KEY,
+		value TEXT
+	);`))
+	return err
+}
+
+func (ft *FastText) setMeta(db execer, key, value string) error {
+	_, err := db.Exec(ft.sql(`INSERT OR REPLACE INTO fasttext_meta(key, value) VALUES(?, ?);`), key, value)
+	return err
+}
+
+// metaGeneration is the metadata key of the generation of the
+// vocabulary, bumped by every write to it.
+const metaGeneration = "generation"
+
+// bumpGeneration records a write to the vocabulary: it bumps the
+// generation, which versions the neighbor cache and the ANN index, and
+// clears the neighbor cache.
+func (ft *FastText) bumpGeneration(db execer) error {
+	if err := ft.createMetaTable(db); err != nil {
+		return err
+	}
+	_, err := db.Exec(ft.sql(`INSERT INTO fasttext_meta(key, value) VALUES(?, '1')
+		ON CONFLICT(key) DO UPDATE SET value = CAST(value AS INTEGER) + 1;`), metaGeneration)
+	if err != nil {
+		return err
+	}
+	return ft.clearNNCache(db)
+}
+
+// generation returns the generation of the vocabulary, 0 if it was
+// never bumped.
+func (ft *FastText) generation() (int64, error) {
+	value, ok, err := ft.getMeta(metaGeneration)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// getMeta returns the metadata value of the key, and whether it exists.
+func (ft *FastText) getMeta(key string) (string, bool, error) {
+	var value string
+	err := ft.db.QueryRow(ft.sql(`SELECT value FROM fasttext_meta WHERE key=?;`), key).Scan(&value)
+	if err == sql.ErrNoRows || (err != nil && isNoSuchTable(err)) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// Metadata keys describing the stored vectors.
+const (
+	metaPrecision  = "precision"
+	metaByteOrder  = "byte_order"
+	metaDim        = "dim"
+	metaNormalized = "normalized"
+)
+
+// vecFormat describes how the vectors are stored in the database.
+type vecFormat struct {
+	codec Codec
+	// dim is the dimension of the vectors, 0 if unknown.
+	dim int
+	// zstd compresses the blobs, if set.
+	zstd *blobCompressor
+	// normalized is set if the vectors are scaled to unit length.
+	normalized bool
+	// pca projects the input vectors, if set.
+	pca *pcaProjection
+}
+
+// compression returns the compression of the blobs.
+func (f vecFormat) compression() Compression {
+	if f.zstd != nil {
+		return Zstd
+	}
+	return NoCompression
+}
+
+// String describes the format of the blobs, which must be the same for
+// rows to be exchanged between databases.
+func (f vecFormat) String() string {
+	s := f.codec.String()
+	if f.zstd != nil {
+		s = fmt.Sprintf("%s/zstd-%d", s, f.zstd.id())
+	}
+	if f.normalized {
+		s += "/unit"
+	}
+	if f.pca != nil {
+		s += fmt.Sprintf("/pca-%d", f.pca.inputDim())
+	}
+	return s
+}
+
+// Codec returns the codec of the stored vectors.
+func (ft *FastText) Codec() (Codec, error) {
+	f, err := ft.vecFormat()
+	return f.codec, err
+}
+
+// vecFormat returns the storage format of the vectors, read from the
+// metadata table on first use. Databases without metadata store float32
+// vectors, in a byte order detected from the vectors.
+func (ft *FastText) vecFormat() (vecFormat, error) {
+	ft.formatMu.Lock()
+	defer ft.formatMu.Unlock()
+	if ft.format != nil {
+		return *ft.format, nil
+	}
+	f := vecFormat{codec: Codec{Precision: DefaultCodec.Precision, Order: ByteOrder}}
+	value, ok, err := ft.getMeta(metaPrecision)
+	if err != nil {
+		return f, err
+	}
+	if ok {
+		if f.codec.Precision, err = ParsePrecision(value); err != nil {
+			return f, err
+		}
+	}
+	value, ok, err = ft.getMeta(metaByteOrder)
+	if err != nil {
+		return f, err
+	}
+	if ok {
+		if f.codec.Order, err = parseByteOrder(value); err != nil {
+			return f, err
+		}
+	} else if f.codec.Order, err = ft.detectByteOrder(ft.db); err != nil {
+		return f, err
+	}
+	value, ok, err = ft.getMeta(metaDim)
+	if err != nil {
+		return f, err
+	}
+	if ok {
+		if f.dim, err = strconv.Atoi(value); err != nil {
+			return f, err
+		}
+	}
+	if err := ft.loadCompression(&f); err != nil {
+		return f, err
+	}
+	if err := ft.loadPCA(&f); err != nil {
+		return f, err
+	}
+	value, ok, err = ft.getMeta(metaNormalized)
+	if err != nil {
+		return f, err
+	}
+	if ok {
+		if f.normalized, err = strconv.ParseBool(value); err != nil {
+			return f, err
+		}
+	}
+	ft.format = &f
+	return f, nil
+}
+
+// setVecFormat records the storage format in the metadata table.
+func (ft *FastText) setVecFormat(db execer, f vecFormat) error {
+	if err := ft.setMeta(db, metaPrecision, f.codec.Precision.String()); err != nil {
+		return err
+	}
+	if err := ft.setMeta(db, metaByteOrder, byteOrderName(f.codec.Order)); err != nil {
+		return err
+	}
+	if err := ft.setMeta(db, metaDim, strconv.Itoa(f.dim)); err != nil {
+		return err
+	}
+	if err := ft.setCompression(db, f); err != nil {
+		return err
+	}
+	if err := ft.setMeta(db, metaNormalized, strconv.FormatBool(f.normalized)); err != nil {
+		return err
+	}
+	if err := ft.setPCA(db, f); err != nil {
+		return err
+	}
+	ft.formatMu.Lock()
+	ft.format = &f
+	ft.formatMu.Unlock()
+	return nil
+}
+
+// decode decodes a vector stored in the database.
+func (ft *FastText) decode(data []byte) ([]float32, error) {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	return f.decode(data)
+}
+
+// encode encodes a vector to be stored in the database.
+func (ft *FastText) encode(vec []float32) ([]byte, error) {
+	if err := ft.checkDim(vec); err != nil {
+		return nil, err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	return f.encode(vec), nil
+}
+
+// input checks the dimension of an input vector of a build, setting it
+// if unknown, and returns the vector to store, projected if the vectors
+// are.
+func (f *vecFormat) input(emb *wordEmb) ([]float32, error) {
+	vec := emb.Vec
+	if f.pca != nil {
+		if len(vec) != f.pca.inputDim() {
+			return nil, fmt.Errorf("fasttext: embedding of %q has %d dimensions, expected %d",
+				emb.Word, len(vec), f.pca.inputDim())
+		}
+		vec = f.pca.project(vec)
+	}
+	if f.dim == 0 {
+		f.dim = len(vec)
+	}
+	if len(vec) != f.dim {
+		return nil, fmt.Errorf("fasttext: embedding of %q has %d dimensions, expected %d",
+			emb.Word, len(vec), f.dim)
+	}
+	return vec, nil
+}
+
+// checkDim returns an error if the vector does not have the dimension
+// of the stored vectors.
+func (ft *FastText) checkDim(vec []float32) error {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	if f.dim != 0 && len(vec) != f.dim {
+		return fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), f.dim)
+	}
+	return nil
+}
+
+// encode encodes the vector into a blob, scaling it to unit length
+// first if the vectors are normalized.
+func (f vecFormat) encode(vec []float32) []byte {
+	if f.normalized {
+		vec = unitVec(vec)
+	}
+	data := f.codec.Encode(vec)
+	if f.zstd != nil {
+		return f.zstd.compress(data)
+	}
+	return data
+}
+
+// decode decodes the blob, validating its size if the dimension is known.
+func (f vecFormat) decode(data []byte) ([]float32, error) {
+	if f.zstd != nil {
+		var err error
+		if data, err = f.zstd.decompress(data); err != nil {
+			return nil, err
+		}
+	}
+	if f.dim != 0 {
+		if err := f.codec.Validate(data, f.dim); err != nil {
+			return nil, err
+		}
+	}
+	return f.codec.Decode(data)
+}
+
+// decodeInto decodes the blob into dst, validating its size against the
+// length of dst.
+func (f vecFormat) decodeInto(dst []float32, data []byte) error {
+	if f.zstd != nil {
+		var err error
+		if data, err = f.zstd.decompress(data); err != nil {
+			return err
+		}
+	}
+	if err := f.codec.Validate(data, len(dst)); err != nil {
+		return err
+	}
+	f.codec.decodeInto(dst, data)
+	return nil
+}
@@ -0,0 +1,200 @@
+package fasttext
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics records the activity of a session, e.g. to export it to a
+// monitoring system. Its methods are called synchronously, possibly
+// concurrently, and should be fast.
+type Metrics interface {
+	// ObserveLookup is called after each GetEmb, GetEmbInto or GetEmbs,
+	// and the functions built on them.
+	ObserveLookup(LookupStats)
+	// ObserveSearch is called after each neighbor search, with its
+	// method (MethodExact or MethodANN) and duration.
+	ObserveSearch(method string, d time.Duration)
+}
+
+// LookupStats describes a look-up of one or more words.
+type LookupStats struct {
+	// Words is the number of words looked up.
+	Words int
+	// MemoryHits is the number of words found in the cache of WithCache
+	// or preloaded.
+	MemoryHits int
+	// Misses is the number of words missing from the vocabulary, before
+	// the OOV policy.
+	Misses int
+	// Duration is the time taken by the look-up.
+	Duration time.Duration
+}
+
+// WithMetrics records the activity of the session in m, see Counters for
+// an implementation.
+func WithMetrics(m Metrics) Option {
+	return func(ft *FastText) {
+		ft.metrics = m
+	}
+}
+
+// observeLookup records the look-up of a word found in source.
+func (ft *FastText) observeLookup(start time.Time, word, source string, err error) {
+	st := LookupStats{Words: 1, Duration: time.Since(start)}
+	switch {
+	case source == SourcePreload || source == SourceCache:
+		st.MemoryHits = 1
+	case source == SourceOOV || errors.Is(err, ErrNoEmbFound):
+		st.Misses = 1
+	}
+	ft.observe(st, "word", word)
+}
+
+// observe records a look-up, described by args in the slow query log.
+func (ft *FastText) observe(st LookupStats, args ...interface{}) {
+	if ft.metrics != nil {
+		ft.metrics.ObserveLookup(st)
+	}
+	ft.logSlow("fasttext: slow look-up", st.Duration, args...)
+}
+
+// observeSearch records a neighbor search started at start.
+func (ft *FastText) observeSearch(method string, start time.Time) {
+	if !ft.observing() {
+		return
+	}
+	d := time.Since(start)
+	if ft.metrics != nil {
+		ft.metrics.ObserveSearch(method, d)
+	}
+	ft.logSlow("fasttext: slow neighbor search", d, "method", method)
+}
+
+// LatencyBuckets are the upper bounds of the buckets of the latency
+// histograms of Counters.
+var LatencyBuckets = []time.Duration{
+	50 * time.Microsecond, 100 * time.Microsecond, 250 * time.Microsecond, 500 * time.Microsecond,
+	time.Millisecond, 2500 * time.Microsecond, 5 * time.Millisecond, 10 * time.Millisecond,
+	25 * time.Millisecond, 50 * time.Millisecond, 100 * time.Millisecond, 250 * time.Millisecond,
+	500 * time.Millisecond, time.Second,
+}
+
+// Histogram counts durations in the buckets of LatencyBuckets. It is
+// safe for concurrent use.
+type Histogram struct {
+	// counts has a last bucket for the durations above all bounds.
+	counts []int64
+	sum    int64
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{counts: make([]int64, len(LatencyBuckets)+1)}
+}
+
+// Observe counts a duration.
+func (h *Histogram) Observe(d time.Duration) {
+	i := 0
+	for i < len(LatencyBuckets) && d > LatencyBuckets[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+// Counts returns the number of durations of each bucket, the last one
+// counting those above all the bounds of LatencyBuckets.
+func (h *Histogram) Counts() []int64 {
+	counts := make([]int64, len(h.counts))
+	for i := range counts {
+		counts[i] = atomic.LoadInt64(&h.counts[i])
+	}
+	return counts
+}
+
+// Sum returns the total of the durations.
+func (h *Histogram) Sum() time.Duration {
+	return time.Duration(atomic.LoadInt64(&h.sum))
+}
+
+// Counters is a Metrics counting the activity of a session in memory.
+// It is an expvar.Var, so it can be published as is:
+//
+//	c := fasttext.NewCounters()
+//	expvar.Publish("fasttext", c)
+//	ft := fasttext.NewFastText("/path/to/sqlite3/file", fasttext.WithMetrics(c))
+type Counters struct {
+	lookups    int64
+	words      int64
+	memoryHits int64
+	misses     int64
+	searches   int64
+	// LookupLatency and SearchLatency are the histograms of the
+	// durations of the look-ups and of the neighbor searches.
+	LookupLatency *Histogram
+	SearchLatency *Histogram
+}
+
+// NewCounters returns zeroed counters.
+func NewCounters() *Counters {
+	return &Counters{LookupLatency: newHistogram(), SearchLatency: newHistogram()}
+}
+
+// ObserveLookup implements Metrics.
+func (c *Counters) ObserveLookup(st LookupStats) {
+	atomic.AddInt64(&c.lookups, 1)
+	atomic.AddInt64(&c.words, int64(st.Words))
+	atomic.AddInt64(&c.memoryHits, int64(st.MemoryHits))
+	atomic.AddInt64(&c.misses, int64(st.Misses))
+	c.LookupLatency.Observe(st.Duration)
+}
+
+// ObserveSearch implements Metrics.
+func (c *Counters) ObserveSearch(method string, d time.Duration) {
+	atomic.AddInt64(&c.searches, 1)
+	c.SearchLatency.Observe(d)
+}
+
+// CounterValues is a snapshot of Counters.
+type CounterValues struct {
+	Lookups    int64 `json:"lookups"`
+	Words      int64 `json:"words"`
+	MemoryHits int64 `json:"memory_hits"`
+	Misses     int64 `json:"misses"`
+	Searches   int64 `json:"searches"`
+}
+
+// Values returns the current values of the counters.
+func (c *Counters) Values() CounterValues {
+	return CounterValues{
+		Lookups:    atomic.LoadInt64(&c.lookups),
+		Words:      atomic.LoadInt64(&c.words),
+		MemoryHits: atomic.LoadInt64(&c.memoryHits),
+		Misses:     atomic.LoadInt64(&c.misses),
+		Searches:   atomic.LoadInt64(&c.searches),
+	}
+}
+
+// String returns the counters and histograms as JSON, implementing
+// expvar.Var.
+func (c *Counters) String() string {
+	histogram := func(h *Histogram) interface{} {
+		bounds := make([]float64, len(LatencyBuckets))
+		for i, b := range LatencyBuckets {
+			bounds[i] = b.Seconds()
+		}
+		return map[string]interface{}{
+			"bounds_seconds": bounds,
+			"counts":         h.Counts(),
+			"sum_seconds":    h.Sum().Seconds(),
+		}
+	}
+	data, _ := json.Marshal(struct {
+		CounterValues
+		LookupLatency interface{} `json:"lookup_latency"`
+		SearchLatency interface{} `json:"search_latency"`
+	}{c.Values(), histogram(c.LookupLatency), histogram(c.SearchLatency)})
+	return string(data)
+}
@@ -0,0 +1,161 @@
+package fasttext
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// metaSchemaVersion is the metadata key of the number of migrations
+// applied to the database.
+const metaSchemaVersion = "schema_version"
+
+// migration upgrades the schema of a database by one version.
+type migration struct {
+	name string
+	up   func(ft *FastText, tx *sql.Tx) error
+}
+
+// migrations are the schema changes since the first version of the
+// package, in order. Version n is reached by applying the first n
+// migrations. They are idempotent, as databases built before versions
+// were recorded may have some of them already.
+var migrations = []migration{
+	{"record the vector format in a metadata table", migrateMeta},
+	{"add the rank and freq columns", migrateColumns},
+}
+
+// Migrate upgrades a database built by an older version of the package
+// in place, without rebuilding it: it records the format of its vectors
+// in the metadata table, so they are never misdecoded, and adds the
+// columns of ranks and frequencies. Each migration runs in its own
+// transaction, so an interrupted migration can be resumed by calling
+// Migrate again. Migrating an up-to-date database does nothing.
+func (ft *FastText) Migrate() error {
+	version, err := ft.schemaVersion()
+	if err != nil {
+		return err
+	}
+	for ; version < len(migrations); version++ {
+		if err := ft.migrate(version); err != nil {
+			return fmt.Errorf("fasttext: migration %d (%s): %w", version+1, migrations[version].name, err)
+		}
+	}
+	// Forget what was read from the old schema.
+	ft.formatMu.Lock()
+	ft.format = nil
+	ft.rankCol = ""
+	ft.formatMu.Unlock()
+	return nil
+}
+
+// schemaVersion returns the number of migrations applied to the
+// database, 0 if it was built before versions were recorded.
+func (ft *FastText) schemaVersion() (int, error) {
+	value, ok, err := ft.getMeta(metaSchemaVersion)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// migrate applies the migration of the given version.
+func (ft *FastText) migrate(version int) error {
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var n int
+	if err := tx.QueryRow(ft.sql(`SELECT COUNT(*) FROM fasttext;`)).Scan(&n); err != nil {
+		if isNoSuchTable(err) {
+			return errors.New("no database to migrate")
+		}
+		return err
+	}
+	if err := migrations[version].up(ft, tx); err != nil {
+		return err
+	}
+	if err := ft.setMeta(tx, metaSchemaVersion, strconv.Itoa(version+1)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// migrateMeta creates the metadata table and records the format of
+// the vectors of the first versions, float32 values in the byte order
+// detected from the vectors, unless it is already recorded.
+func migrateMeta(ft *FastText, tx *sql.Tx) error {
+	if err := ft.createMetaTable(tx); err != nil {
+		return err
+	}
+	var size sql.NullInt64
+	if err := tx.QueryRow(ft.sql(`SELECT length(emb) FROM fasttext LIMIT 1;`)).Scan(&size); err != nil &&
+		err != sql.ErrNoRows {
+		return err
+	}
+	order, err := ft.detectByteOrder(tx)
+	if err != nil {
+		return err
+	}
+	format := map[string]string{
+		metaPrecision: Float32.String(),
+		metaByteOrder: byteOrderName(order),
+		metaDim:       strconv.FormatInt(size.Int64/4, 10),
+	}
+	// A format recorded in part is left as is.
+	var recorded int
+	if err := tx.QueryRow(ft.sql(`SELECT COUNT(*) FROM fasttext_meta WHERE key IN (?, ?, ?);`),
+		metaPrecision, metaByteOrder, metaDim).Scan(&recorded); err != nil {
+		return err
+	}
+	if recorded > 0 {
+		return nil
+	}
+	for key, value := range format {
+		if err := ft.setMeta(tx, key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateColumns adds the rank column, filled with the insertion order
+// which the first versions used as rank, and the freq column.
+func migrateColumns(ft *FastText, tx *sql.Tx) error {
+	columns := make(map[string]bool)
+	rows, err := tx.Query(`SELECT name FROM pragma_table_info(?);`, ft.table)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if !columns["rank"] {
+		for _, q := range []string{
+			`ALTER TABLE fasttext ADD COLUMN rank INTEGER;`,
+			`UPDATE fasttext SET rank = rowid;`,
+			`CREATE INDEX IF NOT EXISTS fasttext_rank ON fasttext(rank);`,
+		} {
+			if _, err := tx.Exec(ft.sql(q)); err != nil {
+				return err
+			}
+		}
+	}
+	if !columns["freq"] {
+		if _, err := tx.Exec(ft.sql(`ALTER TABLE fasttext ADD COLUMN freq INTEGER;`)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
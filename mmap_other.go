@@ -0,0 +1,16 @@
+//go:build !unix
+
+package fasttext
+
+import (
+	"io/ioutil"
+)
+
+// mapFile reads the file in memory, on platforms without mmap.
+func mapFile(filename string) ([]byte, func() error, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, func() error { return nil }, nil
+}
@@ -0,0 +1,110 @@
+package fasttext
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tableNameRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// WithTableName stores the model of the session in the given table
+// instead of TableName, so that several models (e.g. English wiki,
+// common crawl, domain-tuned) can live in one database file. The
+// auxiliary tables of the model are named after it, e.g. "<name>_meta".
+// The name must be an SQL identifier of letters, digits and
+// underscores; WithTableName panics otherwise.
+func WithTableName(name string) Option {
+	if !ValidTableName(name) {
+		panic(fmt.Sprintf("fasttext: invalid table name %q", name))
+	}
+	return func(ft *FastText) {
+		ft.table = name
+	}
+}
+
+// ValidTableName reports whether name can be given to WithTableName.
+func ValidTableName(name string) bool {
+	return tableNameRe.MatchString(name)
+}
+
+// sql rewrites a query on the tables of the default model for the
+// tables of the model of the session.
+func (ft *FastText) sql(query string) string {
+	if ft.table == TableName {
+		return query
+	}
+	return strings.Replace(query, TableName, ft.table, -1)
+}
+
+// sidecar returns the name of a file stored next to the database for
+// the model of the session, or "" for in-memory databases.
+func (ft *FastText) sidecar(suffix string) string {
+	path := ft.dbPath()
+	if path == "" {
+		return ""
+	}
+	if ft.table == TableName {
+		return path + suffix
+	}
+	return path + "." + ft.table + suffix
+}
+
+// auxSuffixes are the suffixes of the auxiliary tables of a model.
+var auxSuffixes = []string{"_meta", "_payload", "_fuzzy", "_clusters", "_versions", "_nn_cache"}
+
+// ListModels returns the names of the models in the database file of
+// the session, to be opened with WithTableName: the tables with word
+// and emb columns, other than auxiliary tables.
+func (ft *FastText) ListModels() ([]string, error) {
+	rows, err := ft.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' ORDER BY name;`)
+	if err != nil {
+		return nil, err
+	}
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	isTable := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		isTable[t] = true
+	}
+	var models []string
+	for _, t := range tables {
+		aux := false
+		for _, suffix := range auxSuffixes {
+			if strings.HasSuffix(t, suffix) && isTable[strings.TrimSuffix(t, suffix)] {
+				aux = true
+			}
+		}
+		if aux || !tableNameRe.MatchString(t) {
+			continue
+		}
+		ok, err := ft.isModelTable(t)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			models = append(models, t)
+		}
+	}
+	return models, nil
+}
+
+// isModelTable returns whether the table has word and emb columns, and
+// no lang column like the tables of BuildLangDB.
+func (ft *FastText) isModelTable(table string) (bool, error) {
+	var n, lang int
+	err := ft.db.QueryRow(`SELECT COALESCE(SUM(name IN ('word', 'emb')), 0),
+		COALESCE(SUM(name = 'lang'), 0) FROM pragma_table_info(?);`, table).Scan(&n, &lang)
+	return n == 2 && lang == 0, err
+}
@@ -0,0 +1,183 @@
+package fasttext
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// negativePower is the exponent of the unigram distribution of the
+// negative samples, as in word2vec and fastText.
+const negativePower = 0.75
+
+// NegativeSampler draws words from the unigram distribution of the
+// vocabulary raised to the power 0.75, the noise distribution of the
+// negative sampling of word2vec and fastText, in constant time with the
+// alias method. The unigram probabilities are the frequencies given at
+// build time by WithFrequencies, or else estimated from the ranks by
+// Zipf's law; words with neither are never drawn.
+type NegativeSampler struct {
+	words []string
+	// prob and alias are the alias table: slot i draws words[i] with
+	// probability prob[i], and words[alias[i]] otherwise.
+	prob  []float64
+	alias []int32
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// Len returns the number of words that may be drawn.
+func (s *NegativeSampler) Len() int {
+	return len(s.words)
+}
+
+// Draw returns a word drawn with the random source rng, e.g. the source
+// of a training worker.
+func (s *NegativeSampler) Draw(rng *rand.Rand) string {
+	i := rng.Intn(len(s.words))
+	if rng.Float64() >= s.prob[i] {
+		i = int(s.alias[i])
+	}
+	return s.words[i]
+}
+
+// NegativeSampler returns the negative sampler of the vocabulary, built
+// by a scan of the vocabulary on first use and kept until the
+// vocabulary changes.
+func (ft *FastText) NegativeSampler() (*NegativeSampler, error) {
+	ft.formatMu.Lock()
+	s := ft.negSampler
+	ft.formatMu.Unlock()
+	if s != nil {
+		return s, nil
+	}
+	s, err := ft.newNegativeSampler()
+	if err != nil {
+		return nil, err
+	}
+	ft.formatMu.Lock()
+	ft.negSampler = s
+	ft.formatMu.Unlock()
+	return s, nil
+}
+
+// SampleNegatives returns n words drawn by the negative sampler of the
+// vocabulary, see NegativeSampler, other than the excluded words, e.g.
+// the context of a training example. Words may be drawn several times.
+func (ft *FastText) SampleNegatives(n int, exclude []string) ([]string, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	s, err := ft.NegativeSampler()
+	if err != nil {
+		return nil, err
+	}
+	excluded := make(map[string]bool, len(exclude))
+	for _, word := range ft.normalizeAll(exclude) {
+		excluded[word] = true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	words := make([]string, 0, n)
+	// Give up if the excluded words hold most of the distribution.
+	for tries := 0; len(words) < n; tries++ {
+		if tries >= 100*(n+1) {
+			return nil, errors.New("fasttext: excluded words hold the distribution of the negative samples")
+		}
+		if word := s.Draw(s.rng); !excluded[word] {
+			words = append(words, word)
+		}
+	}
+	return words, nil
+}
+
+// newNegativeSampler builds the alias table of the vocabulary.
+func (ft *FastText) newNegativeSampler() (*NegativeSampler, error) {
+	col, err := ft.rankColumn()
+	if err != nil {
+		return nil, err
+	}
+	freqCol := "freq"
+	if col == "rowid" {
+		// Databases without ranks have no frequencies either.
+		freqCol = "NULL"
+	}
+	total, vocab, err := ft.freqTotals(freqCol)
+	if err != nil {
+		return nil, err
+	}
+	// The probabilities of unigramProbs, up to a constant factor.
+	harmonic := math.Log(float64(vocab)) + 0.5772
+	rows, err := ft.db.Query(ft.sql(`SELECT word, ` + col + `, ` + freqCol + ` FROM fasttext;`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	s := &NegativeSampler{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+	var weights []float64
+	var sum float64
+	for rows.Next() {
+		var word string
+		var rank, freq *int64
+		if err := rows.Scan(&word, &rank, &freq); err != nil {
+			return nil, err
+		}
+		var p float64
+		switch {
+		case freq != nil && total > 0:
+			p = float64(*freq) / float64(total)
+		case rank != nil && *rank > 0:
+			p = 1 / (float64(*rank) * harmonic)
+		}
+		if p <= 0 {
+			continue
+		}
+		w := math.Pow(p, negativePower)
+		s.words = append(s.words, word)
+		weights = append(weights, w)
+		sum += w
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(s.words) == 0 {
+		return nil, errors.New("fasttext: no frequencies or ranks to sample negatives from")
+	}
+	s.prob, s.alias = aliasTable(weights, sum)
+	return s, nil
+}
+
+// aliasTable builds the alias table of Vose's method for the weights of
+// the given sum.
+func aliasTable(weights []float64, sum float64) ([]float64, []int32) {
+	n := len(weights)
+	prob := make([]float64, n)
+	alias := make([]int32, n)
+	var small, large []int32
+	for i, w := range weights {
+		prob[i] = w * float64(n) / sum
+		if prob[i] < 1 {
+			small = append(small, int32(i))
+		} else {
+			large = append(large, int32(i))
+		}
+	}
+	for len(small) > 0 && len(large) > 0 {
+		s, l := small[len(small)-1], large[len(large)-1]
+		small = small[:len(small)-1]
+		alias[s] = l
+		prob[l] -= 1 - prob[s]
+		if prob[l] < 1 {
+			large = large[:len(large)-1]
+			small = append(small, l)
+		}
+	}
+	// The rest are full slots, up to rounding errors.
+	for _, i := range append(small, large...) {
+		prob[i] = 1
+	}
+	return prob, alias
+}
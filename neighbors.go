@@ -0,0 +1,544 @@
+package fasttext
+
+import (
+	"container/heap"
+	"context"
+	"database/sql"
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// scanChunkSize is the number of rowids claimed by a scan worker at a time.
+const scanChunkSize = 4096
+
+// ScoredWord is a word with its similarity score to a query.
+type ScoredWord struct {
+	Word  string
+	Score float64
+}
+
+// NearestNeighbors returns the k words most similar to the given word
+// by cosine similarity, excluding the word itself, in descending order
+// of similarity. The search is an exact scan over the whole vocabulary,
+// split across GOMAXPROCS workers.
+func (ft *FastText) NearestNeighbors(word string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	return ft.NearestNeighborsContext(context.Background(), word, k, opts...)
+}
+
+// NearestNeighborsContext is NearestNeighbors with a context, which
+// cancels the search and is the parent of its span, see WithTracer.
+func (ft *FastText) NearestNeighborsContext(ctx context.Context, word string, k int,
+	opts ...SearchOption) (nn []ScoredWord, err error) {
+	ctx, end := ft.startSpan(ctx, "fasttext.NearestNeighbors", "words", 1, "k", k)
+	defer func() { end(err) }()
+	if ft.nnCache && len(opts) == 0 && k > 0 {
+		return ft.cachedNearest(ctx, word, k)
+	}
+	vec, err := ft.GetEmbContext(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	return ft.nearest(ctx, vec, k, keepWith(ft.excludeWords(word), opts), nil)
+}
+
+// NearestByVector returns the k words whose embeddings are most similar
+// to the given vector by cosine similarity, in descending order of
+// similarity. The vector can come from anywhere, e.g. a sentence
+// average or another model aligned to the same space.
+func (ft *FastText) NearestByVector(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	if err := ft.checkDim(vec); err != nil {
+		return nil, err
+	}
+	return ft.nearest(context.Background(), vec, k, keepWith(ft.excludeWords(), opts), nil)
+}
+
+// NearestNeighborsPage returns the page of neighbors of the given word
+// at ranks [offset, offset+limit), in the same order as NearestNeighbors.
+// Ties are broken by word, so consecutive pages never overlap nor skip
+// words as long as the vocabulary is unchanged.
+func (ft *FastText) NearestNeighborsPage(word string, offset, limit int, opts ...SearchOption) ([]ScoredWord, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	nn, err := ft.NearestNeighbors(word, offset+limit, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if offset >= len(nn) {
+		return nil, nil
+	}
+	return nn[offset:], nil
+}
+
+// NearestNeighborsAfter continues a neighbor listing of the given word:
+// it returns the next limit neighbors ranked after cursor, the last
+// result of the previous page. Unlike NearestNeighborsPage, the cost
+// of a page does not grow with its depth.
+func (ft *FastText) NearestNeighborsAfter(word string, cursor ScoredWord, limit int,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	word = ft.normalize(word)
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	return ft.nearest(context.Background(), vec, limit, keepWith(func(w string, score float64) bool {
+		return w != word && lessScored(ScoredWord{Word: w, Score: score}, cursor)
+	}, opts), nil)
+}
+
+// NearestNeighborsFiltered returns the k words most similar to the
+// given word among the words accepted by keep, e.g. a domain
+// vocabulary. It is NearestNeighbors with the filter of WithFilter:
+// the words are filtered during the scan, so the search returns k words
+// when enough of them pass.
+func (ft *FastText) NearestNeighborsFiltered(word string, k int, keep func(word string) bool,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	return ft.NearestNeighbors(word, k, append(opts, WithFilter(func(w string, _ float64) bool {
+		return keep(w)
+	}))...)
+}
+
+// NearestNeighborsAmong returns the k words of the candidates most
+// similar to the given word, excluding the word itself, in descending
+// order of similarity. Only the candidates are looked up, so it is much
+// faster than a filtered scan of the vocabulary for small candidate
+// sets. Candidates missing from the vocabulary are ignored.
+func (ft *FastText) NearestNeighborsAmong(word string, candidates []string, k int,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	word = ft.normalize(word)
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	keys := ft.normalizeAll(candidates)
+	embs, err := ft.lookupEmbs(keys)
+	if err != nil {
+		return nil, err
+	}
+	keep := keepWith(ft.excludeWords(word), opts)
+	norm := l2norm(vec)
+	seen := make(map[string]bool, len(keys))
+	var nn []ScoredWord
+	for i, key := range keys {
+		if embs[i] == nil || seen[key] {
+			continue
+		}
+		seen[key] = true
+		score := cosine(vec, embs[i], norm)
+		if keep(key, score) {
+			nn = append(nn, ScoredWord{Word: key, Score: score})
+		}
+	}
+	sort.Slice(nn, func(i, j int) bool {
+		return lessScored(nn[j], nn[i])
+	})
+	if k >= 0 && len(nn) > k {
+		nn = nn[:k]
+	}
+	return nn, nil
+}
+
+// SimilarWordsAbove returns the words whose cosine similarity to the
+// given word exceeds minCosine, excluding the word itself, in
+// descending order of similarity, e.g. all the words above 0.7 to
+// expand a synonym list. If limit is positive, only the limit most
+// similar of them are returned. The search is an exact scan, as for
+// NearestNeighbors.
+func (ft *FastText) SimilarWordsAbove(word string, minCosine float64, limit int,
+	opts ...SearchOption) ([]ScoredWord, error) {
+	word = ft.normalize(word)
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+	return ft.nearest(context.Background(), vec, limit, keepWith(func(w string, score float64) bool {
+		return score > minCosine && w != word
+	}, opts), nil)
+}
+
+// SearchOption configures a neighbor search.
+type SearchOption func(*searchConfig)
+
+type searchConfig struct {
+	filters []func(word string, score float64) bool
+}
+
+// WithFilter restricts a neighbor search to the words accepted by fn,
+// e.g. to apply a profanity list or a catalog membership. The filter is
+// applied during the scan, so the search still returns k words when
+// enough of them pass it. It may be called concurrently by the scan
+// workers. Several filters must all accept a word.
+func WithFilter(fn func(word string, score float64) bool) SearchOption {
+	return func(cfg *searchConfig) {
+		cfg.filters = append(cfg.filters, fn)
+	}
+}
+
+// keepWith combines the candidate filter keep with the filters of the
+// search options.
+func keepWith(keep func(string, float64) bool, opts []SearchOption) func(string, float64) bool {
+	cfg := &searchConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if len(cfg.filters) == 0 {
+		return keep
+	}
+	return func(w string, score float64) bool {
+		if !keep(w, score) {
+			return false
+		}
+		for _, fn := range cfg.filters {
+			if !fn(w, score) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// excludeWords returns a candidate filter rejecting the given words.
+func excludeWords(words ...string) func(string, float64) bool {
+	exclude := make(map[string]bool, len(words))
+	for _, w := range words {
+		exclude[w] = true
+	}
+	return func(w string, _ float64) bool {
+		return !exclude[w]
+	}
+}
+
+// nearest scans the vocabulary for the k words most similar to vec
+// among the candidates accepted by keep, recording the scan in exp if
+// it is not nil.
+func (ft *FastText) nearest(ctx context.Context, vec []float32, k int, keep func(word string, score float64) bool,
+	exp *Explanation) ([]ScoredWord, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	nn, err := ft.nearestBatch(ctx, [][]float32{vec}, k,
+		[]func(string, float64) bool{keep}, exp)
+	if err != nil {
+		return nil, err
+	}
+	return nn[0], nil
+}
+
+// nearestBatch scans the vocabulary once for the k words most similar
+// to each of the vectors among the candidates accepted by its keep
+// function. A nil vector gets no neighbors. The workers claim chunks of
+// rows in turn, decode them into one buffer each and keep the best
+// words of each query in bounded heaps, so the memory of the scan does
+// not grow with the vocabulary. Candidates scoring below the worst
+// word of a full heap are not even filtered.
+func (ft *FastText) nearestBatch(ctx context.Context, vecs [][]float32, k int,
+	keeps []func(word string, score float64) bool, exp *Explanation) ([][]ScoredWord, error) {
+	defer ft.observeSearch(MethodExact, time.Now())
+	ctx, cancel := ft.queryContext(ctx)
+	defer cancel()
+	var maxRowid sql.NullInt64
+	if err := ft.db.QueryRowContext(ctx, ft.sql(`SELECT MAX(rowid) FROM fasttext;`)).Scan(&maxRowid); err != nil {
+		return nil, ft.timedOut(ctx, err)
+	}
+	format, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	qnorms := make([]float64, len(vecs))
+	shared := make([]*TopK, len(vecs))
+	for q, vec := range vecs {
+		qnorms[q] = l2norm(vec)
+		shared[q] = NewTopK(k)
+	}
+	var (
+		scored int64
+		next   int64
+		mu     sync.Mutex
+		scanEr error
+		wg     sync.WaitGroup
+	)
+	workers := runtime.GOMAXPROCS(0)
+	if exp != nil {
+		exp.Method = MethodExact
+		exp.Workers = workers
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := make([]*TopK, len(vecs))
+			for q := range local {
+				local[q] = NewTopK(k)
+			}
+			var n int64
+			defer func() { atomic.AddInt64(&scored, n) }()
+			buf := make([]float32, format.dim)
+			for {
+				start := atomic.AddInt64(&next, scanChunkSize) - scanChunkSize + 1
+				if start > maxRowid.Int64 {
+					break
+				}
+				err := ft.scanRange(ctx, start, start+scanChunkSize, buf, func(raw sql.RawBytes, emb []float32) {
+					n++
+					// The word is only copied for the candidates.
+					var word string
+					copied := false
+					// Cosine similarity is a dot product for unit vectors.
+					enorm := 1.0
+					if !format.normalized {
+						enorm = l2norm(emb)
+					}
+					for q, vec := range vecs {
+						if vec == nil {
+							continue
+						}
+						var score float64
+						if qnorms[q] != 0 && enorm != 0 {
+							score = dot(vec, emb) / (qnorms[q] * enorm)
+						}
+						if !local[q].mayKeep(score) {
+							continue
+						}
+						if !copied {
+							word, copied = string(raw), true
+						}
+						if keeps[q](word, score) {
+							local[q].Push(ScoredWord{Word: word, Score: score})
+						}
+					}
+				})
+				if err != nil {
+					mu.Lock()
+					scanEr = err
+					mu.Unlock()
+					return
+				}
+			}
+			mu.Lock()
+			for q := range shared {
+				shared[q].Merge(local[q])
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if scanEr != nil {
+		return nil, ft.timedOut(ctx, scanEr)
+	}
+	if exp != nil {
+		exp.Candidates = int(scored)
+		exp.step("scanned %d candidates with %d workers", scored, workers)
+	}
+	nn := make([][]ScoredWord, len(vecs))
+	for q, vec := range vecs {
+		if vec != nil {
+			nn[q] = shared[q].Sorted()
+		}
+	}
+	return nn, nil
+}
+
+// NearestNeighborsBatch returns the k nearest neighbors of each of the
+// given words, as NearestNeighbors does, in one scan of the vocabulary
+// shared by all the queries. The neighbors of a word missing from the
+// vocabulary are nil.
+func (ft *FastText) NearestNeighborsBatch(words []string, k int, opts ...SearchOption) ([][]ScoredWord, error) {
+	if k <= 0 || len(words) == 0 {
+		return make([][]ScoredWord, len(words)), nil
+	}
+	vecs, err := ft.GetEmbs(words)
+	if err != nil {
+		return nil, err
+	}
+	keeps := make([]func(string, float64) bool, len(words))
+	for q, word := range words {
+		keeps[q] = keepWith(ft.excludeWords(word), opts)
+	}
+	return ft.nearestBatch(context.Background(), vecs, k, keeps, nil)
+}
+
+// scanRange calls fn on every word embedding with rowid in [start, end),
+// decoded into buf if it has the dimension of the vectors. The word and
+// the embedding are only valid during the call.
+func (ft *FastText) scanRange(ctx context.Context, start, end int64, buf []float32,
+	fn func(word sql.RawBytes, emb []float32)) error {
+	rows, err := ft.db.QueryContext(ctx, ft.sql(`SELECT word, emb FROM fasttext WHERE rowid >= ? AND rowid < ?;`), start, end)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var word, data sql.RawBytes
+		if err := rows.Scan(&word, &data); err != nil {
+			return err
+		}
+		emb := buf
+		if len(buf) == 0 {
+			if emb, err = f.decode(data); err != nil {
+				return err
+			}
+		} else if err := f.decodeInto(emb, data); err != nil {
+			return err
+		}
+		fn(word, emb)
+	}
+	return rows.Err()
+}
+
+// TopK keeps the k highest scored words pushed to it. It is the
+// selection used by the neighbor searches, exported for custom scoring
+// loops over Iter or ForEach:
+//
+//	top := fasttext.NewTopK(10)
+//	err := ft.ForEach(func(word string, emb []float32) error {
+//		top.Push(fasttext.ScoredWord{Word: word, Score: score(emb)})
+//		return nil
+//	})
+//	best := top.Sorted()
+//
+// A TopK is not safe for concurrent use.
+type TopK struct {
+	k int
+	h scoredHeap
+}
+
+// NewTopK creates a TopK keeping at most k words.
+func NewTopK(k int) *TopK {
+	if k < 0 {
+		k = 0
+	}
+	return &TopK{k: k, h: make(scoredHeap, 0, minInt(k, topKPrealloc))}
+}
+
+// topKPrealloc bounds the words a TopK preallocates room for, e.g. for
+// the unlimited searches of SimilarWordsAbove.
+const topKPrealloc = 1024
+
+// Push offers a scored word, keeping it if it is among the k best so far.
+func (t *TopK) Push(s ScoredWord) {
+	if len(t.h) < t.k {
+		heap.Push(&t.h, s)
+		return
+	}
+	if t.k > 0 && lessScored(t.h[0], s) {
+		t.h[0] = s
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// mayKeep returns whether a word of the given score may be kept: the
+// TopK is not full, or the score is at least the lowest kept, which
+// ties may beat.
+func (t *TopK) mayKeep(score float64) bool {
+	return len(t.h) < t.k || (t.k > 0 && score >= t.h[0].Score)
+}
+
+// Len returns the number of words kept.
+func (t *TopK) Len() int {
+	return len(t.h)
+}
+
+// Min returns the lowest scored word kept, which a candidate must beat
+// to be kept once the TopK is full.
+func (t *TopK) Min() (ScoredWord, bool) {
+	if len(t.h) == 0 {
+		return ScoredWord{}, false
+	}
+	return t.h[0], true
+}
+
+// Merge pushes all the words kept by other into t.
+func (t *TopK) Merge(other *TopK) {
+	for _, s := range other.h {
+		t.Push(s)
+	}
+}
+
+// Sorted returns the kept words in descending order of score.
+func (t *TopK) Sorted() []ScoredWord {
+	out := make([]ScoredWord, len(t.h))
+	copy(out, t.h)
+	SortScored(out)
+	return out
+}
+
+// SortScored sorts the scored words in descending order of score,
+// breaking ties by word.
+func SortScored(s []ScoredWord) {
+	sort.Sort(sort.Reverse(scoredHeap(s)))
+}
+
+// PartialSortScored returns the k highest scored words of s in
+// descending order of score, without sorting the whole slice.
+func PartialSortScored(s []ScoredWord, k int) []ScoredWord {
+	t := NewTopK(k)
+	for _, x := range s {
+		t.Push(x)
+	}
+	return t.Sorted()
+}
+
+// scoredHeap is a min-heap of scored words.
+type scoredHeap []ScoredWord
+
+func (h scoredHeap) Len() int            { return len(h) }
+func (h scoredHeap) Less(i, j int) bool  { return lessScored(h[i], h[j]) }
+func (h scoredHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *scoredHeap) Push(x interface{}) { *h = append(*h, x.(ScoredWord)) }
+func (h *scoredHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// lessScored orders by score, breaking ties by word so that results
+// are deterministic.
+func lessScored(a, b ScoredWord) bool {
+	if a.Score != b.Score {
+		return a.Score < b.Score
+	}
+	return a.Word > b.Word
+}
+
+// Analogy answers "a is to b as c is to ?" by returning the k words
+// most similar to the vector b - a + c (computed on unit-length
+// embeddings), excluding a, b and c themselves. For example,
+// Analogy("man", "king", "woman", 1) should return "queen". Zero
+// vectors, e.g. from a zero-vector OOV policy, are left out of the sum.
+func (ft *FastText) Analogy(a, b, c string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	var vecs [3][]float32
+	for i, w := range []string{a, b, c} {
+		vec, err := ft.GetEmb(w)
+		if err != nil {
+			return nil, err
+		}
+		vecs[i] = vec
+	}
+	sum := make([]float64, len(vecs[0]))
+	for j, sign := range []float64{-1, 1, 1} {
+		n := l2norm(vecs[j])
+		if n == 0 {
+			continue
+		}
+		for i, x := range vecs[j] {
+			sum[i] += sign * float64(x) / n
+		}
+	}
+	query := make([]float32, len(sum))
+	for i, x := range sum {
+		query[i] = float32(x)
+	}
+	return ft.nearest(context.Background(), query, k, keepWith(ft.excludeWords(a, b, c), opts), nil)
+}
@@ -0,0 +1,96 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// WithNNCache persists the neighbor lists computed by NearestNeighbors
+// without search options in the "<table>_nn_cache" table of the
+// database, keyed by word, k and the generation of the vocabulary,
+// which every build and write bumps, and serves the repeated searches
+// from it: the neighbors of a word with k or fewer results are cut from
+// any list of at least k. Writes to the vocabulary, including
+// ApplySyncDelta, clear the table. In read-only sessions, the lists already in the
+// table are served but no new ones are stored.
+func WithNNCache() Option {
+	return func(ft *FastText) {
+		ft.nnCache = true
+	}
+}
+
+func (ft *FastText) createNNCacheTable(db execer) error {
+	_, err := db.Exec(ft.sql(`
+	CREATE TABLE IF NOT EXISTS fasttext_nn_cache(
+		word TEXT,
+		k INTEGER,
+		version TEXT,
+		neighbors BLOB,
+		PRIMARY KEY(word, k, version)
+	);`))
+	return err
+}
+
+// cachedNearest is NearestNeighbors without search options, served from
+// the neighbor cache when it holds a list of at least k words.
+func (ft *FastText) cachedNearest(ctx context.Context, word string, k int) ([]ScoredWord, error) {
+	word = ft.normalize(word)
+	version, _, err := ft.getMeta(metaGeneration)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = ft.db.QueryRow(ft.sql(`SELECT neighbors FROM fasttext_nn_cache
+		WHERE word = ? AND k >= ? AND version = ? ORDER BY k LIMIT 1;`), word, k, version).Scan(&data)
+	if err == nil {
+		var nn []ScoredWord
+		if err := json.Unmarshal(data, &nn); err != nil {
+			return nil, err
+		}
+		if len(nn) > k {
+			nn = nn[:k]
+		}
+		return nn, nil
+	}
+	if err != sql.ErrNoRows && !isNoSuchTable(err) {
+		return nil, err
+	}
+	vec, err := ft.GetEmbContext(ctx, word)
+	if err != nil {
+		return nil, err
+	}
+	nn, err := ft.nearest(ctx, vec, k, ft.excludeWords(word), nil)
+	if err != nil {
+		return nil, err
+	}
+	if !ft.readOnly {
+		if err := ft.storeNearest(word, k, version, nn); err != nil {
+			return nil, err
+		}
+	}
+	return nn, nil
+}
+
+// storeNearest adds the neighbors of the word to the neighbor cache.
+func (ft *FastText) storeNearest(word string, k int, version string, nn []ScoredWord) error {
+	data, err := json.Marshal(nn)
+	if err != nil {
+		return err
+	}
+	if err := ft.createNNCacheTable(ft.db); err != nil {
+		return err
+	}
+	_, err = ft.db.Exec(ft.sql(`INSERT OR REPLACE INTO fasttext_nn_cache(word, k, version, neighbors)
+		VALUES(?, ?, ?, ?);`), word, k, version, data)
+	return err
+}
+
+// clearNNCache drops the neighbor lists of the neighbor cache, made
+// stale by a write to the vocabulary.
+func (ft *FastText) clearNNCache(db execer) error {
+	if _, err := db.Exec(ft.sql(`DELETE FROM fasttext_nn_cache;`)); err != nil && !isNoSuchTable(err) {
+		return err
+	}
+	return nil
+}
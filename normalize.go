@@ -0,0 +1,59 @@
+package fasttext
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// WithNormalizer normalizes words with fn, both the keys of a build and
+// the words of look-ups, searches and updates, so that e.g. "Paris"
+// finds "paris". Several normalizers are applied in order. They must
+// be idempotent, and a database must be queried with the normalizers
+// it was built with. When a build maps several words to the same key,
+// the first one is kept, which in fastText files is the most frequent,
+// see WithConflictPolicy.
+func WithNormalizer(fn func(word string) string) Option {
+	return func(ft *FastText) {
+		ft.normalizers = append(ft.normalizers, fn)
+	}
+}
+
+// WithLowercase lowercases words, see WithNormalizer.
+func WithLowercase() Option {
+	return WithNormalizer(strings.ToLower)
+}
+
+// WithUnicodeNFC converts words to the Unicode canonical composition
+// (NFC), so decomposed forms of accented letters match the composed
+// forms of the vocabulary, see WithNormalizer.
+func WithUnicodeNFC() Option {
+	return WithNormalizer(norm.NFC.String)
+}
+
+// normalize applies the normalizers of the session to the word.
+func (ft *FastText) normalize(word string) string {
+	for _, fn := range ft.normalizers {
+		word = fn(word)
+	}
+	return word
+}
+
+// normalizeAll returns the normalized words, or words itself without
+// normalizers.
+func (ft *FastText) normalizeAll(words []string) []string {
+	if len(ft.normalizers) == 0 {
+		return words
+	}
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = ft.normalize(w)
+	}
+	return out
+}
+
+// excludeWords returns a candidate filter rejecting the given words,
+// normalized.
+func (ft *FastText) excludeWords(words ...string) func(string, float64) bool {
+	return excludeWords(ft.normalizeAll(words)...)
+}
@@ -0,0 +1,165 @@
+package fasttext
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var npyMagic = []byte("\x93NUMPY")
+
+// npyArray reads the rows of a 2-dimensional NumPy array of floats,
+// either from a .npy file or from the raw data of a pickled array.
+type npyArray struct {
+	r     io.Reader
+	rows  int
+	cols  int
+	order binary.ByteOrder
+	// kind and width of the values: 'f' and 2, 4 or 8.
+	kind  byte
+	width int
+	buf   []byte
+}
+
+var (
+	npyDescrRe   = regexp.MustCompile(`'descr':\s*'([^']*)'`)
+	npyFortranRe = regexp.MustCompile(`'fortran_order':\s*(True|False)`)
+	npyShapeRe   = regexp.MustCompile(`'shape':\s*\(([^)]*)\)`)
+)
+
+// readNpyHeader parses the header of a .npy file, leaving r at the
+// start of the data.
+func readNpyHeader(r io.Reader) (*npyArray, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(npyMagic)+2)
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(magic[:len(npyMagic)], npyMagic) {
+		return nil, errors.New("fasttext: not a .npy file")
+	}
+	var headerLen int
+	switch major := magic[len(npyMagic)]; major {
+	case 1:
+		var n uint16
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	case 2, 3:
+		var n uint32
+		if err := binary.Read(br, binary.LittleEndian, &n); err != nil {
+			return nil, err
+		}
+		headerLen = int(n)
+	default:
+		return nil, fmt.Errorf("fasttext: unsupported .npy version %d", major)
+	}
+	header := make([]byte, headerLen)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, err
+	}
+	h := string(header)
+	descr := npyDescrRe.FindStringSubmatch(h)
+	fortran := npyFortranRe.FindStringSubmatch(h)
+	shape := npyShapeRe.FindStringSubmatch(h)
+	if descr == nil || fortran == nil || shape == nil {
+		return nil, fmt.Errorf("fasttext: malformed .npy header %q", h)
+	}
+	if fortran[1] == "True" {
+		return nil, errors.New("fasttext: Fortran-ordered .npy arrays are not supported")
+	}
+	var dims []int
+	for _, d := range strings.Split(shape[1], ",") {
+		d = strings.TrimSpace(d)
+		if d == "" {
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return nil, fmt.Errorf("fasttext: malformed .npy shape %q", shape[1])
+		}
+		dims = append(dims, n)
+	}
+	if len(dims) != 2 {
+		return nil, fmt.Errorf("fasttext: expected a 2-dimensional array, got shape (%s)", shape[1])
+	}
+	return newNpyArray(br, descr[1], dims[0], dims[1])
+}
+
+// newNpyArray reads an array of the given NumPy type descriptor
+// (e.g. "<f4") and shape from r.
+func newNpyArray(r io.Reader, descr string, rows, cols int) (*npyArray, error) {
+	if len(descr) < 3 {
+		return nil, fmt.Errorf("fasttext: unsupported array type %q", descr)
+	}
+	a := &npyArray{r: r, rows: rows, cols: cols, kind: descr[1]}
+	switch descr[0] {
+	case '<', '|', '=':
+		a.order = binary.LittleEndian
+	case '>':
+		a.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("fasttext: unsupported array type %q", descr)
+	}
+	var err error
+	if a.width, err = strconv.Atoi(descr[2:]); err != nil {
+		return nil, fmt.Errorf("fasttext: unsupported array type %q", descr)
+	}
+	if a.kind != 'f' || (a.width != 2 && a.width != 4 && a.width != 8) {
+		return nil, fmt.Errorf("fasttext: unsupported array type %q, expected floats", descr)
+	}
+	a.buf = make([]byte, cols*a.width)
+	return a, nil
+}
+
+// readRow reads the next row of the array.
+func (a *npyArray) readRow() ([]float32, error) {
+	if _, err := io.ReadFull(a.r, a.buf); err != nil {
+		return nil, err
+	}
+	vec := make([]float32, a.cols)
+	for i := range vec {
+		b := a.buf[i*a.width : (i+1)*a.width]
+		switch a.width {
+		case 2:
+			vec[i] = float16ToFloat32(a.order.Uint16(b))
+		case 4:
+			vec[i] = math.Float32frombits(a.order.Uint32(b))
+		case 8:
+			vec[i] = float32(math.Float64frombits(a.order.Uint64(b)))
+		}
+	}
+	return vec, nil
+}
+
+// zipWords pairs the words with the rows of the array, sending them to
+// a channel for build until done is closed.
+func zipWords(words []string, a *npyArray, done <-chan struct{}) chan *wordEmb {
+	out := make(chan *wordEmb)
+	go func() {
+		defer close(out)
+		if len(words) != a.rows {
+			send(out, &wordEmb{Err: fmt.Errorf("fasttext: %d words for %d vectors", len(words), a.rows)}, done)
+			return
+		}
+		for _, word := range words {
+			vec, err := a.readRow()
+			if err != nil {
+				send(out, &wordEmb{Err: err}, done)
+				return
+			}
+			if !send(out, &wordEmb{Word: word, Vec: vec}, done) {
+				return
+			}
+		}
+	}()
+	return out
+}
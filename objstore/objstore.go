@@ -0,0 +1,96 @@
+//go:build gocloud
+
+// Package objstore builds and installs fastText databases straight from
+// object storage, e.g. Amazon S3 or Google Cloud Storage, through the
+// portable blob URLs of the Go Cloud Development Kit (gocloud.dev):
+//
+//	import (
+//		_ "gocloud.dev/blob/gcsblob"
+//		_ "gocloud.dev/blob/s3blob"
+//	)
+//	...
+//	err := objstore.BuildDBFromObject(ctx, ft, "s3://models/cc.en.300.vec.gz?region=us-east-1")
+//	err := objstore.DownloadDB(ctx, "gs://models/cc.en.300.sqlite", "/var/lib/model.sqlite")
+//
+// The URLs name an object: the bucket URL with the key of the object as
+// its path. For file:// URLs, whose buckets are directories, the key is
+// the last element of the path.
+//
+// The drivers of the storage services are imported by the program, as
+// the URLs of their schemes are only opened once registered. The package
+// is built with the gocloud build tag, so that the core package does
+// not depend on the Go CDK:
+//
+//	go build -tags gocloud
+package objstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/ekzhu/go-fasttext"
+	"gocloud.dev/blob"
+)
+
+// BuildDBFromObject initializes the database of the session from the
+// word embedding file at the object URL, streaming it into the database
+// without storing it on disk, as BuildDB does. Cancelling the context
+// stops the build.
+func BuildDBFromObject(ctx context.Context, ft *fasttext.FastText, objectURL string, opts ...fasttext.BuildOption) error {
+	return readObject(ctx, objectURL, func(r io.Reader) error {
+		return ft.BuildDB(r, opts...)
+	})
+}
+
+// DownloadDB installs the prebuilt database at the object URL at
+// localPath, see InstallDB.
+func DownloadDB(ctx context.Context, objectURL, localPath string) error {
+	return readObject(ctx, objectURL, func(r io.Reader) error {
+		return fasttext.InstallDB(r, localPath)
+	})
+}
+
+// readObject calls fn with a reader of the object at the URL.
+func readObject(ctx context.Context, objectURL string, fn func(r io.Reader) error) error {
+	bucketURL, key, err := splitObjectURL(objectURL)
+	if err != nil {
+		return err
+	}
+	bucket, err := blob.OpenBucket(ctx, bucketURL)
+	if err != nil {
+		return err
+	}
+	defer bucket.Close()
+	r, err := bucket.NewReader(ctx, key, nil)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return fn(r)
+}
+
+// splitObjectURL returns the URL of the bucket and the key of the
+// object at the URL.
+func splitObjectURL(objectURL string) (bucketURL, key string, err error) {
+	u, err := url.Parse(objectURL)
+	if err != nil {
+		return "", "", err
+	}
+	if u.Scheme == "file" {
+		u.Path, key = path.Split(u.Path)
+		if len(u.Path) > 1 {
+			u.Path = strings.TrimSuffix(u.Path, "/")
+		}
+	} else {
+		key, u.Path = strings.TrimPrefix(u.Path, "/"), ""
+	}
+	u.RawPath = ""
+	if key == "" {
+		return "", "", fmt.Errorf("objstore: no object in %s", objectURL)
+	}
+	return u.String(), key, nil
+}
@@ -0,0 +1,75 @@
+//go:build gocloud
+
+package objstore
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+	_ "gocloud.dev/blob/fileblob"
+)
+
+func Test_SplitObjectURL(t *testing.T) {
+	for _, c := range []struct {
+		url, bucket, key string
+	}{
+		{"s3://models/cc.en.300.vec.gz?region=us-east-1", "s3://models?region=us-east-1", "cc.en.300.vec.gz"},
+		{"gs://models/en/wiki.sqlite", "gs://models", "en/wiki.sqlite"},
+		{"file:///data/models/wiki.vec", "file:///data/models", "wiki.vec"},
+	} {
+		bucket, key, err := splitObjectURL(c.url)
+		if err != nil || bucket != c.bucket || key != c.key {
+			t.Errorf("%s: expected %s and %s, got %s and %s, %v", c.url, c.bucket, c.key, bucket, key, err)
+		}
+	}
+	for _, u := range []string{"s3://models", "file:///data/models/"} {
+		if _, _, err := splitObjectURL(u); err == nil {
+			t.Errorf("%s: expected an error without object", u)
+		}
+	}
+}
+
+func Test_BuildAndDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "objstore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	vec, err := ioutil.ReadFile("../testdata/wiki.en.vec")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "wiki.en.vec"), vec, 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	db := filepath.Join(dir, "wiki.sqlite")
+	ft := fasttext.NewFastText(db)
+	if err := BuildDBFromObject(ctx, ft, "file://"+filepath.ToSlash(dir)+"/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ft.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+	ft.Close()
+	mem := fasttext.NewFastText(":memory:")
+	defer mem.Close()
+	if err := BuildDBFromObject(ctx, mem, "file://"+filepath.ToSlash(dir)+"/missing.vec"); err == nil {
+		t.Error("Expected an error for a missing object")
+	}
+
+	local := filepath.Join(dir, "installed.sqlite")
+	if err := DownloadDB(ctx, "file://"+filepath.ToSlash(dir)+"/wiki.sqlite", local); err != nil {
+		t.Fatal(err)
+	}
+	installed := fasttext.NewFastText(local)
+	defer installed.Close()
+	if _, err := installed.GetEmb("has"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,132 @@
+package fasttext
+
+import (
+	"context"
+	"errors"
+)
+
+// OOVPolicy resolves the embedding of a word missing from the
+// vocabulary. The session applies it uniformly in GetEmb, GetEmbs and
+// everything built on them, e.g. GetSentenceEmb and NearestNeighbors.
+// It returns an error matching ErrNoEmbFound if it cannot resolve the
+// word, or a nil embedding without error to leave it out as OOVSkipWord
+// does, and must not call GetEmb or GetEmbs on missing words itself.
+type OOVPolicy func(ft *FastText, word string) ([]float32, error)
+
+// WithOOVPolicy sets the OOV policy of the session, OOVError by default.
+func WithOOVPolicy(policy OOVPolicy) Option {
+	return func(ft *FastText) {
+		ft.oovPolicy = policy
+	}
+}
+
+// OOVError returns a *WordNotFoundError for missing words.
+func OOVError() OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		return nil, &WordNotFoundError{Word: word}
+	}
+}
+
+// OOVSkipWord leaves missing words out: GetEmb reports them missing as
+// OOVError does, GetEmbs returns nil for them, and the sentence
+// embeddings leave them out of the average (see WithSentenceOOVPolicy).
+func OOVSkipWord() OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		return nil, nil
+	}
+}
+
+// OOVZeroVector returns a zero vector for missing words.
+func OOVZeroVector() OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		f, err := ft.vecFormat()
+		if err != nil {
+			return nil, err
+		}
+		if f.dim == 0 {
+			return nil, &WordNotFoundError{Word: word}
+		}
+		return make([]float32, f.dim), nil
+	}
+}
+
+// OOVSubwords returns the average of the embeddings of the substrings
+// of minn to maxn characters of a missing word found in the
+// vocabulary, in the spirit of fastText's subword vectors, e.g. "ing"
+// and "walk" for "walkingly".
+func OOVSubwords(minn, maxn int) OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		runes := []rune(ft.normalize(word))
+		seen := make(map[string]bool)
+		var grams []string
+		for n := maxInt(minn, 1); n <= maxn && n <= len(runes); n++ {
+			for i := 0; i+n <= len(runes); i++ {
+				gram := string(runes[i : i+n])
+				if !seen[gram] {
+					seen[gram] = true
+					grams = append(grams, gram)
+				}
+			}
+		}
+		var sum []float32
+		var found int
+		for start := 0; start < len(grams); start += maxBatchVars {
+			batch := grams[start:minInt(start+maxBatchVars, len(grams))]
+			err := ft.timedLookupBatch(context.Background(), batch, func(_ string, vec []float32) {
+				if sum == nil {
+					sum = make([]float32, len(vec))
+				}
+				axpy(1, vec, sum)
+				found++
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+		if found == 0 {
+			return nil, &WordNotFoundError{Word: word}
+		}
+		return averageVec(sum, found, false), nil
+	}
+}
+
+// OOVFuzzy returns the embedding of the closest vocabulary word found
+// by GetEmbFuzzy.
+func OOVFuzzy() OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		_, vec, err := ft.GetEmbFuzzy(word)
+		return vec, err
+	}
+}
+
+// OOVFunc resolves missing words with fn, e.g. a fallback model.
+func OOVFunc(fn func(word string) ([]float32, error)) OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		return fn(word)
+	}
+}
+
+// OOVChain tries the policies in order, returning the first resolved
+// embedding, e.g. OOVChain(OOVFuzzy(), OOVSubwords(3, 6), OOVZeroVector()).
+func OOVChain(policies ...OOVPolicy) OOVPolicy {
+	return func(ft *FastText, word string) ([]float32, error) {
+		for _, policy := range policies {
+			vec, err := policy(ft, word)
+			if err == nil || !errors.Is(err, ErrNoEmbFound) {
+				return vec, err
+			}
+		}
+		return nil, &WordNotFoundError{Word: word}
+	}
+}
+
+// resolveOOV resolves the missing word with the OOV policy of the
+// session, failing with a *WordNotFoundError if the policy leaves it
+// out.
+func (ft *FastText) resolveOOV(word string) ([]float32, error) {
+	vec, err := ft.oovPolicy(ft, word)
+	if vec == nil && err == nil {
+		return nil, &WordNotFoundError{Word: word}
+	}
+	return vec, err
+}
@@ -0,0 +1,30 @@
+package fasttext
+
+import "time"
+
+// WithVacuum compacts the database file with Optimize at the end of
+// the build. The build always gathers the statistics of the query
+// planner; compacting also drops the free pages left by the batched
+// inserts and the index creation, at the cost of rewriting the file.
+func WithVacuum() BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.vacuum = true
+	}
+}
+
+// Optimize gathers the statistics of the query planner with ANALYZE and
+// compacts the database file with VACUUM, e.g. after a build without
+// WithVacuum or after many PutEmb and DeleteEmb calls. It needs free
+// disk space for a temporary copy of the database and blocks the other
+// writers while it runs.
+func (ft *FastText) Optimize() error {
+	start := time.Now()
+	if _, err := ft.db.Exec(`ANALYZE;`); err != nil {
+		return err
+	}
+	if _, err := ft.db.Exec(`VACUUM;`); err != nil {
+		return err
+	}
+	ft.logInfo("fasttext: optimized", "duration", time.Since(start))
+	return nil
+}
@@ -0,0 +1,192 @@
+package fasttext
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// ParseMode is how malformed lines of text embedding files are handled.
+type ParseMode int
+
+const (
+	// ParseStrict stops the build with a ParseError at the first
+	// malformed line. This is the default.
+	ParseStrict ParseMode = iota
+	// ParseLenient skips the malformed lines, after repairing those it
+	// can: words containing spaces, which are otherwise taken for a too
+	// long vector, are kept with their spaces.
+	ParseLenient
+)
+
+// BadLineFunc is called in lenient mode with the problem of each
+// malformed line, and whether the line was repaired rather than skipped.
+type BadLineFunc func(err *ParseError, repaired bool)
+
+// WithParseMode sets how malformed lines are handled, ParseStrict by
+// default. Lines are malformed if they have no vector, a vector of the
+// wrong dimension, or values that are not finite numbers. The number of
+// malformed lines of a build is recorded in the metadata table, see
+// Stats.
+func WithParseMode(mode ParseMode) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.parse.mode = mode
+	}
+}
+
+// WithBadLineFunc sets a callback reporting the malformed lines in
+// lenient mode, e.g. to log them.
+func WithBadLineFunc(fn BadLineFunc) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.parse.fn = fn
+	}
+}
+
+// WithDelimiter sets the separator of the values of the vectors, a
+// single space by default, e.g. "\t" for tab-separated files.
+func WithDelimiter(sep string) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.parse.sep = sep
+	}
+}
+
+// WithWordSeparator sets the separator between the word and its vector,
+// the delimiter of the values by default. Setting it to "\t" allows
+// words containing spaces, e.g. multi-word phrases, in files whose
+// values are separated by spaces.
+func WithWordSeparator(sep string) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.parse.wordSep = sep
+	}
+}
+
+// metaBadLines is the metadata key of the number of malformed lines of
+// the build.
+const metaBadLines = "bad_lines"
+
+// parseConfig configures the parsing of text embedding files.
+type parseConfig struct {
+	workers int
+	mode    ParseMode
+	fn      BadLineFunc
+	// sep separates the values and wordSep the word from the values,
+	// both a space if empty.
+	sep, wordSep string
+	// badLines counts the malformed lines.
+	badLines int64
+	logger   Logger
+}
+
+// delimiters returns the separator of the values and the separator of
+// the word.
+func (cfg *parseConfig) delimiters() (string, string) {
+	sep := cfg.sep
+	if sep == "" {
+		sep = " "
+	}
+	wordSep := cfg.wordSep
+	if wordSep == "" {
+		wordSep = sep
+	}
+	return sep, wordSep
+}
+
+// split splits a line into the word and the values of its vector, if
+// any.
+func (cfg *parseConfig) split(data string) (string, []string, bool) {
+	sep, wordSep := cfg.delimiters()
+	items := strings.SplitN(data, wordSep, 2)
+	if len(items) < 2 {
+		return items[0], nil, false
+	}
+	return items[0], strings.Split(strings.TrimSpace(items[1]), sep), true
+}
+
+// report reports a malformed line.
+func (cfg *parseConfig) report(err *ParseError) {
+	atomic.AddInt64(&cfg.badLines, 1)
+	if cfg.logger != nil {
+		cfg.logger.Warn("fasttext: malformed line", "line", err.Line, "word", err.Word,
+			"error", err.Err, "repaired", err.repaired)
+	}
+	if cfg.fn != nil {
+		cfg.fn(err, err.repaired)
+	}
+}
+
+// parseBatchLines is the number of lines parsed at once by a worker of
+// readwordEmbdFile.
+const parseBatchLines = 256
+
+// parseBatch is a batch of consecutive lines of a word embedding file.
+type parseBatch struct {
+	lines []string
+	// first is the line number of the first line.
+	first   int
+	embSize int
+	result  chan []*wordEmb
+	// err is a read error after the lines.
+	err error
+}
+
+// parse parses the lines of the batch. In strict mode, it stops at the
+// first malformed line.
+func (b *parseBatch) parse(cfg *parseConfig) ([]*wordEmb, error) {
+	embs := make([]*wordEmb, 0, len(b.lines))
+	for j, data := range b.lines {
+		emb, err := cfg.parseLine(data, b.embSize)
+		if err != nil {
+			err.Line = b.first + j
+			if cfg.mode != ParseLenient {
+				return embs, err
+			}
+			emb.bad = err
+		}
+		embs = append(embs, emb)
+	}
+	return embs, nil
+}
+
+// parseLine parses a line made of a word and its vector. The error of a
+// repaired line comes along with the embedding.
+func (cfg *parseConfig) parseLine(data string, embSize int) (*wordEmb, *ParseError) {
+	word, vecStrs, ok := cfg.split(data)
+	if word == "" {
+		word = " "
+	}
+	emb := &wordEmb{Word: word}
+	if !ok {
+		return emb, &ParseError{Word: word, Err: errors.New("no vector")}
+	}
+	sep, wordSep := cfg.delimiters()
+	var fix *ParseError
+	if extra := len(vecStrs) - embSize; extra > 0 && cfg.mode == ParseLenient && sep == wordSep {
+		// A word containing spaces, unless its last part is a number.
+		if _, err := strconv.ParseFloat(vecStrs[extra-1], 32); err != nil {
+			emb.Word = word + sep + strings.Join(vecStrs[:extra], sep)
+			vecStrs = vecStrs[extra:]
+			fix = &ParseError{Word: emb.Word, Err: fmt.Errorf("word containing %d spaces", extra),
+				repaired: true}
+		}
+	}
+	if len(vecStrs) != embSize {
+		return emb, &ParseError{Word: word, Err: fmt.Errorf("vector of %d values, expected %d", len(vecStrs), embSize)}
+	}
+	vec := make([]float32, embSize)
+	for i := 0; i < embSize; i++ {
+		sf, err := strconv.ParseFloat(vecStrs[i], 32)
+		if err != nil {
+			return &wordEmb{Word: emb.Word}, &ParseError{Word: emb.Word, Err: err}
+		}
+		if math.IsNaN(sf) || math.IsInf(sf, 0) {
+			return &wordEmb{Word: emb.Word}, &ParseError{Word: emb.Word,
+				Err: fmt.Errorf("non-finite value %s", vecStrs[i])}
+		}
+		vec[i] = float32(sf)
+	}
+	emb.Vec = vec
+	return emb, fix
+}
@@ -0,0 +1,165 @@
+package fasttext
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadTableName is the SQLite3 table holding the payloads attached
+// to the words of the default model, "<name>_payload" for the others.
+const PayloadTableName = "fasttext_payload"
+
+// Entry is a word of the vocabulary with everything stored about it.
+type Entry struct {
+	Word string
+	Emb  []float32
+	// Payload is the payload attached with SetPayload or WithPayloads,
+	// nil if none.
+	Payload []byte
+}
+
+// UnmarshalPayload parses the JSON payload of the entry into v.
+func (e *Entry) UnmarshalPayload(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
+
+func (ft *FastText) createPayloadTable(db execer) error {
+	_, err := db.Exec(ft.sql(`
+	CREATE TABLE IF NOT EXISTS fasttext_payload(
+		word TEXT PRIMARY KEY,
+		payload BLOB
+	);`))
+	return err
+}
+
+// SetPayload attaches an arbitrary payload to a word of the vocabulary,
+// e.g. its POS tags, senses or domain flags, replacing any previous
+// one. Payloads are meant to be small; they are returned by Lookup
+// along with the embedding. It returns ErrNoEmbFound if the word is not
+// in the vocabulary.
+func (ft *FastText) SetPayload(word string, payload []byte) error {
+	word = ft.normalize(word)
+	ok, err := ft.Contains(word)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return &WordNotFoundError{Word: word}
+	}
+	if err := ft.createPayloadTable(ft.db); err != nil {
+		return err
+	}
+	_, err = ft.db.Exec(ft.sql(`INSERT OR REPLACE INTO fasttext_payload(word, payload) VALUES(?, ?);`),
+		word, payload)
+	return err
+}
+
+// SetPayloadJSON attaches the JSON encoding of v to a word, see
+// SetPayload.
+func (ft *FastText) SetPayloadJSON(word string, v interface{}) error {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return ft.SetPayload(word, payload)
+}
+
+// Payload returns the payload attached to the word, nil if it has none.
+func (ft *FastText) Payload(word string) ([]byte, error) {
+	word = ft.normalize(word)
+	var payload []byte
+	err := ft.db.QueryRow(ft.sql(`SELECT payload FROM fasttext_payload WHERE word=?;`), word).Scan(&payload)
+	if err == sql.ErrNoRows || (err != nil && isNoSuchTable(err)) {
+		return nil, nil
+	}
+	return payload, err
+}
+
+// DeletePayload removes the payload attached to the word, if any.
+func (ft *FastText) DeletePayload(word string) error {
+	word = ft.normalize(word)
+	_, err := ft.db.Exec(ft.sql(`DELETE FROM fasttext_payload WHERE word=?;`), word)
+	if err != nil && isNoSuchTable(err) {
+		return nil
+	}
+	return err
+}
+
+// Lookup returns the embedding of the word with its payload, or
+// ErrNoEmbFound if the word is not in the vocabulary.
+func (ft *FastText) Lookup(word string) (*Entry, error) {
+	emb, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := ft.Payload(word)
+	if err != nil {
+		return nil, err
+	}
+	return &Entry{Word: ft.normalize(word), Emb: emb, Payload: payload}, nil
+}
+
+// GetEmbWithMeta returns the embedding of the word, parsing its JSON
+// payload (see SetPayloadJSON and WithPayloads) into meta. meta is left
+// untouched if the word has no payload.
+func (ft *FastText) GetEmbWithMeta(word string, meta interface{}) ([]float32, error) {
+	entry, err := ft.Lookup(word)
+	if err != nil {
+		return nil, err
+	}
+	if entry.Payload != nil {
+		if err := entry.UnmarshalPayload(meta); err != nil {
+			return nil, fmt.Errorf("fasttext: payload of %q: %w", entry.Word, err)
+		}
+	}
+	return entry.Emb, nil
+}
+
+// WithPayloads attaches a payload to the words of the build, as
+// SetPayload does: fn is called with each word inserted, normalized,
+// and returns its payload, e.g. the JSON encoding of its POS tag,
+// language or domain flags, or nil for none. An error of fn stops the
+// build. The payload of a duplicate word follows the ConflictPolicy of
+// its embedding.
+func WithPayloads(fn func(word string) ([]byte, error)) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.payloads = fn
+	}
+}
+
+// buildPayload stores the payload of WithPayloads of a word of the
+// build, replacing any previous one if replace is set.
+func (ft *FastText) buildPayload(db execer, fn func(string) ([]byte, error), word string, replace bool) error {
+	payload, err := fn(word)
+	if err != nil {
+		return err
+	}
+	if replace {
+		if _, err := db.Exec(ft.sql(`DELETE FROM fasttext_payload WHERE word=?;`), word); err != nil {
+			return err
+		}
+	}
+	if payload == nil {
+		return nil
+	}
+	_, err = db.Exec(ft.sql(`INSERT OR REPLACE INTO fasttext_payload(word, payload) VALUES(?, ?);`),
+		word, payload)
+	return err
+}
+
+// resolvePayload applies the conflict policy to the payload of a word
+// of the build found again.
+func (ft *FastText) resolvePayload(db execer, cfg *buildConfig, words *buildWords, word string) error {
+	switch {
+	case cfg.payloads == nil:
+		return nil
+	case words.ids[word] == 0:
+		// The word is left out.
+		_, err := db.Exec(ft.sql(`DELETE FROM fasttext_payload WHERE word=?;`), word)
+		return err
+	case cfg.conflict == ConflictOverwrite:
+		return ft.buildPayload(db, cfg.payloads, word, true)
+	}
+	return nil
+}
@@ -0,0 +1,207 @@
+package fasttext
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// pcaSampleRows is the number of vectors the PCA is fitted on.
+const pcaSampleRows = 10000
+
+// Metadata keys of the PCA projection.
+const (
+	metaPCAInputDim   = "pca_input_dim"
+	metaPCAMean       = "pca_mean"
+	metaPCAComponents = "pca_components"
+)
+
+// WithPCA reduces the vectors of BuildDB to their first dims principal
+// components, fitted on the first vectors of the input, which cuts the
+// storage and the cost of the neighbor searches at the price of some
+// fidelity. The projection is recorded in the metadata table: vectors
+// inserted later by AppendDB are projected too, while vectors from
+// other sources, e.g. for PutEmb or NearestByVector, must be projected
+// with Project.
+func WithPCA(dims int) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.pcaDim = dims
+	}
+}
+
+// pcaProjection projects vectors on their principal components.
+type pcaProjection struct {
+	mean []float32
+	// components holds the principal components as rows.
+	components [][]float32
+}
+
+// inputDim returns the dimension of the vectors before projection.
+func (p *pcaProjection) inputDim() int {
+	return len(p.mean)
+}
+
+func (p *pcaProjection) project(vec []float32) []float32 {
+	centered := copyVec(vec)
+	axpy(-1, p.mean, centered)
+	out := make([]float32, len(p.components))
+	for i, c := range p.components {
+		out[i] = float32(dot(c, centered))
+	}
+	return out
+}
+
+// pcaDim returns the number of principal components the vectors are
+// projected on, 0 if they are not.
+func (f vecFormat) pcaDim() int {
+	if f.pca == nil {
+		return 0
+	}
+	return len(f.pca.components)
+}
+
+// fitPCA returns the projection on the first dims principal components
+// of the vectors.
+func fitPCA(vecs [][]float32, dims int) (*pcaProjection, error) {
+	if len(vecs) < 2 {
+		return nil, errors.New("fasttext: PCA needs at least 2 vectors")
+	}
+	dim := len(vecs[0])
+	if dims <= 0 || dims > dim {
+		return nil, fmt.Errorf("fasttext: cannot reduce %d dimensions to %d", dim, dims)
+	}
+	mean := make([]float32, dim)
+	for _, vec := range vecs {
+		if len(vec) != dim {
+			return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), dim)
+		}
+		axpy(1/float32(len(vecs)), vec, mean)
+	}
+	data := mat.NewDense(len(vecs), dim, nil)
+	for i, vec := range vecs {
+		for j, v := range vec {
+			data.Set(i, j, float64(v-mean[j]))
+		}
+	}
+	cov := mat.NewSymDense(dim, nil)
+	cov.SymOuterK(1/float64(len(vecs)-1), data.T())
+	var eig mat.EigenSym
+	if !eig.Factorize(cov, true) {
+		return nil, errors.New("fasttext: PCA did not converge")
+	}
+	var vectors mat.Dense
+	eig.VectorsTo(&vectors)
+	// The eigenvalues are in ascending order.
+	p := &pcaProjection{mean: mean, components: make([][]float32, dims)}
+	for i := range p.components {
+		col := dim - 1 - i
+		c := make([]float32, dim)
+		for j := range c {
+			c[j] = float32(vectors.At(j, col))
+		}
+		p.components[i] = c
+	}
+	return p, nil
+}
+
+// Project projects a vector of the dimension of the input of BuildDB on
+// the principal components of the database built WithPCA, so that it
+// can be compared with the stored vectors. Without a projection, it
+// returns a copy of the vector.
+func (ft *FastText) Project(vec []float32) ([]float32, error) {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	if f.pca == nil {
+		return copyVec(vec), nil
+	}
+	if len(vec) != f.pca.inputDim() {
+		return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), f.pca.inputDim())
+	}
+	return f.pca.project(vec), nil
+}
+
+// encodeFloats encodes the values for the metadata table.
+func encodeFloats(vecs ...[]float32) string {
+	var buf bytes.Buffer
+	for _, vec := range vecs {
+		binary.Write(&buf, binary.LittleEndian, vec)
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+// decodeFloats decodes the values of encodeFloats in vectors of the
+// given dimension.
+func decodeFloats(s string, dim int) ([][]float32, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if dim <= 0 || len(data)%(4*dim) != 0 {
+		return nil, fmt.Errorf("fasttext: %d bytes of vectors of dimension %d", len(data), dim)
+	}
+	vecs := make([][]float32, len(data)/(4*dim))
+	for i := range vecs {
+		vecs[i] = make([]float32, dim)
+		binary.Read(bytes.NewReader(data[i*4*dim:(i+1)*4*dim]), binary.LittleEndian, vecs[i])
+	}
+	return vecs, nil
+}
+
+// loadPCA reads the projection of the vectors from the metadata table.
+func (ft *FastText) loadPCA(f *vecFormat) error {
+	value, ok, err := ft.getMeta(metaPCAInputDim)
+	if err != nil || !ok {
+		return err
+	}
+	dim, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	mean, err := ft.getPCAFloats(metaPCAMean, dim)
+	if err != nil {
+		return err
+	}
+	components, err := ft.getPCAFloats(metaPCAComponents, dim)
+	if err != nil {
+		return err
+	}
+	if len(mean) != 1 || len(components) == 0 {
+		return errors.New("fasttext: incomplete PCA projection")
+	}
+	f.pca = &pcaProjection{mean: mean[0], components: components}
+	return nil
+}
+
+// getPCAFloats reads vectors of the projection from the metadata table.
+func (ft *FastText) getPCAFloats(key string, dim int) ([][]float32, error) {
+	value, _, err := ft.getMeta(key)
+	if err != nil {
+		return nil, err
+	}
+	vecs, err := decodeFloats(value, dim)
+	if err != nil {
+		return nil, fmt.Errorf("fasttext: invalid %s: %w", key, err)
+	}
+	return vecs, nil
+}
+
+// setPCA records the projection in the metadata table.
+func (ft *FastText) setPCA(db execer, f vecFormat) error {
+	if f.pca == nil {
+		return nil
+	}
+	if err := ft.setMeta(db, metaPCAInputDim, strconv.Itoa(f.pca.inputDim())); err != nil {
+		return err
+	}
+	if err := ft.setMeta(db, metaPCAMean, encodeFloats(f.pca.mean)); err != nil {
+		return err
+	}
+	return ft.setMeta(db, metaPCAComponents, encodeFloats(f.pca.components...))
+}
@@ -0,0 +1,36 @@
+package fasttext
+
+import "strings"
+
+// PhraseSeparators are the separators tried by GetPhraseEmb to join the
+// tokens of a phrase into a single vocabulary entry, in order: the
+// pretrained models join multi-word entries with underscores, e.g.
+// "New_York", and sometimes with hyphens or nothing at all.
+var PhraseSeparators = []string{"_", "-", ""}
+
+// GetPhraseEmb returns the embedding of the phrase made of the tokens.
+// It first looks up the tokens joined by each of PhraseSeparators, in a
+// single batch, and returns the embedding of the first joined form in
+// the vocabulary. Otherwise it falls back to the average of the token
+// embeddings, see GetSentenceEmb.
+func (ft *FastText) GetPhraseEmb(tokens []string, opts ...SentenceOption) ([]float32, error) {
+	if len(tokens) > 1 {
+		forms := make([]string, len(PhraseSeparators))
+		for i, sep := range PhraseSeparators {
+			forms[i] = strings.Join(tokens, sep)
+		}
+		embs, err := ft.lookupEmbs(forms)
+		if err != nil {
+			return nil, err
+		}
+		for _, emb := range embs {
+			if emb != nil {
+				if cfg := newSentenceConfig(opts); cfg.normalize {
+					emb = averageVec(copyVec(emb), 1, true)
+				}
+				return emb, nil
+			}
+		}
+	}
+	return ft.GetSentenceEmb(tokens, opts...)
+}
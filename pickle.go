@@ -0,0 +1,556 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// This file implements a minimal Python unpickler, enough to read the
+// objects saved by gensim without executing any Python code: class
+// instances are decoded as pyObject values holding their class name,
+// constructor arguments and state.
+
+// pyGlobal is a reference to a Python class or function.
+type pyGlobal struct {
+	module, name string
+}
+
+// pyObject is an instance created by REDUCE, NEWOBJ or NEWOBJ_EX.
+type pyObject struct {
+	class interface{}
+	args  pyTuple
+	state interface{}
+}
+
+type pyTuple []interface{}
+
+type pyList struct {
+	items []interface{}
+}
+
+type pyDict struct {
+	keys, values []interface{}
+}
+
+func (d *pyDict) set(key, value interface{}) {
+	d.keys = append(d.keys, key)
+	d.values = append(d.values, value)
+}
+
+// get returns the value of a string key.
+func (d *pyDict) get(key string) (interface{}, bool) {
+	for i, k := range d.keys {
+		if s, ok := k.(string); ok && s == key {
+			return d.values[i], true
+		}
+	}
+	return nil, false
+}
+
+type pyMark struct{}
+
+// attr returns the attribute of the instance, looked up in its state.
+func (o *pyObject) attr(name string) (interface{}, bool) {
+	state := o.state
+	// State may be a (dict, slots dict) pair.
+	if t, ok := state.(pyTuple); ok && len(t) == 2 {
+		if d, ok := t[1].(*pyDict); ok {
+			if v, ok := d.get(name); ok {
+				return v, true
+			}
+		}
+		state = t[0]
+	}
+	if d, ok := state.(*pyDict); ok {
+		return d.get(name)
+	}
+	return nil, false
+}
+
+// className returns the qualified name of the class of the instance.
+func (o *pyObject) className() string {
+	if g, ok := o.class.(pyGlobal); ok {
+		return g.module + "." + g.name
+	}
+	return ""
+}
+
+type unpickler struct {
+	r     *bufio.Reader
+	stack []interface{}
+	marks []int
+	memo  map[int]interface{}
+}
+
+// unpickle decodes a single pickled value.
+func unpickle(r io.Reader) (interface{}, error) {
+	u := &unpickler{r: bufio.NewReader(r), memo: make(map[int]interface{})}
+	return u.load()
+}
+
+func (u *unpickler) push(v interface{}) {
+	u.stack = append(u.stack, v)
+}
+
+func (u *unpickler) pop() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("pickle: stack underflow")
+	}
+	v := u.stack[len(u.stack)-1]
+	u.stack = u.stack[:len(u.stack)-1]
+	return v, nil
+}
+
+func (u *unpickler) top() (interface{}, error) {
+	if len(u.stack) == 0 {
+		return nil, errors.New("pickle: stack underflow")
+	}
+	return u.stack[len(u.stack)-1], nil
+}
+
+// popMark pops the values pushed since the last MARK.
+func (u *unpickler) popMark() ([]interface{}, error) {
+	if len(u.marks) == 0 {
+		return nil, errors.New("pickle: missing mark")
+	}
+	m := u.marks[len(u.marks)-1]
+	u.marks = u.marks[:len(u.marks)-1]
+	items := make([]interface{}, len(u.stack)-m)
+	copy(items, u.stack[m:])
+	u.stack = u.stack[:m]
+	return items, nil
+}
+
+// maxPickleBytes bounds the size of a string or bytes value, so a
+// corrupt length fails instead of exhausting memory.
+const maxPickleBytes = 1 << 32
+
+func (u *unpickler) readN(n uint64) ([]byte, error) {
+	if n > maxPickleBytes {
+		return nil, fmt.Errorf("pickle: invalid size %d", n)
+	}
+	buf := make([]byte, n)
+	_, err := io.ReadFull(u.r, buf)
+	return buf, err
+}
+
+func (u *unpickler) readUint(size int) (uint64, error) {
+	buf, err := u.readN(uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for i := size - 1; i >= 0; i-- {
+		v = v<<8 | uint64(buf[i])
+	}
+	return v, nil
+}
+
+func (u *unpickler) readLine() (string, error) {
+	line, err := u.r.ReadString('\n')
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+func (u *unpickler) load() (interface{}, error) {
+	for {
+		op, err := u.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case '\x80': // PROTO
+			if _, err := u.r.ReadByte(); err != nil {
+				return nil, err
+			}
+		case '\x95': // FRAME
+			if _, err := u.readN(8); err != nil {
+				return nil, err
+			}
+		case '.': // STOP
+			return u.pop()
+		case '(': // MARK
+			u.marks = append(u.marks, len(u.stack))
+		case '0': // POP
+			if _, err := u.pop(); err != nil {
+				return nil, err
+			}
+		case '1': // POP_MARK
+			if _, err := u.popMark(); err != nil {
+				return nil, err
+			}
+		case '2': // DUP
+			v, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			u.push(v)
+		case 'N': // NONE
+			u.push(nil)
+		case '\x88': // NEWTRUE
+			u.push(true)
+		case '\x89': // NEWFALSE
+			u.push(false)
+		case 'K', 'M', 'J': // BININT1, BININT2, BININT
+			size := map[byte]int{'K': 1, 'M': 2, 'J': 4}[op]
+			v, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			if op == 'J' {
+				u.push(int64(int32(v)))
+			} else {
+				u.push(int64(v))
+			}
+		case '\x8a', '\x8b': // LONG1, LONG4
+			size := 1
+			if op == '\x8b' {
+				size = 4
+			}
+			n, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			buf, err := u.readN(n)
+			if err != nil {
+				return nil, err
+			}
+			u.push(decodeLong(buf))
+		case 'I', 'L': // INT, LONG
+			line, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			line = strings.TrimSuffix(line, "L")
+			switch line {
+			case "00":
+				u.push(false)
+			case "01":
+				u.push(true)
+			default:
+				v, err := strconv.ParseInt(line, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				u.push(v)
+			}
+		case 'G': // BINFLOAT
+			buf, err := u.readN(8)
+			if err != nil {
+				return nil, err
+			}
+			u.push(math.Float64frombits(binary.BigEndian.Uint64(buf)))
+		case 'F': // FLOAT
+			line, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			v, err := strconv.ParseFloat(line, 64)
+			if err != nil {
+				return nil, err
+			}
+			u.push(v)
+		case '\x8c', 'X', '\x8d': // SHORT_BINUNICODE, BINUNICODE, BINUNICODE8
+			size := map[byte]int{'\x8c': 1, 'X': 4, '\x8d': 8}[op]
+			n, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			buf, err := u.readN(n)
+			if err != nil {
+				return nil, err
+			}
+			u.push(string(buf))
+		case 'C', 'B', '\x8e', 'U', 'T': // SHORT_BINBYTES, BINBYTES, BINBYTES8, SHORT_BINSTRING, BINSTRING
+			size := map[byte]int{'C': 1, 'B': 4, '\x8e': 8, 'U': 1, 'T': 4}[op]
+			n, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			buf, err := u.readN(n)
+			if err != nil {
+				return nil, err
+			}
+			if op == 'U' || op == 'T' {
+				// Python 2 str, mostly used for names.
+				u.push(string(buf))
+			} else {
+				u.push(buf)
+			}
+		case 'V': // UNICODE
+			line, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			u.push(line)
+		case 'S': // STRING
+			line, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				s = strings.Trim(line, `'"`)
+			}
+			u.push(s)
+		case ')': // EMPTY_TUPLE
+			u.push(pyTuple{})
+		case '\x85', '\x86', '\x87': // TUPLE1, TUPLE2, TUPLE3
+			n := int(op-'\x85') + 1
+			if len(u.stack) < n {
+				return nil, errors.New("pickle: stack underflow")
+			}
+			t := make(pyTuple, n)
+			copy(t, u.stack[len(u.stack)-n:])
+			u.stack = u.stack[:len(u.stack)-n]
+			u.push(t)
+		case 't': // TUPLE
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(pyTuple(items))
+		case ']': // EMPTY_LIST
+			u.push(&pyList{})
+		case 'l': // LIST
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(&pyList{items: items})
+		case 'a': // APPEND
+			v, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			if err := u.appendItems([]interface{}{v}); err != nil {
+				return nil, err
+			}
+		case 'e': // APPENDS
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			if err := u.appendItems(items); err != nil {
+				return nil, err
+			}
+		case '}': // EMPTY_DICT
+			u.push(&pyDict{})
+		case 'd': // DICT
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			d := &pyDict{}
+			for i := 0; i+1 < len(items); i += 2 {
+				d.set(items[i], items[i+1])
+			}
+			u.push(d)
+		case 's', 'u': // SETITEM, SETITEMS
+			var items []interface{}
+			if op == 's' {
+				value, err := u.pop()
+				if err != nil {
+					return nil, err
+				}
+				key, err := u.pop()
+				if err != nil {
+					return nil, err
+				}
+				items = []interface{}{key, value}
+			} else if items, err = u.popMark(); err != nil {
+				return nil, err
+			}
+			top, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			d, ok := top.(*pyDict)
+			if !ok {
+				return nil, fmt.Errorf("pickle: cannot set items of %T", top)
+			}
+			for i := 0; i+1 < len(items); i += 2 {
+				d.set(items[i], items[i+1])
+			}
+		case '\x8f': // EMPTY_SET
+			u.push(&pyList{})
+		case '\x90': // ADDITEMS
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			if err := u.appendItems(items); err != nil {
+				return nil, err
+			}
+		case '\x91': // FROZENSET
+			items, err := u.popMark()
+			if err != nil {
+				return nil, err
+			}
+			u.push(&pyList{items: items})
+		case '\x94': // MEMOIZE
+			v, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			u.memo[len(u.memo)] = v
+		case 'q', 'r': // BINPUT, LONG_BINPUT
+			size := 1
+			if op == 'r' {
+				size = 4
+			}
+			i, err := u.readUint(size)
+			if err != nil {
+				return nil, err
+			}
+			v, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			u.memo[int(i)] = v
+		case 'p': // PUT
+			line, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			i, err := strconv.Atoi(line)
+			if err != nil {
+				return nil, err
+			}
+			v, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			u.memo[i] = v
+		case 'h', 'j', 'g': // BINGET, LONG_BINGET, GET
+			var i int
+			switch op {
+			case 'g':
+				line, err := u.readLine()
+				if err != nil {
+					return nil, err
+				}
+				if i, err = strconv.Atoi(line); err != nil {
+					return nil, err
+				}
+			default:
+				size := 1
+				if op == 'j' {
+					size = 4
+				}
+				n, err := u.readUint(size)
+				if err != nil {
+					return nil, err
+				}
+				i = int(n)
+			}
+			v, ok := u.memo[i]
+			if !ok {
+				return nil, fmt.Errorf("pickle: memo key %d not found", i)
+			}
+			u.push(v)
+		case 'c': // GLOBAL
+			module, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			name, err := u.readLine()
+			if err != nil {
+				return nil, err
+			}
+			u.push(pyGlobal{module: module, name: name})
+		case '\x93': // STACK_GLOBAL
+			name, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			module, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			ms, ok1 := module.(string)
+			ns, ok2 := name.(string)
+			if !ok1 || !ok2 {
+				return nil, errors.New("pickle: malformed STACK_GLOBAL")
+			}
+			u.push(pyGlobal{module: ms, name: ns})
+		case 'R', '\x81': // REDUCE, NEWOBJ
+			args, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			class, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			t, _ := args.(pyTuple)
+			u.push(&pyObject{class: class, args: t})
+		case '\x92': // NEWOBJ_EX
+			if _, err := u.pop(); err != nil { // kwargs
+				return nil, err
+			}
+			args, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			class, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			t, _ := args.(pyTuple)
+			u.push(&pyObject{class: class, args: t})
+		case 'b': // BUILD
+			state, err := u.pop()
+			if err != nil {
+				return nil, err
+			}
+			top, err := u.top()
+			if err != nil {
+				return nil, err
+			}
+			if o, ok := top.(*pyObject); ok {
+				o.state = state
+			}
+		default:
+			return nil, fmt.Errorf("pickle: unsupported opcode 0x%02x", op)
+		}
+	}
+}
+
+func (u *unpickler) appendItems(items []interface{}) error {
+	top, err := u.top()
+	if err != nil {
+		return err
+	}
+	l, ok := top.(*pyList)
+	if !ok {
+		return fmt.Errorf("pickle: cannot append to %T", top)
+	}
+	l.items = append(l.items, items...)
+	return nil
+}
+
+// decodeLong decodes a little-endian two's complement integer.
+func decodeLong(buf []byte) interface{} {
+	if len(buf) == 0 {
+		return int64(0)
+	}
+	be := make([]byte, len(buf))
+	for i, b := range buf {
+		be[len(buf)-1-i] = b
+	}
+	n := new(big.Int).SetBytes(be)
+	if buf[len(buf)-1]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(buf))))
+	}
+	if n.IsInt64() {
+		return n.Int64()
+	}
+	return n
+}
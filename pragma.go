@@ -0,0 +1,95 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"time"
+)
+
+// WithWAL sets the journal mode of the database file to write-ahead
+// logging, so that readers do not block each other nor the writer. The
+// mode is recorded in the file and stays on for later sessions.
+func WithWAL() Option {
+	return WithPragma("journal_mode", "WAL")
+}
+
+// WithMmapSize lets SQLite memory-map up to size bytes of the database
+// file, which makes look-ups of on-disk databases nearly as fast as in
+// memory when the file fits.
+func WithMmapSize(size int64) Option {
+	return WithPragma("mmap_size", fmt.Sprint(size))
+}
+
+// WithPageCacheSize sets the size of the page cache of each connection
+// to about kb kilobytes. It is not the LRU cache of embeddings of
+// WithCache.
+func WithPageCacheSize(kb int) Option {
+	return WithPragma("cache_size", fmt.Sprint(-kb))
+}
+
+// WithBusyTimeout makes queries wait up to d for the locks held by other
+// connections instead of failing with SQLITE_BUSY.
+func WithBusyTimeout(d time.Duration) Option {
+	return WithPragma("busy_timeout", fmt.Sprint(d.Milliseconds()))
+}
+
+// WithPragma sets an SQLite pragma on each connection to the database,
+// e.g. WithPragma("synchronous", "OFF"). The pragmas are not set on the
+// database of NewFastTextFromDB.
+func WithPragma(name, value string) Option {
+	return func(ft *FastText) {
+		ft.pragmas = append(ft.pragmas, fmt.Sprintf("PRAGMA %s = %s;", name, value))
+	}
+}
+
+// execPragmas sets the pragmas on a new connection.
+func execPragmas(conn driver.Conn, pragmas []string) error {
+	for _, pragma := range pragmas {
+		if execer, ok := conn.(driver.ExecerContext); ok {
+			_, err := execer.ExecContext(context.Background(), pragma, nil)
+			if err != driver.ErrSkip {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		stmt, err := conn.Prepare(pragma)
+		if err != nil {
+			return err
+		}
+		_, err = stmt.Exec(nil)
+		stmt.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pragmaConnector opens connections with a database/sql driver, setting
+// the pragmas of the session on each.
+type pragmaConnector struct {
+	src     *dbSource
+	driver  driver.Driver
+	pragmas []string
+}
+
+func (c *pragmaConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.src.open(func(dsn string) (driver.Conn, error) {
+		conn, err := c.driver.Open(dsn)
+		if err != nil {
+			return nil, err
+		}
+		if err := execPragmas(conn, c.pragmas); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return conn, nil
+	})
+}
+
+func (c *pragmaConnector) Driver() driver.Driver {
+	return c.driver
+}
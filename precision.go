@@ -0,0 +1,132 @@
+package fasttext
+
+import (
+	"fmt"
+	"math"
+)
+
+// Precision is the floating point format the embedding vectors are
+// stored in.
+type Precision int
+
+const (
+	// Float32 stores 4-byte single precision values, the precision of
+	// the .vec files. This is the default.
+	Float32 Precision = iota
+	// Float16 stores 2-byte half precision values, halving the size of
+	// the database at the cost of about 3 significant digits.
+	Float16
+	// Float64 stores 8-byte double precision values.
+	Float64
+	// Int8 stores 1-byte integers scaled by a float32 factor per
+	// vector, about a quarter of the size of Float32. The error of each
+	// value is at most 0.4% of the largest absolute value of its vector,
+	// which changes cosine similarities by about 0.001.
+	Int8
+)
+
+var precisionNames = map[Precision]string{
+	Float32: "float32",
+	Float16: "float16",
+	Float64: "float64",
+	Int8:    "int8",
+}
+
+func (p Precision) String() string {
+	if name, ok := precisionNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("Precision(%d)", int(p))
+}
+
+// width returns the number of bytes per value.
+func (p Precision) width() int {
+	switch p {
+	case Float16:
+		return 2
+	case Float64:
+		return 8
+	case Int8:
+		return 1
+	}
+	return 4
+}
+
+// ParsePrecision returns the precision with the given name, as
+// returned by Precision.String.
+func ParsePrecision(name string) (Precision, error) {
+	for p, n := range precisionNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("fasttext: unknown precision %q", name)
+}
+
+// WithPrecision sets the precision of the stored vectors, Float32 by
+// default. The precision is recorded in the metadata table and look-ups
+// decode the vectors accordingly.
+func WithPrecision(p Precision) BuildOption {
+	return func(c *buildConfig) {
+		c.codec.Precision = p
+	}
+}
+
+// float32ToFloat16 converts to IEEE 754 half precision, rounding to
+// the nearest even value.
+func float32ToFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int((b>>23)&0xff) - 127 + 15
+	mant := b & 0x7fffff
+	switch {
+	case (b>>23)&0xff == 0xff:
+		// Infinity or NaN.
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+		return sign | 0x7c00
+	case exp >= 0x1f:
+		// Overflow to infinity.
+		return sign | 0x7c00
+	case exp <= 0:
+		// Subnormal half, or underflow to zero.
+		if exp < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint(14 - exp)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		mid := uint32(1) << (shift - 1)
+		if rem > mid || (rem == mid && half&1 == 1) {
+			half++
+		}
+		return sign | uint16(half)
+	}
+	half := uint32(exp)<<10 | mant>>13
+	rem := mant & 0x1fff
+	// A carry into the exponent is the correct rounding.
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++
+	}
+	return sign | uint16(half)
+}
+
+// float16ToFloat32 converts from IEEE 754 half precision.
+func float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+	switch exp {
+	case 0:
+		f := float32(mant) / (1 << 24)
+		if sign != 0 {
+			f = -f
+		}
+		return f
+	case 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	}
+	return math.Float32frombits(sign | (exp-15+127)<<23 | mant<<13)
+}
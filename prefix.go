@@ -0,0 +1,57 @@
+package fasttext
+
+// WordsWithPrefix returns up to limit words of the vocabulary starting
+// with the prefix, in byte order, e.g. for autocompletion. The search
+// is a range scan of the word index.
+func (ft *FastText) WordsWithPrefix(prefix string, limit int) ([]string, error) {
+	prefix = ft.normalize(prefix)
+	if end, ok := prefixEnd(prefix); ok {
+		return ft.queryWords(ft.sql(`SELECT word FROM fasttext WHERE word >= ? AND word < ?
+			ORDER BY word LIMIT ?;`), prefix, end, limit)
+	}
+	return ft.queryWords(ft.sql(`SELECT word FROM fasttext WHERE word >= ?
+		ORDER BY word LIMIT ?;`), prefix, limit)
+}
+
+// WordsMatching returns up to limit words of the vocabulary matching
+// the glob pattern, in byte order, e.g. "walk*" or "colo?r". The pattern
+// follows the case-sensitive GLOB operator of SQLite: * matches any
+// sequence of characters, ? any single character and [...] a set of
+// characters. Patterns starting with a literal prefix only scan the
+// range of the word index with that prefix.
+func (ft *FastText) WordsMatching(glob string, limit int) ([]string, error) {
+	return ft.queryWords(ft.sql(`SELECT word FROM fasttext WHERE word GLOB ?
+		ORDER BY word LIMIT ?;`), ft.normalize(glob), limit)
+}
+
+// prefixEnd returns the smallest string greater than all the strings
+// with the prefix, if there is one.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for len(end) > 0 && end[len(end)-1] == 0xff {
+		end = end[:len(end)-1]
+	}
+	if len(end) == 0 {
+		return "", false
+	}
+	end[len(end)-1]++
+	return string(end), true
+}
+
+// queryWords returns the words selected by the query.
+func (ft *FastText) queryWords(query string, args ...interface{}) ([]string, error) {
+	rows, err := ft.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var words []string
+	for rows.Next() {
+		var word string
+		if err := rows.Scan(&word); err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+	}
+	return words, rows.Err()
+}
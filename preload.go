@@ -0,0 +1,146 @@
+package fasttext
+
+import (
+	"context"
+	"sync"
+)
+
+// preloadState holds the embeddings loaded in memory by the preload
+// options, in front of the database.
+type preloadState struct {
+	top    int
+	words  []string
+	budget int64
+
+	mu     sync.RWMutex
+	loaded bool
+	embs   map[string][]float32
+}
+
+func (ft *FastText) preloadOptions() *preloadState {
+	if ft.preload == nil {
+		ft.preload = &preloadState{}
+	}
+	return ft.preload
+}
+
+// WithPreload keeps the embeddings of the n most frequent words of the
+// vocabulary (see GetRank) in memory, and looks up the others in the
+// database. This is a middle ground between NewFastText and
+// NewFastTextInMem, which copies the whole table. The words are loaded
+// on the first look-up.
+func WithPreload(n int) Option {
+	return func(ft *FastText) {
+		ft.preloadOptions().top = n
+	}
+}
+
+// WithPreloadWords keeps the embeddings of the given words in memory,
+// along with those of WithPreload.
+func WithPreloadWords(words ...string) Option {
+	return func(ft *FastText) {
+		p := ft.preloadOptions()
+		p.words = append(p.words, words...)
+	}
+}
+
+// WithPreloadBudget bounds the memory used by the preloaded embeddings
+// to about mb megabytes: loading stops when the budget is reached,
+// the words of WithPreloadWords first.
+func WithPreloadBudget(mb int) Option {
+	return func(ft *FastText) {
+		ft.preloadOptions().budget = int64(mb) << 20
+	}
+}
+
+// preloadOverhead approximates the memory used per word besides its
+// vector: the map entry, the word and the slice header.
+const preloadOverhead = 64
+
+// preloaded returns the preloaded embedding of the word, if any.
+func (ft *FastText) preloaded(word string) ([]float32, bool, error) {
+	p := ft.preload
+	if p == nil {
+		return nil, false, nil
+	}
+	p.mu.RLock()
+	loaded := p.loaded
+	vec, ok := p.embs[word]
+	p.mu.RUnlock()
+	if !loaded {
+		// Load under the write lock, retrying on the next look-up
+		// after an error, e.g. before the database is built.
+		p.mu.Lock()
+		if !p.loaded {
+			if err := ft.loadPreload(); err != nil {
+				p.mu.Unlock()
+				return nil, false, err
+			}
+			p.loaded = true
+		}
+		vec, ok = p.embs[word]
+		p.mu.Unlock()
+	}
+	if !ok {
+		return nil, false, nil
+	}
+	return copyVec(vec), true, nil
+}
+
+// loadPreload loads the preloaded embeddings, with the lock held.
+func (ft *FastText) loadPreload() error {
+	p := ft.preload
+	p.embs = make(map[string][]float32)
+	var used int64
+	full := false
+	add := func(word string, vec []float32) {
+		size := int64(4*len(vec)+len(word)) + preloadOverhead
+		if p.budget > 0 && used+size > p.budget {
+			full = true
+			return
+		}
+		used += size
+		p.embs[word] = vec
+	}
+	for start := 0; start < len(p.words) && !full; start += maxBatchVars {
+		batch := p.words[start:minInt(start+maxBatchVars, len(p.words))]
+		if err := ft.lookupBatch(context.Background(), batch, add); err != nil {
+			return err
+		}
+	}
+	if p.top <= 0 || full {
+		return nil
+	}
+	it, err := ft.IterTop(p.top)
+	if err != nil {
+		return err
+	}
+	rows := it.rows
+	return ft.scanEmbs(rows, func(word string, vec []float32) {
+		if !full {
+			add(word, vec)
+		}
+	})
+}
+
+// PreloadedLen returns the number of embeddings preloaded in memory,
+// loading them if needed.
+func (ft *FastText) PreloadedLen() (int, error) {
+	if _, _, err := ft.preloaded(""); err != nil {
+		return 0, err
+	}
+	if ft.preload == nil {
+		return 0, nil
+	}
+	ft.preload.mu.RLock()
+	defer ft.preload.mu.RUnlock()
+	return len(ft.preload.embs), nil
+}
+
+// forget drops the preloaded embedding of the word, once it changed in
+// the database.
+func (p *preloadState) forget(word string) {
+	p.mu.Lock()
+	delete(p.embs, word)
+	p.mu.Unlock()
+}
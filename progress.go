@@ -0,0 +1,92 @@
+package fasttext
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// DefaultProgressInterval is the number of inserted words between two
+// calls of the progress callback, unless set with WithProgressInterval.
+const DefaultProgressInterval = 10000
+
+// ProgressFunc is called during a build with the number of words
+// inserted so far and the number of bytes read from the input so far.
+// The bytes are counted before decompression, so they can be compared
+// with the input file size for an ETA. Builds from files other than
+// text embeddings report 0 bytes.
+type ProgressFunc func(wordsInserted int64, bytesRead int64)
+
+type progress struct {
+	fn       ProgressFunc
+	interval int64
+	words    int64
+	bytes    int64
+}
+
+// WithProgress sets a callback reporting the progress of the build,
+// called every DefaultProgressInterval inserted words and once at the
+// end.
+func WithProgress(fn ProgressFunc) BuildOption {
+	return func(cfg *buildConfig) {
+		if cfg.progress == nil {
+			cfg.progress = &progress{interval: DefaultProgressInterval}
+		}
+		cfg.progress.fn = fn
+	}
+}
+
+// WithProgressInterval sets the number of inserted words between two
+// calls of the progress callback.
+func WithProgressInterval(n int64) BuildOption {
+	return func(cfg *buildConfig) {
+		if cfg.progress == nil {
+			cfg.progress = &progress{interval: DefaultProgressInterval}
+		}
+		if n > 0 {
+			cfg.progress.interval = n
+		}
+	}
+}
+
+// BuildDBWithProgress is BuildDB reporting its progress to fn.
+func (ft *FastText) BuildDBWithProgress(r io.Reader, fn ProgressFunc, opts ...BuildOption) error {
+	return ft.BuildDB(r, append(opts, WithProgress(fn))...)
+}
+
+// reader wraps r to count the bytes read from it.
+func (p *progress) reader(r io.Reader) io.Reader {
+	if p == nil || p.fn == nil {
+		return r
+	}
+	return &countingReader{r: r, n: &p.bytes}
+}
+
+// inserted records an inserted word.
+func (p *progress) inserted() {
+	if p == nil || p.fn == nil {
+		return
+	}
+	p.words++
+	if p.words%p.interval == 0 {
+		p.fn(p.words, atomic.LoadInt64(&p.bytes))
+	}
+}
+
+// done reports the final count, unless it was just reported.
+func (p *progress) done() {
+	if p == nil || p.fn == nil || (p.words > 0 && p.words%p.interval == 0) {
+		return
+	}
+	p.fn(p.words, atomic.LoadInt64(&p.bytes))
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,176 @@
+package fasttext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrNoProjection is returned by the projected look-ups of a session
+// without WithProjection.
+var ErrNoProjection = errors.New("fasttext: no projection, see WithProjection")
+
+// linearProjection maps the stored vectors to another vector space.
+type linearProjection struct {
+	// w holds the matrix by rows, one per dimension of the target space.
+	w [][]float32
+	// orthogonal is set if w is a rotation, preserving the cosine
+	// similarities.
+	orthogonal bool
+}
+
+// WithProjection registers a linear map W from the space of the stored
+// vectors to another one, e.g. the alignment matrix learned by MUSE
+// (https://github.com/facebookresearch/MUSE) between two languages or
+// two models, for GetEmbProjected and NearestByVectorProjected. The
+// rows of w are the dimensions of the target space and its columns
+// those of the stored vectors. The database is unchanged: the
+// projection is applied at query time.
+func WithProjection(w [][]float32) Option {
+	return func(ft *FastText) {
+		ft.projection = &linearProjection{w: w, orthogonal: isOrthogonal(w)}
+	}
+}
+
+// ReadProjection reads a projection matrix from a .npy file, e.g. saved
+// from the W of MUSE with numpy.save("W.npy", W), for WithProjection.
+func ReadProjection(r io.Reader) ([][]float32, error) {
+	a, err := readNpyHeader(r)
+	if err != nil {
+		return nil, err
+	}
+	w := make([][]float32, a.rows)
+	for i := range w {
+		if w[i], err = a.readRow(); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// isOrthogonal returns whether the square matrix satisfies WᵀW = I, up
+// to rounding.
+func isOrthogonal(w [][]float32) bool {
+	for _, row := range w {
+		if len(row) != len(w) {
+			return false
+		}
+	}
+	for i := range w {
+		for j := i; j < len(w); j++ {
+			var sum float64
+			for k := range w {
+				sum += float64(w[k][i]) * float64(w[k][j])
+			}
+			expected := 0.0
+			if i == j {
+				expected = 1
+			}
+			if math.Abs(sum-expected) > 1e-4 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// project returns W·v.
+func (p *linearProjection) project(vec []float32) []float32 {
+	out := make([]float32, len(p.w))
+	for i, row := range p.w {
+		out[i] = float32(dot(row, vec))
+	}
+	return out
+}
+
+// transpose returns Wᵀ·v.
+func (p *linearProjection) transpose(vec []float32) []float32 {
+	out := make([]float32, len(p.w[0]))
+	for i, row := range p.w {
+		axpy(vec[i], row, out)
+	}
+	return out
+}
+
+// checkProjection returns the projection of the session, checking it
+// applies to the stored vectors.
+func (ft *FastText) checkProjection() (*linearProjection, error) {
+	p := ft.projection
+	if p == nil {
+		return nil, ErrNoProjection
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	if len(p.w) == 0 {
+		return nil, errors.New("fasttext: empty projection matrix")
+	}
+	for _, row := range p.w {
+		if len(row) != len(p.w[0]) {
+			return nil, errors.New("fasttext: malformed projection matrix")
+		}
+	}
+	if f.dim != 0 && len(p.w[0]) != f.dim {
+		return nil, fmt.Errorf("fasttext: projection of %d dimensions, expected %d", len(p.w[0]), f.dim)
+	}
+	return p, nil
+}
+
+// GetEmbProjected returns the embedding of the word mapped to the
+// target space of WithProjection.
+func (ft *FastText) GetEmbProjected(word string) ([]float32, error) {
+	p, err := ft.checkProjection()
+	if err != nil {
+		return nil, err
+	}
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	return p.project(vec), nil
+}
+
+// NearestByVectorProjected returns the k words whose embeddings, mapped
+// to the target space of WithProjection, are most similar to the given
+// vector of the target space by cosine similarity, in descending order
+// of similarity, e.g. the translations of a word of the other language.
+// An orthogonal projection, as learned by MUSE, is applied to the query
+// instead, at the cost of a NearestByVector; others project every stored
+// vector.
+func (ft *FastText) NearestByVectorProjected(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	p, err := ft.checkProjection()
+	if err != nil {
+		return nil, err
+	}
+	if len(vec) != len(p.w) {
+		return nil, fmt.Errorf("fasttext: vector of dimension %d, expected %d", len(vec), len(p.w))
+	}
+	keep := keepWith(ft.excludeWords(), opts)
+	if p.orthogonal {
+		// cos(Wv, q) = cos(v, Wᵀq) for a rotation W.
+		return ft.nearest(context.Background(), p.transpose(vec), k, keep, nil)
+	}
+	if k <= 0 {
+		return nil, nil
+	}
+	qnorm := l2norm(vec)
+	top := NewTopK(k)
+	err = ft.ForEach(func(word string, emb []float32) error {
+		proj := p.project(emb)
+		var score float64
+		if pnorm := l2norm(proj); qnorm != 0 && pnorm != 0 {
+			score = dot(vec, proj) / (qnorm * pnorm)
+		}
+		if keep(word, score) {
+			top.Push(ScoredWord{Word: word, Score: score})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return top.Sorted(), nil
+}
@@ -0,0 +1,137 @@
+package fasttext
+
+import (
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The .vec files list the words by decreasing corpus frequency, so
+// BuildDB records the position of each word as its rank, 1 for the most
+// frequent word, in the rank column. Words added after the build have
+// no rank. Databases built before ranks were recorded use the insertion
+// order instead.
+
+// rankColumn returns the column holding the ranks.
+func (ft *FastText) rankColumn() (string, error) {
+	ft.formatMu.Lock()
+	defer ft.formatMu.Unlock()
+	if ft.rankCol != "" {
+		return ft.rankCol, nil
+	}
+	var n int
+	err := ft.db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = 'rank';`,
+		ft.table).Scan(&n)
+	if err != nil {
+		return "", err
+	}
+	ft.rankCol = "rowid"
+	if n > 0 {
+		ft.rankCol = "rank"
+	}
+	return ft.rankCol, nil
+}
+
+// rankSQL rewrites a query on the rank column for the column of the
+// database.
+func (ft *FastText) rankSQL(query string) (string, error) {
+	col, err := ft.rankColumn()
+	if err != nil {
+		return "", err
+	}
+	return ft.sql(strings.Replace(query, "rank", col, -1)), nil
+}
+
+// GetRank returns the frequency rank of the word, 1 for the most
+// frequent word, or 0 if the word has no rank.
+func (ft *FastText) GetRank(word string) (int, error) {
+	query, err := ft.rankSQL(`SELECT rank FROM fasttext WHERE word=?;`)
+	if err != nil {
+		return 0, err
+	}
+	var rank sql.NullInt64
+	err = ft.db.QueryRow(query, ft.normalize(word)).Scan(&rank)
+	if err == sql.ErrNoRows {
+		return 0, &WordNotFoundError{Word: word}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int(rank.Int64), nil
+}
+
+// GetFreq returns the corpus frequency of the word given at build time
+// by WithFrequencies, or 0 if unknown.
+func (ft *FastText) GetFreq(word string) (int64, error) {
+	var freq sql.NullInt64
+	err := ft.db.QueryRow(ft.sql(`SELECT freq FROM fasttext WHERE word=?;`), ft.normalize(word)).Scan(&freq)
+	if err == sql.ErrNoRows {
+		return 0, &WordNotFoundError{Word: word}
+	}
+	if err != nil {
+		if isNoSuchColumn(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return freq.Int64, nil
+}
+
+// TopKWords returns the n most frequent words, by increasing rank.
+func (ft *FastText) TopKWords(n int) ([]string, error) {
+	query, err := ft.rankSQL(`SELECT word FROM fasttext WHERE rank IS NOT NULL ORDER BY rank LIMIT ?;`)
+	if err != nil {
+		return nil, err
+	}
+	return ft.queryWords(query, n)
+}
+
+// IterTop returns an iterator over the n most frequent words, by
+// increasing rank, e.g. to restrict a pipeline to the common vocabulary.
+func (ft *FastText) IterTop(n int) (*EmbIterator, error) {
+	query, err := ft.rankSQL(`SELECT word, emb FROM fasttext WHERE rank IS NOT NULL ORDER BY rank LIMIT ?;`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ft.db.Query(query, n)
+	if err != nil {
+		return nil, err
+	}
+	return &EmbIterator{ft: ft, rows: rows}, nil
+}
+
+// WithFrequencies records the corpus frequencies of the words in the
+// freq column at build time, for GetFreq. The reader lists a word and
+// its count per line, separated by white space, e.g. the output of
+// fasttext dump model dict. Words missing from it have no frequency.
+func WithFrequencies(r io.Reader) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.freqs = r
+	}
+}
+
+// readFrequencies parses the word counts of WithFrequencies.
+func (ft *FastText) readFrequencies(r io.Reader) (map[string]int64, error) {
+	freqs := make(map[string]int64)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("fasttext: frequencies line %d: expected a word and a count", line)
+		}
+		count, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("fasttext: frequencies line %d: %w", line, err)
+		}
+		freqs[ft.normalize(fields[0])] += count
+	}
+	return freqs, scanner.Err()
+}
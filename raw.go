@@ -0,0 +1,47 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// GetEmbRaw returns the stored blob of the word embedding of the given
+// word, as encoded by the codec of the database and compressed if it is,
+// so that a service forwarding vectors in binary skips decoding and
+// re-encoding them. The blob is always read from the database,
+// bypassing the cache, the preloaded embeddings and the OOV policy. It
+// is decoded by DecodeVec, or for uncompressed databases by the Codec
+// of the database.
+func (ft *FastText) GetEmbRaw(word string) ([]byte, error) {
+	start := time.Now()
+	word = ft.normalize(word)
+	var data []byte
+	ctx, cancel := ft.queryContext(context.Background())
+	defer cancel()
+	stmt, err := ft.getEmbStmt()
+	if err == nil {
+		err = ft.timedOut(ctx, stmt.QueryRowContext(ctx, word).Scan(&data))
+	}
+	if err == sql.ErrNoRows {
+		err = &WordNotFoundError{Word: word}
+	}
+	if ft.observing() {
+		ft.observeLookup(start, word, SourceDatabase, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// DecodeVec decodes a blob returned by GetEmbRaw into dst, which must
+// have the dimension of the vectors, without allocating a new vector
+// except for compressed blobs.
+func (ft *FastText) DecodeVec(data []byte, dst []float32) error {
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	return f.decodeInto(dst, data)
+}
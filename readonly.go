@@ -0,0 +1,40 @@
+package fasttext
+
+import (
+	"strings"
+)
+
+// WithReadOnly opens the database file read-only, so accidental writes
+// fail. SQLite still takes locks to read, so other processes can
+// write to the file.
+func WithReadOnly() Option {
+	return func(ft *FastText) {
+		ft.readOnly = true
+	}
+}
+
+// WithImmutable opens the database file read-only and tells SQLite that
+// it cannot change, so it takes no locks at all: several processes can
+// share one file on a read-only volume, e.g. a container image layer.
+// Nothing may write to the file while it is open.
+func WithImmutable() Option {
+	return func(ft *FastText) {
+		ft.readOnly = true
+		ft.immutable = true
+	}
+}
+
+// fileDSN returns the URI opening the file with the access mode of the
+// session.
+func (ft *FastText) fileDSN(path string) string {
+	if !ft.readOnly {
+		return path
+	}
+	// Escape the characters that have a meaning in URIs.
+	path = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23").Replace(path)
+	dsn := "file:" + path + "?mode=ro"
+	if ft.immutable {
+		dsn += "&immutable=1"
+	}
+	return dsn
+}
@@ -0,0 +1,275 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// dbSource is the database opened by the connections of a session,
+// which Reload switches.
+type dbSource struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// path is the database file, empty for in-memory databases.
+	path string
+	dsn  string
+	// gen counts the switches: the connections opened before the last
+	// one are retired.
+	gen int64
+	// conns counts the open connections by generation.
+	conns map[int64]int
+	// replicas are the copies of the database the connections are
+	// spread across, the original first, if WithReplicas is set.
+	replicas []*replica
+	// next is the copy the next connection opens first.
+	next int
+}
+
+func newDBSource(path, dsn string) *dbSource {
+	s := &dbSource{path: path, dsn: dsn, conns: make(map[int64]int)}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// dbPath returns the database file of the session, empty for in-memory
+// databases.
+func (ft *FastText) dbPath() string {
+	ft.src.mu.Lock()
+	defer ft.src.mu.Unlock()
+	return ft.src.path
+}
+
+// open opens a connection to the current database with open.
+func (s *dbSource) open(open func(dsn string) (driver.Conn, error)) (driver.Conn, error) {
+	s.mu.Lock()
+	dsn, gen := s.dsn, s.gen
+	var replicas []*replica
+	if len(s.replicas) > 0 {
+		replicas = s.pickReplicas()
+	}
+	s.mu.Unlock()
+	if replicas == nil {
+		replicas = []*replica{{dsn: dsn}}
+	}
+	var err error
+	for _, r := range replicas {
+		var conn driver.Conn
+		if conn, err = open(r.dsn); err != nil {
+			if len(replicas) > 1 {
+				s.fail(r, err)
+			}
+			continue
+		}
+		c := &sourceConn{Conn: conn, src: s, gen: gen}
+		s.mu.Lock()
+		s.conns[gen]++
+		if len(s.replicas) > 0 {
+			c.rep = r
+			r.conns++
+			// A copy that failed is tried again once it opens.
+			r.failedAt = time.Time{}
+		}
+		s.mu.Unlock()
+		return c, nil
+	}
+	return nil, err
+}
+
+// drain waits until the connections of the generations before gen are
+// closed.
+func (s *dbSource) drain(gen int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		old := 0
+		for g, n := range s.conns {
+			if g < gen {
+				old += n
+			}
+		}
+		if old == 0 {
+			return
+		}
+		s.cond.Wait()
+	}
+}
+
+// sourceConn is a connection of a session, which database/sql stops
+// reusing once Reload switched the database.
+type sourceConn struct {
+	driver.Conn
+	src *dbSource
+	gen int64
+	// rep is the copy of the database of the connection, if the session
+	// has replicas.
+	rep *replica
+}
+
+// IsValid implements driver.Validator.
+func (c *sourceConn) IsValid() bool {
+	c.src.mu.Lock()
+	defer c.src.mu.Unlock()
+	return c.gen == c.src.gen && (c.rep == nil || c.rep.failedAt.IsZero())
+}
+
+func (c *sourceConn) Close() error {
+	err := c.Conn.Close()
+	c.src.mu.Lock()
+	if c.src.conns[c.gen]--; c.src.conns[c.gen] <= 0 {
+		delete(c.src.conns, c.gen)
+	}
+	if c.rep != nil {
+		c.rep.conns--
+	}
+	c.src.cond.Broadcast()
+	c.src.mu.Unlock()
+	return err
+}
+
+// The optional interfaces of the driver connection are passed through.
+
+func (c *sourceConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil || c.rep == nil {
+		return stmt, c.failover(err)
+	}
+	return &replicaStmt{Stmt: stmt, c: c}, nil
+}
+
+func (c *sourceConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		tx, err := b.BeginTx(ctx, opts)
+		return tx, c.failover(err)
+	}
+	tx, err := c.Conn.Begin()
+	return tx, c.failover(err)
+}
+
+func (c *sourceConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != nil || c.rep == nil {
+		return rows, c.failover(err)
+	}
+	return &replicaRows{Rows: rows, c: c}, nil
+}
+
+func (c *sourceConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := c.Conn.(driver.ExecerContext); ok {
+		res, err := e.ExecContext(ctx, query, args)
+		return res, c.failover(err)
+	}
+	return nil, driver.ErrSkip
+}
+
+func (c *sourceConn) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func (c *sourceConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// Reload switches the session to another database file, e.g. a newly
+// built version of the model, without interrupting the service: the
+// queries in flight finish on the old database while the new ones run
+// on the new database. Reload returns once the old database is closed,
+// after the queries in flight and the open iterators on it are done,
+// and the caches of the session, e.g. WithCache and WithPreload, are
+// reset. The new database is checked before the switch and should
+// have the format of the old one, as queries running during the switch
+// may see either. The limit of idle connections of WithMaxIdleConns is
+// kept. The session must have been opened on a database file by
+// NewFastText.
+func (ft *FastText) Reload(newPath string) error {
+	ft.src.mu.Lock()
+	path, dsn := ft.src.path, ft.src.dsn
+	ft.src.mu.Unlock()
+	if ft.sharedDB || path == "" || dsn != ft.fileDSN(path) {
+		return errors.New("fasttext: Reload needs a session on a database file")
+	}
+	if len(ft.src.replicas) > 0 {
+		return errors.New("fasttext: cannot reload a session with replicas")
+	}
+	if ft.localCopy != nil {
+		return errors.New("fasttext: cannot reload a session on a local copy")
+	}
+	if _, err := os.Stat(newPath); err != nil {
+		return err
+	}
+	newDSN := ft.fileDSN(newPath)
+	if err := ft.checkDB(newDSN); err != nil {
+		return fmt.Errorf("fasttext: cannot reload %s: %w", newPath, err)
+	}
+	ft.src.mu.Lock()
+	ft.src.path, ft.src.dsn = newPath, newDSN
+	ft.src.gen++
+	gen := ft.src.gen
+	ft.src.mu.Unlock()
+	ft.forget()
+	// Close the idle connections to the old database now; the busy ones
+	// are closed when released.
+	ft.db.SetMaxIdleConns(0)
+	ft.db.SetMaxIdleConns(ft.maxIdleConns)
+	ft.src.drain(gen)
+	// Forget what the last queries on the old database cached.
+	ft.forget()
+	ft.logInfo("fasttext: reloaded", "path", newPath)
+	return nil
+}
+
+// defaultMaxIdleConns is the default of database/sql, see
+// WithMaxIdleConns.
+const defaultMaxIdleConns = 2
+
+// checkDB checks that the database of the dsn holds the model of the
+// session.
+func (ft *FastText) checkDB(dsn string) error {
+	db := sql.OpenDB(ft.connector(newDBSource("", dsn)))
+	defer db.Close()
+	var n int
+	return db.QueryRow(ft.sql(`SELECT COUNT(*) FROM (SELECT word, emb FROM fasttext LIMIT 1);`)).Scan(&n)
+}
+
+// forget resets what the session read from the database.
+func (ft *FastText) forget() {
+	ft.formatMu.Lock()
+	ft.format = nil
+	ft.rankCol = ""
+	ft.freqStats = nil
+	ft.negSampler = nil
+	ft.formatMu.Unlock()
+	ft.annMu.Lock()
+	ft.ann = nil
+	ft.annMu.Unlock()
+	if ft.cache != nil {
+		ft.cache.purge()
+	}
+	if p := ft.preload; p != nil {
+		p.mu.Lock()
+		p.loaded = false
+		p.embs = nil
+		p.mu.Unlock()
+	}
+}
@@ -0,0 +1,188 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// replicaRetry is how long a failed replica is left out before new
+// connections try it again.
+const replicaRetry = 10 * time.Second
+
+// WithReplicas spreads the reads of a session opened by NewFastText on
+// a database file across identical copies of the file, e.g. on other
+// disks or on a tmpfs such as /dev/shm for an in-memory copy, so a
+// read-heavy service is not bound by the throughput of one file. Each
+// connection of the pool opens the next copy in turn, the original file
+// included. A copy that fails to open or to read, e.g. missing or on a
+// failed disk, is left out of new connections for a while: the queries
+// are retried on the other copies, except for those that already
+// returned rows. The session is read-only, see WithReadOnly, and cannot
+// be reloaded; see Replicas for the status of the copies.
+func WithReplicas(paths ...string) Option {
+	return func(ft *FastText) {
+		ft.readOnly = true
+		ft.replicas = append(ft.replicas, paths...)
+	}
+}
+
+// ReplicaStatus is the status of a copy of the database of a session
+// with replicas.
+type ReplicaStatus struct {
+	Path string
+	// Conns is the number of open connections to the copy.
+	Conns int
+	// Healthy is false if the copy failed and is left out of new
+	// connections.
+	Healthy bool
+	// LastError is the last failure of the copy, if any.
+	LastError error
+}
+
+// Replicas returns the status of the copies of the database of a
+// session with WithReplicas, the original file first.
+func (ft *FastText) Replicas() []ReplicaStatus {
+	ft.src.mu.Lock()
+	defer ft.src.mu.Unlock()
+	var status []ReplicaStatus
+	for _, r := range ft.src.replicas {
+		status = append(status, ReplicaStatus{
+			Path:      r.path,
+			Conns:     r.conns,
+			Healthy:   r.healthy(),
+			LastError: r.lastErr,
+		})
+	}
+	return status
+}
+
+// replica is a copy of the database of a session.
+type replica struct {
+	path, dsn string
+	conns     int
+	// failedAt is when the copy last failed, zero if it works.
+	failedAt time.Time
+	lastErr  error
+}
+
+func (r *replica) healthy() bool {
+	return r.failedAt.IsZero() || time.Since(r.failedAt) >= replicaRetry
+}
+
+// addReplicas spreads the connections across the database and its
+// copies.
+func (ft *FastText) addReplicas() {
+	s := ft.src
+	s.replicas = []*replica{{path: s.path, dsn: s.dsn}}
+	for _, path := range ft.replicas {
+		s.replicas = append(s.replicas, &replica{path: path, dsn: ft.fileDSN(path)})
+	}
+}
+
+// pickReplicas returns the copies to try to open in order: the healthy
+// ones in turn, then the failed ones as a last resort. The caller must
+// hold the lock of the source.
+func (s *dbSource) pickReplicas() []*replica {
+	var healthy, failed []*replica
+	for i := range s.replicas {
+		r := s.replicas[(s.next+i)%len(s.replicas)]
+		if r.healthy() {
+			healthy = append(healthy, r)
+		} else {
+			failed = append(failed, r)
+		}
+	}
+	s.next = (s.next + 1) % len(s.replicas)
+	return append(healthy, failed...)
+}
+
+// fail leaves the copy out of new connections for a while.
+func (s *dbSource) fail(r *replica, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.failedAt = time.Now()
+	r.lastErr = err
+}
+
+// isFileError returns whether the error is a failure to open or read
+// the database file rather than of the query.
+func isFileError(err error) bool {
+	var e sqlite3.Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	switch e.Code {
+	case sqlite3.ErrIoErr, sqlite3.ErrCorrupt, sqlite3.ErrNotADB, sqlite3.ErrCantOpen:
+		return true
+	}
+	return false
+}
+
+// failover leaves the copy of the connection out if err is a failure
+// of its file, returning driver.ErrBadConn so that database/sql retries
+// the query on another connection.
+func (c *sourceConn) failover(err error) error {
+	if err == nil || c.rep == nil || len(c.src.replicas) < 2 || !isFileError(err) {
+		return err
+	}
+	c.src.fail(c.rep, err)
+	return driver.ErrBadConn
+}
+
+// replicaStmt is a statement prepared on a copy of the database.
+type replicaStmt struct {
+	driver.Stmt
+	c *sourceConn
+}
+
+func (s *replicaStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		rows, err = s.Stmt.Query(namedValues(args))
+	}
+	if err != nil {
+		return nil, s.c.failover(err)
+	}
+	return &replicaRows{Rows: rows, c: s.c}, nil
+}
+
+func (s *replicaStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err := e.ExecContext(ctx, args)
+		return res, s.c.failover(err)
+	}
+	res, err := s.Stmt.Exec(namedValues(args))
+	return res, s.c.failover(err)
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+// replicaRows are the rows of a query on a copy of the database. A
+// failure of the file while reading them fails the query, and leaves
+// the copy out of later ones.
+type replicaRows struct {
+	driver.Rows
+	c *sourceConn
+}
+
+func (r *replicaRows) Next(dest []driver.Value) error {
+	err := r.Rows.Next(dest)
+	if err != nil && err != io.EOF {
+		r.c.failover(err)
+	}
+	return err
+}
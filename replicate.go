@@ -0,0 +1,320 @@
+package fasttext
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+// DefaultSyncBuckets is the default number of buckets of a SyncManifest.
+const DefaultSyncBuckets = 4096
+
+// SyncManifest summarizes the content of a database as the hashes of
+// buckets of rows, so that two hosts can find which rows differ by
+// exchanging a few kilobytes. Manifests and deltas can be transferred
+// with encoding/gob or encoding/json.
+//
+// Replicating an updated database to an edge host goes:
+//
+//	// On the edge host.
+//	manifest, err := edge.SyncManifest(fasttext.DefaultSyncBuckets)
+//	// On the origin host, given the edge manifest.
+//	delta, err := origin.SyncDelta(manifest)
+//	// On the edge host, given the delta.
+//	err = edge.ApplySyncDelta(delta)
+type SyncManifest struct {
+	// Codec is the codec of the stored vectors, with their compression.
+	Codec string
+	// Buckets holds the hash of each bucket of rows.
+	Buckets []uint64
+}
+
+// SyncDelta holds the rows of the buckets that differ between two
+// databases.
+type SyncDelta struct {
+	Codec string
+	// NumBuckets is the number of buckets of the manifests compared.
+	NumBuckets int
+	// Changed lists the buckets to replace.
+	Changed []int
+	// Rows are the rows of the changed buckets.
+	Rows []SyncRow
+}
+
+// SyncRow is a row of a SyncDelta, with the ID, rank and frequency of
+// the word so that replicas keep them.
+type SyncRow struct {
+	ID   int64
+	Word string
+	Emb  []byte
+	// Rank and Freq are nil if the word has none.
+	Rank, Freq *int64
+}
+
+// SyncManifest computes the manifest of the database with the given
+// number of buckets. More buckets make the manifest larger and deltas
+// smaller.
+func (ft *FastText) SyncManifest(buckets int) (*SyncManifest, error) {
+	if buckets <= 0 {
+		buckets = DefaultSyncBuckets
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return nil, err
+	}
+	m := &SyncManifest{Codec: f.String(), Buckets: make([]uint64, buckets)}
+	err = ft.forEachSyncRow(func(r *SyncRow) error {
+		// Summing makes the bucket hash independent of row order.
+		m.Buckets[syncBucket(r.Word, buckets)] += r.hash()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// SyncDelta computes the rows to transfer to bring the database
+// described by the remote manifest up to date with this one.
+func (ft *FastText) SyncDelta(remote *SyncManifest) (*SyncDelta, error) {
+	if len(remote.Buckets) == 0 {
+		return nil, errors.New("fasttext: sync manifest without buckets")
+	}
+	local, err := ft.SyncManifest(len(remote.Buckets))
+	if err != nil {
+		return nil, err
+	}
+	if local.Codec != remote.Codec {
+		return nil, fmt.Errorf("fasttext: cannot sync %s database with %s database, copy it instead",
+			local.Codec, remote.Codec)
+	}
+	if len(local.Buckets) != len(remote.Buckets) {
+		return nil, fmt.Errorf("fasttext: cannot compare manifests of %d and %d buckets",
+			len(local.Buckets), len(remote.Buckets))
+	}
+	d := &SyncDelta{Codec: local.Codec, NumBuckets: len(local.Buckets)}
+	changed := make(map[int]bool)
+	for b := range local.Buckets {
+		if local.Buckets[b] != remote.Buckets[b] {
+			d.Changed = append(d.Changed, b)
+			changed[b] = true
+		}
+	}
+	if len(d.Changed) == 0 {
+		return d, nil
+	}
+	err = ft.forEachSyncRow(func(r *SyncRow) error {
+		if changed[syncBucket(r.Word, d.NumBuckets)] {
+			d.Rows = append(d.Rows, *r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// validate checks that the rows of the delta are distinct words of its
+// changed buckets, and returns the set of them.
+func (d *SyncDelta) validate() (map[int]bool, error) {
+	if d.NumBuckets <= 0 {
+		return nil, fmt.Errorf("fasttext: invalid sync delta of %d buckets", d.NumBuckets)
+	}
+	changed := make(map[int]bool, len(d.Changed))
+	for _, b := range d.Changed {
+		if b < 0 || b >= d.NumBuckets {
+			return nil, fmt.Errorf("fasttext: invalid bucket %d of sync delta of %d buckets", b, d.NumBuckets)
+		}
+		changed[b] = true
+	}
+	words := make(map[string]bool, len(d.Rows))
+	for _, r := range d.Rows {
+		if !changed[syncBucket(r.Word, d.NumBuckets)] {
+			return nil, fmt.Errorf("fasttext: row %q of sync delta is not in a changed bucket", r.Word)
+		}
+		if words[r.Word] {
+			return nil, fmt.Errorf("fasttext: duplicate row %q in sync delta", r.Word)
+		}
+		words[r.Word] = true
+	}
+	return changed, nil
+}
+
+// ApplySyncDelta brings the changed buckets of rows up to date with the
+// ones of the delta, in a single transaction: the rows that differ are
+// replaced, with the IDs, ranks and frequencies of the delta, and the
+// words missing from it are deleted along with their payloads.
+func (ft *FastText) ApplySyncDelta(d *SyncDelta) error {
+	changed, err := d.validate()
+	if err != nil {
+		return err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	if f.String() != d.Codec {
+		return fmt.Errorf("fasttext: cannot apply %s delta to %s database", d.Codec, f)
+	}
+	local := make(map[string]uint64)
+	ids := make(map[string]int64)
+	err = ft.forEachSyncRow(func(r *SyncRow) error {
+		if changed[syncBucket(r.Word, d.NumBuckets)] {
+			local[r.Word] = r.hash()
+			ids[r.Word] = r.ID
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	remote := make(map[string]bool, len(d.Rows))
+	for _, r := range d.Rows {
+		remote[r.Word] = true
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var words []string
+	remove := func(word string) error {
+		_, err := tx.Exec(ft.sql(`DELETE FROM fasttext WHERE word=?;`), word)
+		return err
+	}
+	for word := range local {
+		if remote[word] {
+			continue
+		}
+		words = append(words, word)
+		if err := remove(word); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ft.sql(`DELETE FROM fasttext_payload WHERE word=?;`), word); err != nil && !isNoSuchTable(err) {
+			return err
+		}
+	}
+	// Words moving to another ID are deleted first, so that their IDs
+	// are free for the rows inserted.
+	var upserts []SyncRow
+	for _, r := range d.Rows {
+		h, ok := local[r.Word]
+		if ok && h == r.hash() {
+			continue
+		}
+		if ok && ids[r.Word] != r.ID {
+			if err := remove(r.Word); err != nil {
+				return err
+			}
+		}
+		upserts = append(upserts, r)
+	}
+	for _, r := range upserts {
+		if _, ok := local[r.Word]; ok && ids[r.Word] == r.ID {
+			_, err = tx.Exec(ft.sql(`UPDATE fasttext SET emb = ?, rank = ?, freq = ? WHERE rowid = ?;`),
+				r.Emb, r.Rank, r.Freq, r.ID)
+		} else {
+			_, err = tx.Exec(ft.sql(`INSERT INTO fasttext(rowid, word, emb, rank, freq) VALUES(?, ?, ?, ?, ?);`),
+				r.ID, r.Word, r.Emb, r.Rank, r.Freq)
+		}
+		if err != nil {
+			return err
+		}
+		words = append(words, r.Word)
+	}
+	if err := ft.bumpGeneration(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	ft.invalidate(words)
+	return nil
+}
+
+// forEachSyncRow calls fn on every row, reused across calls. Syncing
+// needs the rank and freq columns, see Migrate.
+func (ft *FastText) forEachSyncRow(fn func(r *SyncRow) error) error {
+	if col, err := ft.rankColumn(); err != nil || col != "rank" {
+		if err != nil {
+			return err
+		}
+		return errors.New("fasttext: cannot sync a database without ranks, run Migrate first")
+	}
+	rows, err := ft.db.Query(ft.sql(`SELECT rowid, word, emb, rank, freq FROM fasttext;`))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var r SyncRow
+	for rows.Next() {
+		var rank, freq sql.NullInt64
+		if err := rows.Scan(&r.ID, &r.Word, &r.Emb, &rank, &freq); err != nil {
+			return err
+		}
+		r.Rank, r.Freq = nullInt64Ptr(rank), nullInt64Ptr(freq)
+		if err := fn(&r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullInt64Ptr(n sql.NullInt64) *int64 {
+	if !n.Valid {
+		return nil
+	}
+	return &n.Int64
+}
+
+// forEachRow calls fn on every stored (word, blob) pair.
+func (ft *FastText) forEachRow(fn func(word string, emb []byte) error) error {
+	rows, err := ft.db.Query(ft.sql(`SELECT word, emb FROM fasttext;`))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var word string
+		var emb []byte
+		if err := rows.Scan(&word, &emb); err != nil {
+			return err
+		}
+		if err := fn(word, emb); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func syncBucket(word string, buckets int) int {
+	h := fnv.New32a()
+	h.Write([]byte(word))
+	return int(h.Sum32() % uint32(buckets))
+}
+
+// hash hashes every column of the row.
+func (r *SyncRow) hash() uint64 {
+	h := fnv.New64a()
+	var buf [8]byte
+	writeInt := func(n int64) {
+		binary.LittleEndian.PutUint64(buf[:], uint64(n))
+		h.Write(buf[:])
+	}
+	writeInt(r.ID)
+	h.Write([]byte(r.Word))
+	h.Write([]byte{0})
+	h.Write(r.Emb)
+	for _, n := range []*int64{r.Rank, r.Freq} {
+		if n == nil {
+			h.Write([]byte{0})
+			continue
+		}
+		h.Write([]byte{1})
+		writeInt(*n)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,462 @@
+// gRPC service of the fastText embedding database, implemented by
+// package github.com/ekzhu/go-fasttext/rpc. Clients in other languages
+// can be generated from this file.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: fasttext.proto
+
+package rpc
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetEmbeddingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetEmbeddingRequest) Reset() {
+	*x = GetEmbeddingRequest{}
+	mi := &file_fasttext_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetEmbeddingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetEmbeddingRequest) ProtoMessage() {}
+
+func (x *GetEmbeddingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetEmbeddingRequest.ProtoReflect.Descriptor instead.
+func (*GetEmbeddingRequest) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetEmbeddingRequest) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+type Embedding struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Word   string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	Vector []float32              `protobuf:"fixed32,2,rep,packed,name=vector,proto3" json:"vector,omitempty"`
+	// found is false for words not in the vocabulary.
+	Found         bool `protobuf:"varint,3,opt,name=found,proto3" json:"found,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Embedding) Reset() {
+	*x = Embedding{}
+	mi := &file_fasttext_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Embedding) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Embedding) ProtoMessage() {}
+
+func (x *Embedding) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Embedding.ProtoReflect.Descriptor instead.
+func (*Embedding) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Embedding) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+func (x *Embedding) GetVector() []float32 {
+	if x != nil {
+		return x.Vector
+	}
+	return nil
+}
+
+func (x *Embedding) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+type BatchGetEmbeddingsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Words         []string               `protobuf:"bytes,1,rep,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetEmbeddingsRequest) Reset() {
+	*x = BatchGetEmbeddingsRequest{}
+	mi := &file_fasttext_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetEmbeddingsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetEmbeddingsRequest) ProtoMessage() {}
+
+func (x *BatchGetEmbeddingsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetEmbeddingsRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetEmbeddingsRequest) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BatchGetEmbeddingsRequest) GetWords() []string {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+type BatchGetEmbeddingsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Embeddings    []*Embedding           `protobuf:"bytes,1,rep,name=embeddings,proto3" json:"embeddings,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetEmbeddingsResponse) Reset() {
+	*x = BatchGetEmbeddingsResponse{}
+	mi := &file_fasttext_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetEmbeddingsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetEmbeddingsResponse) ProtoMessage() {}
+
+func (x *BatchGetEmbeddingsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetEmbeddingsResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetEmbeddingsResponse) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchGetEmbeddingsResponse) GetEmbeddings() []*Embedding {
+	if x != nil {
+		return x.Embeddings
+	}
+	return nil
+}
+
+type NearestNeighborsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	K             int32                  `protobuf:"varint,2,opt,name=k,proto3" json:"k,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NearestNeighborsRequest) Reset() {
+	*x = NearestNeighborsRequest{}
+	mi := &file_fasttext_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NearestNeighborsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearestNeighborsRequest) ProtoMessage() {}
+
+func (x *NearestNeighborsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearestNeighborsRequest.ProtoReflect.Descriptor instead.
+func (*NearestNeighborsRequest) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *NearestNeighborsRequest) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+func (x *NearestNeighborsRequest) GetK() int32 {
+	if x != nil {
+		return x.K
+	}
+	return 0
+}
+
+type Neighbor struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Word          string                 `protobuf:"bytes,1,opt,name=word,proto3" json:"word,omitempty"`
+	Score         float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Neighbor) Reset() {
+	*x = Neighbor{}
+	mi := &file_fasttext_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Neighbor) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Neighbor) ProtoMessage() {}
+
+func (x *Neighbor) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Neighbor.ProtoReflect.Descriptor instead.
+func (*Neighbor) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Neighbor) GetWord() string {
+	if x != nil {
+		return x.Word
+	}
+	return ""
+}
+
+func (x *Neighbor) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type NearestNeighborsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Neighbors     []*Neighbor            `protobuf:"bytes,1,rep,name=neighbors,proto3" json:"neighbors,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *NearestNeighborsResponse) Reset() {
+	*x = NearestNeighborsResponse{}
+	mi := &file_fasttext_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *NearestNeighborsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*NearestNeighborsResponse) ProtoMessage() {}
+
+func (x *NearestNeighborsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_fasttext_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use NearestNeighborsResponse.ProtoReflect.Descriptor instead.
+func (*NearestNeighborsResponse) Descriptor() ([]byte, []int) {
+	return file_fasttext_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *NearestNeighborsResponse) GetNeighbors() []*Neighbor {
+	if x != nil {
+		return x.Neighbors
+	}
+	return nil
+}
+
+var File_fasttext_proto protoreflect.FileDescriptor
+
+const file_fasttext_proto_rawDesc = "" +
+	"\n" +
+	"\x0efasttext.proto\x12\bfasttext\")\n" +
+	"\x13GetEmbeddingRequest\x12\x12\n" +
+	"\x04word\x18\x01 \x01(\tR\x04word\"M\n" +
+	"\tEmbedding\x12\x12\n" +
+	"\x04word\x18\x01 \x01(\tR\x04word\x12\x16\n" +
+	"\x06vector\x18\x02 \x03(\x02R\x06vector\x12\x14\n" +
+	"\x05found\x18\x03 \x01(\bR\x05found\"1\n" +
+	"\x19BatchGetEmbeddingsRequest\x12\x14\n" +
+	"\x05words\x18\x01 \x03(\tR\x05words\"Q\n" +
+	"\x1aBatchGetEmbeddingsResponse\x123\n" +
+	"\n" +
+	"embeddings\x18\x01 \x03(\v2\x13.fasttext.EmbeddingR\n" +
+	"embeddings\";\n" +
+	"\x17NearestNeighborsRequest\x12\x12\n" +
+	"\x04word\x18\x01 \x01(\tR\x04word\x12\f\n" +
+	"\x01k\x18\x02 \x01(\x05R\x01k\"4\n" +
+	"\bNeighbor\x12\x12\n" +
+	"\x04word\x18\x01 \x01(\tR\x04word\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\"L\n" +
+	"\x18NearestNeighborsResponse\x120\n" +
+	"\tneighbors\x18\x01 \x03(\v2\x12.fasttext.NeighborR\tneighbors2\xd6\x02\n" +
+	"\bFastText\x12B\n" +
+	"\fGetEmbedding\x12\x1d.fasttext.GetEmbeddingRequest\x1a\x13.fasttext.Embedding\x12_\n" +
+	"\x12BatchGetEmbeddings\x12#.fasttext.BatchGetEmbeddingsRequest\x1a$.fasttext.BatchGetEmbeddingsResponse\x12Y\n" +
+	"\x10NearestNeighbors\x12!.fasttext.NearestNeighborsRequest\x1a\".fasttext.NearestNeighborsResponse\x12J\n" +
+	"\x10StreamEmbeddings\x12\x1d.fasttext.GetEmbeddingRequest\x1a\x13.fasttext.Embedding(\x010\x01B\"Z github.com/ekzhu/go-fasttext/rpcb\x06proto3"
+
+var (
+	file_fasttext_proto_rawDescOnce sync.Once
+	file_fasttext_proto_rawDescData []byte
+)
+
+func file_fasttext_proto_rawDescGZIP() []byte {
+	file_fasttext_proto_rawDescOnce.Do(func() {
+		file_fasttext_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_fasttext_proto_rawDesc), len(file_fasttext_proto_rawDesc)))
+	})
+	return file_fasttext_proto_rawDescData
+}
+
+var file_fasttext_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_fasttext_proto_goTypes = []any{
+	(*GetEmbeddingRequest)(nil),        // 0: fasttext.GetEmbeddingRequest
+	(*Embedding)(nil),                  // 1: fasttext.Embedding
+	(*BatchGetEmbeddingsRequest)(nil),  // 2: fasttext.BatchGetEmbeddingsRequest
+	(*BatchGetEmbeddingsResponse)(nil), // 3: fasttext.BatchGetEmbeddingsResponse
+	(*NearestNeighborsRequest)(nil),    // 4: fasttext.NearestNeighborsRequest
+	(*Neighbor)(nil),                   // 5: fasttext.Neighbor
+	(*NearestNeighborsResponse)(nil),   // 6: fasttext.NearestNeighborsResponse
+}
+var file_fasttext_proto_depIdxs = []int32{
+	1, // 0: fasttext.BatchGetEmbeddingsResponse.embeddings:type_name -> fasttext.Embedding
+	5, // 1: fasttext.NearestNeighborsResponse.neighbors:type_name -> fasttext.Neighbor
+	0, // 2: fasttext.FastText.GetEmbedding:input_type -> fasttext.GetEmbeddingRequest
+	2, // 3: fasttext.FastText.BatchGetEmbeddings:input_type -> fasttext.BatchGetEmbeddingsRequest
+	4, // 4: fasttext.FastText.NearestNeighbors:input_type -> fasttext.NearestNeighborsRequest
+	0, // 5: fasttext.FastText.StreamEmbeddings:input_type -> fasttext.GetEmbeddingRequest
+	1, // 6: fasttext.FastText.GetEmbedding:output_type -> fasttext.Embedding
+	3, // 7: fasttext.FastText.BatchGetEmbeddings:output_type -> fasttext.BatchGetEmbeddingsResponse
+	6, // 8: fasttext.FastText.NearestNeighbors:output_type -> fasttext.NearestNeighborsResponse
+	1, // 9: fasttext.FastText.StreamEmbeddings:output_type -> fasttext.Embedding
+	6, // [6:10] is the sub-list for method output_type
+	2, // [2:6] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_fasttext_proto_init() }
+func file_fasttext_proto_init() {
+	if File_fasttext_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_fasttext_proto_rawDesc), len(file_fasttext_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_fasttext_proto_goTypes,
+		DependencyIndexes: file_fasttext_proto_depIdxs,
+		MessageInfos:      file_fasttext_proto_msgTypes,
+	}.Build()
+	File_fasttext_proto = out.File
+	file_fasttext_proto_goTypes = nil
+	file_fasttext_proto_depIdxs = nil
+}
@@ -0,0 +1,242 @@
+// gRPC service of the fastText embedding database, implemented by
+// package github.com/ekzhu/go-fasttext/rpc. Clients in other languages
+// can be generated from this file.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: fasttext.proto
+
+package rpc
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	FastText_GetEmbedding_FullMethodName       = "/fasttext.FastText/GetEmbedding"
+	FastText_BatchGetEmbeddings_FullMethodName = "/fasttext.FastText/BatchGetEmbeddings"
+	FastText_NearestNeighbors_FullMethodName   = "/fasttext.FastText/NearestNeighbors"
+	FastText_StreamEmbeddings_FullMethodName   = "/fasttext.FastText/StreamEmbeddings"
+)
+
+// FastTextClient is the client API for FastText service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type FastTextClient interface {
+	// GetEmbedding returns the embedding of a word, or a NOT_FOUND error.
+	GetEmbedding(ctx context.Context, in *GetEmbeddingRequest, opts ...grpc.CallOption) (*Embedding, error)
+	// BatchGetEmbeddings returns the embeddings of words, in order.
+	BatchGetEmbeddings(ctx context.Context, in *BatchGetEmbeddingsRequest, opts ...grpc.CallOption) (*BatchGetEmbeddingsResponse, error)
+	// NearestNeighbors returns the k words most similar to a word.
+	NearestNeighbors(ctx context.Context, in *NearestNeighborsRequest, opts ...grpc.CallOption) (*NearestNeighborsResponse, error)
+	// StreamEmbeddings answers a stream of look-ups, in order.
+	StreamEmbeddings(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[GetEmbeddingRequest, Embedding], error)
+}
+
+type fastTextClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFastTextClient(cc grpc.ClientConnInterface) FastTextClient {
+	return &fastTextClient{cc}
+}
+
+func (c *fastTextClient) GetEmbedding(ctx context.Context, in *GetEmbeddingRequest, opts ...grpc.CallOption) (*Embedding, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Embedding)
+	err := c.cc.Invoke(ctx, FastText_GetEmbedding_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fastTextClient) BatchGetEmbeddings(ctx context.Context, in *BatchGetEmbeddingsRequest, opts ...grpc.CallOption) (*BatchGetEmbeddingsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchGetEmbeddingsResponse)
+	err := c.cc.Invoke(ctx, FastText_BatchGetEmbeddings_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fastTextClient) NearestNeighbors(ctx context.Context, in *NearestNeighborsRequest, opts ...grpc.CallOption) (*NearestNeighborsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(NearestNeighborsResponse)
+	err := c.cc.Invoke(ctx, FastText_NearestNeighbors_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fastTextClient) StreamEmbeddings(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[GetEmbeddingRequest, Embedding], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &FastText_ServiceDesc.Streams[0], FastText_StreamEmbeddings_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[GetEmbeddingRequest, Embedding]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FastText_StreamEmbeddingsClient = grpc.BidiStreamingClient[GetEmbeddingRequest, Embedding]
+
+// FastTextServer is the server API for FastText service.
+// All implementations must embed UnimplementedFastTextServer
+// for forward compatibility.
+type FastTextServer interface {
+	// GetEmbedding returns the embedding of a word, or a NOT_FOUND error.
+	GetEmbedding(context.Context, *GetEmbeddingRequest) (*Embedding, error)
+	// BatchGetEmbeddings returns the embeddings of words, in order.
+	BatchGetEmbeddings(context.Context, *BatchGetEmbeddingsRequest) (*BatchGetEmbeddingsResponse, error)
+	// NearestNeighbors returns the k words most similar to a word.
+	NearestNeighbors(context.Context, *NearestNeighborsRequest) (*NearestNeighborsResponse, error)
+	// StreamEmbeddings answers a stream of look-ups, in order.
+	StreamEmbeddings(grpc.BidiStreamingServer[GetEmbeddingRequest, Embedding]) error
+	mustEmbedUnimplementedFastTextServer()
+}
+
+// UnimplementedFastTextServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFastTextServer struct{}
+
+func (UnimplementedFastTextServer) GetEmbedding(context.Context, *GetEmbeddingRequest) (*Embedding, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetEmbedding not implemented")
+}
+func (UnimplementedFastTextServer) BatchGetEmbeddings(context.Context, *BatchGetEmbeddingsRequest) (*BatchGetEmbeddingsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetEmbeddings not implemented")
+}
+func (UnimplementedFastTextServer) NearestNeighbors(context.Context, *NearestNeighborsRequest) (*NearestNeighborsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NearestNeighbors not implemented")
+}
+func (UnimplementedFastTextServer) StreamEmbeddings(grpc.BidiStreamingServer[GetEmbeddingRequest, Embedding]) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEmbeddings not implemented")
+}
+func (UnimplementedFastTextServer) mustEmbedUnimplementedFastTextServer() {}
+func (UnimplementedFastTextServer) testEmbeddedByValue()                  {}
+
+// UnsafeFastTextServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FastTextServer will
+// result in compilation errors.
+type UnsafeFastTextServer interface {
+	mustEmbedUnimplementedFastTextServer()
+}
+
+func RegisterFastTextServer(s grpc.ServiceRegistrar, srv FastTextServer) {
+	// If the following call pancis, it indicates UnimplementedFastTextServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FastText_ServiceDesc, srv)
+}
+
+func _FastText_GetEmbedding_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetEmbeddingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FastTextServer).GetEmbedding(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FastText_GetEmbedding_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FastTextServer).GetEmbedding(ctx, req.(*GetEmbeddingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FastText_BatchGetEmbeddings_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetEmbeddingsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FastTextServer).BatchGetEmbeddings(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FastText_BatchGetEmbeddings_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FastTextServer).BatchGetEmbeddings(ctx, req.(*BatchGetEmbeddingsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FastText_NearestNeighbors_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NearestNeighborsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FastTextServer).NearestNeighbors(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FastText_NearestNeighbors_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FastTextServer).NearestNeighbors(ctx, req.(*NearestNeighborsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FastText_StreamEmbeddings_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(FastTextServer).StreamEmbeddings(&grpc.GenericServerStream[GetEmbeddingRequest, Embedding]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type FastText_StreamEmbeddingsServer = grpc.BidiStreamingServer[GetEmbeddingRequest, Embedding]
+
+// FastText_ServiceDesc is the grpc.ServiceDesc for FastText service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FastText_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fasttext.FastText",
+	HandlerType: (*FastTextServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetEmbedding",
+			Handler:    _FastText_GetEmbedding_Handler,
+		},
+		{
+			MethodName: "BatchGetEmbeddings",
+			Handler:    _FastText_BatchGetEmbeddings_Handler,
+		},
+		{
+			MethodName: "NearestNeighbors",
+			Handler:    _FastText_NearestNeighbors_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEmbeddings",
+			Handler:       _FastText_StreamEmbeddings_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "fasttext.proto",
+}
@@ -0,0 +1,254 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func newTestClient(t *testing.T, opts ...Option) (FastTextClient, func()) {
+	ft := fasttext.NewFastText(":memory:")
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(ft, opts...)
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewFastTextClient(conn), func() {
+		conn.Close()
+		s.Stop()
+		ft.Close()
+	}
+}
+
+func Test_Service(t *testing.T) {
+	client, done := newTestClient(t)
+	defer done()
+	ctx := context.Background()
+
+	emb, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !emb.Found || emb.Word != "has" || len(emb.Vector) != 300 {
+		t.Errorf("Unexpected embedding %v %v %d", emb.Found, emb.Word, len(emb.Vector))
+	}
+	_, err = client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "not-a-word"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	batch, err := client.BatchGetEmbeddings(ctx, &BatchGetEmbeddingsRequest{Words: []string{"has", "not-a-word"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Embeddings) != 2 || !batch.Embeddings[0].Found || batch.Embeddings[1].Found {
+		t.Errorf("Unexpected batch %v", batch.Embeddings)
+	}
+
+	_, err = client.BatchGetEmbeddings(ctx, &BatchGetEmbeddingsRequest{Words: make([]string, MaxWords+1)})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for too many words, got %v", err)
+	}
+
+	nn, err := client.NearestNeighbors(ctx, &NearestNeighborsRequest{Word: "has", K: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn.Neighbors) != 3 || nn.Neighbors[0].Word != "have" || nn.Neighbors[0].Score <= 0 {
+		t.Errorf("Unexpected neighbors %v", nn.Neighbors)
+	}
+
+	stream, err := client.StreamEmbeddings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := []string{"the", "not-a-word", "page"}
+	for _, w := range words {
+		if err := stream.Send(&GetEmbeddingRequest{Word: w}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stream.CloseSend()
+	for i, w := range words {
+		emb, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if emb.Word != w || emb.Found != (i != 1) {
+			t.Errorf("Unexpected streamed embedding %v %v", emb.Word, emb.Found)
+		}
+	}
+}
+
+func Test_ServiceAuth(t *testing.T) {
+	client, done := newTestClient(t, WithAuthKeys("secret"))
+	defer done()
+	ctx := context.Background()
+	_, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(ctx, "x-api-key", "secreT")
+	if _, err := client.GetEmbedding(wrong, &GetEmbeddingRequest{Word: "has"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated for a wrong key, got %v", err)
+	}
+	for _, md := range [][]string{{"authorization", "Bearer secret"}, {"x-api-key", "secret"}} {
+		ctx := metadata.AppendToOutgoingContext(ctx, md...)
+		if _, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"}); err != nil {
+			t.Errorf("%s: %v", md[0], err)
+		}
+	}
+}
+
+func Test_ServiceCoHosted(t *testing.T) {
+	ft := fasttext.NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(ServerOptions(WithAuthKeys("secret"))...)
+	defer s.Stop()
+	RegisterFastTextServer(s, NewService(ft))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := context.Background()
+
+	// The other services use the default codec and are not
+	// authenticated.
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("Unexpected health %v", resp.Status)
+	}
+	client := NewFastTextClient(conn)
+	if _, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated, got %v", err)
+	}
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-api-key", "secret")
+	if _, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func Test_ServiceCancel(t *testing.T) {
+	ft := fasttext.NewFastText(":memory:")
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(ft)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("GetEmbedding: expected Canceled, got %v", err)
+	}
+	_, err = s.BatchGetEmbeddings(ctx, &BatchGetEmbeddingsRequest{Words: []string{"has"}})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("BatchGetEmbeddings: expected Canceled, got %v", err)
+	}
+	_, err = s.NearestNeighbors(ctx, &NearestNeighborsRequest{Word: "has", K: 3})
+	if status.Code(err) != codes.Canceled {
+		t.Errorf("NearestNeighbors: expected Canceled, got %v", err)
+	}
+}
+
+// testTracer records the spans started in the context of a call
+// carrying a "trace-id" in its metadata.
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, func(error)) {
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("trace-id")) > 0 {
+		tr.mu.Lock()
+		tr.spans = append(tr.spans, name)
+		tr.mu.Unlock()
+	}
+	return ctx, func(error) {}
+}
+
+func Test_ServiceTracing(t *testing.T) {
+	tr := &testTracer{}
+	ft := fasttext.NewFastText(":memory:", fasttext.WithTracer(tr))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(ft)
+	defer s.Stop()
+	go s.Serve(lis)
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := NewFastTextClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "trace-id", "1")
+
+	if _, err := client.GetEmbedding(ctx, &GetEmbeddingRequest{Word: "has"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.BatchGetEmbeddings(ctx, &BatchGetEmbeddingsRequest{Words: []string{"has"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NearestNeighbors(ctx, &NearestNeighborsRequest{Word: "has", K: 3}); err != nil {
+		t.Fatal(err)
+	}
+	stream, err := client.StreamEmbeddings(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.Send(&GetEmbeddingRequest{Word: "has"}); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	// The look-up of the word of NearestNeighbors is a child of its
+	// search.
+	expected := []string{"fasttext.GetEmb", "fasttext.GetEmbs", "fasttext.NearestNeighbors", "fasttext.GetEmb",
+		"fasttext.GetEmb"}
+	if !reflect.DeepEqual(tr.spans, expected) {
+		t.Errorf("Unexpected spans in the call context %v", tr.spans)
+	}
+}
@@ -0,0 +1,232 @@
+// Package rpc serves a fastText database over gRPC, with the service
+// described in fasttext.proto: unary look-ups and neighbor searches,
+// and a bidirectional stream of look-ups for offline feature
+// generation.
+//
+// The messages and the service stubs are generated from
+// fasttext.proto; Go clients are created with NewFastTextClient. The
+// look-ups are cancelled with their calls, and their spans (see
+// fasttext.WithTracer) are children of the span of the call context,
+// e.g. of a tracing interceptor.
+package rpc
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative fasttext.proto
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/ekzhu/go-fasttext"
+	"github.com/ekzhu/go-fasttext/config"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultK is the number of neighbors returned when K is not set.
+const DefaultK = 10
+
+// MaxK bounds the number of neighbors of a request.
+const MaxK = 1000
+
+// MaxWords bounds the number of words of a batch request, the same
+// as for the /embs endpoint of the HTTP server.
+const MaxWords = 10000
+
+// Service implements FastTextServer on a FastText session.
+type Service struct {
+	UnimplementedFastTextServer
+	ft *fasttext.FastText
+}
+
+// NewService creates the service of the FastText session, which it
+// does not close.
+func NewService(ft *fasttext.FastText) *Service {
+	return &Service{ft: ft}
+}
+
+// GetEmbedding implements FastTextServer.
+func (s *Service) GetEmbedding(ctx context.Context, req *GetEmbeddingRequest) (*Embedding, error) {
+	emb, err := s.ft.GetEmbContext(ctx, req.Word)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	return &Embedding{Word: req.Word, Vector: emb, Found: true}, nil
+}
+
+// BatchGetEmbeddings implements FastTextServer.
+func (s *Service) BatchGetEmbeddings(ctx context.Context, req *BatchGetEmbeddingsRequest) (*BatchGetEmbeddingsResponse, error) {
+	if len(req.Words) > MaxWords {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d words per request", MaxWords)
+	}
+	embs, err := s.ft.GetEmbsContext(ctx, req.Words)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &BatchGetEmbeddingsResponse{Embeddings: make([]*Embedding, len(embs))}
+	for i, emb := range embs {
+		resp.Embeddings[i] = &Embedding{Word: req.Words[i], Vector: emb, Found: emb != nil}
+	}
+	return resp, nil
+}
+
+// NearestNeighbors implements FastTextServer.
+func (s *Service) NearestNeighbors(ctx context.Context, req *NearestNeighborsRequest) (*NearestNeighborsResponse, error) {
+	k := int(req.K)
+	if k == 0 {
+		k = DefaultK
+	}
+	if k < 0 || k > MaxK {
+		return nil, status.Errorf(codes.InvalidArgument, "k must be in [1, %d]", MaxK)
+	}
+	nn, err := s.ft.NearestNeighborsContext(ctx, req.Word, k)
+	if err != nil {
+		return nil, statusError(err)
+	}
+	resp := &NearestNeighborsResponse{Neighbors: make([]*Neighbor, len(nn))}
+	for i, n := range nn {
+		resp.Neighbors[i] = &Neighbor{Word: n.Word, Score: n.Score}
+	}
+	return resp, nil
+}
+
+// StreamEmbeddings implements FastTextServer.
+func (s *Service) StreamEmbeddings(stream FastText_StreamEmbeddingsServer) error {
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		emb, err := s.ft.GetEmbContext(stream.Context(), req.Word)
+		if err != nil && !errors.Is(err, fasttext.ErrNoEmbFound) {
+			return statusError(err)
+		}
+		if err := stream.Send(&Embedding{Word: req.Word, Vector: emb, Found: emb != nil}); err != nil {
+			return err
+		}
+	}
+}
+
+func statusError(err error) error {
+	switch {
+	case errors.Is(err, fasttext.ErrNoEmbFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Option configures a server created by NewServer.
+type Option func(*serverConfig)
+
+type serverConfig struct {
+	authKeys [][]byte
+	grpcOpts []grpc.ServerOption
+}
+
+// WithAuthKeys requires the calls to carry one of the keys in their
+// metadata, either as "authorization: Bearer <key>" or "x-api-key".
+func WithAuthKeys(keys ...string) Option {
+	return func(cfg *serverConfig) {
+		for _, key := range keys {
+			cfg.authKeys = append(cfg.authKeys, []byte(key))
+		}
+	}
+}
+
+// WithGRPCOptions adds options to the underlying gRPC server, e.g. TLS
+// credentials.
+func WithGRPCOptions(opts ...grpc.ServerOption) Option {
+	return func(cfg *serverConfig) {
+		cfg.grpcOpts = append(cfg.grpcOpts, opts...)
+	}
+}
+
+// ServerOptions returns the gRPC server options of the given options,
+// for a gRPC server that also hosts other services. The API keys are
+// only checked for the calls to the FastText service.
+func ServerOptions(opts ...Option) []grpc.ServerOption {
+	cfg := &serverConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	var grpcOpts []grpc.ServerOption
+	if len(cfg.authKeys) > 0 {
+		grpcOpts = append(grpcOpts,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+				handler grpc.UnaryHandler) (interface{}, error) {
+				if !cfg.authorized(ctx, info.FullMethod) {
+					return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+				}
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+				handler grpc.StreamHandler) error {
+				if !cfg.authorized(ss.Context(), info.FullMethod) {
+					return status.Error(codes.Unauthenticated, "missing or invalid API key")
+				}
+				return handler(srv, ss)
+			}))
+	}
+	return append(grpcOpts, cfg.grpcOpts...)
+}
+
+// authorized reports whether the call of the method carries a valid
+// key, or is not to the FastText service.
+func (cfg *serverConfig) authorized(ctx context.Context, method string) bool {
+	if !strings.HasPrefix(method, "/"+FastText_ServiceDesc.ServiceName+"/") {
+		return true
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, key := range md.Get("x-api-key") {
+		if cfg.validKey(key) {
+			return true
+		}
+	}
+	for _, auth := range md.Get("authorization") {
+		if strings.HasPrefix(auth, "Bearer ") && cfg.validKey(strings.TrimPrefix(auth, "Bearer ")) {
+			return true
+		}
+	}
+	return false
+}
+
+// validKey compares the key to all the keys in constant time, so that
+// the response time does not leak them.
+func (cfg *serverConfig) validKey(key string) bool {
+	valid := 0
+	for _, k := range cfg.authKeys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), k)
+	}
+	return valid == 1
+}
+
+// NewServer creates a gRPC server of the FastText session.
+func NewServer(ft *fasttext.FastText, opts ...Option) *grpc.Server {
+	s := grpc.NewServer(ServerOptions(opts...)...)
+	RegisterFastTextServer(s, NewService(ft))
+	return s
+}
+
+// ListenAndServe opens the session described by the configuration and
+// serves it on cfg.Server.GRPCAddr with cfg.Server.AuthKeys.
+func ListenAndServe(cfg *config.Config) error {
+	lis, err := net.Listen("tcp", cfg.Server.GRPCAddr)
+	if err != nil {
+		return err
+	}
+	ft := cfg.Open()
+	defer ft.Close()
+	return NewServer(ft, WithAuthKeys(cfg.Server.AuthKeys...)).Serve(lis)
+}
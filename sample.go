@@ -0,0 +1,127 @@
+package fasttext
+
+import (
+	"database/sql"
+	"math/rand"
+	"strings"
+)
+
+// SampleWords returns n distinct words drawn uniformly at random from
+// the vocabulary, in random order, e.g. for negative sampling or
+// evaluation sets. The same seed gives the same sample of the same
+// database. It returns the whole vocabulary, shuffled, if it has fewer
+// than n words. The words are looked up by random rowids rather than by
+// a scan of the table, so the cost grows with n, not with the size of
+// the vocabulary.
+func (ft *FastText) SampleWords(n int, seed int64) ([]string, error) {
+	var words []string
+	err := ft.sampleRows(n, seed, false, func(word string, _ []float32) {
+		words = append(words, word)
+	})
+	return words, err
+}
+
+// SampleEmbs is SampleWords returning the embeddings of the words along
+// with them.
+func (ft *FastText) SampleEmbs(n int, seed int64) ([]string, [][]float32, error) {
+	var words []string
+	var embs [][]float32
+	err := ft.sampleRows(n, seed, true, func(word string, emb []float32) {
+		words = append(words, word)
+		embs = append(embs, emb)
+	})
+	return words, embs, err
+}
+
+// sampleRows calls fn with up to n rows drawn uniformly at random,
+// decoding their embeddings if withEmbs is set. It looks up the rowids
+// of a lazy random permutation of the range of rowids, skipping the
+// gaps left by deleted rows.
+func (ft *FastText) sampleRows(n int, seed int64, withEmbs bool, fn func(word string, emb []float32)) error {
+	if n <= 0 {
+		return nil
+	}
+	var maxRowid sql.NullInt64
+	if err := ft.db.QueryRow(ft.sql(`SELECT MAX(rowid) FROM fasttext;`)).Scan(&maxRowid); err != nil {
+		return err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	query := `SELECT rowid, word FROM fasttext WHERE rowid IN (`
+	if withEmbs {
+		query = `SELECT rowid, word, emb FROM fasttext WHERE rowid IN (`
+	}
+	rng := rand.New(rand.NewSource(seed))
+	// swapped holds the values moved by the Fisher-Yates shuffle of
+	// 1..maxRowid, the others being in place.
+	swapped := make(map[int64]int64)
+	at := func(i int64) int64 {
+		if v, ok := swapped[i]; ok {
+			return v
+		}
+		return i + 1
+	}
+	type sampled struct {
+		word string
+		emb  []float32
+	}
+	var found int
+	for next := int64(0); next < maxRowid.Int64 && found < n; {
+		// Draw the rowids of the next batch, twice the number of rows
+		// still needed to absorb some gaps.
+		size := int64(minInt(2*(n-found), maxBatchVars))
+		if rest := maxRowid.Int64 - next; size > rest {
+			size = rest
+		}
+		args := make([]interface{}, size)
+		for i := range args {
+			j := next + rng.Int63n(maxRowid.Int64-next)
+			vi, vj := at(next), at(j)
+			swapped[j] = vi
+			delete(swapped, next)
+			args[i] = vj
+			next++
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(args)), ",")
+		rows, err := ft.db.Query(ft.sql(query)+placeholders+`);`, args...)
+		if err != nil {
+			return err
+		}
+		batch := make(map[int64]sampled, len(args))
+		for rows.Next() {
+			var rowid int64
+			var s sampled
+			var binVec []byte
+			dest := []interface{}{&rowid, &s.word}
+			if withEmbs {
+				dest = append(dest, &binVec)
+			}
+			if err := rows.Scan(dest...); err != nil {
+				rows.Close()
+				return err
+			}
+			if withEmbs {
+				if s.emb, err = f.decode(binVec); err != nil {
+					rows.Close()
+					return err
+				}
+			}
+			batch[rowid] = s
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		// Keep the rows in the order drawn, so that a truncated batch
+		// is still a uniform sample.
+		for _, rowid := range args {
+			if s, ok := batch[rowid.(int64)]; ok && found < n {
+				fn(s.word, s.emb)
+				found++
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+package fasttext
+
+// SaveTo writes a compacted copy of the database to a new file, e.g. to
+// persist a database built or modified in memory. It uses SQLite's
+// VACUUM INTO, so the copy is consistent even while other goroutines
+// use the session, and the file must not exist.
+func (ft *FastText) SaveTo(path string) error {
+	_, err := ft.db.Exec(`VACUUM main INTO ?;`, path)
+	return err
+}
@@ -0,0 +1,243 @@
+package fasttext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+// DefaultSelfTestSample is the number of random rows decoded by
+// SelfTest, unless set with WithSample.
+const DefaultSelfTestSample = 1000
+
+// CheckResult is the outcome of one check of a SelfTest.
+type CheckResult struct {
+	Name   string
+	OK     bool
+	Detail string
+}
+
+// SelfTestReport lists the outcomes of the checks of a SelfTest.
+type SelfTestReport struct {
+	Checks []CheckResult
+}
+
+// Passed returns whether all the checks passed.
+func (r *SelfTestReport) Passed() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteTo writes the report, one check per line, for CI logs.
+func (r *SelfTestReport) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+	for _, c := range r.Checks {
+		status := "PASS"
+		if !c.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&buf, "%s %-12s %s\n", status, c.Name, c.Detail)
+	}
+	return buf.WriteTo(w)
+}
+
+func (r *SelfTestReport) add(name string, ok bool, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, CheckResult{Name: name, OK: ok, Detail: fmt.Sprintf(format, args...)})
+}
+
+type similarityCheck struct {
+	a, b string
+	min  float64
+}
+
+type selfTestConfig struct {
+	sample int
+	seed   int64
+	pairs  []similarityCheck
+	source *sourceCheck
+}
+
+// SelfTestOption configures SelfTest.
+type SelfTestOption func(*selfTestConfig)
+
+// WithSample sets the number of random rows decoded by SelfTest.
+func WithSample(n int) SelfTestOption {
+	return func(cfg *selfTestConfig) {
+		cfg.sample = n
+	}
+}
+
+// WithSimilarityCheck makes SelfTest check that the words a and b are
+// both in the vocabulary with a cosine similarity of at least min,
+// e.g. ("king", "queen", 0.5).
+func WithSimilarityCheck(a, b string, min float64) SelfTestOption {
+	return func(cfg *selfTestConfig) {
+		cfg.pairs = append(cfg.pairs, similarityCheck{a: a, b: b, min: min})
+	}
+}
+
+// SelfTest validates the database end to end, as a quick startup or
+// CI/CD check of a shipped artifact: the schema of the table, the
+// metadata, the decoding of a random sample of rows, the use of the
+// word index by look-ups, and the similarities set with
+// WithSimilarityCheck. Failed checks are reported, not returned as
+// errors; the error is only set when the checks cannot run.
+func (ft *FastText) SelfTest(opts ...SelfTestOption) (*SelfTestReport, error) {
+	cfg := &selfTestConfig{sample: DefaultSelfTestSample, seed: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	r := &SelfTestReport{}
+	if err := ft.checkSchema(r); err != nil {
+		return nil, err
+	}
+	if !r.Passed() {
+		return r, nil
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		r.add("metadata", false, "%v", err)
+		return r, nil
+	}
+	if _, ok, err := ft.getMeta(metaDim); err != nil {
+		return nil, err
+	} else if ok {
+		r.add("metadata", true, "%v vectors of dimension %d", f.codec, f.dim)
+	} else {
+		r.add("metadata", true, "legacy database without metadata, assuming %v", f.codec)
+	}
+	if err := ft.checkSample(r, f, cfg); err != nil {
+		return nil, err
+	}
+	ft.checkIndex(r)
+	ft.checkPairs(r, cfg)
+	return r, nil
+}
+
+// checkIndex checks that look-ups use the word index.
+func (ft *FastText) checkIndex(r *SelfTestReport) {
+	plan := ft.queryPlan(ft.sql(`SELECT emb FROM fasttext WHERE word=?;`), "")
+	r.add("index", strings.Contains(plan, "INDEX"), "%s", plan)
+}
+
+// checkPairs checks the similarities of WithSimilarityCheck.
+func (ft *FastText) checkPairs(r *SelfTestReport, cfg *selfTestConfig) {
+	for _, p := range cfg.pairs {
+		name := "similarity"
+		sim, err := ft.Similarity(p.a, p.b)
+		if err != nil {
+			r.add(name, false, "%s/%s: %v", p.a, p.b, err)
+			continue
+		}
+		r.add(name, sim >= p.min, "%s/%s: %.4f, expected at least %.4f", p.a, p.b, sim, p.min)
+	}
+}
+
+// checkSchema checks the columns of the table.
+func (ft *FastText) checkSchema(r *SelfTestReport) error {
+	rows, err := ft.db.Query(ft.sql(`PRAGMA table_info(fasttext);`))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	cols := map[string]string{}
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             interface{}
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return err
+		}
+		cols[name] = strings.ToUpper(typ)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	switch {
+	case len(cols) == 0:
+		r.add("schema", false, "no table %s", ft.table)
+	case cols["word"] != "TEXT" || cols["emb"] != "BLOB":
+		r.add("schema", false, "expected columns word TEXT and emb BLOB, got %v", cols)
+	default:
+		r.add("schema", true, "table %s(word TEXT, emb BLOB)", ft.table)
+	}
+	return nil
+}
+
+// checkSample decodes random rows.
+func (ft *FastText) checkSample(r *SelfTestReport, f vecFormat, cfg *selfTestConfig) error {
+	var maxRowid int64
+	if err := ft.db.QueryRow(ft.sql(`SELECT IFNULL(MAX(rowid), 0) FROM fasttext;`)).Scan(&maxRowid); err != nil {
+		return err
+	}
+	if maxRowid == 0 {
+		r.add("sample", false, "empty table")
+		return nil
+	}
+	rng := rand.New(rand.NewSource(cfg.seed))
+	var checked, failed int
+	var firstErr string
+	for start := 0; start < cfg.sample; start += maxBatchVars {
+		n := minInt(maxBatchVars, cfg.sample-start)
+		args := make([]interface{}, n)
+		for i := range args {
+			args[i] = rng.Int63n(maxRowid) + 1
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", n), ",")
+		rows, err := ft.db.Query(ft.sql(`SELECT word, emb FROM fasttext WHERE rowid IN (`)+placeholders+`);`, args...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var word string
+			var binVec []byte
+			if err := rows.Scan(&word, &binVec); err != nil {
+				rows.Close()
+				return err
+			}
+			checked++
+			if err := checkVec(f, binVec); err != nil {
+				failed++
+				if firstErr == "" {
+					firstErr = fmt.Sprintf("%q: %v", word, err)
+				}
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		r.add("sample", false, "%d of %d rows failed to decode, first %s", failed, checked, firstErr)
+	} else {
+		r.add("sample", true, "%d random rows decoded", checked)
+	}
+	return nil
+}
+
+// checkVec decodes the blob, checking its dimension and values.
+func checkVec(f vecFormat, binVec []byte) error {
+	vec, err := f.decode(binVec)
+	if err != nil {
+		return err
+	}
+	if f.dim == 0 && len(vec) == 0 {
+		return fmt.Errorf("empty vector")
+	}
+	for i, v := range vec {
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return fmt.Errorf("invalid value %v at %d", v, i)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package fasttext
+
+import "errors"
+
+// ErrAllOOV is returned when none of the tokens of a sentence is in
+// the vocabulary.
+var ErrAllOOV = errors.New("None of the tokens has an embedding")
+
+// SentenceOOVPolicy decides how GetSentenceEmb treats tokens missing
+// from the vocabulary.
+//
+// Deprecated: use an OOVPolicy with WithSentenceOOVPolicy.
+type SentenceOOVPolicy int
+
+const (
+	// OOVSkip leaves out-of-vocabulary tokens out of the average, as
+	// OOVSkipWord does.
+	OOVSkip SentenceOOVPolicy = iota
+	// OOVZero counts out-of-vocabulary tokens as zero vectors, shrinking
+	// the average towards the origin, as OOVZeroVector does.
+	OOVZero
+	// OOVFail returns ErrNoEmbFound if any token is out of vocabulary, as
+	// OOVError does.
+	OOVFail
+)
+
+type sentenceConfig struct {
+	normalize bool
+	// oov resolves the tokens left missing by the session, nil to skip
+	// them.
+	oov       OOVPolicy
+	component []float32
+	// defaultIDF is the IDF of the words missing from the IDF table of
+	// EmbedDocument, if set.
+	defaultIDF *float64
+}
+
+// SentenceOption configures GetSentenceEmb.
+type SentenceOption func(*sentenceConfig)
+
+func newSentenceConfig(opts []SentenceOption) *sentenceConfig {
+	cfg := &sentenceConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	return cfg
+}
+
+// WithL2Normalize scales the sentence embedding to unit length.
+func WithL2Normalize() SentenceOption {
+	return func(c *sentenceConfig) {
+		c.normalize = true
+	}
+}
+
+// WithSentenceOOV sets the treatment of out-of-vocabulary tokens,
+// OOVSkip by default.
+//
+// Deprecated: use WithSentenceOOVPolicy with OOVSkipWord, OOVZeroVector
+// or OOVError.
+func WithSentenceOOV(policy SentenceOOVPolicy) SentenceOption {
+	switch policy {
+	case OOVZero:
+		return WithSentenceOOVPolicy(OOVZeroVector())
+	case OOVFail:
+		return WithSentenceOOVPolicy(OOVError())
+	}
+	return WithSentenceOOVPolicy(OOVSkipWord())
+}
+
+// WithSentenceOOVPolicy sets the policy resolving the tokens still
+// missing once looked up, OOVSkipWord by default: the OOV policy of the
+// session (see WithOOVPolicy) applies first, in the look-up, and this
+// policy to the tokens it leaves missing. A token the policy cannot
+// resolve fails the sentence, e.g. with OOVError.
+func WithSentenceOOVPolicy(policy OOVPolicy) SentenceOption {
+	return func(c *sentenceConfig) {
+		c.oov = policy
+	}
+}
+
+// resolveOOV returns the embedding of a token left missing by the
+// session under the policy of WithSentenceOOVPolicy, nil if the token
+// is left out.
+func (c *sentenceConfig) resolveOOV(ft *FastText, word string) ([]float32, error) {
+	if c.oov == nil {
+		return nil, nil
+	}
+	return c.oov(ft, word)
+}
+
+// GetSentenceEmb returns the average of the word embeddings of the
+// tokens, looked up in a single batch. It returns ErrAllOOV if no
+// token has an embedding.
+func (ft *FastText) GetSentenceEmb(tokens []string, opts ...SentenceOption) ([]float32, error) {
+	embs, err := ft.GetEmbs(tokens)
+	if err != nil {
+		return nil, err
+	}
+	return ft.averageEmbs(tokens, embs, newSentenceConfig(opts))
+}
+
+// averageEmbs averages the embeddings of the tokens, following the
+// out-of-vocabulary policy of the configuration.
+func (ft *FastText) averageEmbs(tokens []string, embs [][]float32, cfg *sentenceConfig) ([]float32, error) {
+	var sum []float32
+	var n int
+	for i, emb := range embs {
+		if emb == nil {
+			var err error
+			if emb, err = cfg.resolveOOV(ft, tokens[i]); err != nil {
+				return nil, err
+			}
+			if emb == nil {
+				continue
+			}
+		}
+		if sum == nil {
+			sum = make([]float32, len(emb))
+		}
+		axpy(1, emb, sum)
+		n++
+	}
+	if sum == nil {
+		return nil, ErrAllOOV
+	}
+	return averageVec(sum, n, cfg.normalize), nil
+}
+
+// averageVec divides the sum by n in place, scaling the result to unit length
+// if normalize is set.
+func averageVec(sum []float32, n int, normalize bool) []float32 {
+	alpha := 1 / float32(n)
+	if normalize {
+		if norm := l2norm(sum); norm > 0 {
+			alpha = float32(1 / norm)
+		}
+	}
+	scale(alpha, sum)
+	return sum
+}
@@ -0,0 +1,266 @@
+// Package server exposes a fastText database over a small HTTP/JSON
+// API, so services in other languages can share one database:
+//
+//	GET  /emb/{word}        {"word": "king", "emb": [0.1, ...]}
+//	POST /embs              {"words": ["king", "queen"]} -> {"embs": [[...], null]}
+//	GET  /nn/{word}?k=10    {"word": "king", "neighbors": [{"word": "queen", "score": 0.75}, ...]}
+//
+// Errors are returned as {"error": "..."} with a 404 status for words
+// not in the vocabulary, and a 413 status for /embs bodies over
+// MaxBodyBytes. The look-ups are cancelled when the client disconnects,
+// and their spans (see fasttext.WithTracer) are children of the span of
+// the request context, e.g. of a tracing middleware.
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekzhu/go-fasttext"
+	"github.com/ekzhu/go-fasttext/config"
+)
+
+// DefaultK is the number of neighbors returned by /nn when k is not set.
+const DefaultK = 10
+
+// MaxK bounds the k parameter of /nn.
+const MaxK = 1000
+
+// MaxWords bounds the number of words of a /embs request.
+const MaxWords = 10000
+
+// MaxBodyBytes bounds the size of the body of a /embs request.
+const MaxBodyBytes = 1 << 20
+
+// The timeouts of the server started by ListenAndServe, so that slow
+// clients cannot hold connections open.
+const (
+	ReadHeaderTimeout = 10 * time.Second
+	ReadTimeout       = 30 * time.Second
+	WriteTimeout      = time.Minute
+)
+
+// Server serves the embeddings of a FastText session. It is an
+// http.Handler.
+type Server struct {
+	ft       *fasttext.FastText
+	authKeys [][]byte
+	mux      *http.ServeMux
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAuthKeys requires the requests to carry one of the keys, either
+// as "Authorization: Bearer <key>" or in an "X-API-Key" header.
+func WithAuthKeys(keys ...string) Option {
+	return func(s *Server) {
+		for _, key := range keys {
+			s.authKeys = append(s.authKeys, []byte(key))
+		}
+	}
+}
+
+// New creates a server for the FastText session, which it does not
+// close.
+func New(ft *fasttext.FastText, opts ...Option) *Server {
+	s := &Server{
+		ft:  ft,
+		mux: http.NewServeMux(),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.mux.HandleFunc("/emb/", s.handleEmb)
+	s.mux.HandleFunc("/embs", s.handleEmbs)
+	s.mux.HandleFunc("/nn/", s.handleNN)
+	return s
+}
+
+// ListenAndServe opens the session described by the configuration and
+// serves it on cfg.Server.Addr with cfg.Server.AuthKeys.
+func ListenAndServe(cfg *config.Config) error {
+	ft := cfg.Open()
+	defer ft.Close()
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           New(ft, WithAuthKeys(cfg.Server.AuthKeys...)),
+		ReadHeaderTimeout: ReadHeaderTimeout,
+		ReadTimeout:       ReadTimeout,
+		WriteTimeout:      WriteTimeout,
+	}
+	return srv.ListenAndServe()
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(r) {
+		writeError(w, http.StatusUnauthorized, "missing or invalid API key")
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.authKeys) == 0 {
+		return true
+	}
+	if key := r.Header.Get("X-API-Key"); key != "" {
+		return s.validKey(key)
+	}
+	auth := r.Header.Get("Authorization")
+	if strings.HasPrefix(auth, "Bearer ") {
+		return s.validKey(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return false
+}
+
+// validKey compares the key to all the keys in constant time, so that
+// the response time does not leak them.
+func (s *Server) validKey(key string) bool {
+	valid := 0
+	for _, k := range s.authKeys {
+		valid |= subtle.ConstantTimeCompare([]byte(key), k)
+	}
+	return valid == 1
+}
+
+type embResponse struct {
+	Word string    `json:"word"`
+	Emb  []float32 `json:"emb"`
+}
+
+type embsRequest struct {
+	Words []string `json:"words"`
+}
+
+type embsResponse struct {
+	Embs [][]float32 `json:"embs"`
+}
+
+type nnResponse struct {
+	Word      string     `json:"word"`
+	Neighbors []neighbor `json:"neighbors"`
+}
+
+type neighbor struct {
+	Word  string  `json:"word"`
+	Score float64 `json:"score"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleEmb(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	word, ok := pathWord(w, r, "/emb/")
+	if !ok {
+		return
+	}
+	emb, err := s.ft.GetEmbContext(r.Context(), word)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, embResponse{Word: word, Emb: emb})
+}
+
+func (s *Server) handleEmbs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		if len(body) >= MaxBodyBytes {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body too large")
+		} else {
+			writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		}
+		return
+	}
+	var req embsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	}
+	if len(req.Words) > MaxWords {
+		writeError(w, http.StatusBadRequest, "too many words")
+		return
+	}
+	embs, err := s.ft.GetEmbsContext(r.Context(), req.Words)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, embsResponse{Embs: embs})
+}
+
+func (s *Server) handleNN(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	word, ok := pathWord(w, r, "/nn/")
+	if !ok {
+		return
+	}
+	k := DefaultK
+	if v := r.URL.Query().Get("k"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > MaxK {
+			writeError(w, http.StatusBadRequest, "invalid k")
+			return
+		}
+		k = n
+	}
+	nn, err := s.ft.NearestNeighborsContext(r.Context(), word, k)
+	if err != nil {
+		writeLookupError(w, err)
+		return
+	}
+	resp := nnResponse{Word: word, Neighbors: make([]neighbor, len(nn))}
+	for i, n := range nn {
+		resp.Neighbors[i] = neighbor{Word: n.Word, Score: n.Score}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// pathWord returns the unescaped word following prefix in the path.
+func pathWord(w http.ResponseWriter, r *http.Request, prefix string) (string, bool) {
+	word, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), prefix))
+	if err != nil || word == "" {
+		writeError(w, http.StatusBadRequest, "invalid word")
+		return "", false
+	}
+	return word, true
+}
+
+func writeLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, fasttext.ErrNoEmbFound) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
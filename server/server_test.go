@@ -0,0 +1,182 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ekzhu/go-fasttext"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*httptest.Server, *fasttext.FastText) {
+	ft := fasttext.NewFastText(":memory:")
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	return httptest.NewServer(New(ft, opts...)), ft
+}
+
+func Test_Server(t *testing.T) {
+	ts, ft := newTestServer(t)
+	defer ft.Close()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/emb/has")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emb embResponse
+	json.NewDecoder(resp.Body).Decode(&emb)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || emb.Word != "has" || len(emb.Emb) != 300 {
+		t.Errorf("Unexpected /emb response %d %v", resp.StatusCode, emb.Word)
+	}
+
+	resp, err = http.Get(ts.URL + "/emb/not-a-word")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", resp.StatusCode)
+	}
+
+	resp, err = http.Post(ts.URL+"/embs", "application/json",
+		strings.NewReader(`{"words": ["has", "not-a-word"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var embs embsResponse
+	json.NewDecoder(resp.Body).Decode(&embs)
+	resp.Body.Close()
+	if len(embs.Embs) != 2 || len(embs.Embs[0]) != 300 || embs.Embs[1] != nil {
+		t.Errorf("Unexpected /embs response %v", embs)
+	}
+
+	resp, err = http.Get(ts.URL + "/nn/has?k=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nn nnResponse
+	json.NewDecoder(resp.Body).Decode(&nn)
+	resp.Body.Close()
+	expected, err := ft.NearestNeighbors("has", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nn.Neighbors) != 3 || nn.Neighbors[0].Word != expected[0].Word {
+		t.Errorf("Unexpected /nn response %v", nn)
+	}
+}
+
+func Test_ServerAuth(t *testing.T) {
+	ts, ft := newTestServer(t, WithAuthKeys("secret"))
+	defer ft.Close()
+	defer ts.Close()
+	for key, status := range map[string]int{"": 401, "wrong": 401, "secret": 200} {
+		req, _ := http.NewRequest("GET", ts.URL+"/emb/has", nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Key %q: expected %d, got %d", key, status, resp.StatusCode)
+		}
+	}
+}
+
+func Test_ServerLimits(t *testing.T) {
+	ts, ft := newTestServer(t)
+	defer ft.Close()
+	defer ts.Close()
+	words := make([]string, MaxWords+1)
+	for i := range words {
+		words[i] = "has"
+	}
+	tooMany, _ := json.Marshal(embsRequest{Words: words})
+	for body, status := range map[string]int{
+		string(tooMany): http.StatusBadRequest,
+		`{"words": ["` + strings.Repeat("a", MaxBodyBytes) + `"]}`: http.StatusRequestEntityTooLarge,
+	} {
+		resp, err := http.Post(ts.URL+"/embs", "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Errorf("Body of %d bytes: expected %d, got %d", len(body), status, resp.StatusCode)
+		}
+	}
+}
+
+func Test_ServerCancel(t *testing.T) {
+	ts, ft := newTestServer(t)
+	defer ft.Close()
+	defer ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	h := ts.Config.Handler
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/emb/has", nil),
+		httptest.NewRequest("POST", "/embs", strings.NewReader(`{"words": ["has"]}`)),
+		httptest.NewRequest("GET", "/nn/has?k=3", nil),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req.WithContext(ctx))
+		if w.Code == http.StatusOK || !strings.Contains(w.Body.String(), context.Canceled.Error()) {
+			t.Errorf("%s: expected the look-up to be cancelled, got %d %s", req.URL.Path, w.Code, w.Body)
+		}
+	}
+}
+
+type spanKey struct{}
+
+// testTracer records the spans started in the context of a span of a
+// tracing middleware.
+type testTracer struct {
+	spans []string
+}
+
+func (tr *testTracer) Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, func(error)) {
+	if ctx.Value(spanKey{}) != nil {
+		tr.spans = append(tr.spans, name)
+	}
+	return ctx, func(error) {}
+}
+
+func Test_ServerTracing(t *testing.T) {
+	tr := &testTracer{}
+	ft := fasttext.NewFastText(":memory:", fasttext.WithTracer(tr))
+	defer ft.Close()
+	if err := ft.BuildDBFromFile("../testdata/wiki.en.vec"); err != nil {
+		t.Fatal(err)
+	}
+	s := New(ft)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), spanKey{}, "request")))
+	})
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/emb/has", nil),
+		httptest.NewRequest("POST", "/embs", strings.NewReader(`{"words": ["has"]}`)),
+		httptest.NewRequest("GET", "/nn/has?k=3", nil),
+	} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: %d %s", req.URL.Path, w.Code, w.Body)
+		}
+	}
+	// The look-up of the word of /nn is a child of its search.
+	expected := []string{"fasttext.GetEmb", "fasttext.GetEmbs", "fasttext.NearestNeighbors", "fasttext.GetEmb"}
+	if !reflect.DeepEqual(tr.spans, expected) {
+		t.Errorf("Unexpected spans in the request context %v", tr.spans)
+	}
+}
@@ -0,0 +1,269 @@
+package fasttext
+
+import (
+	"math"
+	"strings"
+)
+
+// DefaultSIFWeight is the smoothing parameter a of SIF recommended by
+// Arora et al.
+const DefaultSIFWeight = 1e-3
+
+// sifIterations is the number of power iterations estimating the
+// common component of a set of sentences.
+const sifIterations = 50
+
+// WithCommonComponent removes the projection of the sentence embedding
+// on the unit vector u, the common component returned by
+// GetSentenceEmbsSIF, from the embedding of GetSentenceEmbSIF.
+func WithCommonComponent(u []float32) SentenceOption {
+	return func(c *sentenceConfig) {
+		c.component = u
+	}
+}
+
+// GetSentenceEmbSIF returns the smooth inverse frequency embedding of
+// the tokens (Arora et al., "A Simple but Tough-to-Beat Baseline for
+// Sentence Embeddings", 2017): the average of their word embeddings
+// weighted by a/(a + p(w)), where p(w) is the unigram probability of
+// the word, so that frequent words count less. Smaller values of a,
+// e.g. DefaultSIFWeight, discount frequent words more.
+//
+// The probabilities come from the frequencies given at build time by
+// WithFrequencies, or else are estimated from the ranks of the words by
+// Zipf's law. Words without either count fully.
+//
+// The second step of SIF, removing the component common to a corpus of
+// sentences, needs the corpus: use GetSentenceEmbsSIF, or pass the
+// component it returns with WithCommonComponent.
+func (ft *FastText) GetSentenceEmbSIF(tokens []string, a float64, opts ...SentenceOption) ([]float32, error) {
+	cfg := newSentenceConfig(opts)
+	embs, err := ft.GetEmbs(tokens)
+	if err != nil {
+		return nil, err
+	}
+	probs, err := ft.unigramProbs(tokens)
+	if err != nil {
+		return nil, err
+	}
+	vec, err := ft.sifAverage(ft.normalizeAll(tokens), embs, probs, a, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.component != nil {
+		removeComponent(vec, cfg.component)
+	}
+	if cfg.normalize {
+		averageVec(vec, 1, true)
+	}
+	return vec, nil
+}
+
+// GetSentenceEmbsSIF returns the SIF embeddings of the sentences, see
+// GetSentenceEmbSIF, with their common component removed: the first
+// singular vector of the matrix of their weighted averages. It also
+// returns that component, to embed further sentences consistently with
+// WithCommonComponent. A sentence without any token in the vocabulary
+// gets a nil embedding, unless the policy of WithSentenceOOVPolicy
+// makes it an error.
+func (ft *FastText) GetSentenceEmbsSIF(sentences [][]string, a float64,
+	opts ...SentenceOption) ([][]float32, []float32, error) {
+	cfg := newSentenceConfig(opts)
+	var all []string
+	for _, tokens := range sentences {
+		all = append(all, tokens...)
+	}
+	embs, err := ft.GetEmbs(all)
+	if err != nil {
+		return nil, nil, err
+	}
+	probs, err := ft.unigramProbs(all)
+	if err != nil {
+		return nil, nil, err
+	}
+	all = ft.normalizeAll(all)
+	vecs := make([][]float32, len(sentences))
+	var start int
+	for i, tokens := range sentences {
+		end := start + len(tokens)
+		vecs[i], err = ft.sifAverage(all[start:end], embs[start:end], probs, a, cfg)
+		if err == ErrAllOOV {
+			err = nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		start = end
+	}
+	u := cfg.component
+	if u == nil {
+		u = firstSingularVector(vecs)
+	}
+	for _, vec := range vecs {
+		if vec == nil {
+			continue
+		}
+		if u != nil {
+			removeComponent(vec, u)
+		}
+		if cfg.normalize {
+			averageVec(vec, 1, true)
+		}
+	}
+	return vecs, u, nil
+}
+
+// sifAverage returns the weighted average of the embeddings of the
+// words, following the out-of-vocabulary policy of the configuration.
+func (ft *FastText) sifAverage(words []string, embs [][]float32, probs map[string]float64, a float64,
+	cfg *sentenceConfig) ([]float32, error) {
+	var sum []float32
+	var n int
+	for i, emb := range embs {
+		if emb == nil {
+			var err error
+			if emb, err = cfg.resolveOOV(ft, words[i]); err != nil {
+				return nil, err
+			}
+			if emb == nil {
+				continue
+			}
+		}
+		if sum == nil {
+			sum = make([]float32, len(emb))
+		}
+		axpy(float32(a/(a+probs[words[i]])), emb, sum)
+		n++
+	}
+	if sum == nil {
+		return nil, ErrAllOOV
+	}
+	return averageVec(sum, n, false), nil
+}
+
+// removeComponent subtracts the projection of vec on the unit vector u.
+func removeComponent(vec, u []float32) {
+	axpy(-float32(dot(vec, u)), u, vec)
+}
+
+// firstSingularVector returns the first right singular vector of the
+// matrix of the non-nil vectors, computed by power iteration, or nil if
+// there are none.
+func firstSingularVector(vecs [][]float32) []float32 {
+	var dim int
+	for _, vec := range vecs {
+		if vec != nil {
+			dim = len(vec)
+			break
+		}
+	}
+	if dim == 0 {
+		return nil
+	}
+	u := make([]float32, dim)
+	for i := range u {
+		u[i] = 1 / float32(math.Sqrt(float64(dim)))
+	}
+	next := make([]float32, dim)
+	for iter := 0; iter < sifIterations; iter++ {
+		for i := range next {
+			next[i] = 0
+		}
+		// next = X^T X u
+		for _, vec := range vecs {
+			if vec != nil {
+				axpy(float32(dot(vec, u)), vec, next)
+			}
+		}
+		norm := l2norm(next)
+		if norm == 0 {
+			return nil
+		}
+		scale(float32(1/norm), next)
+		u, next = next, u
+	}
+	return u
+}
+
+// unigramProbs returns the unigram probabilities of the words in the
+// vocabulary with a frequency or a rank.
+func (ft *FastText) unigramProbs(words []string) (map[string]float64, error) {
+	words = ft.normalizeAll(words)
+	col, err := ft.rankColumn()
+	if err != nil {
+		return nil, err
+	}
+	freqCol := "freq"
+	if col == "rowid" {
+		// Databases without ranks have no frequencies either.
+		freqCol = "NULL"
+	}
+	total, vocab, err := ft.freqTotals(freqCol)
+	if err != nil {
+		return nil, err
+	}
+	// Zipf's law: p(r) = 1 / (r H(n)), with H(n) ~ ln(n) + γ.
+	harmonic := math.Log(float64(vocab)) + 0.5772
+	probs := make(map[string]float64, len(words))
+	unique := make([]string, 0, len(words))
+	for _, word := range words {
+		if _, ok := probs[word]; !ok {
+			probs[word] = 0
+			unique = append(unique, word)
+		}
+	}
+	for start := 0; start < len(unique); start += maxBatchVars {
+		batch := unique[start:minInt(start+maxBatchVars, len(unique))]
+		args := make([]interface{}, len(batch))
+		for i, word := range batch {
+			args[i] = word
+		}
+		placeholders := strings.Repeat("?, ", len(batch)-1) + "?"
+		rows, err := ft.db.Query(ft.sql(`SELECT word, `+col+`, `+freqCol+` FROM fasttext
+			WHERE word IN (`+placeholders+`);`), args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var word string
+			var rank, freq *int64
+			if err := rows.Scan(&word, &rank, &freq); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			switch {
+			case freq != nil && total > 0:
+				probs[word] = float64(*freq) / float64(total)
+			case rank != nil && *rank > 0 && vocab > 0:
+				probs[word] = 1 / (float64(*rank) * harmonic)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return probs, nil
+}
+
+// freqStats are the totals of the vocabulary used by unigramProbs.
+type freqStats struct {
+	total, vocab int64
+}
+
+// freqTotals returns the sum of the frequencies and the size of the
+// vocabulary, computed on first use.
+func (ft *FastText) freqTotals(freqCol string) (int64, int64, error) {
+	ft.formatMu.Lock()
+	defer ft.formatMu.Unlock()
+	if ft.freqStats == nil {
+		s := &freqStats{}
+		err := ft.db.QueryRow(ft.sql(`SELECT IFNULL(SUM(`+freqCol+`), 0), COUNT(*) FROM fasttext;`)).
+			Scan(&s.total, &s.vocab)
+		if err != nil {
+			return 0, 0, err
+		}
+		ft.freqStats = s
+	}
+	return ft.freqStats.total, ft.freqStats.vocab, nil
+}
@@ -0,0 +1,82 @@
+package fasttext
+
+import "gonum.org/v1/gonum/blas"
+
+// CosineSimilarity returns the cosine similarity between two vectors
+// of the same length. It returns 0 if either vector is all zeros.
+func CosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		panic("fasttext: vectors of different lengths")
+	}
+	return cosine(a, b, l2norm(a))
+}
+
+// CosineDistance returns 1 minus the cosine similarity between two
+// vectors of the same length.
+func CosineDistance(a, b []float32) float64 {
+	return 1 - CosineSimilarity(a, b)
+}
+
+// Similarity returns the cosine similarity between the embeddings
+// of two words.
+func (ft *FastText) Similarity(w1, w2 string) (float64, error) {
+	v1, err := ft.GetEmb(w1)
+	if err != nil {
+		return 0, err
+	}
+	v2, err := ft.GetEmb(w2)
+	if err != nil {
+		return 0, err
+	}
+	return CosineSimilarity(v1, v2), nil
+}
+
+// Distance returns the cosine distance between the embeddings of
+// two words.
+func (ft *FastText) Distance(w1, w2 string) (float64, error) {
+	sim, err := ft.Similarity(w1, w2)
+	if err != nil {
+		return 0, err
+	}
+	return 1 - sim, nil
+}
+
+// SimilarityMatrix returns the cosine similarities between all pairs of
+// the words, looked up in batches and multiplied at once: the similarity of words[i] and
+// words[j] is m[i][j], and m is symmetric. It returns a
+// *WordNotFoundError if a word is missing from the vocabulary.
+func (ft *FastText) SimilarityMatrix(words []string) ([][]float64, error) {
+	embs, err := ft.GetEmbs(words)
+	if err != nil {
+		return nil, err
+	}
+	dim := 0
+	for i, emb := range embs {
+		if emb == nil {
+			return nil, &WordNotFoundError{Word: words[i]}
+		}
+		dim = len(emb)
+	}
+	// The unit vectors as rows of a matrix A, whose product A·Aᵀ holds
+	// the similarities.
+	a := make([]float32, len(embs)*dim)
+	for i, emb := range embs {
+		copy(a[i*dim:], unitVec(emb))
+	}
+	n := len(words)
+	c := make([]float32, n*n)
+	if n > 0 && dim > 0 {
+		blas32.Ssyrk(blas.Upper, blas.NoTrans, n, dim, 1, a, dim, 0, c, n)
+	}
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			m[i][j] = float64(c[i*n+j])
+			m[j][i] = m[i][j]
+		}
+	}
+	return m, nil
+}
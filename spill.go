@@ -0,0 +1,242 @@
+package fasttext
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// SpillFileSuffix is appended to the database file name to name the
+// file of its quantized vectors, used by the disk-spill searches. For
+// a model stored with WithTableName, the table name is inserted before it.
+const SpillFileSuffix = ".q8"
+
+// DefaultMemoryBudget is the memory budget of disk-spill searches in
+// megabytes, unless set with WithMemoryBudget.
+const DefaultMemoryBudget = 16
+
+var (
+	// ErrNoSpillFile is returned by disk-spill searches when no
+	// quantized vector file has been built for the database.
+	ErrNoSpillFile = errors.New("No spill file found, call BuildSpillFile first")
+
+	spillMagic = []byte("FTQ8\n")
+)
+
+// spillRerank is the factor of extra candidates kept by the quantized
+// scan to be re-scored with the exact vectors.
+const spillRerank = 4
+
+// WithMemoryBudget bounds the memory used by the disk-spill searches to
+// about mb megabytes: the quantized vectors are streamed from disk in
+// blocks of that size.
+func WithMemoryBudget(mb int) Option {
+	return func(ft *FastText) {
+		ft.memBudget = mb
+	}
+}
+
+// BuildSpillFile writes the vectors of the vocabulary, quantized to
+// 8-bit integers, next to the database file with the SpillFileSuffix.
+// The file is about a quarter of the size of the float32 matrix and is
+// read sequentially by NearestNeighborsSpill, so an exact search never
+// needs the matrix in memory. It must be rebuilt when the vocabulary
+// changes.
+func (ft *FastText) BuildSpillFile() error {
+	if ft.dbPath() == "" {
+		return errors.New("fasttext: disk-spill search needs an on-disk database")
+	}
+	count, err := ft.vocabSize()
+	if err != nil {
+		return err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(ft.sidecar(SpillFileSuffix))
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(file)
+	if _, err = w.Write(spillMagic); err == nil {
+		err = binary.Write(w, binary.LittleEndian, []int64{int64(f.dim), int64(count)})
+	}
+	q := make([]int8, f.dim)
+	if err == nil {
+		err = ft.ForEach(func(word string, emb []float32) error {
+			if len(emb) != len(q) {
+				return fmt.Errorf("fasttext: embedding of %q has %d dimensions, expected %d",
+					word, len(emb), len(q))
+			}
+			if len(word) > math.MaxUint16 {
+				return fmt.Errorf("fasttext: word of %d bytes too long for the spill file", len(word))
+			}
+			scale := quantize(q, emb)
+			if err := binary.Write(w, binary.LittleEndian, uint16(len(word))); err != nil {
+				return err
+			}
+			if _, err := w.WriteString(word); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, scale); err != nil {
+				return err
+			}
+			return binary.Write(w, binary.LittleEndian, q)
+		})
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return err
+	}
+	return file.Close()
+}
+
+// quantize writes the unit vector of vec to q, scaled to the int8
+// range, and returns the scale to multiply q by to get it back.
+func quantize(q []int8, vec []float32) float32 {
+	n := l2norm(vec)
+	var maxAbs float64
+	for _, v := range vec {
+		if a := math.Abs(float64(v)); a > maxAbs {
+			maxAbs = a
+		}
+	}
+	if n == 0 || maxAbs == 0 {
+		for i := range q {
+			q[i] = 0
+		}
+		return 0
+	}
+	for i, v := range vec {
+		q[i] = int8(math.Floor(float64(v)/maxAbs*127 + 0.5))
+	}
+	return float32(maxAbs / n / 127)
+}
+
+// NearestNeighborsSpill is an approximate version of NearestNeighbors
+// within a bounded memory budget (see WithMemoryBudget): it streams the
+// file built by BuildSpillFile to select the best candidates by their
+// quantized vectors, and re-scores them with their exact vectors. True
+// neighbors ranked past the candidates by the quantization error are
+// missed, and the search options apply to the quantized scores. It
+// returns ErrNoSpillFile if there is no file.
+func (ft *FastText) NearestNeighborsSpill(word string, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	return ft.nearestSpill(vec, k, keepWith(ft.excludeWords(word), opts))
+}
+
+// NearestByVectorSpill is the disk-spill version of NearestByVector.
+func (ft *FastText) NearestByVectorSpill(vec []float32, k int, opts ...SearchOption) ([]ScoredWord, error) {
+	if err := ft.checkDim(vec); err != nil {
+		return nil, err
+	}
+	return ft.nearestSpill(vec, k, keepWith(ft.excludeWords(), opts))
+}
+
+func (ft *FastText) nearestSpill(vec []float32, k int, keep func(word string, score float64) bool) ([]ScoredWord, error) {
+	if k <= 0 {
+		return nil, nil
+	}
+	if ft.dbPath() == "" {
+		return nil, ErrNoSpillFile
+	}
+	file, err := os.Open(ft.sidecar(SpillFileSuffix))
+	if os.IsNotExist(err) {
+		return nil, ErrNoSpillFile
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	budget := ft.memBudget
+	if budget <= 0 {
+		budget = DefaultMemoryBudget
+	}
+	r := bufio.NewReaderSize(file, budget<<20)
+	magic := make([]byte, len(spillMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != string(spillMagic) {
+		return nil, errors.New("fasttext: not a spill file")
+	}
+	var header [2]int64
+	if err := binary.Read(r, binary.LittleEndian, header[:]); err != nil {
+		return nil, err
+	}
+	dim, n := int(header[0]), int(header[1])
+	if dim != len(vec) {
+		return nil, fmt.Errorf("fasttext: spill file has %d dimensions, expected %d", dim, len(vec))
+	}
+	count, err := ft.vocabSize()
+	if err != nil {
+		return nil, err
+	}
+	if count != n {
+		return nil, fmt.Errorf("fasttext: stale spill file of %d words for %d words, rebuild it", n, count)
+	}
+
+	// Quantized scan.
+	qnorm := l2norm(vec)
+	candidates := NewTopK(k * spillRerank)
+	record := make([]byte, 4+dim)
+	var wordLen uint16
+	for i := 0; i < n; i++ {
+		if err := binary.Read(r, binary.LittleEndian, &wordLen); err != nil {
+			return nil, err
+		}
+		word := make([]byte, wordLen)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, record); err != nil {
+			return nil, err
+		}
+		scale := math.Float32frombits(binary.LittleEndian.Uint32(record))
+		var dot float64
+		for j, q := range record[4:] {
+			dot += float64(vec[j]) * float64(int8(q))
+		}
+		score := 0.0
+		if qnorm != 0 {
+			score = dot * float64(scale) / qnorm
+		}
+		if keep(string(word), score) {
+			candidates.Push(ScoredWord{Word: string(word), Score: score})
+		}
+	}
+
+	// Exact re-scoring.
+	approx := candidates.Sorted()
+	words := make([]string, len(approx))
+	for i, c := range approx {
+		words[i] = c.Word
+	}
+	embs, err := ft.lookupEmbs(words)
+	if err != nil {
+		return nil, err
+	}
+	top := NewTopK(k)
+	for i, emb := range embs {
+		if emb != nil {
+			top.Push(ScoredWord{Word: words[i], Score: cosine(vec, emb, qnorm)})
+		}
+	}
+	return top.Sorted(), nil
+}
+
+// vocabSize returns the number of words in the database.
+func (ft *FastText) vocabSize() (int, error) {
+	var count int
+	err := ft.db.QueryRow(ft.sql(`SELECT COUNT(*) FROM fasttext;`)).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,107 @@
+package fasttext
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// sqliteConnector opens connections to the SQLite3 database of a
+// session, registering the SQL functions of the package on each of them.
+type sqliteConnector struct {
+	src    *dbSource
+	driver *sqlite3.SQLiteDriver
+}
+
+func newSQLiteConnector(src *dbSource, ft *FastText) *sqliteConnector {
+	return &sqliteConnector{
+		src: src,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: ft.connectHook,
+		},
+	}
+}
+
+func (c *sqliteConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.src.open(c.driver.Open)
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// connectHook prepares a new connection of the built-in driver.
+func (ft *FastText) connectHook(conn *sqlite3.SQLiteConn) error {
+	if err := ft.registerFuncs(conn); err != nil {
+		return err
+	}
+	return execPragmas(conn, ft.pragmas)
+}
+
+// registerFuncs registers the SQL functions available to QueryRaw:
+//
+//	cosine(emb1, emb2)  cosine similarity between two embedding blobs
+func (ft *FastText) registerFuncs(conn *sqlite3.SQLiteConn) error {
+	return conn.RegisterFunc("cosine", func(a, b []byte) (float64, error) {
+		va, err := ft.decode(a)
+		if err != nil {
+			return 0, err
+		}
+		vb, err := ft.decode(b)
+		if err != nil {
+			return 0, err
+		}
+		if len(va) != len(vb) {
+			return 0, errors.New("cosine: embeddings of different lengths")
+		}
+		return cosine(va, vb, l2norm(va)), nil
+	}, true)
+}
+
+// QueryRaw runs a read-only SQL query against the database and calls fn
+// on its result rows. The query can use the cosine(emb1, emb2) SQL
+// function; []float32 arguments are bound as encoded embeddings, so
+// words can be filtered by pattern and similarity at once:
+//
+//	q, _ := ft.GetEmb("king")
+//	err := ft.QueryRaw(func(rows *sql.Rows) error {
+//		for rows.Next() {
+//			...
+//		}
+//		return rows.Err()
+//	}, `SELECT word, cosine(emb, ?) AS sim FROM fasttext
+//		WHERE word LIKE 'k%' AND sim > 0.5 ORDER BY sim DESC;`, q)
+//
+// The query runs with SQLite's query_only pragma set, so it cannot
+// modify the database.
+func (ft *FastText) QueryRaw(fn func(rows *sql.Rows) error, query string, args ...interface{}) error {
+	ctx := context.Background()
+	conn, err := ft.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `PRAGMA query_only = ON;`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(ctx, `PRAGMA query_only = OFF;`)
+	for i, arg := range args {
+		if vec, ok := arg.([]float32); ok {
+			if args[i], err = ft.encode(vec); err != nil {
+				return err
+			}
+		}
+	}
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err := fn(rows); err != nil {
+		return err
+	}
+	return rows.Close()
+}
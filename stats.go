@@ -0,0 +1,78 @@
+package fasttext
+
+import (
+	"strconv"
+	"time"
+)
+
+// metaBuiltAt is the metadata key of the build time, in RFC 3339 format.
+const metaBuiltAt = "built_at"
+
+// Stats describes the database of a session.
+type Stats struct {
+	// Words is the size of the vocabulary.
+	Words int
+	// Dim is the dimension of the vectors, 0 if unknown.
+	Dim int
+	// Codec is the storage format of the vectors.
+	Codec Codec
+	// FloatWidth is the number of bytes per stored value.
+	FloatWidth int
+	// Compression is the compression of the stored vectors.
+	Compression Compression
+	// Normalized is set if the stored vectors have unit length.
+	Normalized bool
+	// Size is the size of the database in bytes.
+	Size int64
+	// BuiltAt is the time the database was built, zero if unknown.
+	BuiltAt time.Time
+	// BadLines is the number of malformed lines of the input of the
+	// build, see WithParseMode.
+	BadLines int64
+}
+
+// Stats returns statistics about the database, e.g. for health checks
+// or to verify a deployment serves the expected model.
+func (ft *FastText) Stats() (Stats, error) {
+	var s Stats
+	var err error
+	if s.Words, err = ft.vocabSize(); err != nil {
+		return s, err
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		return s, err
+	}
+	s.Dim = f.dim
+	s.Codec = f.codec
+	s.FloatWidth = f.codec.Width()
+	s.Compression = f.compression()
+	s.Normalized = f.normalized
+	var pages, pageSize int64
+	if err := ft.db.QueryRow(`PRAGMA page_count;`).Scan(&pages); err != nil {
+		return s, err
+	}
+	if err := ft.db.QueryRow(`PRAGMA page_size;`).Scan(&pageSize); err != nil {
+		return s, err
+	}
+	s.Size = pages * pageSize
+	value, ok, err := ft.getMeta(metaBadLines)
+	if err != nil {
+		return s, err
+	}
+	if ok {
+		if s.BadLines, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return s, err
+		}
+	}
+	value, ok, err = ft.getMeta(metaBuiltAt)
+	if err != nil {
+		return s, err
+	}
+	if ok {
+		if s.BuiltAt, err = time.Parse(time.RFC3339, value); err != nil {
+			return s, err
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,118 @@
+package fasttext
+
+import (
+	"context"
+	"runtime"
+)
+
+// EmbResult is the result of the lookup of a word by StreamEmbs.
+type EmbResult struct {
+	Word string
+	Emb  []float32
+	// Err is a *WordNotFoundError for a word missing from the vocabulary,
+	// or the error of the lookup.
+	Err error
+}
+
+// streamBatch is a batch of words looked up by StreamEmbs.
+type streamBatch struct {
+	words []string
+	embs  [][]float32
+	err   error
+	// done is closed once the batch is looked up.
+	done chan struct{}
+}
+
+// StreamEmbs looks up the words received from the channel and sends
+// their embeddings on the returned channel, in the order of the words,
+// e.g. for offline feature extraction over more tokens than fit in
+// memory. The words available at once are looked up in batches, with
+// several batches in flight over the connection pool. The returned
+// channel is closed once words is closed and all the results are sent,
+// or when the context is cancelled, after which words is no longer read
+// and the look-ups in flight are cancelled.
+func (ft *FastText) StreamEmbs(ctx context.Context, words <-chan string) <-chan EmbResult {
+	workers := runtime.GOMAXPROCS(0)
+	jobs := make(chan *streamBatch)
+	// pending holds the batches in flight in order.
+	pending := make(chan *streamBatch, workers)
+	out := make(chan EmbResult, maxBatchVars)
+
+	go func() {
+		defer close(jobs)
+		defer close(pending)
+		for closed := false; !closed; {
+			batch := &streamBatch{done: make(chan struct{})}
+			select {
+			case word, ok := <-words:
+				if !ok {
+					return
+				}
+				batch.words = append(batch.words, word)
+			case <-ctx.Done():
+				return
+			}
+			// Take the words already available without waiting.
+		fill:
+			for len(batch.words) < maxBatchVars {
+				select {
+				case word, ok := <-words:
+					if !ok {
+						closed = true
+						break fill
+					}
+					batch.words = append(batch.words, word)
+				default:
+					break fill
+				}
+			}
+			select {
+			case pending <- batch:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- batch:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for batch := range jobs {
+				batch.embs, batch.err = ft.GetEmbsContext(ctx, batch.words)
+				close(batch.done)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(out)
+		for batch := range pending {
+			select {
+			case <-batch.done:
+			case <-ctx.Done():
+				return
+			}
+			for i, word := range batch.words {
+				r := EmbResult{Word: word}
+				switch {
+				case batch.err != nil:
+					r.Err = batch.err
+				case batch.embs[i] == nil:
+					r.Err = &WordNotFoundError{Word: word}
+				default:
+					r.Emb = batch.embs[i]
+				}
+				select {
+				case out <- r:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
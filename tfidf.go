@@ -0,0 +1,80 @@
+package fasttext
+
+import "sort"
+
+// WithDefaultIDF sets the IDF of the words missing from the IDF table
+// of EmbedDocument, by default the largest IDF of the table: words
+// unseen when the table was computed are taken as the rarest.
+func WithDefaultIDF(idf float64) SentenceOption {
+	return func(c *sentenceConfig) {
+		c.defaultIDF = &idf
+	}
+}
+
+// EmbedDocument returns the TF-IDF weighted average of the word
+// embeddings of the words of a document, given their number of
+// occurrences in the document and their inverse document frequencies,
+// e.g. log(N/df) over a corpus of N documents. The words are looked up
+// in a single batch. Words missing from the IDF table get the IDF of
+// WithDefaultIDF, and words missing from the vocabulary are treated as
+// set by WithSentenceOOVPolicy. It returns ErrAllOOV if no word of the
+// document has an embedding.
+func (ft *FastText) EmbedDocument(tokenCounts map[string]int, idf map[string]float64,
+	opts ...SentenceOption) ([]float32, error) {
+	cfg := newSentenceConfig(opts)
+	defaultIDF := 0.0
+	if cfg.defaultIDF != nil {
+		defaultIDF = *cfg.defaultIDF
+	} else {
+		for _, v := range idf {
+			if v > defaultIDF {
+				defaultIDF = v
+			}
+		}
+	}
+	// The words are sorted for reproducible sums.
+	words := make([]string, 0, len(tokenCounts))
+	for word, n := range tokenCounts {
+		if n > 0 {
+			words = append(words, word)
+		}
+	}
+	sort.Strings(words)
+	embs, err := ft.GetEmbs(words)
+	if err != nil {
+		return nil, err
+	}
+	var sum []float32
+	var total float64
+	for i, emb := range embs {
+		word := words[i]
+		weight, ok := idf[word]
+		if !ok {
+			weight = defaultIDF
+		}
+		weight *= float64(tokenCounts[word])
+		if emb == nil {
+			if emb, err = cfg.resolveOOV(ft, word); err != nil {
+				return nil, err
+			}
+			if emb == nil {
+				continue
+			}
+		}
+		if sum == nil {
+			sum = make([]float32, len(emb))
+		}
+		axpy(float32(weight), emb, sum)
+		total += weight
+	}
+	if sum == nil {
+		return nil, ErrAllOOV
+	}
+	if total != 0 {
+		scale(float32(1/total), sum)
+	}
+	if cfg.normalize {
+		averageVec(sum, 1, true)
+	}
+	return sum, nil
+}
@@ -0,0 +1,50 @@
+package fasttext
+
+import (
+	"context"
+	"time"
+)
+
+// WithQueryTimeout bounds each look-up and exact neighbor search of the
+// session to d: a query stalled longer, e.g. on a disk under heavy IO
+// contention, is interrupted and fails with a *QueryTimeoutError. The
+// batch look-ups of GetEmbs are bounded per query of up to
+// maxBatchVars words. Builds, iterations and preloading are not
+// bounded.
+func WithQueryTimeout(d time.Duration) Option {
+	return func(ft *FastText) {
+		ft.queryTimeout = d
+	}
+}
+
+// queryContext returns the context of a query derived from parent,
+// bounded by the timeout of WithQueryTimeout, if any.
+func (ft *FastText) queryContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if ft.queryTimeout <= 0 {
+		return parent, func() {}
+	}
+	return context.WithTimeout(parent, ft.queryTimeout)
+}
+
+// timedOut replaces the error of a query interrupted by the deadline of
+// its context with a *QueryTimeoutError, and by its cancellation with
+// context.Canceled.
+func (ft *FastText) timedOut(ctx context.Context, err error) error {
+	if err != nil {
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			return &QueryTimeoutError{Timeout: ft.queryTimeout}
+		case context.Canceled:
+			return context.Canceled
+		}
+	}
+	return err
+}
+
+// timedLookupBatch is lookupBatch bounded by the timeout of
+// WithQueryTimeout.
+func (ft *FastText) timedLookupBatch(ctx context.Context, words []string, fn func(word string, vec []float32)) error {
+	ctx, cancel := ft.queryContext(ctx)
+	defer cancel()
+	return ft.timedOut(ctx, ft.lookupBatch(ctx, words, fn))
+}
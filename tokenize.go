@@ -0,0 +1,72 @@
+package fasttext
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Tokenizer splits raw text into the tokens looked up by EmbedText.
+type Tokenizer interface {
+	Tokenize(text string) []string
+}
+
+// TokenizerFunc adapts a function to the Tokenizer interface.
+type TokenizerFunc func(text string) []string
+
+// Tokenize calls f(text).
+func (f TokenizerFunc) Tokenize(text string) []string {
+	return f(text)
+}
+
+// DefaultTokenizer is the tokenizer of EmbedText unless set with
+// WithTokenizer. It splits the text into runs of letters, digits and
+// combining marks, dropping punctuation and white space.
+var DefaultTokenizer Tokenizer = TokenizerFunc(unicodeWords)
+
+func unicodeWords(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+}
+
+// WithTokenizer sets the tokenizer of EmbedText, e.g. one matching the
+// preprocessing of the training corpus of the model.
+func WithTokenizer(t Tokenizer) Option {
+	return func(ft *FastText) {
+		ft.tokenizer = t
+	}
+}
+
+// TokenHit reports whether a token of a text is in the vocabulary.
+type TokenHit struct {
+	Token string
+	Found bool
+}
+
+// EmbedText tokenizes the raw text and returns the average embedding of
+// its tokens, see GetSentenceEmb.
+func (ft *FastText) EmbedText(text string, opts ...SentenceOption) ([]float32, error) {
+	vec, _, err := ft.EmbedTextDetails(text, opts...)
+	return vec, err
+}
+
+// EmbedTextDetails is EmbedText also reporting, for each token in
+// order, whether it is in the vocabulary, e.g. to measure coverage. The
+// tokens are reported even if the embedding fails.
+func (ft *FastText) EmbedTextDetails(text string, opts ...SentenceOption) ([]float32, []TokenHit, error) {
+	tokenizer := ft.tokenizer
+	if tokenizer == nil {
+		tokenizer = DefaultTokenizer
+	}
+	tokens := tokenizer.Tokenize(text)
+	embs, err := ft.GetEmbs(tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+	hits := make([]TokenHit, len(tokens))
+	for i, token := range tokens {
+		hits[i] = TokenHit{Token: token, Found: embs[i] != nil}
+	}
+	vec, err := ft.averageEmbs(tokens, embs, newSentenceConfig(opts))
+	return vec, hits, err
+}
@@ -0,0 +1,33 @@
+package fasttext
+
+import "context"
+
+// Tracer creates the spans of the queries of a session, e.g. with an
+// adapter to an OpenTelemetry tracer. Start begins a span of the given
+// name with attributes given as alternating keys and values, and returns
+// the context of the span and a function ending it with the error of
+// the query, if any.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...interface{}) (context.Context, func(err error))
+}
+
+// WithTracer traces GetEmb, GetEmbs and NearestNeighbors, and their
+// Context variants, with spans of t named "fasttext.GetEmb",
+// "fasttext.GetEmbs" and "fasttext.NearestNeighbors", with the number
+// of queried words as the "words" attribute. The spans of the Context
+// variants are children of the span of their context, so the SQL
+// queries of an instrumented connector (see WithOpenDB) are children
+// of them.
+func WithTracer(t Tracer) Option {
+	return func(ft *FastText) {
+		ft.tracer = t
+	}
+}
+
+// startSpan starts a span of the tracer of WithTracer, if any.
+func (ft *FastText) startSpan(ctx context.Context, name string, attrs ...interface{}) (context.Context, func(error)) {
+	if ft.tracer == nil {
+		return ctx, func(error) {}
+	}
+	return ft.tracer.Start(ctx, name, attrs...)
+}
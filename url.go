@@ -0,0 +1,126 @@
+package fasttext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// urlMaxRetries is the number of times a download is resumed in a row
+// after failing.
+const urlMaxRetries = 5
+
+// urlRetryDelay is the delay before the first retry of a download,
+// doubled at each retry.
+var urlRetryDelay = time.Second
+
+// BuildDBFromURL initializes the SQLite3 database from a word embedding
+// file downloaded from the URL, e.g. one of the .vec.gz files of
+// https://fasttext.cc/docs/en/crawl-vectors.html, streaming it into the
+// database without storing it on disk. Gzip compressed and zipped files
+// are decompressed transparently. If the connection fails, the download
+// resumes where it stopped with a Range request, provided the server
+// supports them. Cancelling the context stops the build.
+func (ft *FastText) BuildDBFromURL(ctx context.Context, url string, opts ...BuildOption) error {
+	body := &urlReader{ctx: ctx, client: http.DefaultClient, url: url}
+	defer body.Close()
+	return ft.BuildDB(body, opts...)
+}
+
+// urlReader reads the body of a URL, resuming the download after
+// errors.
+type urlReader struct {
+	ctx    context.Context
+	client *http.Client
+	url    string
+	body   io.ReadCloser
+	// offset is the number of bytes read so far.
+	offset int64
+	// etag identifies the version of the file, so that a resumed
+	// download does not mix versions.
+	etag    string
+	ranges  bool
+	retries int
+}
+
+func (r *urlReader) Read(p []byte) (int, error) {
+	for {
+		if r.body == nil {
+			if err := r.open(); err != nil {
+				return 0, err
+			}
+		}
+		n, err := r.body.Read(p)
+		r.offset += int64(n)
+		if n > 0 {
+			r.retries = 0
+		}
+		if err == nil || err == io.EOF {
+			return n, err
+		}
+		r.body.Close()
+		r.body = nil
+		if r.ctx.Err() != nil || !r.ranges || r.retries >= urlMaxRetries {
+			return n, fmt.Errorf("fasttext: reading %s: %w", r.url, err)
+		}
+		if n > 0 {
+			return n, nil
+		}
+		select {
+		case <-time.After(urlRetryDelay << uint(r.retries)):
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+		r.retries++
+	}
+}
+
+// open requests the rest of the file from the current offset.
+func (r *urlReader) open() error {
+	req, err := http.NewRequest(http.MethodGet, r.url, nil)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(r.ctx)
+	if r.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", r.offset))
+		if r.etag != "" {
+			req.Header.Set("If-Range", r.etag)
+		}
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && r.offset > 0:
+	case resp.StatusCode == http.StatusOK && r.offset == 0:
+		r.etag = resp.Header.Get("ETag")
+		r.ranges = resp.Header.Get("Accept-Ranges") == "bytes"
+	case resp.StatusCode == http.StatusOK:
+		// The file changed since the download started, or the server
+		// ignored the range.
+		resp.Body.Close()
+		return errors.New("fasttext: cannot resume download of " + r.url)
+	default:
+		resp.Body.Close()
+		return fmt.Errorf("fasttext: GET %s: %s", r.url, resp.Status)
+	}
+	r.body = resp.Body
+	return nil
+}
+
+func (r *urlReader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	// Drain a little so the connection can be reused.
+	io.CopyN(ioutil.Discard, r.body, 4<<10)
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
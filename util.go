@@ -1,28 +1,38 @@
 package fasttext
 
 import (
-	"bytes"
-	"encoding/binary"
+	"math"
+	"strings"
 )
 
-func vecToBytes(vec []float32, order binary.ByteOrder) []byte {
-	buf := new(bytes.Buffer)
-	for _, v := range vec {
-		binary.Write(buf, order, v)
-	}
-	return buf.Bytes()
+func copyVec(vec []float32) []float32 {
+	out := make([]float32, len(vec))
+	copy(out, vec)
+	return out
+}
+
+func l2norm(vec []float32) float64 {
+	return math.Sqrt(dot(vec, vec))
 }
 
-func bytesToVec(data []byte, order binary.ByteOrder) ([]float32, error) {
-	size := len(data) / 4
-	vec := make([]float32, size)
-	buf := bytes.NewReader(data)
-	var v float32
-	for i := range vec {
-		if err := binary.Read(buf, order, &v); err != nil {
-			return nil, err
-		}
-		vec[i] = v
+// cosine returns the cosine similarity between a and b, given the
+// precomputed norm of a.
+func cosine(a, b []float32, anorm float64) float64 {
+	bb := dot(b, b)
+	if anorm == 0 || bb == 0 {
+		return 0
 	}
-	return vec, nil
+	return dot(a, b) / (anorm * math.Sqrt(bb))
+}
+
+// isNoSuchColumn returns whether the error is SQLite's complaint about
+// a missing column, e.g. a column added to databases built since.
+func isNoSuchColumn(err error) bool {
+	return strings.Contains(err.Error(), "no such column")
+}
+
+// isNoSuchTable returns whether the error is SQLite's complaint about
+// a missing table, e.g. the metadata table of an old database.
+func isNoSuchTable(err error) bool {
+	return strings.Contains(err.Error(), "no such table")
 }
@@ -0,0 +1,87 @@
+package fasttext
+
+import "math"
+
+// Vector is a word embedding with arithmetic methods, for composing
+// vectors without hand-written loops:
+//
+//	king, _ := ft.GetVector("king")
+//	man, _ := ft.GetVector("man")
+//	woman, _ := ft.GetVector("woman")
+//	nn, err := ft.NearestByVector(king.Sub(man).Add(woman), 10)
+//
+// The methods return new vectors and leave their operands unchanged.
+// Operands must have the same length.
+type Vector []float32
+
+// GetVector returns the word embedding of the given word as a Vector.
+func (ft *FastText) GetVector(word string) (Vector, error) {
+	emb, err := ft.GetEmb(word)
+	return Vector(emb), err
+}
+
+// Add returns v + w.
+func (v Vector) Add(w Vector) Vector {
+	checkLen(v, w)
+	out := v.copy()
+	axpy(1, w, out)
+	return out
+}
+
+// Sub returns v - w.
+func (v Vector) Sub(w Vector) Vector {
+	checkLen(v, w)
+	out := v.copy()
+	axpy(-1, w, out)
+	return out
+}
+
+// Scale returns alpha times v.
+func (v Vector) Scale(alpha float32) Vector {
+	out := v.copy()
+	scale(alpha, out)
+	return out
+}
+
+// Norm returns the Euclidean length of v.
+func (v Vector) Norm() float64 {
+	return l2norm(v)
+}
+
+// Normalize returns v scaled to unit length, or a copy of v if it is
+// all zeros.
+func (v Vector) Normalize() Vector {
+	n := l2norm(v)
+	if n == 0 {
+		return v.copy()
+	}
+	return v.Scale(float32(1 / n))
+}
+
+// Dot returns the dot product of v and w.
+func (v Vector) Dot(w Vector) float64 {
+	checkLen(v, w)
+	return dot(v, w)
+}
+
+// Cosine returns the cosine similarity between v and w, 0 if either is
+// all zeros.
+func (v Vector) Cosine(w Vector) float64 {
+	return CosineSimilarity(v, w)
+}
+
+// Distance returns the Euclidean distance between v and w.
+func (v Vector) Distance(w Vector) float64 {
+	d := v.Sub(w)
+	return math.Sqrt(dot(d, d))
+}
+
+func (v Vector) copy() Vector {
+	return Vector(copyVec(v))
+}
+
+func checkLen(v, w Vector) {
+	if len(v) != len(w) {
+		panic("fasttext: vectors of different lengths")
+	}
+}
@@ -0,0 +1,261 @@
+package fasttext
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math/rand"
+	"strings"
+)
+
+// sourceCheck is the comparison of the database with its input of
+// WithSourceCheck.
+type sourceCheck struct {
+	r      io.Reader
+	sample int
+	opts   []BuildOption
+}
+
+// WithSourceCheck makes Verify compare the stored vectors of a random
+// sample of words of the input of the build, read from r, to their
+// vectors in the input, by checksum of their encoding. The options
+// are the parsing options of the build, e.g. WithDelimiter. Words
+// missing from the database, e.g. filtered out by the build, are
+// reported but do not fail the check.
+func WithSourceCheck(r io.Reader, sample int, opts ...BuildOption) SelfTestOption {
+	return func(cfg *selfTestConfig) {
+		cfg.source = &sourceCheck{r: r, sample: sample, opts: opts}
+	}
+}
+
+// Verify checks the integrity of the whole database, e.g. of a copy
+// before shipping it, where SelfTest only checks a sample: the SQLite
+// file structure, the schema of the table, the completion of the build
+// and the metadata, the decoding of every row to the recorded dimension,
+// the use of the word index by look-ups, the similarities set with
+// WithSimilarityCheck and the vectors of the input of WithSourceCheck.
+// It reads the whole database. Failed checks are reported, not returned
+// as errors; the error is only set when the checks cannot run.
+func (ft *FastText) Verify(opts ...SelfTestOption) (*SelfTestReport, error) {
+	cfg := &selfTestConfig{seed: 1}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	r := &SelfTestReport{}
+	if err := ft.checkIntegrity(r); err != nil {
+		return nil, err
+	}
+	if err := ft.checkSchema(r); err != nil {
+		return nil, err
+	}
+	if !r.Passed() {
+		return r, nil
+	}
+	f, err := ft.vecFormat()
+	if err != nil {
+		r.add("metadata", false, "%v", err)
+		return r, nil
+	}
+	state, ok, err := ft.getMeta(metaBuildState)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case ok && state != buildComplete:
+		r.add("metadata", false, "build %s", state)
+	case f.dim == 0:
+		r.add("metadata", false, "no recorded dimension, see Migrate")
+	default:
+		r.add("metadata", true, "%v vectors of dimension %d", f, f.dim)
+	}
+	if err := ft.checkRows(r, f); err != nil {
+		return nil, err
+	}
+	ft.checkIndex(r)
+	ft.checkPairs(r, cfg)
+	if cfg.source != nil {
+		if err := ft.checkSource(r, f, cfg); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// checkIntegrity runs SQLite's check of the file structure.
+func (ft *FastText) checkIntegrity(r *SelfTestReport) error {
+	rows, err := ft.db.Query(`PRAGMA quick_check;`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	var problems []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return err
+		}
+		if s != "ok" {
+			problems = append(problems, s)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		r.add("integrity", false, "%s", strings.Join(problems, "; "))
+	} else {
+		r.add("integrity", true, "ok")
+	}
+	return nil
+}
+
+// checkRows decodes every row.
+func (ft *FastText) checkRows(r *SelfTestReport, f vecFormat) error {
+	var checked, failed int
+	var firstErr string
+	err := ft.forEachRow(func(word string, binVec []byte) error {
+		checked++
+		if err := checkVec(f, binVec); err != nil {
+			failed++
+			if firstErr == "" {
+				firstErr = fmt.Sprintf("%q: %v", word, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case failed > 0:
+		r.add("rows", false, "%d of %d rows failed to decode, first %s", failed, checked, firstErr)
+	case checked == 0:
+		r.add("rows", false, "empty table")
+	default:
+		r.add("rows", true, "%d rows decoded", checked)
+	}
+	return nil
+}
+
+// checkSource compares a random sample of the input with the stored
+// vectors.
+func (ft *FastText) checkSource(r *SelfTestReport, f vecFormat, cfg *selfTestConfig) error {
+	bcfg := ft.newBuildConfig(cfg.source.opts)
+	defer bcfg.stop()
+	input, err := decompress(cfg.source.r)
+	if err != nil {
+		return err
+	}
+	// Reservoir sampling of the first occurrence of each word.
+	rng := rand.New(rand.NewSource(cfg.seed))
+	var sample []*wordEmb
+	keys := make(map[string]bool)
+	var seen int
+	for emb := range readwordEmbdFile(input, &bcfg.parse, bcfg.done) {
+		if emb.Err != nil {
+			r.add("source", false, "%v", emb.Err)
+			return nil
+		}
+		emb.Word = ft.normalize(emb.Word)
+		if keys[emb.Word] {
+			continue
+		}
+		keys[emb.Word] = true
+		seen++
+		if len(sample) < cfg.source.sample {
+			sample = append(sample, emb)
+		} else if i := rng.Intn(seen); i < len(sample) {
+			sample[i] = emb
+		}
+	}
+	var checked, missing, failed int
+	var firstErr string
+	fail := func(format string, args ...interface{}) {
+		failed++
+		if firstErr == "" {
+			firstErr = fmt.Sprintf(format, args...)
+		}
+	}
+	for start := 0; start < len(sample); start += maxBatchVars {
+		batch := sample[start:minInt(start+maxBatchVars, len(sample))]
+		words := make([]string, len(batch))
+		for i, emb := range batch {
+			words[i] = emb.Word
+		}
+		stored, err := ft.rawBlobs(words)
+		if err != nil {
+			return err
+		}
+		for _, emb := range batch {
+			blob, ok := stored[emb.Word]
+			if !ok {
+				missing++
+				continue
+			}
+			checked++
+			expected, err := f.raw(emb)
+			if err != nil {
+				fail("%q: %v", emb.Word, err)
+				continue
+			}
+			if f.zstd != nil {
+				if blob, err = f.zstd.decompress(blob); err != nil {
+					fail("%q: %v", emb.Word, err)
+					continue
+				}
+			}
+			if a, b := crc32.ChecksumIEEE(blob), crc32.ChecksumIEEE(expected); a != b {
+				fail("%q: checksum %08x, expected %08x", emb.Word, a, b)
+			}
+		}
+	}
+	switch {
+	case failed > 0:
+		r.add("source", false, "%d of %d words differ from the input, first %s", failed, checked, firstErr)
+	case checked == 0 && len(sample) > 0:
+		r.add("source", false, "none of %d sampled words in the database", len(sample))
+	default:
+		r.add("source", true, "%d sampled words match the input, %d missing", checked, missing)
+	}
+	return nil
+}
+
+// raw returns the blob of the input vector before compression.
+func (f vecFormat) raw(emb *wordEmb) ([]byte, error) {
+	if f.dim == 0 {
+		return nil, errors.New("unknown dimension")
+	}
+	vec, err := f.input(emb)
+	if err != nil {
+		return nil, err
+	}
+	if f.normalized {
+		vec = unitVec(vec)
+	}
+	return f.codec.Encode(vec), nil
+}
+
+// rawBlobs returns the stored blobs of the words found in the database.
+func (ft *FastText) rawBlobs(words []string) (map[string][]byte, error) {
+	args := make([]interface{}, len(words))
+	for i, w := range words {
+		args[i] = w
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(words)), ",")
+	rows, err := ft.db.Query(ft.sql(`SELECT word, emb FROM fasttext WHERE word IN (`)+placeholders+`);`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blobs := make(map[string][]byte, len(words))
+	for rows.Next() {
+		var word string
+		var blob []byte
+		if err := rows.Scan(&word, &blob); err != nil {
+			return nil, err
+		}
+		blobs[word] = blob
+	}
+	return blobs, rows.Err()
+}
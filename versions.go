@@ -0,0 +1,148 @@
+package fasttext
+
+import (
+	"database/sql"
+	"io"
+)
+
+// VersionsTableName is the SQLite3 table holding the vectors of other
+// versions of the default model keyed by (tag, word), "<name>_versions"
+// for the others.
+const VersionsTableName = "fasttext_versions"
+
+// versionNeighbors is the number of nearest neighbors compared by
+// CompareVersions.
+const versionNeighbors = 10
+
+func (ft *FastText) createVersionsTable(db execer) error {
+	_, err := db.Exec(ft.sql(`
+	CREATE TABLE IF NOT EXISTS fasttext_versions(
+		tag TEXT,
+		word TEXT,
+		emb BLOB,
+		PRIMARY KEY (tag, word)
+	);`))
+	return err
+}
+
+// BuildVersionDB imports the word embeddings of another version of the
+// model, e.g. a retrained one, in any format supported by BuildDB,
+// under the given tag, e.g. "2024q3", to serve both side by side with
+// GetEmbVersion while migrating. The versions are stored in the format
+// of the database, so they must have its dimension. Words already
+// imported for the tag are replaced.
+func (ft *FastText) BuildVersionDB(tag string, wordEmbFile io.Reader, opts ...BuildOption) error {
+	return ft.buildKeyed(ft.createVersionsTable,
+		`INSERT OR REPLACE INTO fasttext_versions(tag, word, emb) VALUES(?, ?, ?);`,
+		tag, wordEmbFile, opts)
+}
+
+// Versions returns the tags imported with BuildVersionDB.
+func (ft *FastText) Versions() ([]string, error) {
+	rows, err := ft.db.Query(ft.sql(`SELECT DISTINCT tag FROM fasttext_versions ORDER BY tag;`))
+	if err != nil {
+		if isNoSuchTable(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer rows.Close()
+	var tags []string
+	for rows.Next() {
+		var tag string
+		if err := rows.Scan(&tag); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// DeleteVersion removes the vectors of the version, e.g. once the
+// migration away from it is over.
+func (ft *FastText) DeleteVersion(tag string) error {
+	_, err := ft.db.Exec(ft.sql(`DELETE FROM fasttext_versions WHERE tag=?;`), tag)
+	if err != nil && isNoSuchTable(err) {
+		return nil
+	}
+	return err
+}
+
+// GetEmbVersion returns the word embedding of the given word in the
+// version of the model with the given tag.
+func (ft *FastText) GetEmbVersion(word, tag string) ([]float32, error) {
+	var binVec []byte
+	err := ft.db.QueryRow(ft.sql(`SELECT emb FROM fasttext_versions WHERE tag=? AND word=?;`),
+		tag, ft.normalize(word)).Scan(&binVec)
+	if err == sql.ErrNoRows || (err != nil && isNoSuchTable(err)) {
+		return nil, &WordNotFoundError{Word: word}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ft.decode(binVec)
+}
+
+// VersionDrift compares the embedding of a word in a version of the
+// model with the current one.
+type VersionDrift struct {
+	Tag string
+	// Cosine is the cosine similarity of the two embeddings. It is only
+	// meaningful if the versions share their vector space, e.g. for a
+	// model fine-tuned from the other or aligned to it.
+	Cosine float64
+	// NeighborOverlap is the fraction of the 10 nearest neighbors of the
+	// word shared by the two versions, which compares the versions
+	// whether their spaces are aligned or not.
+	NeighborOverlap float64
+}
+
+// CompareVersions measures the drift of the word between the current
+// model and each of its versions having the word, in the order of
+// Versions. Finding the neighbors scans the current model and each
+// version.
+func (ft *FastText) CompareVersions(word string) ([]VersionDrift, error) {
+	vec, err := ft.GetEmb(word)
+	if err != nil {
+		return nil, err
+	}
+	current, err := ft.NearestNeighbors(word, versionNeighbors)
+	if err != nil {
+		return nil, err
+	}
+	neighbors := make(map[string]bool, len(current))
+	for _, s := range current {
+		neighbors[s.Word] = true
+	}
+	tags, err := ft.Versions()
+	if err != nil {
+		return nil, err
+	}
+	var drifts []VersionDrift
+	for _, tag := range tags {
+		other, err := ft.GetEmbVersion(word, tag)
+		if _, ok := err.(*WordNotFoundError); ok {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		nn, err := ft.nearestKeyed(`SELECT word, emb FROM fasttext_versions WHERE tag=?;`, tag,
+			other, versionNeighbors, ft.excludeWords(word))
+		if err != nil {
+			return nil, err
+		}
+		var shared int
+		for _, s := range nn {
+			if neighbors[s.Word] {
+				shared++
+			}
+		}
+		d := VersionDrift{Tag: tag, Cosine: cosine(vec, other, l2norm(vec))}
+		if n := maxInt(len(nn), len(current)); n > 0 {
+			d.NeighborOverlap = float64(shared) / float64(n)
+		}
+		drifts = append(drifts, d)
+	}
+	return drifts, nil
+}
@@ -0,0 +1,81 @@
+package fasttext
+
+import (
+	"regexp"
+	"unicode/utf8"
+)
+
+// vocabFilter selects the words imported by a build.
+type vocabFilter struct {
+	maxWords  int64
+	minLength int
+	exclude   *regexp.Regexp
+	only      map[string]bool
+	// normalized is set once only holds normalized words.
+	normalized bool
+}
+
+// WithMaxWords imports only the n first words of the input, which are
+// the most frequent ones in .vec files. The build stops reading the
+// input once they are imported.
+func WithMaxWords(n int64) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.filter.maxWords = n
+	}
+}
+
+// WithMinWordLength skips the words shorter than n characters.
+func WithMinWordLength(n int) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.filter.minLength = n
+	}
+}
+
+// WithExcludePattern skips the words matching the regular expression,
+// e.g. `^\d+$` for numbers or `[^\p{L}]` for words that are not only
+// made of letters.
+func WithExcludePattern(re *regexp.Regexp) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.filter.exclude = re
+	}
+}
+
+// WithVocabulary imports only the given words, e.g. a domain vocabulary,
+// after normalization. Ranks are still the positions of the words in
+// the whole input.
+func WithVocabulary(words []string) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.filter.only = make(map[string]bool, len(words))
+		for _, word := range words {
+			cfg.filter.only[word] = true
+		}
+	}
+}
+
+// keep returns whether the word, normalized to key, passes the filter.
+// The vocabulary is normalized on first use.
+func (f *vocabFilter) keep(ft *FastText, word, key string) bool {
+	if f.minLength > 0 && utf8.RuneCountInString(word) < f.minLength {
+		return false
+	}
+	if f.exclude != nil && f.exclude.MatchString(word) {
+		return false
+	}
+	if f.only != nil {
+		if len(ft.normalizers) > 0 && !f.normalized {
+			only := make(map[string]bool, len(f.only))
+			for word := range f.only {
+				only[ft.normalize(word)] = true
+			}
+			f.only = only
+			f.normalized = true
+		}
+		return f.only[key]
+	}
+	return true
+}
+
+// full returns whether n words are enough.
+func (f *vocabFilter) full(n int64) bool {
+	return f.maxWords > 0 && n >= f.maxWords
+}
@@ -0,0 +1,63 @@
+package fasttext
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Warm loads the embeddings of the given words in memory before serving,
+// e.g. the known hot vocabulary of a service, for a predictable
+// cold-start latency. The words are kept along with the preloaded
+// embeddings if the session has preload options (see WithPreload),
+// regardless of the budget, or added to the cache of WithCache otherwise,
+// where they are subject to eviction. Words missing from the vocabulary
+// are ignored.
+func (ft *FastText) Warm(words []string) error {
+	if ft.preload == nil && ft.cache == nil {
+		return errors.New("fasttext: nothing to warm, see WithCache and WithPreload")
+	}
+	if ft.preload != nil {
+		// Load the preloaded embeddings first, which would otherwise
+		// replace the warmed ones.
+		if _, _, err := ft.preloaded(""); err != nil {
+			return err
+		}
+	}
+	words = ft.normalizeAll(words)
+	for start := 0; start < len(words); start += maxBatchVars {
+		batch := words[start:minInt(start+maxBatchVars, len(words))]
+		if err := ft.lookupBatch(context.Background(), batch, ft.warm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WarmFromReader calls Warm on the words read from r, one per line.
+func (ft *FastText) WarmFromReader(r io.Reader) error {
+	var words []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			words = append(words, word)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return ft.Warm(words)
+}
+
+// warm keeps the embedding of the word in memory.
+func (ft *FastText) warm(word string, vec []float32) {
+	if p := ft.preload; p != nil {
+		p.mu.Lock()
+		p.embs[word] = vec
+		p.mu.Unlock()
+		return
+	}
+	ft.cache.add(word, vec)
+}
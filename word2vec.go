@@ -0,0 +1,125 @@
+package fasttext
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BuildDBFromWord2Vec initializes the SQLite3 database from a word2vec
+// binary file, e.g. GoogleNews-vectors-negative300.bin or the output of
+// gensim's save_word2vec_format(binary=True): a "<vocabulary size>
+// <dimension>" header line, then each word followed by a space and its
+// vector as little-endian float32 values. The values are read exactly,
+// without the round trip through text of a .vec conversion. Gzip
+// compressed files are decompressed transparently.
+func (ft *FastText) BuildDBFromWord2Vec(r io.Reader, opts ...BuildOption) error {
+	cfg := ft.newBuildConfig(opts)
+	r, err := decompress(cfg.progress.reader(r))
+	if err != nil {
+		return err
+	}
+	return ft.build(readWord2VecBinary(r, cfg.done), cfg)
+}
+
+// word2VecSniffSize is the size of the start of a file inspected by
+// isWord2VecBinary.
+const word2VecSniffSize = 64 << 10
+
+// isWord2VecBinary returns whether the reader starts with a word2vec
+// binary file rather than a text file: a "<vocabulary size> <dimension>"
+// header line followed by a word whose values are not text. The first
+// line of values of a text file is made of digits, signs, points,
+// exponents, infinities and NaNs only, which few float32 values fit in.
+func isWord2VecBinary(br *bufio.Reader) bool {
+	b, _ := br.Peek(word2VecSniffSize)
+	i := bytes.IndexByte(b, '\n')
+	if i < 0 {
+		return false
+	}
+	dim, ok := parseHeader(string(b[:i]))
+	if !ok || dim <= 0 {
+		return false
+	}
+	b = b[i+1:]
+	j := bytes.IndexByte(b, ' ')
+	if j < 0 {
+		return false
+	}
+	b = b[j+1:]
+	if len(b) > 4*dim {
+		b = b[:4*dim]
+	}
+	for _, c := range b {
+		if c == '\n' {
+			break
+		}
+		if !strings.ContainsRune(" \t\r0123456789.+-eEinfaINFA", rune(c)) {
+			return true
+		}
+	}
+	return false
+}
+
+// readWord2VecBinary reads the word embeddings of a word2vec binary
+// file, sending them to a channel for build until done is closed.
+func readWord2VecBinary(r io.Reader, done <-chan struct{}) chan *wordEmb {
+	out := make(chan *wordEmb)
+	go func() {
+		defer close(out)
+		br := bufio.NewReader(r)
+		count, dim, err := readWord2VecHeader(br)
+		if err != nil {
+			send(out, &wordEmb{Err: err}, done)
+			return
+		}
+		buf := make([]byte, 4*dim)
+		for i := 0; i < count; i++ {
+			word, err := br.ReadString(' ')
+			if err == nil {
+				_, err = io.ReadFull(br, buf)
+			}
+			if err != nil {
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				send(out, &wordEmb{Err: fmt.Errorf("fasttext: reading word %d of %d: %w", i+1, count, err)}, done)
+				return
+			}
+			vec := make([]float32, dim)
+			for j := range vec {
+				vec[j] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*j:]))
+			}
+			// The vectors may be followed by a newline.
+			word = strings.TrimLeft(strings.TrimSuffix(word, " "), "\r\n")
+			if !send(out, &wordEmb{Word: word, Vec: vec}, done) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// readWord2VecHeader reads the header line of a word2vec binary file.
+func readWord2VecHeader(br *bufio.Reader) (count, dim int, err error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return 0, 0, fmt.Errorf("fasttext: reading word2vec header: %w", err)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 2 {
+		count, err = strconv.Atoi(fields[0])
+		if err == nil {
+			dim, err = strconv.Atoi(fields[1])
+		}
+	}
+	if len(fields) != 2 || err != nil || count < 0 || dim <= 0 {
+		return 0, 0, fmt.Errorf("fasttext: malformed word2vec header %q", strings.TrimSpace(line))
+	}
+	return count, dim, nil
+}
@@ -0,0 +1,132 @@
+package fasttext
+
+import (
+	"errors"
+	"strings"
+)
+
+// PutEmb inserts the embedding of a word after the build, e.g. a domain
+// term, a merged phrase or a corrected vector, replacing the embedding
+// of a word already in the vocabulary, which keeps its rank. The vector
+// must have the dimension of the stored vectors.
+func (ft *FastText) PutEmb(word string, vec []float32) error {
+	return ft.PutEmbs([]string{word}, [][]float32{vec})
+}
+
+// PutEmbs inserts the embeddings of the words in one transaction, see
+// PutEmb. Either all or none of them are inserted.
+func (ft *FastText) PutEmbs(words []string, vecs [][]float32) error {
+	if len(words) != len(vecs) {
+		return errors.New("fasttext: number of words and vectors differ")
+	}
+	words = ft.normalizeAll(words)
+	binVecs := make([][]byte, len(vecs))
+	for i, vec := range vecs {
+		binVec, err := ft.encode(vec)
+		if err != nil {
+			return err
+		}
+		binVecs[i] = binVec
+	}
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	stmt, err := tx.Prepare(ft.sql(`INSERT INTO fasttext(word, emb) VALUES(?, ?)
+		ON CONFLICT(word) DO UPDATE SET emb = excluded.emb;`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i, word := range words {
+		if _, err := stmt.Exec(word, binVecs[i]); err != nil {
+			return err
+		}
+	}
+	if err := ft.bumpGeneration(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	ft.invalidate(words)
+	return nil
+}
+
+// DeleteEmb removes the word from the vocabulary, along with its
+// payload. It returns ErrNoEmbFound if the word is not in the
+// vocabulary.
+func (ft *FastText) DeleteEmb(word string) error {
+	n, err := ft.deleteEmbs([]string{word})
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return &WordNotFoundError{Word: word}
+	}
+	return nil
+}
+
+// DeleteEmbs removes the words from the vocabulary in one transaction,
+// e.g. to prune profanity and junk tokens. Words not in the vocabulary
+// are ignored.
+func (ft *FastText) DeleteEmbs(words []string) error {
+	_, err := ft.deleteEmbs(words)
+	return err
+}
+
+// deleteEmbs returns the number of words deleted.
+func (ft *FastText) deleteEmbs(words []string) (int64, error) {
+	words = ft.normalizeAll(words)
+	tx, err := ft.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var deleted int64
+	for start := 0; start < len(words); start += maxBatchVars {
+		batch := words[start:minInt(start+maxBatchVars, len(words))]
+		args := make([]interface{}, len(batch))
+		for i, w := range batch {
+			args[i] = w
+		}
+		in := `(` + strings.TrimSuffix(strings.Repeat("?,", len(batch)), ",") + `)`
+		res, err := tx.Exec(ft.sql(`DELETE FROM fasttext WHERE word IN `)+in+`;`, args...)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += n
+		if _, err := tx.Exec(ft.sql(`DELETE FROM fasttext_payload WHERE word IN `)+in+`;`, args...); err != nil && !isNoSuchTable(err) {
+			return 0, err
+		}
+	}
+	if err := ft.bumpGeneration(tx); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	ft.invalidate(words)
+	return deleted, nil
+}
+
+// invalidate drops the in-memory copies of the embeddings of the words,
+// and the negative sampler of the vocabulary.
+func (ft *FastText) invalidate(words []string) {
+	ft.formatMu.Lock()
+	ft.negSampler = nil
+	ft.formatMu.Unlock()
+	for _, word := range words {
+		if ft.cache != nil {
+			ft.cache.remove(word)
+		}
+		if ft.preload != nil {
+			ft.preload.forget(word)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package fasttext
+
+import "errors"
+
+// ErrWriterClosed is returned when using a closed Writer.
+var ErrWriterClosed = errors.New("fasttext: writer closed")
+
+// Writer builds the database from word embeddings put one by one, e.g.
+// by a program training or transforming them, with the throughput of
+// BuildDB: the words are inserted in batches of transactions and the
+// word index is only created by Close. A Writer is not safe for
+// concurrent use.
+type Writer struct {
+	embs chan *wordEmb
+	// done is closed when the build stops.
+	done   <-chan struct{}
+	result chan error
+	// err is the result of the build once it stopped.
+	err      error
+	finished bool
+	closed   bool
+}
+
+// NewWriter starts a build of the database with the given options, as
+// BuildDB does. The sample of the vectors fitted by WithPCA and zstd
+// compression is taken from the first words put, before any Flush.
+func (ft *FastText) NewWriter(opts ...BuildOption) *Writer {
+	cfg := ft.newBuildConfig(opts)
+	w := &Writer{
+		embs:   make(chan *wordEmb, 64),
+		done:   cfg.done,
+		result: make(chan error, 1),
+	}
+	go func() {
+		w.result <- ft.build(w.embs, cfg)
+	}()
+	return w
+}
+
+// Put adds the embedding of a word. The vector is copied, and must have
+// the dimension of the first one. An error of the build, e.g. a vector
+// of another dimension, is returned by a later call to the Writer, at
+// the latest by Close.
+func (w *Writer) Put(word string, vec []float32) error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	emb := &wordEmb{Word: word, Vec: append([]float32(nil), vec...)}
+	if !send(w.embs, emb, w.done) {
+		return w.wait()
+	}
+	return nil
+}
+
+// Flush commits the words put so far, so that an interrupted build
+// keeps them and can be resumed by putting the same words again. They
+// are only indexed by Close.
+func (w *Writer) Flush() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	flushed := make(chan error, 1)
+	if !send(w.embs, &wordEmb{flushed: flushed}, w.done) {
+		return w.wait()
+	}
+	select {
+	case err := <-flushed:
+		return err
+	case <-w.done:
+		return w.wait()
+	}
+}
+
+// Close completes the build: it commits the last words put, creates
+// the word index and records the build as complete.
+func (w *Writer) Close() error {
+	if !w.closed {
+		w.closed = true
+		close(w.embs)
+	}
+	return w.wait()
+}
+
+// wait returns the result of the build, once it stopped.
+func (w *Writer) wait() error {
+	if !w.finished {
+		w.err = <-w.result
+		w.finished = true
+	}
+	return w.err
+}
@@ -0,0 +1,227 @@
+package fasttext
+
+import (
+	"encoding/base64"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is the compression of the stored vector blobs.
+type Compression int
+
+const (
+	// NoCompression stores the encoded vectors as is. This is the default.
+	NoCompression Compression = iota
+	// Zstd compresses each blob with zstd, using a dictionary trained on
+	// the first vectors of the build so that even single rows compress.
+	// Look-ups decompress transparently.
+	Zstd
+)
+
+var compressionNames = map[Compression]string{
+	NoCompression: "none",
+	Zstd:          "zstd",
+}
+
+func (c Compression) String() string {
+	if name, ok := compressionNames[c]; ok {
+		return name
+	}
+	return fmt.Sprintf("Compression(%d)", int(c))
+}
+
+// ParseCompression returns the compression with the given name, as
+// returned by Compression.String.
+func ParseCompression(name string) (Compression, error) {
+	for c, n := range compressionNames {
+		if n == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("fasttext: unknown compression %q", name)
+}
+
+// WithCompression sets the compression of the stored vectors of
+// BuildDB, NoCompression by default. The compression and its dictionary
+// are recorded in the metadata table.
+func WithCompression(c Compression) BuildOption {
+	return func(cfg *buildConfig) {
+		cfg.compression = c
+	}
+}
+
+// Metadata keys of the compression.
+const (
+	metaCompression = "compression"
+	metaZstdDict    = "zstd_dict"
+)
+
+const (
+	// zstdSampleRows is the number of vectors the dictionary is trained on.
+	zstdSampleRows = 1000
+	// zstdMaxHistory is the size of the raw content of the dictionary.
+	zstdMaxHistory = 64 << 10
+)
+
+// blobCompressor compresses blobs with a zstd dictionary. It is safe
+// for concurrent use.
+type blobCompressor struct {
+	dict []byte
+	enc  *zstd.Encoder
+	dec  *zstd.Decoder
+}
+
+func newBlobCompressor(dict []byte) (*blobCompressor, error) {
+	eopts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	dopts := []zstd.DOption{zstd.WithDecoderConcurrency(0)}
+	if len(dict) > 0 {
+		eopts = append(eopts, zstd.WithEncoderDict(dict))
+		dopts = append(dopts, zstd.WithDecoderDicts(dict))
+	}
+	enc, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	dec, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		return nil, err
+	}
+	return &blobCompressor{dict: dict, enc: enc, dec: dec}, nil
+}
+
+// trainZstd builds a compressor with a dictionary trained on the
+// sample blobs: the raw content of the dictionary is taken from half of
+// them and its entropy tables from the other half. Without enough
+// samples, the blobs are compressed without dictionary.
+func trainZstd(samples [][]byte) (*blobCompressor, error) {
+	var history []byte
+	var contents [][]byte
+	for i, s := range samples {
+		if i%2 == 0 && len(history)+len(s) <= zstdMaxHistory {
+			history = append(history, s...)
+		} else {
+			contents = append(contents, s)
+		}
+	}
+	dict, err := buildZstdDict(history, contents)
+	if err != nil {
+		return nil, err
+	}
+	return newBlobCompressor(dict)
+}
+
+// buildZstdDict builds a dictionary, or returns nil if the samples are
+// too few.
+func buildZstdDict(history []byte, contents [][]byte) (dict []byte, err error) {
+	if len(history) < 8 || len(contents) == 0 {
+		return nil, nil
+	}
+	defer func() {
+		// BuildDict panics on degenerate samples, e.g. when the history
+		// matches all the contents.
+		if recover() != nil {
+			dict, err = nil, nil
+		}
+	}()
+	// Dictionary IDs below 32768 are reserved.
+	id := crc32.ChecksumIEEE(history)%(1<<31-1<<15) + 1<<15
+	dict, err = zstd.BuildDict(zstd.BuildDictOptions{
+		ID:       id,
+		Contents: contents,
+		History:  history,
+		Offsets:  [3]int{1, 4, 8},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fasttext: training zstd dictionary: %w", err)
+	}
+	return dict, nil
+}
+
+func (c *blobCompressor) compress(blob []byte) []byte {
+	return c.enc.EncodeAll(blob, nil)
+}
+
+func (c *blobCompressor) decompress(blob []byte) ([]byte, error) {
+	return c.dec.DecodeAll(blob, nil)
+}
+
+// id returns the ID of the dictionary, which must match for compressed
+// rows to be exchanged between databases.
+func (c *blobCompressor) id() uint32 {
+	if len(c.dict) == 0 {
+		return 0
+	}
+	id, err := zstd.InspectDictionary(c.dict)
+	if err != nil {
+		return 0
+	}
+	return id.ID()
+}
+
+// loadCompression reads the compression of the stored vectors from
+// the metadata table.
+func (ft *FastText) loadCompression(f *vecFormat) error {
+	value, ok, err := ft.getMeta(metaCompression)
+	if err != nil || !ok {
+		return err
+	}
+	c, err := ParseCompression(value)
+	if err != nil || c == NoCompression {
+		return err
+	}
+	value, _, err = ft.getMeta(metaZstdDict)
+	if err != nil {
+		return err
+	}
+	dict, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("fasttext: invalid zstd dictionary: %w", err)
+	}
+	f.zstd, err = newBlobCompressor(dict)
+	return err
+}
+
+// setCompression records the compression in the metadata table.
+func (ft *FastText) setCompression(db execer, f vecFormat) error {
+	if f.zstd == nil {
+		return nil
+	}
+	if err := ft.setMeta(db, metaCompression, Zstd.String()); err != nil {
+		return err
+	}
+	return ft.setMeta(db, metaZstdDict, base64.StdEncoding.EncodeToString(f.zstd.dict))
+}
+
+// peekEmbs receives up to n word embeddings from embs, stopping at an
+// error or a flush, and returns them along with a channel sending them again
+// followed by the rest of embs, until done is closed.
+func peekEmbs(embs <-chan *wordEmb, n int, done <-chan struct{}) ([]*wordEmb, <-chan *wordEmb) {
+	var head []*wordEmb
+	for len(head) < n {
+		emb, ok := <-embs
+		if !ok {
+			break
+		}
+		head = append(head, emb)
+		if emb.Err != nil || emb.flushed != nil {
+			break
+		}
+	}
+	out := make(chan *wordEmb)
+	go func() {
+		defer close(out)
+		for _, emb := range head {
+			if !send(out, emb, done) {
+				return
+			}
+		}
+		for emb := range embs {
+			if !send(out, emb, done) {
+				return
+			}
+		}
+	}()
+	return head, out
+}